@@ -71,6 +71,8 @@ func (ae *ArrayExpression) String() string {
 type HashExpression struct {
 	Token token.Token
 	Data  map[string]Expression
+	// Keys are Data's keys in the order they appear in the source
+	Keys []string
 }
 
 func (he *HashExpression) expressionNode() {}
@@ -81,8 +83,8 @@ func (he *HashExpression) String() string {
 	var out bytes.Buffer
 	var pairs []string
 
-	for key, value := range he.Data {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", key, value.String()))
+	for _, key := range he.Keys {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", key, he.Data[key].String()))
 	}
 
 	out.WriteString("{ ")
@@ -162,6 +164,7 @@ func (m *MultiVariableExpression) String() string {
 
 // AssignExpression represents variable assignment in Goby.
 type AssignExpression struct {
+	// Token is `=`, or the operator of assignments like `a += 1`, whose Value is `a + 1`
 	Token     token.Token
 	Variables []Variable
 	Value     Expression
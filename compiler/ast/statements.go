@@ -184,6 +184,8 @@ func (ws *WhileStatement) String() string {
 type BlockStatement struct {
 	Token      token.Token // {
 	Statements []Statement
+	// End is the `end` or `else` token that closes the block
+	End token.Token
 }
 
 func (bs *BlockStatement) statementNode() {}
@@ -5,7 +5,7 @@ import (
 	"github.com/goby-lang/goby/compiler/ast"
 )
 
-func (g *Generator) compileExpression(is *InstructionSet, exp ast.Expression, scope *scope, table *localTable) {
+func (g *Generator) compileExpression(is *InstructionSet, exp ast.Expression, scope *scope, table *LocalTable) {
 	// See fsm initialization's comment
 	if g.fsm.Is(keepExp) {
 		switch exp := exp.(type) {
@@ -31,9 +31,9 @@ func (g *Generator) compileExpression(is *InstructionSet, exp ast.Expression, sc
 			}
 			is.define(NewArray, len(exp.Elements))
 		case *ast.HashExpression:
-			for key, value := range exp.Data {
+			for _, key := range exp.Keys {
 				is.define(PutString, fmt.Sprintf("\"%s\"", key))
-				g.compileExpression(is, value, scope, table)
+				g.compileExpression(is, exp.Data[key], scope, table)
 			}
 			is.define(NewHash, len(exp.Data)*2)
 		case *ast.SelfExpression:
@@ -59,7 +59,7 @@ func (g *Generator) compileExpression(is *InstructionSet, exp ast.Expression, sc
 	}
 }
 
-func (g *Generator) compileIdentifier(is *InstructionSet, exp *ast.Identifier, scope *scope, table *localTable) {
+func (g *Generator) compileIdentifier(is *InstructionSet, exp *ast.Identifier, scope *scope, table *LocalTable) {
 	index, depth, ok := table.getLCL(exp.Value, table.depth)
 
 	// This means it's a local variable.
@@ -74,7 +74,7 @@ func (g *Generator) compileIdentifier(is *InstructionSet, exp *ast.Identifier, s
 	is.define(Send, exp.Value, 0)
 }
 
-func (g *Generator) compileYieldExpression(is *InstructionSet, exp *ast.YieldExpression, scope *scope, table *localTable) {
+func (g *Generator) compileYieldExpression(is *InstructionSet, exp *ast.YieldExpression, scope *scope, table *LocalTable) {
 	oldState := g.fsm.Current()
 	g.fsm.Event(keepExp)
 
@@ -88,7 +88,7 @@ func (g *Generator) compileYieldExpression(is *InstructionSet, exp *ast.YieldExp
 	g.fsm.Event(oldState)
 }
 
-func (g *Generator) compileCallExpression(is *InstructionSet, exp *ast.CallExpression, scope *scope, table *localTable) {
+func (g *Generator) compileCallExpression(is *InstructionSet, exp *ast.CallExpression, scope *scope, table *LocalTable) {
 	oldState := g.fsm.Current()
 
 	// We need the receiver expression and argument expressions
@@ -101,12 +101,10 @@ func (g *Generator) compileCallExpression(is *InstructionSet, exp *ast.CallExpre
 
 	if exp.Block != nil {
 		// Inside block should be one level deeper than outside
-		newTable := newLocalTable(table.depth + 1)
-		newTable.upper = table
-		blockIndex := g.blockCounter
-		g.blockCounter++
-		g.compileBlockArgExpression(blockIndex, exp, scope, newTable)
-		is.define(Send, exp.Method, len(exp.Arguments), fmt.Sprintf("block:%d", blockIndex))
+		newTable := table.NewBlockTable()
+		blockIS := g.newInstructionSet("", Block)
+		g.compileBlockArgExpression(blockIS, exp, scope, newTable)
+		is.define(Send, exp.Method, len(exp.Arguments), fmt.Sprintf("block:%d", blockIS.id))
 		return
 	}
 	is.define(Send, exp.Method, len(exp.Arguments))
@@ -119,7 +117,7 @@ func (g *Generator) compileCallExpression(is *InstructionSet, exp *ast.CallExpre
 	g.fsm.Event(oldState)
 }
 
-func (g *Generator) compileAssignExpression(is *InstructionSet, exp *ast.AssignExpression, scope *scope, table *localTable) {
+func (g *Generator) compileAssignExpression(is *InstructionSet, exp *ast.AssignExpression, scope *scope, table *LocalTable) {
 	oldState := g.fsm.Current()
 	g.fsm.Event(keepExp)
 	g.compileExpression(is, exp.Value, scope, table)
@@ -148,27 +146,24 @@ func (g *Generator) compileAssignExpression(is *InstructionSet, exp *ast.AssignE
 	}
 }
 
-func (g *Generator) compileBlockArgExpression(index int, exp *ast.CallExpression, scope *scope, table *localTable) {
+func (g *Generator) compileBlockArgExpression(is *InstructionSet, exp *ast.CallExpression, scope *scope, table *LocalTable) {
 	oldState := g.fsm.Current()
 	// We don't need any unused expression inside block
 	g.fsm.Event(removeExp)
 
-	is := &InstructionSet{}
-	is.name = fmt.Sprint(index)
-	is.isType = Block
-
 	for i := 0; i < len(exp.BlockArguments); i++ {
 		table.set(exp.BlockArguments[i].Value)
 	}
 
 	g.compileCodeBlock(is, exp.Block, scope, table)
 	g.endInstructions(is)
+	is.setLocals(table)
 	g.instructionSets = append(g.instructionSets, is)
 
 	g.fsm.Event(oldState)
 }
 
-func (g *Generator) compileIfExpression(is *InstructionSet, exp *ast.IfExpression, scope *scope, table *localTable) {
+func (g *Generator) compileIfExpression(is *InstructionSet, exp *ast.IfExpression, scope *scope, table *LocalTable) {
 	oldState := g.fsm.Current()
 
 	// Compiles condition so we need every expression
@@ -212,7 +207,7 @@ func (g *Generator) compileIfExpression(is *InstructionSet, exp *ast.IfExpressio
 	anchor2.line = is.count
 }
 
-func (g *Generator) compilePrefixExpression(is *InstructionSet, exp *ast.PrefixExpression, scope *scope, table *localTable) {
+func (g *Generator) compilePrefixExpression(is *InstructionSet, exp *ast.PrefixExpression, scope *scope, table *LocalTable) {
 	switch exp.Operator {
 	case "!":
 		g.compileExpression(is, exp.Right, scope, table)
@@ -224,7 +219,7 @@ func (g *Generator) compilePrefixExpression(is *InstructionSet, exp *ast.PrefixE
 	}
 }
 
-func (g *Generator) compileInfixExpression(is *InstructionSet, node *ast.InfixExpression, scope *scope, table *localTable) {
+func (g *Generator) compileInfixExpression(is *InstructionSet, node *ast.InfixExpression, scope *scope, table *LocalTable) {
 	g.compileExpression(is, node.Left, scope, table)
 	g.compileExpression(is, node.Right, scope, table)
 
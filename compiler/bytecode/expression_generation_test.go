@@ -120,7 +120,7 @@ func TestMultipleVariableAssignment(t *testing.T) {
 	`
 
 	expected := `
-<Def:foo>
+<Def:foo:0>
 0 putobject 1
 1 putobject 2
 2 putobject 3
@@ -129,7 +129,7 @@ func TestMultipleVariableAssignment(t *testing.T) {
 <ProgramStart>
 0 putself
 1 putstring "foo"
-2 def_method 0
+2 def_method 0 0
 3 putself
 4 send foo 0
 5 expand_array 3
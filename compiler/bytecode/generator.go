@@ -2,6 +2,7 @@ package bytecode
 
 import (
 	"bytes"
+	"fmt"
 	"strings"
 
 	"github.com/goby-lang/goby/compiler/ast"
@@ -11,7 +12,7 @@ import (
 type scope struct {
 	self       ast.Statement
 	program    *ast.Program
-	localTable *localTable
+	localTable *LocalTable
 	line       int
 	anchors    map[string]*anchor
 }
@@ -24,7 +25,7 @@ func newScope(stmt ast.Statement) *scope {
 type Generator struct {
 	REPL            bool
 	instructionSets []*InstructionSet
-	blockCounter    int
+	setCounter      int
 	scope           *scope
 	fsm             *fsm.FSM
 }
@@ -86,6 +87,19 @@ func NewGenerator() *Generator {
 	}
 }
 
+// newInstructionSet creates the instruction set of a method, class or block body with a new id.
+// Instructions that define or call the body refer to it by the id, so bodies with same name never get mixed up.
+func (g *Generator) newInstructionSet(name, isType string) *InstructionSet {
+	is := &InstructionSet{id: g.setCounter, name: name, isType: isType}
+	g.setCounter++
+
+	if isType == Block {
+		is.name = fmt.Sprint(is.id)
+	}
+
+	return is
+}
+
 // ResetInstructionSets clears generator's instruction sets
 func (g *Generator) ResetInstructionSets() {
 	g.instructionSets = []*InstructionSet{}
@@ -96,6 +110,12 @@ func (g *Generator) InitTopLevelScope(program *ast.Program) {
 	g.scope = &scope{program: program, localTable: newLocalTable(0), anchors: make(map[string]*anchor)}
 }
 
+// InitScope is like InitTopLevelScope, but the program can use the variables of the table and its upper tables,
+// like an expression a debugger evaluates in a method's frame
+func (g *Generator) InitScope(program *ast.Program, table *LocalTable) {
+	g.scope = &scope{program: program, localTable: table, anchors: make(map[string]*anchor)}
+}
+
 // GenerateByteCode returns compiled instructions in string format
 func (g *Generator) GenerateByteCode(stmts []ast.Statement) string {
 	g.compileStatements(stmts, g.scope, g.scope.localTable)
@@ -114,7 +134,7 @@ func (g *Generator) GenerateInstructions(stmts []ast.Statement) []*InstructionSe
 	return g.instructionSets
 }
 
-func (g *Generator) compileCodeBlock(is *InstructionSet, stmt *ast.BlockStatement, scope *scope, table *localTable) {
+func (g *Generator) compileCodeBlock(is *InstructionSet, stmt *ast.BlockStatement, scope *scope, table *LocalTable) {
 	for i, s := range stmt.Statements {
 		/*
 			We shouldn't remove last expression since it would be the method's return value. Example:
@@ -3,6 +3,7 @@ package bytecode
 import (
 	"github.com/goby-lang/goby/compiler/lexer"
 	"github.com/goby-lang/goby/compiler/parser"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -72,32 +73,32 @@ end
 i
 `
 	expected := `
-<Def:bar>
+<Def:bar:1>
 0 putself
 1 invokeblock 0
 2 leave
-<DefClass:Foo>
+<DefClass:Foo:0>
 0 putself
 1 putstring "bar"
-2 def_method 0
+2 def_method 0 1
 3 leave
-<Block:1>
+<Block:3>
 0 putobject 3
 1 getlocal 2 1
 2 send + 1
 3 setlocal 2 1
 4 leave
-<Block:0>
+<Block:2>
 0 putobject 3
 1 getlocal 1 0
 2 send * 1
 3 setlocal 1 1
 4 getlocal 1 3
-5 send bar 0 block:1
+5 send bar 0 block:3
 6 leave
 <ProgramStart>
 0 putself
-1 def_class class:Foo
+1 def_class class:Foo 0
 2 pop
 3 putobject 100
 4 setlocal 0 0
@@ -109,7 +110,7 @@ i
 10 send new 0
 11 setlocal 0 3
 12 getlocal 0 3
-13 send bar 0 block:0
+13 send bar 0 block:2
 14 getlocal 0 1
 15 leave
 `
@@ -197,12 +198,12 @@ func TestBasicMethodReDefineAndExecution(t *testing.T) {
 	`
 
 	expected := `
-<Def:foo>
+<Def:foo:0>
 0 getlocal 0 0
 1 putobject 100
 2 send + 1
 3 leave
-<Def:foo>
+<Def:foo:1>
 0 getlocal 0 0
 1 putobject 10
 2 send + 1
@@ -210,10 +211,10 @@ func TestBasicMethodReDefineAndExecution(t *testing.T) {
 <ProgramStart>
 0 putself
 1 putstring "foo"
-2 def_method 1
+2 def_method 1 0
 3 putself
 4 putstring "foo"
-5 def_method 1
+5 def_method 1 1
 6 putself
 7 putobject 11
 8 send foo 1
@@ -235,7 +236,7 @@ func TestBasicMethodDefineAndExecution(t *testing.T) {
 	`
 
 	expected := `
-<Def:foo>
+<Def:foo:0>
 0 putobject 10
 1 setlocal 0 2
 2 getlocal 0 0
@@ -247,7 +248,7 @@ func TestBasicMethodDefineAndExecution(t *testing.T) {
 <ProgramStart>
 0 putself
 1 putstring "foo"
-2 def_method 2
+2 def_method 2 0
 3 putself
 4 putobject 11
 5 putobject 1
@@ -269,7 +270,7 @@ func TestMethodDefWithDefaultValueArgument(t *testing.T) {
 	`
 
 	expected := `
-<Def:foo>
+<Def:foo:0>
 0 putobject 10
 1 setlocal 0 1 1
 2 getlocal 0 0
@@ -279,7 +280,7 @@ func TestMethodDefWithDefaultValueArgument(t *testing.T) {
 <ProgramStart>
 0 putself
 1 putstring "foo"
-2 def_method 2
+2 def_method 2 0
 3 putself
 4 putobject 100
 5 send foo 1
@@ -290,6 +291,145 @@ func TestMethodDefWithDefaultValueArgument(t *testing.T) {
 	compareBytecode(t, bytecode, expected)
 }
 
+func TestSourceLines(t *testing.T) {
+	input := `a = 1
+def foo(x)
+  x + a
+end
+
+foo(a)`
+
+	sets := compileToInstructions(input)
+	tests := []struct {
+		set      string
+		expected []int
+	}{
+		// Instructions after the last statement, like leave, are on the last statement's line
+		{"foo", []int{3, 3, 3, 3, 3}},
+		{Program, []int{1, 1, 2, 2, 2, 6, 6, 6, 6}},
+	}
+
+	for i, tt := range tests {
+		var set *InstructionSet
+
+		for _, is := range sets {
+			if is.Name() == tt.set {
+				set = is
+			}
+		}
+
+		lines := []int{}
+
+		for _, ins := range set.Instructions {
+			lines = append(lines, ins.SourceLine())
+		}
+
+		if !reflect.DeepEqual(lines, tt.expected) {
+			t.Fatalf("At case %d expect %s's lines to be %v. got: %v", i, tt.set, tt.expected, lines)
+		}
+	}
+}
+
+func TestWhileSourceLines(t *testing.T) {
+	input := `x = 2
+while x > 0 do
+  x -= 1
+end`
+
+	lines := []int{}
+
+	for _, ins := range compileToInstructions(input)[0].Instructions {
+		lines = append(lines, ins.SourceLine())
+	}
+
+	// The condition is compiled after the body, but it's still on the while statement's line
+	expected := []int{1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 2, 2, 2, 2, 2, 2, 2}
+
+	if !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("Expect lines to be %v. got: %v", expected, lines)
+	}
+}
+
+func TestLocalNames(t *testing.T) {
+	input := `a = 1
+def foo(x, y = 2)
+  z = x
+  [1].each do |i|
+    j = i + z
+  end
+end`
+
+	tests := []struct {
+		setType  string
+		expected []string
+	}{
+		{Program, []string{"a"}},
+		{MethodDef, []string{"x", "y", "z"}},
+		{Block, []string{"i", "j"}},
+	}
+
+	sets := compileToInstructions(input)
+
+	for i, tt := range tests {
+		for _, is := range sets {
+			if is.SetType() == tt.setType && !reflect.DeepEqual(is.LocalNames(), tt.expected) {
+				t.Fatalf("At case %d expect %s's locals to be %v. got: %v", i, tt.setType, tt.expected, is.LocalNames())
+			}
+		}
+	}
+}
+
+func TestInitScope(t *testing.T) {
+	input := `a + b
+[1].each do |c|
+  a + c
+end`
+
+	expected := `
+<Block:0>
+0 getlocal 2 0
+1 getlocal 0 0
+2 send + 1
+3 leave
+<ProgramStart>
+0 getlocal 1 0
+1 getlocal 0 0
+2 send + 1
+3 putobject 1
+4 newarray 1
+5 send each 0 block:0
+6 leave
+`
+
+	// b is a block's variable, and a is its outer variable
+	table := NewLocalTable()
+	table.Define("a")
+	table = table.NewBlockTable()
+	table.Define("b")
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program, err := p.ParseProgram()
+	if err != nil {
+		panic(err.Message)
+	}
+	g := NewGenerator()
+	g.InitScope(program, table)
+	compareBytecode(t, g.GenerateByteCode(program.Statements), expected)
+}
+
+func compileToInstructions(input string) []*InstructionSet {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program, err := p.ParseProgram()
+	if err != nil {
+		panic(err.Message)
+	}
+	g := NewGenerator()
+	g.InitTopLevelScope(program)
+	return g.GenerateInstructions(program.Statements)
+}
+
 func compileToBytecode(input string) string {
 	l := lexer.New(input)
 	p := parser.New(l)
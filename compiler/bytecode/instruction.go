@@ -40,6 +40,17 @@ const (
 	InvokeBlock         = "invokeblock"
 	Pop                 = "pop"
 	Leave               = "leave"
+
+	// Specialized instructions are generated by the Optimizer from sends of common operators
+	OptPlus  = "opt_plus"
+	OptMinus = "opt_minus"
+	OptMult  = "opt_mult"
+	OptLt    = "opt_lt"
+	OptLe    = "opt_le"
+	OptGt    = "opt_gt"
+	OptGe    = "opt_ge"
+	OptEq    = "opt_eq"
+	OptNeq   = "opt_neq"
 )
 
 // Instruction represents compiled bytecode instruction
@@ -48,6 +59,8 @@ type Instruction struct {
 	Params []string
 	line   int
 	anchor *anchor
+	// sourceLine is the line of the statement the instruction is compiled from
+	sourceLine int
 }
 
 // AnchorLine returns instruction anchor's line number if it has an anchor
@@ -64,6 +77,12 @@ func (i *Instruction) Line() int {
 	return i.line
 }
 
+// SourceLine returns the line of the source code the instruction is compiled from, which starts from 1.
+// It's 0 for instructions compiled before their set's first statement, like default values of parameters.
+func (i *Instruction) SourceLine() int {
+	return i.sourceLine
+}
+
 func (i *Instruction) compile() string {
 	if i.anchor != nil {
 		return fmt.Sprintf("%d %s %d\n", i.line, i.Action, i.anchor.line)
@@ -81,11 +100,17 @@ type anchor struct {
 
 // InstructionSet contains a set of Instructions and some metadata
 type InstructionSet struct {
+	// id identifies a method, class or block body, it's unique in its generator
+	id           int
 	name         string
 	isType       string
 	Instructions []*Instruction
 	count        int
 	argTypes     []int
+	localCount   int
+	localNames   []string
+	// sourceLine is the line of the statement being compiled into the set
+	sourceLine int
 }
 
 // ArgTypes returns enums that represents each argument's type
@@ -93,6 +118,27 @@ func (is *InstructionSet) ArgTypes() []int {
 	return is.argTypes
 }
 
+// LocalCount returns the number of local variables the instruction set uses, including its parameters
+func (is *InstructionSet) LocalCount() int {
+	return is.localCount
+}
+
+// LocalNames returns the names of the instruction set's local variables by their indexes
+func (is *InstructionSet) LocalNames() []string {
+	return is.localNames
+}
+
+// setLocals records the number and names of local variables in the table
+func (is *InstructionSet) setLocals(table *LocalTable) {
+	is.localCount = table.count
+	is.localNames = table.names()
+}
+
+// ID returns the id that def_method, def_singleton_method, def_class and send instructions use to refer to the set
+func (is *InstructionSet) ID() int {
+	return is.id
+}
+
 // Name returns instruction set's name
 func (is *InstructionSet) Name() string {
 	return is.name
@@ -105,7 +151,7 @@ func (is *InstructionSet) SetType() string {
 
 func (is *InstructionSet) define(action string, params ...interface{}) {
 	ps := []string{}
-	i := &Instruction{Action: action, Params: ps, line: is.count}
+	i := &Instruction{Action: action, Params: ps, line: is.count, sourceLine: is.sourceLine}
 	for _, param := range params {
 		switch p := param.(type) {
 		case string:
@@ -125,13 +171,21 @@ func (is *InstructionSet) define(action string, params ...interface{}) {
 	is.count++
 }
 
+// header returns the first line of the set's bytecode, like `<Def:foo:1>`
+func (is *InstructionSet) header() string {
+	switch is.isType {
+	case Program:
+		return fmt.Sprintf("<%s>", is.isType)
+	case MethodDef, ClassDef:
+		return fmt.Sprintf("<%s:%s:%d>", is.isType, is.name, is.id)
+	default:
+		return fmt.Sprintf("<%s:%s>", is.isType, is.name)
+	}
+}
+
 func (is *InstructionSet) compile() string {
 	var out bytes.Buffer
-	if is.isType == Program {
-		out.WriteString(fmt.Sprintf("<%s>\n", is.isType))
-	} else {
-		out.WriteString(fmt.Sprintf("<%s:%s>\n", is.isType, is.name))
-	}
+	out.WriteString(is.header() + "\n")
 
 	for _, i := range is.Instructions {
 		out.WriteString(i.compile())
@@ -1,19 +1,21 @@
 package bytecode
 
-type localTable struct {
+// LocalTable contains local variables of a program, method, class, module or block body.
+// Blocks' tables have upper tables, so outer bodies' variables can be used in blocks.
+type LocalTable struct {
 	store map[string]int
 	count int
 	depth int
-	upper *localTable
+	upper *LocalTable
 }
 
-func (lt *localTable) get(v string) (int, bool) {
+func (lt *LocalTable) get(v string) (int, bool) {
 	i, ok := lt.store[v]
 
 	return i, ok
 }
 
-func (lt *localTable) set(val string) int {
+func (lt *LocalTable) set(val string) int {
 	c, ok := lt.store[val]
 
 	if !ok {
@@ -26,7 +28,18 @@ func (lt *localTable) set(val string) int {
 	return c
 }
 
-func (lt *localTable) setLCL(v string, d int) (index, depth int) {
+// names returns the table's variable names by their indexes
+func (lt *LocalTable) names() []string {
+	names := make([]string, lt.count)
+
+	for name, index := range lt.store {
+		names[index] = name
+	}
+
+	return names
+}
+
+func (lt *LocalTable) setLCL(v string, d int) (index, depth int) {
 	index, depth, ok := lt.getLCL(v, d)
 
 	if !ok {
@@ -38,7 +51,7 @@ func (lt *localTable) setLCL(v string, d int) (index, depth int) {
 	return index, depth
 }
 
-func (lt *localTable) getLCL(v string, d int) (index, depth int, ok bool) {
+func (lt *LocalTable) getLCL(v string, d int) (index, depth int, ok bool) {
 	index, ok = lt.get(v)
 
 	if ok {
@@ -53,7 +66,40 @@ func (lt *localTable) getLCL(v string, d int) (index, depth int, ok bool) {
 	return -1, 0, false
 }
 
-func newLocalTable(depth int) *localTable {
+func newLocalTable(depth int) *LocalTable {
 	s := make(map[string]int)
-	return &localTable{store: s, depth: depth}
+	return &LocalTable{store: s, depth: depth}
+}
+
+// NewLocalTable returns the table of a program, method, class or module body
+func NewLocalTable() *LocalTable {
+	return newLocalTable(0)
+}
+
+// NewBlockTable returns the table of a block in the table's body
+func (lt *LocalTable) NewBlockTable() *LocalTable {
+	t := newLocalTable(lt.depth + 1)
+	t.upper = lt
+	return t
+}
+
+// Upper returns the table of the body where the table's block is, or nil if it isn't a block's table
+func (lt *LocalTable) Upper() *LocalTable {
+	return lt.upper
+}
+
+// Define sets a variable in the table even if upper tables have it, like a block parameter, and returns its index
+func (lt *LocalTable) Define(name string) int {
+	return lt.set(name)
+}
+
+// Assign sets a variable like assignments do, which use the variable of upper tables if they have it.
+// It returns the variable's index, and depth that is how many tables up it is.
+func (lt *LocalTable) Assign(name string) (index, depth int) {
+	return lt.setLCL(name, lt.depth)
+}
+
+// Lookup returns the index and depth of a variable in the table or its upper tables
+func (lt *LocalTable) Lookup(name string) (index, depth int, ok bool) {
+	return lt.getLCL(name, lt.depth)
 }
@@ -0,0 +1,407 @@
+package bytecode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// optimization pass names
+const (
+	ConstantFolding     = "constant_folding"
+	DeadCodeElimination = "dead_code_elimination"
+	Peephole            = "peephole"
+	Specialization      = "specialization"
+)
+
+// OptimizationPasses lists all optimization passes in the order they run
+var OptimizationPasses = []string{ConstantFolding, DeadCodeElimination, Peephole, Specialization}
+
+// specializedSends maps operators to the specialized instructions that replace their sends
+var specializedSends = map[string]string{
+	"+":  OptPlus,
+	"-":  OptMinus,
+	"*":  OptMult,
+	"<":  OptLt,
+	"<=": OptLe,
+	">":  OptGt,
+	">=": OptGe,
+	"==": OptEq,
+	"!=": OptNeq,
+}
+
+// pureActions are instructions that only push a value, so they can be dropped together with a following pop
+var pureActions = map[string]bool{
+	PutObject:           true,
+	PutString:           true,
+	PutNull:             true,
+	PutSelf:             true,
+	GetLocal:            true,
+	GetInstanceVariable: true,
+}
+
+// Optimizer rewrites instruction sets generated by Generator before they're sent to the vm.
+// Passes are run in the order of OptimizationPasses:
+//
+//   - constant_folding: evaluates arithmetic and comparisons between integer literals and concatenations of string literals
+//   - dead_code_elimination: removes instructions that can't be reached, like ones after `leave` or `jump`
+//   - peephole: threads jumps to jumps, removes jumps to the next instruction and values that are pushed then popped
+//   - specialization: replaces sends of common operators with specialized instructions, which the vm evaluates
+//     without method dispatch when both operands are integers
+//
+// Folding and specialization assume Integer's and String's operators aren't redefined. The vm checks this for
+// specialized instructions, but folded values are calculated at compile time, so constant_folding is disabled
+// by default and only programs that don't redefine operators should enable it. Each pass can be disabled for debugging.
+type Optimizer struct {
+	disabled map[string]bool
+}
+
+// NewOptimizer returns an optimizer with all passes but constant_folding enabled
+func NewOptimizer() *Optimizer {
+	return &Optimizer{disabled: map[string]bool{ConstantFolding: true}}
+}
+
+// Enable turns on given passes, "all" turns on every pass
+func (o *Optimizer) Enable(passes ...string) error {
+	for _, pass := range passes {
+		if pass == "all" {
+			for _, p := range OptimizationPasses {
+				delete(o.disabled, p)
+			}
+			continue
+		}
+
+		if !isOptimizationPass(pass) {
+			return fmt.Errorf("Unknown optimization pass: %s. Available passes: %s", pass, strings.Join(OptimizationPasses, ", "))
+		}
+
+		delete(o.disabled, pass)
+	}
+
+	return nil
+}
+
+// Disable turns off given passes, "all" turns off every pass
+func (o *Optimizer) Disable(passes ...string) error {
+	for _, pass := range passes {
+		if pass == "all" {
+			for _, p := range OptimizationPasses {
+				o.disabled[p] = true
+			}
+			continue
+		}
+
+		if !isOptimizationPass(pass) {
+			return fmt.Errorf("Unknown optimization pass: %s. Available passes: %s", pass, strings.Join(OptimizationPasses, ", "))
+		}
+
+		o.disabled[pass] = true
+	}
+
+	return nil
+}
+
+// Enabled reports whether the pass will be run
+func (o *Optimizer) Enabled(pass string) bool {
+	return isOptimizationPass(pass) && !o.disabled[pass]
+}
+
+// Optimize runs enabled passes on each instruction set
+func (o *Optimizer) Optimize(sets []*InstructionSet) {
+	for _, is := range sets {
+		if o.Enabled(ConstantFolding) {
+			is.foldConstants()
+		}
+		if o.Enabled(DeadCodeElimination) {
+			is.eliminateDeadCode()
+		}
+		if o.Enabled(Peephole) {
+			is.threadJumps()
+			is.removeUselessInstructions()
+		}
+		if o.Enabled(Specialization) {
+			is.specializeSends()
+		}
+	}
+}
+
+func isOptimizationPass(pass string) bool {
+	for _, p := range OptimizationPasses {
+		if p == pass {
+			return true
+		}
+	}
+
+	return false
+}
+
+// jumpTargets returns the indexes of instructions that some jump lands on
+func (is *InstructionSet) jumpTargets() map[int]bool {
+	targets := make(map[int]bool)
+
+	for _, i := range is.Instructions {
+		if i.anchor != nil {
+			targets[i.anchor.line] = true
+		}
+	}
+
+	return targets
+}
+
+// compact drops removed instructions and renumbers the rest.
+// A jump to a removed instruction lands on the first kept instruction after it,
+// so passes must only remove jump targets whose removal doesn't change what happens from there.
+// Anchors can be shared between jumps and they still point to old indexes, so every jump gets a new anchor.
+func (is *InstructionSet) compact(removed []bool) {
+	newIndexes := make([]int, len(is.Instructions)+1)
+	kept := []*Instruction{}
+
+	for n, i := range is.Instructions {
+		newIndexes[n] = len(kept)
+
+		if !removed[n] {
+			kept = append(kept, i)
+		}
+	}
+
+	newIndexes[len(is.Instructions)] = len(kept)
+
+	for n, i := range kept {
+		i.line = n
+
+		if i.anchor != nil {
+			line := i.anchor.line
+
+			if line > len(is.Instructions) {
+				line = len(is.Instructions)
+			}
+
+			i.anchor = &anchor{line: newIndexes[line]}
+		}
+	}
+
+	is.Instructions = kept
+	is.count = len(kept)
+}
+
+// foldConstants replaces operations between literals with their results, like `1 + 2` with `3`
+func (is *InstructionSet) foldConstants() {
+	targets := is.jumpTargets()
+	removed := make([]bool, len(is.Instructions))
+	// indexes of instructions that are kept so far
+	kept := []int{}
+
+	for n := range is.Instructions {
+		kept = append(kept, n)
+
+		for len(kept) >= 3 {
+			l, r, s := kept[len(kept)-3], kept[len(kept)-2], kept[len(kept)-1]
+
+			// Jumps landing in the middle of the operation would miss the folded value
+			if targets[r] || targets[s] {
+				break
+			}
+
+			result, ok := foldOperation(is.Instructions[l], is.Instructions[r], is.Instructions[s])
+
+			if !ok {
+				break
+			}
+
+			is.Instructions[l].Action = result.Action
+			is.Instructions[l].Params = result.Params
+			removed[r] = true
+			removed[s] = true
+			kept = kept[:len(kept)-2]
+		}
+	}
+
+	is.compact(removed)
+}
+
+// foldOperation returns the instruction that pushes the result of the left and right literals' operation
+func foldOperation(left, right, send *Instruction) (*Instruction, bool) {
+	if send.Action != Send || len(send.Params) != 2 || send.Params[1] != "1" {
+		return nil, false
+	}
+
+	operator := send.Params[0]
+
+	if left.Action == PutString && right.Action == PutString {
+		if operator != "+" {
+			return nil, false
+		}
+
+		value := fmt.Sprintf("\"%s%s\"", unquote(left.Params[0]), unquote(right.Params[0]))
+		return &Instruction{Action: PutString, Params: []string{value}}, true
+	}
+
+	l, ok := integerLiteral(left)
+
+	if !ok {
+		return nil, false
+	}
+
+	r, ok := integerLiteral(right)
+
+	if !ok {
+		return nil, false
+	}
+
+	var result interface{}
+
+	switch operator {
+	case "+":
+		result = l + r
+	case "-":
+		result = l - r
+	case "*":
+		result = l * r
+	case "/":
+		if r == 0 {
+			return nil, false
+		}
+		result = l / r
+	case "%":
+		if r == 0 {
+			return nil, false
+		}
+		result = l % r
+	case "<":
+		result = l < r
+	case "<=":
+		result = l <= r
+	case ">":
+		result = l > r
+	case ">=":
+		result = l >= r
+	case "==":
+		result = l == r
+	case "!=":
+		result = l != r
+	default:
+		return nil, false
+	}
+
+	return &Instruction{Action: PutObject, Params: []string{fmt.Sprint(result)}}, true
+}
+
+func integerLiteral(i *Instruction) (int, bool) {
+	if i.Action != PutObject || len(i.Params) != 1 {
+		return 0, false
+	}
+
+	// Same as how the vm parses putobject's param
+	value, err := strconv.ParseInt(i.Params[0], 0, 64)
+
+	if err != nil {
+		return 0, false
+	}
+
+	return int(value), true
+}
+
+func unquote(param string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(param, "\""), "\"")
+}
+
+// eliminateDeadCode removes instructions that can't be reached from the first instruction
+func (is *InstructionSet) eliminateDeadCode() {
+	reached := make([]bool, len(is.Instructions))
+	pending := []int{0}
+
+	for len(pending) > 0 {
+		n := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if n >= len(is.Instructions) || reached[n] {
+			continue
+		}
+
+		reached[n] = true
+		i := is.Instructions[n]
+
+		switch {
+		// Jumps without anchors, like `next` in blocks, are kept for Verify to reject
+		case i.Action == Leave || (i.Action == Jump && i.anchor == nil):
+		case i.Action == Jump:
+			pending = append(pending, i.anchor.line)
+		case (i.Action == BranchIf || i.Action == BranchUnless) && i.anchor != nil:
+			pending = append(pending, i.anchor.line, n+1)
+		default:
+			pending = append(pending, n+1)
+		}
+	}
+
+	removed := make([]bool, len(is.Instructions))
+
+	for n := range is.Instructions {
+		removed[n] = !reached[n]
+	}
+
+	is.compact(removed)
+}
+
+// threadJumps makes jumps that land on another `jump` go to its destination directly
+func (is *InstructionSet) threadJumps() {
+	for _, i := range is.Instructions {
+		if i.anchor == nil {
+			continue
+		}
+
+		line := i.anchor.line
+
+		// Loops made only of jumps never end, so we stop following them once we're back
+		for hops := 0; hops < len(is.Instructions); hops++ {
+			if line >= len(is.Instructions) || is.Instructions[line].Action != Jump || is.Instructions[line].anchor == nil {
+				break
+			}
+
+			line = is.Instructions[line].anchor.line
+		}
+
+		if line != i.anchor.line {
+			i.anchor = &anchor{line: line}
+		}
+	}
+}
+
+// removeUselessInstructions removes jumps to the next instruction and values that are popped right after they're pushed
+func (is *InstructionSet) removeUselessInstructions() {
+	targets := is.jumpTargets()
+	removed := make([]bool, len(is.Instructions))
+
+	for n, i := range is.Instructions {
+		if i.Action == Jump && i.anchor != nil && i.anchor.line == n+1 {
+			removed[n] = true
+			continue
+		}
+
+		if n+1 >= len(is.Instructions) || removed[n] {
+			continue
+		}
+
+		next := is.Instructions[n+1]
+
+		if pureActions[i.Action] && next.Action == Pop && !targets[n] && !targets[n+1] {
+			removed[n] = true
+			removed[n+1] = true
+		}
+	}
+
+	is.compact(removed)
+}
+
+// specializeSends replaces operator sends that have one argument and no block with specialized instructions
+func (is *InstructionSet) specializeSends() {
+	for _, i := range is.Instructions {
+		if i.Action != Send || len(i.Params) != 2 || i.Params[1] != "1" {
+			continue
+		}
+
+		if action, ok := specializedSends[i.Params[0]]; ok {
+			i.Action = action
+			i.Params = []string{}
+		}
+	}
+}
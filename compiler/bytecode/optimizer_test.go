@@ -0,0 +1,264 @@
+package bytecode
+
+import (
+	"bytes"
+	"github.com/goby-lang/goby/compiler/lexer"
+	"github.com/goby-lang/goby/compiler/parser"
+	"strings"
+	"testing"
+)
+
+func TestConstantFoldingOptimization(t *testing.T) {
+	input := `
+	a = 1 + 2 * 3 - -4
+	b = "foo" + "bar" + "baz"
+	c = 10 / 3 >= 3
+	d = 10 % 0
+	e = a + 1
+	`
+
+	expected := `
+<ProgramStart>
+0 putobject 11
+1 setlocal 0 0
+2 putstring "foobarbaz"
+3 setlocal 0 1
+4 putobject true
+5 setlocal 0 2
+6 putobject 10
+7 putobject 0
+8 send % 1
+9 setlocal 0 3
+10 getlocal 0 0
+11 putobject 1
+12 send + 1
+13 setlocal 0 4
+14 leave
+`
+
+	bytecode := optimizeToBytecode(input, Specialization)
+	compareBytecode(t, bytecode, expected)
+}
+
+func TestDeadCodeEliminationOptimization(t *testing.T) {
+	input := `
+	def foo(x)
+	  if x
+	    return 1
+	    puts("unreachable")
+	  end
+	  return 2
+	  3
+	end
+	`
+
+	expected := `
+<Def:foo:0>
+0 getlocal 0 0
+1 branchunless 4
+2 putobject 1
+3 leave
+4 putobject 2
+5 leave
+<ProgramStart>
+0 putself
+1 putstring "foo"
+2 def_method 1 0
+3 leave
+`
+
+	bytecode := optimizeToBytecode(input, Peephole)
+	compareBytecode(t, bytecode, expected)
+}
+
+func TestPeepholeOptimization(t *testing.T) {
+	input := `
+	i = 0
+	while i < 10 do
+	  i += 1
+	end
+	`
+
+	expected := `
+<ProgramStart>
+0 putobject 0
+1 setlocal 0 0
+2 jump 7
+3 getlocal 0 0
+4 putobject 1
+5 send + 1
+6 setlocal 0 0
+7 getlocal 0 0
+8 putobject 10
+9 send < 1
+10 branchif 3
+11 leave
+`
+
+	bytecode := optimizeToBytecode(input, Specialization)
+	compareBytecode(t, bytecode, expected)
+}
+
+func TestJumpThreadingOptimization(t *testing.T) {
+	input := `
+	a = 1
+	b = if a
+	  if a > 1
+	    1
+	  else
+	    2
+	  end
+	else
+	  3
+	end
+	`
+
+	expected := `
+<ProgramStart>
+0 putobject 1
+1 setlocal 0 0
+2 getlocal 0 0
+3 branchunless 12
+4 getlocal 0 0
+5 putobject 1
+6 opt_gt
+7 branchunless 10
+8 putobject 1
+9 jump 13
+10 putobject 2
+11 jump 13
+12 putobject 3
+13 setlocal 0 1
+14 leave
+`
+
+	bytecode := optimizeToBytecode(input)
+	compareBytecode(t, bytecode, expected)
+}
+
+func TestSpecializationOptimization(t *testing.T) {
+	input := `
+	a = 1
+	b = a + 2
+	c = a < b
+	d = a / b
+	e = a.push(b)
+	`
+
+	expected := `
+<ProgramStart>
+0 putobject 1
+1 setlocal 0 0
+2 getlocal 0 0
+3 putobject 2
+4 opt_plus
+5 setlocal 0 1
+6 getlocal 0 0
+7 getlocal 0 1
+8 opt_lt
+9 setlocal 0 2
+10 getlocal 0 0
+11 getlocal 0 1
+12 send / 1
+13 setlocal 0 3
+14 getlocal 0 0
+15 getlocal 0 1
+16 send push 1
+17 setlocal 0 4
+18 leave
+`
+
+	bytecode := optimizeToBytecode(input)
+	compareBytecode(t, bytecode, expected)
+}
+
+func TestOptimizeJumpsWithoutTargets(t *testing.T) {
+	input := `
+	[1, 2, 3].each do |i|
+	  if i == 1
+	    next
+	  end
+	  if i == 3
+	    break
+	  end
+	  puts(i)
+	end
+	`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program, _ := p.ParseProgram()
+	g := NewGenerator()
+	g.InitTopLevelScope(program)
+	sets := g.GenerateInstructions(program.Statements)
+	NewOptimizer().Optimize(sets)
+
+	// `next` and `break` outside of loops are left for Verify
+	err := Verify(sets)
+
+	if err == nil || !strings.Contains(err.Error(), "jump: missing jump target") {
+		t.Fatalf("Expect a missing jump target error. got: %v", err)
+	}
+}
+
+func TestDisabledOptimizations(t *testing.T) {
+	input := `
+	i = 1 + 2
+	while i < 10 do
+	  i += 1
+	end
+
+	def foo
+	  return 1
+	  2
+	end
+	`
+
+	bytecode := optimizeToBytecode(input, "all")
+	compareBytecode(t, bytecode, compileToBytecode(input))
+
+	o := NewOptimizer()
+	err := o.Disable(Peephole, "inline")
+
+	if err == nil || err.Error() != "Unknown optimization pass: inline. Available passes: constant_folding, dead_code_elimination, peephole, specialization" {
+		t.Fatalf("Expect an unknown pass error. got: %v", err)
+	}
+
+	if o.Enabled(Peephole) || !o.Enabled(DeadCodeElimination) {
+		t.Fatalf("Expect only peephole pass to be disabled")
+	}
+
+	// Folded values would ignore redefined operators, so folding is opt-in
+	if o.Enabled(ConstantFolding) {
+		t.Fatalf("Expect constant folding to be disabled by default")
+	}
+
+	if err := o.Enable(ConstantFolding); err != nil || !o.Enabled(ConstantFolding) {
+		t.Fatalf("Expect constant folding to be enabled. got: %v", err)
+	}
+}
+
+func optimizeToBytecode(input string, disabled ...string) string {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program, err := p.ParseProgram()
+	if err != nil {
+		panic(err.Message)
+	}
+	g := NewGenerator()
+	g.InitTopLevelScope(program)
+	sets := g.GenerateInstructions(program.Statements)
+
+	o := NewOptimizer()
+	o.Enable("all")
+	if err := o.Disable(disabled...); err != nil {
+		panic(err.Error())
+	}
+	o.Optimize(sets)
+
+	var out bytes.Buffer
+	for _, is := range sets {
+		out.WriteString(is.compile())
+	}
+	return strings.TrimSpace(out.String())
+}
@@ -13,7 +13,7 @@ const (
 	OptionedArg
 )
 
-func (g *Generator) compileStatements(stmts []ast.Statement, scope *scope, table *localTable) {
+func (g *Generator) compileStatements(stmts []ast.Statement, scope *scope, table *LocalTable) {
 	is := &InstructionSet{isType: Program, name: Program}
 
 	for _, statement := range stmts {
@@ -21,11 +21,13 @@ func (g *Generator) compileStatements(stmts []ast.Statement, scope *scope, table
 	}
 
 	g.endInstructions(is)
+	is.setLocals(table)
 	g.instructionSets = append(g.instructionSets, is)
 }
 
-func (g *Generator) compileStatement(is *InstructionSet, statement ast.Statement, scope *scope, table *localTable) {
+func (g *Generator) compileStatement(is *InstructionSet, statement ast.Statement, scope *scope, table *LocalTable) {
 	scope.line++
+	is.sourceLine = statementLine(statement)
 	switch stmt := statement.(type) {
 	case *ast.ExpressionStatement:
 		g.compileExpression(is, stmt.Expression, scope, table)
@@ -50,7 +52,31 @@ func (g *Generator) compileStatement(is *InstructionSet, statement ast.Statement
 	}
 }
 
-func (g *Generator) compileWhileStmt(is *InstructionSet, stmt *ast.WhileStatement, scope *scope, table *localTable) {
+// statementLine returns the line the statement starts at, which starts from 1
+func statementLine(statement ast.Statement) int {
+	switch stmt := statement.(type) {
+	case *ast.ExpressionStatement:
+		return stmt.Token.Line + 1
+	case *ast.DefStatement:
+		return stmt.Token.Line + 1
+	case *ast.ClassStatement:
+		return stmt.Token.Line + 1
+	case *ast.ModuleStatement:
+		return stmt.Token.Line + 1
+	case *ast.ReturnStatement:
+		return stmt.Token.Line + 1
+	case *ast.WhileStatement:
+		return stmt.Token.Line + 1
+	case *ast.NextStatement:
+		return stmt.Token.Line + 1
+	case *ast.BreakStatement:
+		return stmt.Token.Line + 1
+	}
+
+	return 0
+}
+
+func (g *Generator) compileWhileStmt(is *InstructionSet, stmt *ast.WhileStatement, scope *scope, table *LocalTable) {
 	anchor1 := &anchor{}
 	breakAnchor := &anchor{}
 
@@ -65,9 +91,12 @@ func (g *Generator) compileWhileStmt(is *InstructionSet, stmt *ast.WhileStatemen
 	scope.anchors["next"] = anchor1
 	scope.anchors["break"] = breakAnchor
 	g.fsm.Event(removeExp)
+	line := is.sourceLine
 	g.compileCodeBlock(is, stmt.Body, scope, table)
 	g.fsm.Event(keepExp)
 
+	// The condition is compiled after the body, but it's on the while statement's line
+	is.sourceLine = line
 	anchor1.line = is.count
 
 	g.compileExpression(is, stmt.Condition, scope, table)
@@ -87,61 +116,57 @@ func (g *Generator) compileBreakStatement(is *InstructionSet, scope *scope) {
 	is.define(Jump, scope.anchors["break"])
 }
 
-func (g *Generator) compileClassStmt(is *InstructionSet, stmt *ast.ClassStatement, scope *scope, table *localTable) {
+func (g *Generator) compileClassStmt(is *InstructionSet, stmt *ast.ClassStatement, scope *scope, table *LocalTable) {
+	newIS := g.newInstructionSet(stmt.Name.Value, ClassDef)
 	is.define(PutSelf)
 
 	if stmt.SuperClass != nil {
 		g.compileExpression(is, stmt.SuperClass, scope, table)
-		is.define(DefClass, "class:"+stmt.Name.Value, stmt.SuperClassName)
+		is.define(DefClass, "class:"+stmt.Name.Value, newIS.id, stmt.SuperClassName)
 	} else {
-		is.define(DefClass, "class:"+stmt.Name.Value)
+		is.define(DefClass, "class:"+stmt.Name.Value, newIS.id)
 	}
 
 	is.define(Pop)
 	scope = newScope(stmt)
 
 	// compile class's content
-	newIS := &InstructionSet{}
-	newIS.name = stmt.Name.Value
-	newIS.isType = ClassDef
 
 	g.compileCodeBlock(newIS, stmt.Body, scope, scope.localTable)
 	newIS.define(Leave)
+	newIS.setLocals(scope.localTable)
 	g.instructionSets = append(g.instructionSets, newIS)
 }
 
 func (g *Generator) compileModuleStmt(is *InstructionSet, stmt *ast.ModuleStatement, scope *scope) {
+	newIS := g.newInstructionSet(stmt.Name.Value, ClassDef)
 	is.define(PutSelf)
-	is.define(DefClass, "module:"+stmt.Name.Value)
+	is.define(DefClass, "module:"+stmt.Name.Value, newIS.id)
 	is.define(Pop)
 
 	scope = newScope(stmt)
-	newIS := &InstructionSet{}
-	newIS.name = stmt.Name.Value
-	newIS.isType = ClassDef
 
 	g.compileCodeBlock(newIS, stmt.Body, scope, scope.localTable)
 	newIS.define(Leave)
+	newIS.setLocals(scope.localTable)
 	g.instructionSets = append(g.instructionSets, newIS)
 }
 
 func (g *Generator) compileDefStmt(is *InstructionSet, stmt *ast.DefStatement, scope *scope) {
+	newIS := g.newInstructionSet(stmt.Name.Value, MethodDef)
 	is.define(PutSelf)
 	is.define(PutString, fmt.Sprintf("\"%s\"", stmt.Name.Value))
 
 	switch stmt.Receiver.(type) {
 	case *ast.SelfExpression:
-		is.define(DefSingletonMethod, len(stmt.Parameters))
+		is.define(DefSingletonMethod, len(stmt.Parameters), newIS.id)
 	case nil:
-		is.define(DefMethod, len(stmt.Parameters))
+		is.define(DefMethod, len(stmt.Parameters), newIS.id)
 	}
 
 	scope = newScope(stmt)
 
 	// compile method definition's content
-	newIS := &InstructionSet{}
-	newIS.name = stmt.Name.Value
-	newIS.isType = MethodDef
 
 	for i := 0; i < len(stmt.Parameters); i++ {
 		var argType int
@@ -167,5 +192,6 @@ func (g *Generator) compileDefStmt(is *InstructionSet, stmt *ast.DefStatement, s
 	}
 
 	g.endInstructions(newIS)
+	newIS.setLocals(scope.localTable)
 	g.instructionSets = append(g.instructionSets, newIS)
 }
@@ -20,7 +20,7 @@ Foo.new(100, 50).bar
 `
 
 	expected := `
-<Def:initialize>
+<Def:initialize:1>
 0 getlocal 0 0
 1 setinstancevariable @x
 2 getlocal 0 1
@@ -30,24 +30,24 @@ Foo.new(100, 50).bar
 6 send - 1
 7 setinstancevariable @z
 8 leave
-<Def:bar>
+<Def:bar:2>
 0 getinstancevariable @x
 1 getinstancevariable @y
 2 send + 1
 3 getinstancevariable @z
 4 send + 1
 5 leave
-<DefClass:Foo>
+<DefClass:Foo:0>
 0 putself
 1 putstring "initialize"
-2 def_method 2
+2 def_method 2 1
 3 putself
 4 putstring "bar"
-5 def_method 0
+5 def_method 0 2
 6 leave
 <ProgramStart>
 0 putself
-1 def_class class:Foo
+1 def_class class:Foo 0
 2 pop
 3 getconstant Foo false
 4 putobject 100
@@ -75,27 +75,27 @@ func TestNamespacedClass(t *testing.T) {
 	`
 
 	expected := `
-<Def:bar>
+<Def:bar:3>
 0 putnil
 1 leave
-<DefClass:Baz>
+<DefClass:Baz:2>
 0 putself
 1 putstring "bar"
-2 def_method 0
+2 def_method 0 3
 3 leave
-<DefClass:Bar>
+<DefClass:Bar:1>
 0 putself
-1 def_class class:Baz
+1 def_class class:Baz 2
 2 pop
 3 leave
-<DefClass:Foo>
+<DefClass:Foo:0>
 0 putself
-1 def_class class:Bar
+1 def_class class:Bar 1
 2 pop
 3 leave
 <ProgramStart>
 0 putself
-1 def_class module:Foo
+1 def_class module:Foo 0
 2 pop
 3 getconstant Foo true
 4 getconstant Bar true
@@ -120,17 +120,17 @@ end
 Foo.bar
 `
 	expected := `
-<Def:bar>
+<Def:bar:1>
 0 putobject 10
 1 leave
-<DefClass:Foo>
+<DefClass:Foo:0>
 0 putself
 1 putstring "bar"
-2 def_singleton_method 0
+2 def_singleton_method 0 1
 3 leave
 <ProgramStart>
 0 putself
-1 def_class class:Foo
+1 def_class class:Foo 0
 2 pop
 3 getconstant Foo false
 4 send bar 0
@@ -155,23 +155,23 @@ end
 Foo.new.bar
 `
 	expected := `
-<Def:bar>
+<Def:bar:1>
 0 putobject 10
 1 leave
-<DefClass:Bar>
+<DefClass:Bar:0>
 0 putself
 1 putstring "bar"
-2 def_method 0
+2 def_method 0 1
 3 leave
-<DefClass:Foo>
+<DefClass:Foo:2>
 0 leave
 <ProgramStart>
 0 putself
-1 def_class class:Bar
+1 def_class class:Bar 0
 2 pop
 3 putself
 4 getconstant Bar false
-5 def_class class:Foo Bar
+5 def_class class:Foo 2 Bar
 6 pop
 7 getconstant Foo false
 8 send new 0
@@ -199,25 +199,25 @@ end
 Foo.new.bar
 `
 	expected := `
-<Def:bar>
+<Def:bar:1>
 0 putobject 10
 1 leave
-<DefClass:Bar>
+<DefClass:Bar:0>
 0 putself
 1 putstring "bar"
-2 def_method 0
+2 def_method 0 1
 3 leave
-<DefClass:Foo>
+<DefClass:Foo:2>
 0 putself
 1 getconstant Bar false
 2 send include 1
 3 leave
 <ProgramStart>
 0 putself
-1 def_class module:Bar
+1 def_class module:Bar 0
 2 pop
 3 putself
-4 def_class class:Foo
+4 def_class class:Foo 2
 5 pop
 6 getconstant Foo false
 7 send new 0
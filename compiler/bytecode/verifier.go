@@ -0,0 +1,429 @@
+package bytecode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VerificationError describes why Verify rejects instruction sets
+type VerificationError struct {
+	// Set is the header of the instruction set, like `<Def:foo:1>`
+	Set string
+	// Line is the index of the rejected instruction, -1 if the error is about the whole set
+	Line    int
+	Action  string
+	Message string
+}
+
+func (e *VerificationError) Error() string {
+	if e.Line < 0 {
+		return fmt.Sprintf("Invalid bytecode in %s: %s", e.Set, e.Message)
+	}
+
+	return fmt.Sprintf("Invalid bytecode in %s at %d %s: %s", e.Set, e.Line, e.Action, e.Message)
+}
+
+// operandKind is what an instruction's param is expected to be
+type operandKind int
+
+const (
+	// anyOperand is a name, like a method or constant name
+	anyOperand operandKind = iota
+	// countOperand is a non-negative integer, like an argument count or a local's depth and index
+	countOperand
+	// idOperand is the id of a method, class or block body
+	idOperand
+	// literalOperand is putobject's integer, `true`, `false` or `nil`
+	literalOperand
+	// stringOperand is putstring's quoted string
+	stringOperand
+	// booleanOperand is getconstant's namespace flag
+	booleanOperand
+	// subjectOperand is def_class's `class:Name` or `module:Name`
+	subjectOperand
+	// blockOperand is send's `block:id`
+	blockOperand
+)
+
+// operandSpec lists an action's required params, followed by its optional ones
+type operandSpec struct {
+	required []operandKind
+	optional []operandKind
+	// jump is set for actions that need an anchor
+	jump bool
+}
+
+var operandSpecs = map[string]operandSpec{
+	Pop:                 {},
+	PutObject:           {required: []operandKind{literalOperand}},
+	PutString:           {required: []operandKind{stringOperand}},
+	PutSelf:             {},
+	PutNull:             {},
+	GetConstant:         {required: []operandKind{anyOperand, booleanOperand}},
+	GetLocal:            {required: []operandKind{countOperand, countOperand}},
+	GetInstanceVariable: {required: []operandKind{anyOperand}},
+	SetLocal:            {required: []operandKind{countOperand, countOperand}, optional: []operandKind{countOperand}},
+	SetConstant:         {required: []operandKind{anyOperand}},
+	SetInstanceVariable: {required: []operandKind{anyOperand}},
+	NewRange:            {optional: []operandKind{countOperand}},
+	NewArray:            {required: []operandKind{countOperand}},
+	ExpandArray:         {required: []operandKind{countOperand}},
+	NewHash:             {required: []operandKind{countOperand}},
+	BranchUnless:        {jump: true},
+	BranchIf:            {jump: true},
+	Jump:                {jump: true},
+	DefMethod:           {required: []operandKind{countOperand, idOperand}},
+	DefSingletonMethod:  {required: []operandKind{countOperand, idOperand}},
+	DefClass:            {required: []operandKind{subjectOperand, idOperand}, optional: []operandKind{anyOperand}},
+	Send:                {required: []operandKind{anyOperand, countOperand}, optional: []operandKind{blockOperand}},
+	InvokeBlock:         {required: []operandKind{countOperand}},
+	Leave:               {},
+	OptPlus:             {},
+	OptMinus:            {},
+	OptMult:             {},
+	OptLt:               {},
+	OptLe:               {},
+	OptGt:               {},
+	OptGe:               {},
+	OptEq:               {},
+	OptNeq:              {},
+}
+
+// bodyTypes maps actions that refer to bodies to the type of sets they refer to
+var bodyTypes = map[string]string{
+	DefMethod:          MethodDef,
+	DefSingletonMethod: MethodDef,
+	DefClass:           ClassDef,
+	Send:               Block,
+}
+
+// Verify checks instruction sets before they're evaluated, so malformed ones are rejected instead of
+// breaking the vm in the middle of evaluation. It checks that:
+//
+//   - there's exactly one program set, and method, class and block sets have unique ids
+//   - actions are known and their params have expected counts and formats
+//   - jumps land inside their sets
+//   - def_method, def_singleton_method, def_class and send refer to existing sets of the right types,
+//     and each block is given by only one send
+//   - locals are inside the scopes of their sets and the blocks' enclosing sets
+//   - instructions don't pop values their set didn't push, on any branch that reaches them
+//
+// The returned error is a *VerificationError.
+func Verify(sets []*InstructionSet) error {
+	v := &verifier{bodies: make(map[int]*InstructionSet), parents: make(map[int]*InstructionSet)}
+	programs := 0
+
+	for _, is := range sets {
+		if is.isType == Program {
+			programs++
+			continue
+		}
+
+		if _, ok := v.bodies[is.id]; ok {
+			return &VerificationError{Set: is.header(), Line: -1, Message: fmt.Sprintf("duplicate id %d", is.id)}
+		}
+
+		v.bodies[is.id] = is
+	}
+
+	if programs != 1 {
+		return &VerificationError{Set: "<" + Program + ">", Line: -1, Message: fmt.Sprintf("expect 1 program set. got: %d", programs)}
+	}
+
+	// Blocks' enclosing sets are found by operands, so locals are checked after all operands
+	for _, is := range sets {
+		for n, i := range is.Instructions {
+			if err := v.verifyOperands(is, i); err != nil {
+				return is.errorAt(n, i, "%s", err.Error())
+			}
+		}
+	}
+
+	for _, is := range sets {
+		for n, i := range is.Instructions {
+			if err := v.verifyLocal(is, i); err != nil {
+				return is.errorAt(n, i, "%s", err.Error())
+			}
+		}
+
+		if err := is.verifyStack(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifier holds what Verify knows about all instruction sets
+type verifier struct {
+	// bodies are method, class and block sets by their ids
+	bodies map[int]*InstructionSet
+	// parents are the sets where blocks are given, by the blocks' ids
+	parents map[int]*InstructionSet
+}
+
+func (is *InstructionSet) errorAt(n int, i *Instruction, format string, args ...interface{}) *VerificationError {
+	return &VerificationError{Set: is.header(), Line: n, Action: i.Action, Message: fmt.Sprintf(format, args...)}
+}
+
+func (v *verifier) verifyOperands(is *InstructionSet, i *Instruction) error {
+	spec, ok := operandSpecs[i.Action]
+
+	if !ok {
+		return fmt.Errorf("unknown action")
+	}
+
+	if spec.jump {
+		if i.anchor == nil {
+			return fmt.Errorf("missing jump target")
+		}
+
+		// Jumping to the end of the set finishes it, like running out of instructions
+		if i.anchor.line < 0 || i.anchor.line > len(is.Instructions) {
+			return fmt.Errorf("jump target %d is out of range 0..%d", i.anchor.line, len(is.Instructions))
+		}
+	}
+
+	// leave's params are never used, so they're not checked
+	if i.Action == Leave {
+		return nil
+	}
+
+	if len(i.Params) < len(spec.required) || len(i.Params) > len(spec.required)+len(spec.optional) {
+		if len(spec.optional) == 0 {
+			return fmt.Errorf("expect %d params. got: %d", len(spec.required), len(i.Params))
+		}
+
+		return fmt.Errorf("expect %d..%d params. got: %d", len(spec.required), len(spec.required)+len(spec.optional), len(i.Params))
+	}
+
+	kinds := append(append([]operandKind{}, spec.required...), spec.optional...)
+
+	for n, param := range i.Params {
+		id, err := verifyOperand(kinds[n], param)
+
+		if err != nil {
+			return fmt.Errorf("param %d %s", n, err.Error())
+		}
+
+		if id < 0 {
+			continue
+		}
+
+		body, ok := v.bodies[id]
+
+		if !ok {
+			return fmt.Errorf("can't find instruction set %d", id)
+		}
+
+		if body.isType != bodyTypes[i.Action] {
+			return fmt.Errorf("expect instruction set %d to be %s. got: %s", id, bodyTypes[i.Action], body.isType)
+		}
+
+		if body.isType == Block {
+			if _, ok := v.parents[id]; ok {
+				return fmt.Errorf("block %d is already given by another send", id)
+			}
+
+			v.parents[id] = is
+		}
+	}
+
+	return nil
+}
+
+// verifyLocal checks getlocal's and setlocal's depth and index.
+// A block's locals with depth n belong to the set n levels out, and they must be in its local table.
+func (v *verifier) verifyLocal(is *InstructionSet, i *Instruction) error {
+	if i.Action != GetLocal && i.Action != SetLocal {
+		return nil
+	}
+
+	depth, _ := strconv.Atoi(i.Params[0])
+	index, _ := strconv.Atoi(i.Params[1])
+	scope := is
+
+	for d := 0; d < depth; d++ {
+		parent, ok := v.parents[scope.id]
+
+		if scope.isType != Block || !ok {
+			return fmt.Errorf("depth %d is deeper than the set's scope", depth)
+		}
+
+		scope = parent
+	}
+
+	if index >= scope.localCount {
+		return fmt.Errorf("index %d is out of %s's %d locals", index, scope.header(), scope.localCount)
+	}
+
+	return nil
+}
+
+// verifyOperand checks the param's format, and returns the id it refers to or -1
+func verifyOperand(kind operandKind, param string) (int, error) {
+	switch kind {
+	case countOperand:
+		if n, err := strconv.Atoi(param); err != nil || n < 0 {
+			return -1, fmt.Errorf("expect a non-negative integer. got: %s", param)
+		}
+	case idOperand:
+		return parseID(param)
+	case literalOperand:
+		if _, err := strconv.ParseInt(param, 0, 64); err != nil && param != "true" && param != "false" && param != "nil" {
+			return -1, fmt.Errorf("expect an integer, true, false or nil. got: %s", param)
+		}
+	case stringOperand:
+		if len(param) < 2 || !strings.HasPrefix(param, "\"") || !strings.HasSuffix(param, "\"") {
+			return -1, fmt.Errorf("expect a quoted string. got: %s", param)
+		}
+	case booleanOperand:
+		if param != "true" && param != "false" {
+			return -1, fmt.Errorf("expect true or false. got: %s", param)
+		}
+	case subjectOperand:
+		if !strings.HasPrefix(param, "class:") && !strings.HasPrefix(param, "module:") {
+			return -1, fmt.Errorf("expect class:Name or module:Name. got: %s", param)
+		}
+	case blockOperand:
+		if !strings.HasPrefix(param, "block:") {
+			return -1, fmt.Errorf("expect block:id. got: %s", param)
+		}
+
+		return parseID(strings.TrimPrefix(param, "block:"))
+	}
+
+	return -1, nil
+}
+
+func parseID(param string) (int, error) {
+	id, err := strconv.Atoi(param)
+
+	if err != nil || id < 0 {
+		return -1, fmt.Errorf("expect an instruction set id. got: %s", param)
+	}
+
+	return id, nil
+}
+
+// stackState is the stack when an instruction is evaluated
+type stackState struct {
+	// height is the number of values pushed by the set's instructions
+	height int
+	// namespace is set when the top value may be a namespace pushed by getconstant, which the next getconstant replaces
+	namespace bool
+}
+
+// merge combines states of paths that reach the same instruction, and reports whether the state changed.
+// The lowest height is kept since it's the one that can underflow.
+func (s *stackState) merge(other stackState) bool {
+	changed := false
+
+	if other.height < s.height {
+		s.height = other.height
+		changed = true
+	}
+
+	if other.namespace && !s.namespace {
+		s.namespace = true
+		changed = true
+	}
+
+	return changed
+}
+
+// stackEffect returns how many values the instruction pops and pushes.
+// Params are already verified, so they can be parsed without checking errors.
+func stackEffect(i *Instruction, before stackState) (pops, pushes int) {
+	count := func(n int) int {
+		c, _ := strconv.Atoi(i.Params[n])
+		return c
+	}
+
+	switch i.Action {
+	case PutObject, PutString, PutSelf, PutNull, GetLocal, GetInstanceVariable:
+		return 0, 1
+	case GetConstant:
+		if before.namespace {
+			return 1, 1
+		}
+
+		return 0, 1
+	case Pop, SetLocal, SetConstant, SetInstanceVariable, BranchUnless, BranchIf:
+		return 1, 0
+	case NewRange:
+		return 2, 1
+	case NewArray, NewHash:
+		return count(0), 1
+	case ExpandArray:
+		return 1, count(0)
+	case DefMethod, DefSingletonMethod:
+		return 2, 0
+	case DefClass:
+		return 1, 1
+	case Send:
+		return count(1) + 1, 1
+	case InvokeBlock:
+		// The block's arguments follow a `putself`, which is popped with them
+		return count(0) + 1, 1
+	case OptPlus, OptMinus, OptMult, OptLt, OptLe, OptGt, OptGe, OptEq, OptNeq:
+		return 2, 1
+	default:
+		return 0, 0
+	}
+}
+
+// verifyStack follows every path through the set, and checks its instructions never pop values the set didn't push.
+// Generated sets leave unused values on the stack, so paths can reach an instruction with different heights.
+// Instructions are checked with the lowest of them.
+func (is *InstructionSet) verifyStack() error {
+	states := make([]*stackState, len(is.Instructions)+1)
+	states[0] = &stackState{}
+	pending := []int{0}
+
+	for len(pending) > 0 {
+		n := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if n == len(is.Instructions) {
+			continue
+		}
+
+		i := is.Instructions[n]
+		before := *states[n]
+		pops, pushes := stackEffect(i, before)
+
+		if before.height < pops {
+			return is.errorAt(n, i, "pops %d values, but the stack only has %d", pops, before.height)
+		}
+
+		after := stackState{height: before.height - pops + pushes, namespace: i.Action == GetConstant && i.Params[1] == "true"}
+		next := []int{}
+
+		switch i.Action {
+		case Leave:
+		case Jump:
+			next = append(next, i.anchor.line)
+		case BranchIf, BranchUnless:
+			next = append(next, i.anchor.line, n+1)
+		default:
+			next = append(next, n+1)
+		}
+
+		for _, m := range next {
+			if states[m] == nil {
+				s := after
+				states[m] = &s
+				pending = append(pending, m)
+				continue
+			}
+
+			if states[m].merge(after) {
+				pending = append(pending, m)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package bytecode
+
+import (
+	"github.com/goby-lang/goby/compiler/lexer"
+	"github.com/goby-lang/goby/compiler/parser"
+	"testing"
+)
+
+func TestVerifyGeneratedInstructions(t *testing.T) {
+	tests := []string{
+		`
+		class Foo < Bar
+		  def bar(a, b = 10)
+		    if a > b
+		      a
+		    else
+		      while a < b do
+		        a += 1
+		      end
+		    end
+		  end
+		end
+		`,
+		`
+		module Foo
+		  def self.bar
+		    [1, 2].map do |i|
+		      x = i
+		      [3].each do |j|
+		        x += j + i
+		      end
+		    end
+		  end
+		end
+		`,
+		`
+		if false
+		  x = 1
+		end
+
+		a, b = [1, 2]
+		c = { foo: 1..2 }
+		Foo::Bar::Baz
+		`,
+	}
+
+	for i, input := range tests {
+		sets := generateInstructionSets(input)
+
+		if err := Verify(sets); err != nil {
+			t.Fatalf("At case %d: Expect instructions to be valid. got: %s", i, err.Error())
+		}
+
+		NewOptimizer().Optimize(sets)
+
+		if err := Verify(sets); err != nil {
+			t.Fatalf("At case %d: Expect optimized instructions to be valid. got: %s", i, err.Error())
+		}
+	}
+}
+
+func TestVerifyMalformedInstructions(t *testing.T) {
+	tests := []struct {
+		input    string
+		malform  func(sets []*InstructionSet)
+		expected string
+	}{
+		{`1`, func(sets []*InstructionSet) {
+			sets[0].Instructions[0].Action = "putfoo"
+		}, "Invalid bytecode in <ProgramStart> at 0 putfoo: unknown action"},
+		{`foo(1)`, func(sets []*InstructionSet) {
+			sets[0].Instructions[2].Params = []string{"foo"}
+		}, "Invalid bytecode in <ProgramStart> at 2 send: expect 2..3 params. got: 1"},
+		{`foo(1)`, func(sets []*InstructionSet) {
+			sets[0].Instructions[2].Params[1] = "-1"
+		}, "Invalid bytecode in <ProgramStart> at 2 send: param 1 expect a non-negative integer. got: -1"},
+		{`"foo"`, func(sets []*InstructionSet) {
+			sets[0].Instructions[0].Params[0] = "foo"
+		}, "Invalid bytecode in <ProgramStart> at 0 putstring: param 0 expect a quoted string. got: foo"},
+		{`a = 1
+		if a
+		  2
+		end`, func(sets []*InstructionSet) {
+			sets[0].Instructions[3].anchor = nil
+		}, "Invalid bytecode in <ProgramStart> at 3 branchunless: missing jump target"},
+		{`if true
+		  2
+		end`, func(sets []*InstructionSet) {
+			sets[0].Instructions[1].anchor = &anchor{line: 100}
+		}, "Invalid bytecode in <ProgramStart> at 1 branchunless: jump target 100 is out of range 0..6"},
+		{`def foo; end`, func(sets []*InstructionSet) {
+			sets[1].Instructions[2].Params[1] = "5"
+		}, "Invalid bytecode in <ProgramStart> at 2 def_method: can't find instruction set 5"},
+		{`[1].each do |i| end`, func(sets []*InstructionSet) {
+			sets[1].Instructions[2].Params[2] = "blk:0"
+		}, "Invalid bytecode in <ProgramStart> at 2 send: param 2 expect block:id. got: blk:0"},
+		{`class Foo; end
+		[1].each do |i| end`, func(sets []*InstructionSet) {
+			sets[2].Instructions[5].Params[2] = "block:0"
+		}, "Invalid bytecode in <ProgramStart> at 5 send: expect instruction set 0 to be Block. got: DefClass"},
+		{`foo(1)`, func(sets []*InstructionSet) {
+			sets[0].Instructions[2].Params[1] = "2"
+		}, "Invalid bytecode in <ProgramStart> at 2 send: pops 3 values, but the stack only has 2"},
+		{`a = 1
+		a`, func(sets []*InstructionSet) {
+			sets[0].Instructions[2].Params[1] = "1"
+		}, "Invalid bytecode in <ProgramStart> at 2 getlocal: index 1 is out of <ProgramStart>'s 1 locals"},
+		{`a = 1
+		[1].each do |i|
+		  a
+		end`, func(sets []*InstructionSet) {
+			sets[0].Instructions[0].Params[0] = "2"
+		}, "Invalid bytecode in <Block:0> at 0 getlocal: depth 2 is deeper than the set's scope"},
+		{`def foo
+		  yield
+		end`, func(sets []*InstructionSet) {
+			sets[0].Instructions = sets[0].Instructions[1:]
+		}, "Invalid bytecode in <Def:foo:0> at 0 invokeblock: pops 1 values, but the stack only has 0"},
+	}
+
+	for i, tt := range tests {
+		sets := generateInstructionSets(tt.input)
+		tt.malform(sets)
+		err := Verify(sets)
+
+		if err == nil {
+			t.Fatalf("At case %d: Expect error %s. got nil", i, tt.expected)
+		}
+
+		if err.Error() != tt.expected {
+			t.Fatalf("At case %d: Expect error:\n%s\ngot:\n%s", i, tt.expected, err.Error())
+		}
+	}
+}
+
+func TestVerifySetErrors(t *testing.T) {
+	sets := generateInstructionSets(`
+	def foo; end
+	def bar; end
+	`)
+
+	sets[1].id = sets[0].id
+
+	if err := Verify(sets); err == nil || err.Error() != "Invalid bytecode in <Def:bar:0>: duplicate id 0" {
+		t.Fatalf("Expect duplicate id error. got: %v", err)
+	}
+
+	sets = generateInstructionSets(`1`)
+	sets = append(sets, sets[0])
+
+	if err := Verify(sets); err == nil || err.Error() != "Invalid bytecode in <ProgramStart>: expect 1 program set. got: 2" {
+		t.Fatalf("Expect program set error. got: %v", err)
+	}
+
+	sets = generateInstructionSets(`
+	[1].each do |i| end
+	`)
+	sets[1].Instructions = append(sets[1].Instructions, sets[1].Instructions[:4]...)
+
+	err := Verify(sets)
+
+	if err == nil || err.Error() != "Invalid bytecode in <ProgramStart> at 6 send: block 0 is already given by another send" {
+		t.Fatalf("Expect block error. got: %v", err)
+	}
+
+	if _, ok := err.(*VerificationError); !ok {
+		t.Fatalf("Expect *VerificationError. got: %T", err)
+	}
+}
+
+func generateInstructionSets(input string) []*InstructionSet {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program, err := p.ParseProgram()
+	if err != nil {
+		panic(err.Message)
+	}
+	g := NewGenerator()
+	g.InitTopLevelScope(program)
+	return g.GenerateInstructions(program.Statements)
+}
@@ -1,34 +1,68 @@
 package compiler
 
 import (
-	"fmt"
+	"github.com/goby-lang/goby/compiler/ast"
 	"github.com/goby-lang/goby/compiler/bytecode"
 	"github.com/goby-lang/goby/compiler/lexer"
 	"github.com/goby-lang/goby/compiler/parser"
 )
 
-// CompileToBytecode compiles input source code into Goby bytecode
+// Optimizer optimizes instructions returned by CompileToInstructions.
+// Its passes can be disabled for debugging the compiler or the vm.
+var Optimizer = bytecode.NewOptimizer()
+
+// CompileToBytecode compiles input source code into Goby bytecode.
+// Syntax errors are returned as Diagnostics.
 func CompileToBytecode(input string) (string, error) {
-	l := lexer.New(input)
-	p := parser.New(l)
-	program, err := p.ParseProgram()
+	program, err := ParseFile("", input)
 	if err != nil {
-		return "", fmt.Errorf(err.Message)
+		return "", err
 	}
 	g := bytecode.NewGenerator()
 	g.InitTopLevelScope(program)
 	return g.GenerateByteCode(program.Statements), nil
 }
 
-// CompileToInstructions compiles input source code into instruction set data structures
+// CompileToInstructions compiles input source code into instruction set data structures.
+// Syntax errors are returned as Diagnostics.
 func CompileToInstructions(input string) ([]*bytecode.InstructionSet, error) {
+	return CompileFileToInstructions("", input)
+}
+
+// CompileFileToInstructions is like CompileToInstructions, but its diagnostics have the file name
+func CompileFileToInstructions(filename, input string) ([]*bytecode.InstructionSet, error) {
+	program, err := ParseFile(filename, input)
+	if err != nil {
+		return nil, err
+	}
+	g := bytecode.NewGenerator()
+	g.InitTopLevelScope(program)
+	sets := g.GenerateInstructions(program.Statements)
+	Optimizer.Optimize(sets)
+	return sets, nil
+}
+
+// CompileInScope is like CompileToInstructions, but the program can use the variables of the table and its upper tables
+func CompileInScope(input string, table *bytecode.LocalTable) ([]*bytecode.InstructionSet, error) {
+	program, err := ParseFile("", input)
+	if err != nil {
+		return nil, err
+	}
+	g := bytecode.NewGenerator()
+	g.InitScope(program, table)
+	sets := g.GenerateInstructions(program.Statements)
+	Optimizer.Optimize(sets)
+	return sets, nil
+}
+
+// ParseFile parses input source code into its AST, for tools like the formatter.
+// Syntax errors are returned as Diagnostics, which have the file name.
+func ParseFile(filename, input string) (*ast.Program, error) {
 	l := lexer.New(input)
 	p := parser.New(l)
 	program, err := p.ParseProgram()
 	if err != nil {
-		return nil, fmt.Errorf(err.Message)
+		return nil, newDiagnostics(filename, input, p.Errors())
 	}
-	g := bytecode.NewGenerator()
-	g.InitTopLevelScope(program)
-	return g.GenerateInstructions(program.Statements), nil
+	return program, nil
 }
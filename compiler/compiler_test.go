@@ -0,0 +1,55 @@
+package compiler
+
+import (
+	"testing"
+)
+
+func TestCompileDiagnostics(t *testing.T) {
+	input := `def foo(a, b
+  a + b
+end
+
+x = 1 +
+	bar(1, 2 3)
+`
+
+	_, err := CompileFileToInstructions("foo.gb", input)
+	ds, ok := err.(Diagnostics)
+
+	if !ok {
+		t.Fatalf("Expect Diagnostics. got: %T", err)
+	}
+
+	if len(ds) != 2 {
+		t.Fatalf("Expect 2 diagnostics. got: %d", len(ds))
+	}
+
+	d := ds[1]
+
+	if d.File != "foo.gb" || d.Line != 6 || d.Column != 11 || d.Kind != "WrongTokenError" || d.Message != "expected next token to be ), got INT instead" {
+		t.Fatalf("Unexpected diagnostic: %+v", d)
+	}
+
+	expected := `foo.gb:2:3: WrongTokenError: expected next token to be ), got IDENT instead
+   2 |   a + b
+     |   ^
+foo.gb:6:11: WrongTokenError: expected next token to be ), got INT instead
+   6 | 	bar(1, 2 3)
+     | 	         ^`
+
+	if err.Error() != expected {
+		t.Fatalf("Expect error message:\n%s\ngot:\n%s", expected, err.Error())
+	}
+}
+
+func TestCompileDiagnosticsUnderlineTokens(t *testing.T) {
+	_, err := CompileToInstructions(`foo = end`)
+
+	expected := `1:7: UnexpectedEndError: unexpected end
+   1 | foo = end
+     |       ^~~`
+
+	if err == nil || err.Error() != expected {
+		t.Fatalf("Expect error message:\n%s\ngot:\n%v", expected, err)
+	}
+}
@@ -0,0 +1,106 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goby-lang/goby/compiler/parser"
+)
+
+// Diagnostic is a syntax error found while compiling a source file
+type Diagnostic struct {
+	File string
+	// Line and Column start from 1, like editors show them
+	Line    int
+	Column  int
+	Kind    string
+	Message string
+	// length is the length of the token the error occurs at, and source is the token's line
+	length int
+	source string
+}
+
+// Diagnostics are all syntax errors of a source file.
+// Its error message shows each of them with a code frame, which underlines where the error occurs:
+//
+//	foo.gb:2:10: WrongTokenError: expected next token to be ), got IDENT instead
+//	   2 | foo(1, 2 bar
+//	     |          ^~~
+type Diagnostics []*Diagnostic
+
+func (ds Diagnostics) Error() string {
+	frames := make([]string, len(ds))
+
+	for i, d := range ds {
+		frames[i] = d.String()
+	}
+
+	return strings.Join(frames, "\n")
+}
+
+// String returns the diagnostic's position, kind and message, followed by its code frame
+func (d *Diagnostic) String() string {
+	position := fmt.Sprintf("%d:%d", d.Line, d.Column)
+
+	if d.File != "" {
+		position = d.File + ":" + position
+	}
+
+	return fmt.Sprintf("%s: %s: %s\n%s", position, d.Kind, d.Message, d.codeFrame())
+}
+
+// Length returns the length of the token the error occurs at, which editors underline
+func (d *Diagnostic) Length() int {
+	return d.length
+}
+
+func (d *Diagnostic) codeFrame() string {
+	gutter := fmt.Sprintf("%4d | ", d.Line)
+	emptyGutter := strings.Repeat(" ", len(gutter)-2) + "| "
+	column := d.Column - 1
+
+	if column > len(d.source) {
+		column = len(d.source)
+	}
+
+	// Tabs are kept, so the caret lines up with the source however wide tabs are shown
+	padding := []byte(d.source[:column])
+
+	for i, c := range padding {
+		if c != '\t' {
+			padding[i] = ' '
+		}
+	}
+
+	underline := "^"
+
+	if d.length > 1 {
+		underline += strings.Repeat("~", d.length-1)
+	}
+
+	return gutter + d.source + "\n" + emptyGutter + string(padding) + underline
+}
+
+func newDiagnostics(filename, input string, errs []*parser.Error) Diagnostics {
+	lines := strings.Split(input, "\n")
+	ds := make(Diagnostics, len(errs))
+
+	for i, err := range errs {
+		d := &Diagnostic{
+			File:    filename,
+			Line:    err.Line + 1,
+			Column:  err.Column + 1,
+			Kind:    err.Kind(),
+			Message: err.Description,
+			length:  err.Length,
+		}
+
+		if err.Line < len(lines) {
+			d.source = strings.TrimRight(lines[err.Line], "\r")
+		}
+
+		ds[i] = d
+	}
+
+	return ds
+}
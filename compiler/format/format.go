@@ -0,0 +1,624 @@
+// Package format prints Goby programs in their canonical style, which `goby fmt` uses.
+package format
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/goby-lang/goby/compiler"
+	"github.com/goby-lang/goby/compiler/ast"
+	"github.com/goby-lang/goby/compiler/lexer"
+	"github.com/goby-lang/goby/compiler/parser"
+	"github.com/goby-lang/goby/compiler/token"
+)
+
+// indentation is the indentation of each block level
+const indentation = "  "
+
+// atom is the precedence of expressions that never need parentheses, like literals and method calls.
+// Arguments without parentheses must end their line, so calls like `foo 1` can't have parentheses either.
+const atom = parser.CALL + 1
+
+// Source formats input source code. Comments and blank lines between statements are kept,
+// other layout follows the canonical style:
+//
+//   - Blocks are indented by 2 spaces, and at most 1 blank line separates statements
+//   - Infix operators are surrounded by spaces, except `::` and `..`
+//   - Hashes are printed like `{ a: 1 }`
+//   - Arrays and hashes are printed in 1 line, unless their first element starts at a new line,
+//     then each element has its own line
+//
+// Parentheses are only kept around arguments, or where operators' precedence needs them.
+// Syntax errors are returned as compiler.Diagnostics.
+func Source(filename, input string) (string, error) {
+	program, err := compiler.ParseFile(filename, input)
+	if err != nil {
+		return "", err
+	}
+
+	p := newPrinter(input)
+	p.printBody(program.Statements, -1, position{line: math.MaxInt32})
+
+	if p.out.Len() > 0 {
+		p.newline()
+	}
+
+	return p.out.String(), nil
+}
+
+type position struct {
+	line   int
+	column int
+}
+
+func positionOf(tok token.Token) position {
+	return position{line: tok.Line, column: tok.Column}
+}
+
+func (pos position) before(other position) bool {
+	return pos.line < other.line || pos.line == other.line && pos.column < other.column
+}
+
+type printer struct {
+	out    bytes.Buffer
+	indent int
+	// atLineStart means current line's indentation isn't written yet, so blank lines don't have it
+	atLineStart bool
+	// afterSymbol means the last written text is a symbol like `:foo`,
+	// which the lexer ends at a space or comma
+	afterSymbol bool
+
+	lines []string
+	// tokens are the source's tokens without comments, and index maps their positions to their indexes
+	tokens []token.Token
+	index  map[position]int
+	// comments are the source's comments, and comment is the index of the first one that isn't printed yet
+	comments []token.Token
+	comment  int
+	// line is the last source line of the last printed statement or comment, for keeping blank lines
+	line int
+}
+
+func newPrinter(input string) *printer {
+	p := &printer{lines: strings.Split(input, "\n"), index: map[position]int{}}
+	l := lexer.New(input)
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type == token.Comment {
+			p.comments = append(p.comments, tok)
+			continue
+		}
+
+		p.index[positionOf(tok)] = len(p.tokens)
+		p.tokens = append(p.tokens, tok)
+	}
+
+	return p
+}
+
+func (p *printer) write(s string) {
+	if p.afterSymbol && s[0] != ' ' && s[0] != ',' {
+		p.out.WriteByte(' ')
+	}
+
+	if p.atLineStart {
+		p.out.WriteString(strings.Repeat(indentation, p.indent))
+		p.atLineStart = false
+	}
+
+	p.out.WriteString(s)
+	p.afterSymbol = false
+}
+
+func (p *printer) newline() {
+	p.out.WriteByte('\n')
+	p.atLineStart = true
+	p.afterSymbol = false
+}
+
+// startLine starts the line of a statement or comment at the source line.
+// A blank line is kept if there is one before it in the source, and blank is true.
+func (p *printer) startLine(line int, blank bool) {
+	if p.out.Len() > 0 {
+		p.newline()
+	}
+
+	if blank && line > p.line+1 {
+		p.newline()
+	}
+}
+
+// printBody prints statements of a program or block, with comments before the end position.
+// header is the line where the block starts, whose comment stays at its end.
+func (p *printer) printBody(stmts []ast.Statement, header int, end position) {
+	var starts []position
+	var body []ast.Statement
+
+	for _, stmt := range stmts {
+		// Semicolons are parsed as empty statements
+		if s, ok := stmt.(*ast.ExpressionStatement); ok && s.Expression == nil {
+			continue
+		}
+
+		body = append(body, stmt)
+		starts = append(starts, positionOf(statementToken(stmt)))
+	}
+
+	starts = append(starts, end)
+	p.line = header
+	p.printTrailingComment(header, starts[0])
+	first := true
+
+	for i, stmt := range body {
+		first = p.printComments(starts[i], first)
+		p.startLine(starts[i].line, !first)
+		first = false
+
+		p.printStatement(stmt)
+		p.line = p.lastLineBefore(starts[i+1])
+		p.printTrailingComment(p.line, starts[i+1])
+	}
+
+	p.printComments(end, first)
+}
+
+// printComments prints comments before the position in their own lines, and returns if nothing is printed in the body yet
+func (p *printer) printComments(pos position, first bool) bool {
+	for ; p.comment < len(p.comments) && positionOf(p.comments[p.comment]).before(pos); p.comment++ {
+		c := p.comments[p.comment]
+		p.startLine(c.Line, !first)
+		p.write(strings.TrimRight(c.Literal, " \t\r"))
+		p.line = c.Line
+		first = false
+	}
+
+	return first
+}
+
+// printTrailingComment prints the comment at the end of the line, if it's before the position
+func (p *printer) printTrailingComment(line int, pos position) {
+	if p.comment == len(p.comments) {
+		return
+	}
+
+	c := p.comments[p.comment]
+
+	if c.Line == line && positionOf(c).before(pos) {
+		p.write(" " + strings.TrimRight(c.Literal, " \t\r"))
+		p.comment++
+	}
+}
+
+// lastLineBefore returns the line where the last token before the position ends
+func (p *printer) lastLineBefore(pos position) int {
+	i := sort.Search(len(p.tokens), func(i int) bool {
+		return !positionOf(p.tokens[i]).before(pos)
+	}) - 1
+
+	if i < 0 {
+		return p.line
+	}
+
+	return p.tokens[i].Line + strings.Count(p.tokens[i].Literal, "\n")
+}
+
+// nextToken returns the token after the given one
+func (p *printer) nextToken(tok token.Token) (token.Token, bool) {
+	i, ok := p.index[positionOf(tok)]
+
+	if !ok || i+1 == len(p.tokens) {
+		return token.Token{}, false
+	}
+
+	return p.tokens[i+1], true
+}
+
+// followedByParen checks if the token, like a method name, is followed by `(`
+func (p *printer) followedByParen(tok token.Token) bool {
+	next, ok := p.nextToken(tok)
+	return ok && next.Type == token.LParen
+}
+
+// opensLines checks if the token, like `[`, is followed by a token at another line
+func (p *printer) opensLines(tok token.Token) bool {
+	next, ok := p.nextToken(tok)
+	return ok && next.Line > tok.Line
+}
+
+func statementToken(stmt ast.Statement) token.Token {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		return stmt.Token
+	case *ast.ReturnStatement:
+		return stmt.Token
+	case *ast.DefStatement:
+		return stmt.Token
+	case *ast.ClassStatement:
+		return stmt.Token
+	case *ast.ModuleStatement:
+		return stmt.Token
+	case *ast.WhileStatement:
+		return stmt.Token
+	case *ast.NextStatement:
+		return stmt.Token
+	case *ast.BreakStatement:
+		return stmt.Token
+	}
+
+	return token.Token{}
+}
+
+func (p *printer) printStatement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		p.printExpression(stmt.Expression)
+	case *ast.ReturnStatement:
+		if stmt.ReturnValue == nil {
+			// `return;` returns the value on the stack, which `return nil` doesn't
+			p.write("return;")
+			return
+		}
+
+		p.write("return ")
+		p.printExpression(stmt.ReturnValue)
+	case *ast.DefStatement:
+		p.write("def ")
+
+		if stmt.Receiver != nil {
+			p.printExpression(stmt.Receiver)
+			p.write(".")
+		}
+
+		p.write(stmt.Name.Value)
+
+		if len(stmt.Parameters) > 0 {
+			p.write("(")
+			p.printExpressions(stmt.Parameters)
+			p.write(")")
+		}
+
+		p.printBlock(stmt.BlockStatement)
+	case *ast.ClassStatement:
+		p.write("class " + stmt.Name.Value)
+
+		if stmt.SuperClass != nil {
+			p.write(" < ")
+			p.printExpression(stmt.SuperClass)
+		}
+
+		p.printBlock(stmt.Body)
+	case *ast.ModuleStatement:
+		p.write("module " + stmt.Name.Value)
+		p.printBlock(stmt.Body)
+	case *ast.WhileStatement:
+		p.write("while ")
+		p.printExpression(stmt.Condition)
+		p.write(" do")
+		p.printBlock(stmt.Body)
+	case *ast.NextStatement:
+		p.write("next")
+	case *ast.BreakStatement:
+		p.write("break")
+	}
+}
+
+// printBlock prints the block's statements in a deeper level, and the `end` keyword if the block isn't closed by `else`
+func (p *printer) printBlock(bs *ast.BlockStatement) {
+	p.indent++
+	p.printBody(bs.Statements, bs.Token.Line, positionOf(bs.End))
+	p.indent--
+
+	if bs.End.Type != token.Else {
+		p.newline()
+		p.write("end")
+	}
+}
+
+func (p *printer) printExpressions(exps []ast.Expression) {
+	for i, exp := range exps {
+		if i > 0 {
+			p.write(", ")
+		}
+
+		p.printExpression(exp)
+	}
+}
+
+// printOperand prints the expression in parentheses if its precedence is lower than min
+func (p *printer) printOperand(exp ast.Expression, min int) {
+	if precedenceOf(exp) >= min {
+		p.printExpression(exp)
+		return
+	}
+
+	p.write("(")
+	p.printExpression(exp)
+	p.write(")")
+}
+
+func precedenceOf(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		if exp.Token.Type == token.ResolutionOperator {
+			return atom
+		}
+
+		return parser.Precedence(exp.Token.Type)
+	case *ast.AssignExpression, *ast.MultiVariableExpression:
+		return parser.ASSIGN
+	case *ast.RangeExpression:
+		return parser.RANGE
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.IfExpression:
+		return parser.LOWEST
+	case *ast.CallExpression:
+		switch exp.Token.Type {
+		case token.Incr, token.Decr:
+			return parser.SUM
+		}
+	}
+
+	return atom
+}
+
+// methodToken returns the token of the call's method name, which follows `.` in calls with receivers
+func (p *printer) methodToken(exp *ast.CallExpression) token.Token {
+	if exp.Token.Type == token.Dot {
+		tok, _ := p.nextToken(exp.Token)
+		return tok
+	}
+
+	return exp.Token
+}
+
+func (p *printer) printExpression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		p.write(exp.Value)
+	case *ast.InstanceVariable:
+		p.write(exp.Value)
+	case *ast.Constant:
+		p.write(exp.Value)
+	case *ast.IntegerLiteral:
+		p.write(exp.Token.Literal)
+	case *ast.StringLiteral:
+		p.printString(exp.Token)
+	case *ast.BooleanExpression:
+		p.write(exp.Token.Literal)
+	case *ast.NilExpression:
+		p.write("nil")
+	case *ast.SelfExpression:
+		p.write("self")
+	case *ast.ArrayExpression:
+		p.printList(exp.Token, "[", "]", len(exp.Elements), func(i int) {
+			p.printExpression(exp.Elements[i])
+		})
+	case *ast.HashExpression:
+		p.printList(exp.Token, "{ ", " }", len(exp.Keys), func(i int) {
+			p.write(exp.Keys[i] + ": ")
+			p.printExpression(exp.Data[exp.Keys[i]])
+		})
+	case *ast.PrefixExpression:
+		p.write(exp.Operator)
+
+		// `- -1` can't be written as `--1`, which is a decrement
+		if right, ok := exp.Right.(*ast.PrefixExpression); ok && right.Operator == exp.Operator {
+			p.write("(")
+			p.printExpression(exp.Right)
+			p.write(")")
+			return
+		}
+
+		p.printOperand(exp.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		p.printInfix(exp)
+	case *ast.RangeExpression:
+		p.printOperand(exp.Start, parser.RANGE)
+		p.write("..")
+		p.printOperand(exp.End, parser.RANGE+1)
+	case *ast.MultiVariableExpression:
+		for i, v := range exp.Variables {
+			if i > 0 {
+				p.write(", ")
+			}
+
+			p.printExpression(v)
+		}
+	case *ast.AssignExpression:
+		p.printAssign(exp)
+	case *ast.IfExpression:
+		p.write("if ")
+		p.printExpression(exp.Condition)
+		p.printBlock(exp.Consequence)
+
+		if exp.Alternative != nil {
+			p.newline()
+			p.write("else")
+			p.printBlock(exp.Alternative)
+		}
+	case *ast.YieldExpression:
+		p.write("yield")
+		p.printArguments(exp.Arguments, p.followedByParen(exp.Token), false)
+	case *ast.CallExpression:
+		p.printCall(exp)
+	}
+}
+
+// printString prints a string literal with its original quotes, or a symbol like `:foo`
+func (p *printer) printString(tok token.Token) {
+	quote := p.lines[tok.Line][tok.Column]
+
+	if quote == ':' {
+		p.write(":" + tok.Literal)
+		p.afterSymbol = true
+		return
+	}
+
+	p.write(string(quote) + tok.Literal + string(quote))
+}
+
+// printList prints elements of an array or hash, which is opened by the token.
+// If its first element starts at a new line, each element has its own line.
+func (p *printer) printList(open token.Token, left, right string, n int, printElement func(i int)) {
+	if n == 0 {
+		p.write(strings.TrimSpace(left) + strings.TrimSpace(right))
+		return
+	}
+
+	if !p.opensLines(open) {
+		p.write(left)
+
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				p.write(", ")
+			}
+
+			printElement(i)
+		}
+
+		p.write(right)
+		return
+	}
+
+	p.write(strings.TrimSpace(left))
+	p.indent++
+
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			p.write(",")
+		}
+
+		p.newline()
+		printElement(i)
+	}
+
+	p.indent--
+	p.newline()
+	p.write(strings.TrimSpace(right))
+}
+
+func (p *printer) printInfix(exp *ast.InfixExpression) {
+	if exp.Token.Type == token.ResolutionOperator {
+		p.printOperand(exp.Left, atom)
+		p.write("::")
+		p.printExpression(exp.Right)
+		return
+	}
+
+	// Infix operators are left associative
+	precedence := parser.Precedence(exp.Token.Type)
+	p.printOperand(exp.Left, precedence)
+	p.write(" " + exp.Operator + " ")
+	p.printOperand(exp.Right, precedence+1)
+}
+
+func (p *printer) printAssign(exp *ast.AssignExpression) {
+	for i, v := range exp.Variables {
+		if i > 0 {
+			p.write(", ")
+		}
+
+		p.printExpression(v)
+	}
+
+	p.write(" " + exp.Token.Literal + " ")
+	value := exp.Value
+
+	// Value of `a += 1` is `a + 1`
+	if infix, ok := value.(*ast.InfixExpression); ok && exp.Token.Type != token.Assign {
+		value = infix.Right
+	}
+
+	p.printOperand(value, parser.ASSIGN+1)
+}
+
+func (p *printer) printCall(exp *ast.CallExpression) {
+	args := exp.Arguments
+
+	switch exp.Token.Type {
+	case token.Incr, token.Decr:
+		p.printOperand(exp.Receiver, parser.SUM)
+		p.write(exp.Method)
+		return
+	case token.LBracket:
+		p.printOperand(exp.Receiver, atom)
+		p.write("[")
+
+		if len(args) > 0 {
+			p.printExpression(args[0])
+		}
+
+		p.write("]")
+
+		if exp.Method == "[]=" {
+			p.write(" = ")
+			p.printOperand(args[1], parser.NORMAL+1)
+		}
+
+		return
+	case token.Dot:
+		p.printOperand(exp.Receiver, atom)
+		p.write(".")
+
+		// Setter method call like `a.b = 1`
+		if strings.HasSuffix(exp.Method, "=") {
+			p.write(strings.TrimSuffix(exp.Method, "="))
+			p.printArguments(args[:len(args)-1], p.followedByParen(p.methodToken(exp)), false)
+			p.write(" = ")
+			p.printOperand(args[len(args)-1], parser.NORMAL+1)
+			return
+		}
+
+		p.write(exp.Method)
+		p.printArguments(args, p.followedByParen(p.methodToken(exp)), false)
+	default:
+		// Calls without parentheses and arguments are identifiers, like `foo`
+		p.write(exp.Method)
+		p.printArguments(args, p.followedByParen(p.methodToken(exp)), exp.Block == nil)
+	}
+
+	if exp.Block != nil {
+		p.write(" do")
+
+		if len(exp.BlockArguments) > 0 {
+			p.write(" |")
+
+			for i, arg := range exp.BlockArguments {
+				if i > 0 {
+					p.write(", ")
+				}
+
+				p.write(arg.Value)
+			}
+
+			p.write("|")
+		}
+
+		p.printBlock(exp.Block)
+	}
+}
+
+// printArguments prints arguments in parentheses if they have them in the source,
+// empty parentheses are only printed if required is true
+func (p *printer) printArguments(args []ast.Expression, parens, required bool) {
+	if len(args) == 0 {
+		if required {
+			p.write("()")
+		}
+
+		return
+	}
+
+	if !parens {
+		p.write(" ")
+		p.printExpressions(args)
+		return
+	}
+
+	p.write("(")
+	p.printExpressions(args)
+	p.write(")")
+}
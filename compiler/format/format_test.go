@@ -0,0 +1,245 @@
+package format
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goby-lang/goby/compiler"
+	"github.com/goby-lang/goby/compiler/lexer"
+	"github.com/goby-lang/goby/compiler/token"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`
+class Foo<Bar
+    def set( x,y=1 )
+     @x=x+y*2
+    end
+  def self.get;@x;end
+end
+`, `class Foo < Bar
+  def set(x, y = 1)
+    @x = x + y * 2
+  end
+  def self.get
+    @x
+  end
+end
+`},
+		{`a = (1 + 2) * 3
+b = 1 - (2 - 3)
+c = (1 - 2) - 3
+d = -(a + b).to_s
+e = - -1
+f = Net::HTTP::Client.new(1..(2 + 3))
+g = !(a == b) && (c || d)
+`, `a = (1 + 2) * 3
+b = 1 - (2 - 3)
+c = 1 - 2 - 3
+d = -(a + b).to_s
+e = -(-1)
+f = Net::HTTP::Client.new(1..2 + 3)
+g = !(a == b) && (c || d)
+`},
+		{`# leading
+
+
+# second group
+def foo # header
+  # first
+
+  bar   # trailing
+
+
+  # before end
+
+end # after end
+x = 1 # x
+`, `# leading
+
+# second group
+def foo # header
+  # first
+
+  bar # trailing
+
+  # before end
+end # after end
+x = 1 # x
+`},
+		{`h = {a: 1,   b: [1,2]}
+a = [
+  1, 2,
+    3]
+e = {  }
+f = foo({
+  a: [], b: {c: 1}
+})
+`, `h = { a: 1, b: [1, 2] }
+a = [
+  1,
+  2,
+  3
+]
+e = {}
+f = foo({
+  a: [],
+  b: { c: 1 }
+})
+`},
+		{`foo.each do |a,b| puts a
+end
+bar() do
+    yield(1)
+end
+thread do; end
+i+=1;x.y=2
+h["a"]=3
+i++
+attr_reader   :port,:host
+puts 'single' + "double"
+`, `foo.each do |a, b|
+  puts a
+end
+bar do
+  yield(1)
+end
+thread do
+end
+i += 1
+x.y = 2
+h["a"] = 3
+i++
+attr_reader :port, :host
+puts 'single' + "double"
+`},
+		{`if a>1
+b
+else
+   # otherwise
+c
+end
+while i<3 do
+  if i==1
+  next
+  end
+i++
+end
+`, `if a > 1
+  b
+else
+  # otherwise
+  c
+end
+while i < 3 do
+  if i == 1
+    next
+  end
+  i++
+end
+`},
+		{`x = "multiple
+lines"
+
+foo() # comment
+`, `x = "multiple
+lines"
+
+foo() # comment
+`},
+		{``, ``},
+	}
+
+	for i, tt := range tests {
+		output, err := Source("", tt.input)
+
+		if err != nil {
+			t.Fatalf("At case %d: %s", i, err.Error())
+		}
+
+		if output != tt.expected {
+			t.Fatalf("At case %d, expect output:\n%s\ngot:\n%s", i, tt.expected, output)
+		}
+
+		expected, _ := compiler.CompileToBytecode(tt.input)
+		bytecode, _ := compiler.CompileToBytecode(output)
+
+		if bytecode != expected {
+			t.Fatalf("At case %d, expect bytecode:\n%s\ngot:\n%s", i, expected, bytecode)
+		}
+	}
+}
+
+func TestSourceSyntaxError(t *testing.T) {
+	_, err := Source("foo.gb", "def foo(a\nend")
+
+	if _, ok := err.(compiler.Diagnostics); !ok {
+		t.Fatalf("Expect Diagnostics. got: %T", err)
+	}
+}
+
+// TestSourceFiles formats every Goby file under samples and lib, and checks that
+// the output keeps their comments and bytecode, and doesn't change if it's formatted again
+func TestSourceFiles(t *testing.T) {
+	for _, dir := range []string{"../../samples", "../../lib"} {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || filepath.Ext(path) != ".gb" {
+				return err
+			}
+
+			input, err := ioutil.ReadFile(path)
+
+			if err != nil {
+				return err
+			}
+
+			output, err := Source(path, string(input))
+
+			if err != nil {
+				t.Fatalf("Can't format %s: %s", path, err.Error())
+			}
+
+			again, _ := Source(path, output)
+
+			if again != output {
+				t.Fatalf("Formatting %s isn't idempotent. formatted once:\n%s\ntwice:\n%s", path, output, again)
+			}
+
+			expected, _ := compiler.CompileToBytecode(string(input))
+			bytecode, err := compiler.CompileToBytecode(output)
+
+			if err != nil || bytecode != expected {
+				t.Fatalf("Formatted %s has different bytecode:\n%s", path, output)
+			}
+
+			if countComments(output) != countComments(string(input)) {
+				t.Fatalf("Formatted %s lost comments:\n%s", path, output)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+}
+
+func countComments(input string) int {
+	n := 0
+	l := lexer.New(input)
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type == token.Comment {
+			n++
+		}
+	}
+
+	return n
+}
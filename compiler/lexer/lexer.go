@@ -1,6 +1,8 @@
 package lexer
 
 import (
+	"strings"
+
 	"github.com/goby-lang/goby/compiler/token"
 	"github.com/looplab/fsm"
 )
@@ -12,7 +14,10 @@ type Lexer struct {
 	readPosition int
 	ch           byte
 	line         int
-	FSM          *fsm.FSM
+	// lineStart is the position where current line starts, and tokenColumn is current token's column
+	lineStart   int
+	tokenColumn int
+	FSM         *fsm.FSM
 }
 
 // New initializes a new lexer with input string
@@ -39,16 +44,33 @@ func New(input string) *Lexer {
 
 // NextToken makes lexer tokenize next character(s)
 func (l *Lexer) NextToken() token.Token {
+	tok := l.readToken()
+	tok.Column = l.tokenColumn
+
+	return tok
+}
+
+func (l *Lexer) readToken() token.Token {
 
 	var tok token.Token
 	l.resetNosymbol()
 
 	l.skipWhitespace()
+	l.tokenColumn = l.position - l.lineStart
 	switch l.ch {
 	case '"', byte('\''):
-		tok.Literal = l.readString(l.ch)
-		tok.Type = token.String
+		// Strings can have multiple lines, so the token's line is where it starts
 		tok.Line = l.line
+		quote := l.ch
+		literal, terminated := l.readString(quote)
+
+		// Unterminated strings run to the end of input, so the parser reports them at their opening quotes
+		if !terminated {
+			return token.Token{Type: token.Illegal, Literal: string(quote), Line: tok.Line}
+		}
+
+		tok.Literal = literal
+		tok.Type = token.String
 		return tok
 	case '=':
 		if l.peekChar() == '=' {
@@ -202,6 +224,8 @@ func (l *Lexer) NextToken() token.Token {
 		tok.Literal = ""
 		tok.Type = token.EOF
 		tok.Line = l.line
+		// Following tokens are EOF too, so we stay at the end of input
+		return tok
 	default:
 		if isLetter(l.ch) {
 			if 'A' <= l.ch && l.ch <= 'Z' {
@@ -241,7 +265,10 @@ func (l *Lexer) NextToken() token.Token {
 				return tok
 			}
 
-			return newToken(token.Illegal, l.ch, l.line)
+			// `@` is skipped like other illegal characters, so lexing goes on after it
+			tok = newToken(token.Illegal, l.ch, l.line)
+			l.readChar()
+			return tok
 		} else if isDigit(l.ch) {
 			tok.Literal = l.readNumber()
 			tok.Type = token.Int
@@ -260,6 +287,7 @@ func (l *Lexer) skipWhitespace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\r' || l.ch == '\n' {
 		if l.ch == '\n' {
 			l.line++
+			l.lineStart = l.readPosition
 		}
 		l.readChar()
 	}
@@ -306,25 +334,30 @@ func (l *Lexer) readInstanceVariable() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readString(ch byte) string {
+// readString reads a string quoted by ch, it returns false if input ends before the closing quote
+func (l *Lexer) readString(ch byte) (string, bool) {
 	l.readChar()
 
-	// Strings like "" or ''
-	if l.ch == ch {
-		l.readChar()
-		return ""
-	}
-
 	position := l.position // currently at string's first letter
 
-	for l.peekChar() != ch && l.peekChar() != 0 {
+	for l.ch != ch && l.ch != 0 {
 		l.readChar()
 	}
 
-	l.readChar()                           // currently at string's last letter
 	result := l.input[position:l.position] // get full string
-	l.readChar()                           // move to string's later quote
-	return result
+	terminated := l.ch == ch
+
+	if terminated {
+		l.readChar() // move after string's later quote
+	}
+
+	// Lines in strings are counted too, so following tokens have right positions
+	if i := strings.LastIndex(result, "\n"); i != -1 {
+		l.line += strings.Count(result, "\n")
+		l.lineStart = position + i + 1
+	}
+
+	return result, terminated
 }
 
 func (l *Lexer) readSymbol() string {
@@ -434,3 +434,83 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenColumn(t *testing.T) {
+	input := `foo = bar(1, "s")
+  @a += :sym
+	end # comment
+x = "a
+bc" + 'd'`
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"foo", 0, 0},
+		{"=", 0, 4},
+		{"bar", 0, 6},
+		{"(", 0, 9},
+		{"1", 0, 10},
+		{",", 0, 11},
+		{"s", 0, 13},
+		{")", 0, 16},
+		{"@a", 1, 2},
+		{"+=", 1, 5},
+		{"sym", 1, 8},
+		{"end", 2, 1},
+		{"# comment", 2, 5},
+		{"x", 3, 0},
+		{"=", 3, 2},
+		{"a\nbc", 3, 4},
+		{"+", 4, 4},
+		{"d", 4, 6},
+		{"", 4, 9},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d", i, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestUnterminatedString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{`y = "`, []token.Token{
+			{Type: token.Ident, Literal: "y", Line: 0, Column: 0},
+			{Type: token.Assign, Literal: "=", Line: 0, Column: 2},
+			{Type: token.Illegal, Literal: `"`, Line: 0, Column: 4},
+			{Type: token.EOF, Literal: "", Line: 0, Column: 5},
+		}},
+		{"x = ''\ny = 'a\nb", []token.Token{
+			{Type: token.Ident, Literal: "x", Line: 0, Column: 0},
+			{Type: token.Assign, Literal: "=", Line: 0, Column: 2},
+			{Type: token.String, Literal: "", Line: 0, Column: 4},
+			{Type: token.Ident, Literal: "y", Line: 1, Column: 0},
+			{Type: token.Assign, Literal: "=", Line: 1, Column: 2},
+			{Type: token.Illegal, Literal: "'", Line: 1, Column: 4},
+			{Type: token.EOF, Literal: "", Line: 2, Column: 1},
+		}},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+
+		for j, expected := range tt.expected {
+			if tok := l.NextToken(); tok != expected {
+				t.Fatalf("At case %d token %d expect %+v. got: %+v", i, j, expected, tok)
+			}
+		}
+	}
+}
@@ -65,6 +65,15 @@ const (
 	CALL
 )
 
+// Precedence returns the precedence of an infix operator token, like SUM for `+`
+func Precedence(t token.Type) int {
+	if p, ok := precedence[t]; ok {
+		return p
+	}
+
+	return NORMAL
+}
+
 type (
 	prefixParseFn func() ast.Expression
 	infixParseFn  func(ast.Expression) ast.Expression
@@ -78,8 +87,9 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	}
 
 	if p.curTokenIs(token.Ident) && p.fsm.Is(normal) {
+		// Calls with blocks can be followed by other calls, like `foo do ... end.bar`
 		if p.peekTokenIs(token.Do) {
-			return p.parseCallExpressionWithoutParenAndReceiver(p.curToken)
+			return p.parseInfixExpressions(precedence, p.parseCallExpressionWithoutParenAndReceiver(p.curToken))
 		}
 
 		/*
@@ -108,8 +118,11 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		}
 	}
 
-	leftExp := parseFn()
+	return p.parseInfixExpressions(precedence, parseFn())
+}
 
+// parseInfixExpressions parses operators and calls following the expression, which bind tighter than the precedence
+func (p *Parser) parseInfixExpressions(precedence int, leftExp ast.Expression) ast.Expression {
 	for !p.peekTokenIs(token.Semicolon) && precedence < p.peekPrecedence() && p.peekTokenAtSameLine() {
 
 		infixFn := p.infixParseFns[p.peekToken.Type]
@@ -192,35 +205,29 @@ func (p *Parser) parsePostfixExpression(receiver ast.Expression) ast.Expression
 }
 
 func (p *Parser) parseHashExpression() ast.Expression {
-	hash := &ast.HashExpression{Token: p.curToken}
-	hash.Data = p.parseHashPairs()
+	hash := &ast.HashExpression{Token: p.curToken, Data: map[string]ast.Expression{}}
+	p.parseHashPairs(hash)
 	return hash
 }
 
-func (p *Parser) parseHashPairs() map[string]ast.Expression {
-	pairs := map[string]ast.Expression{}
-
+func (p *Parser) parseHashPairs(hash *ast.HashExpression) {
 	if p.peekTokenIs(token.RBrace) {
 		p.nextToken() // '}'
-		return pairs
+		return
 	}
 
-	p.parseHashPair(pairs)
+	p.parseHashPair(hash)
 
 	for p.peekTokenIs(token.Comma) {
 		p.nextToken()
 
-		p.parseHashPair(pairs)
-	}
-
-	if !p.expectPeek(token.RBrace) {
-		return nil
+		p.parseHashPair(hash)
 	}
 
-	return pairs
+	p.expectPeek(token.RBrace)
 }
 
-func (p *Parser) parseHashPair(pairs map[string]ast.Expression) {
+func (p *Parser) parseHashPair(hash *ast.HashExpression) {
 	var key string
 	var value ast.Expression
 
@@ -236,7 +243,12 @@ func (p *Parser) parseHashPair(pairs map[string]ast.Expression) {
 
 	p.nextToken()
 	value = p.parseExpression(NORMAL)
-	pairs[key] = value
+
+	if _, ok := hash.Data[key]; !ok {
+		hash.Keys = append(hash.Keys, key)
+	}
+
+	hash.Data[key] = value
 }
 
 func (p *Parser) parseArrayExpression() ast.Expression {
@@ -337,7 +349,11 @@ func (p *Parser) parseAssignExpression(v ast.Expression) ast.Expression {
 	case *ast.MultiVariableExpression:
 		exp.Variables = v.Variables
 	default:
-		p.error = &Error{Message: fmt.Sprintf("Can't assign value to %s. Line: %d", v.String(), p.curToken.Line), errType: InvalidAssignmentError}
+		// Targets that failed to parse, like `1 / @`, already have their errors
+		if len(p.errors) == p.recovered {
+			desc := fmt.Sprintf("Can't assign value to %s", v.String())
+			p.addError(InvalidAssignmentError, p.curToken, desc, fmt.Sprintf("%s. Line: %d", desc, p.curToken.Line))
+		}
 	}
 
 	if len(exp.Variables) == 1 {
@@ -349,7 +365,7 @@ func (p *Parser) parseAssignExpression(v ast.Expression) ast.Expression {
 			p.nextToken()
 			value = p.parseExpression(precedence)
 		case token.MinusEq, token.PlusEq, token.OrEq:
-			tok = token.Token{Type: token.Assign, Literal: "=", Line: p.curToken.Line}
+			tok = p.curToken
 
 			// Syntax Surgar: Assignment with operator case
 			infixOperator := token.Token{Line: p.curToken.Line}
@@ -374,7 +390,8 @@ func (p *Parser) parseAssignExpression(v ast.Expression) ast.Expression {
 				Right:    p.parseExpression(LOWEST),
 			}
 		default:
-			p.error = &Error{errType: UnexpectedTokenError, Message: fmt.Sprintf("Unexpect token '%s' for assgin expression", p.curToken.Literal)}
+			msg := fmt.Sprintf("Unexpect token '%s' for assgin expression", p.curToken.Literal)
+			p.addError(UnexpectedTokenError, p.curToken, msg, msg)
 		}
 	} else {
 		tok = p.curToken
@@ -426,7 +443,8 @@ func (p *Parser) parseCallExpressionWithoutParenAndReceiver(methodToken token.To
 	// foo <- method token     x <- current token
 	exp := &ast.CallExpression{Token: methodToken, Receiver: self, Method: methodToken.Literal}
 
-	if p.curToken.Line == methodToken.Line { // foo x
+	// current token is the method token itself if there's no argument, like `foo do`
+	if p.curToken.Line == methodToken.Line && p.curToken != methodToken { // foo x
 		exp.Arguments = p.parseCallArgumentsWithoutParens()
 	}
 
@@ -441,8 +459,16 @@ func (p *Parser) parseCallExpressionWithoutParenAndReceiver(methodToken token.To
 }
 
 func (p *Parser) parseCallExpressionWithParen(receiver ast.Expression) ast.Expression {
+	m, ok := receiver.(*ast.Identifier)
+
+	// Only method names can be followed by arguments, `true (1)` isn't a call
+	if !ok {
+		msg := fmt.Sprintf("unexpected %s Line: %d", p.curToken.Literal, p.curToken.Line)
+		p.addError(UnexpectedTokenError, p.curToken, "unexpected "+p.curToken.Literal, msg)
+		return nil
+	}
+
 	p.fsm.Event(parseFuncCall)
-	m := receiver.(*ast.Identifier)
 	mn := m.Value
 
 	// real receiver is self
@@ -567,7 +593,8 @@ func (p *Parser) parseCallArgumentsWithoutParens() []ast.Expression {
 		args = append(args, p.parseExpression(NORMAL))
 	}
 
-	if p.peekTokenAtSameLine() {
+	// Arguments can be followed by a block, like `foo x do ... end`
+	if p.peekTokenAtSameLine() && !p.peekTokenIs(token.Do) {
 		return nil
 	}
 	return args
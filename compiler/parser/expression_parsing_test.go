@@ -3,6 +3,7 @@ package parser
 import (
 	"github.com/goby-lang/goby/compiler/ast"
 	"github.com/goby-lang/goby/compiler/lexer"
+	"strings"
 	"testing"
 )
 
@@ -115,6 +116,24 @@ func TestHashExpression(t *testing.T) {
 	}
 }
 
+func TestHashExpressionKeys(t *testing.T) {
+	l := lexer.New(`{ b: 1, a: 2, c: 3, a: 4 }`)
+	p := New(l)
+	program, err := p.ParseProgram()
+
+	if err != nil {
+		t.Fatal(err.Message)
+	}
+
+	hash := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.HashExpression)
+
+	if strings.Join(hash.Keys, ",") != "b,a,c" {
+		t.Fatalf("Expect hash keys to be in source order. got=%v", hash.Keys)
+	}
+
+	testIntegerLiteral(t, hash.Data["a"], 4)
+}
+
 func TestHashAccessExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -659,6 +678,61 @@ func TestCallExpressionWithBlock(t *testing.T) {
 	testMethodName(t, exp, "puts")
 }
 
+func TestCallExpressionAfterBlock(t *testing.T) {
+	input := `
+	expect do
+	  foo
+	end.to raise_error(Error)
+	`
+	l := lexer.New(input)
+	p := New(l)
+	program, err := p.ParseProgram()
+
+	if err != nil {
+		t.Fatal(err.Message)
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	callExpression := stmt.Expression.(*ast.CallExpression)
+	testMethodName(t, callExpression, "to")
+	testMethodName(t, callExpression.Arguments[0], "raise_error")
+
+	receiver := callExpression.Receiver.(*ast.CallExpression)
+	testMethodName(t, receiver, "expect")
+
+	exp := receiver.Block.Statements[0].(*ast.ExpressionStatement).Expression
+	testIdentifier(t, exp, "foo")
+}
+
+func TestCallExpressionWithoutParensWithBlock(t *testing.T) {
+	input := `
+	describe Foo, "bar" do
+	  baz
+	end
+	`
+	l := lexer.New(input)
+	p := New(l)
+	program, err := p.ParseProgram()
+
+	if err != nil {
+		t.Fatal(err.Message)
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	callExpression := stmt.Expression.(*ast.CallExpression)
+	testMethodName(t, callExpression, "describe")
+
+	if len(callExpression.Arguments) != 2 {
+		t.Fatalf("Expect 2 arguments. got: %d", len(callExpression.Arguments))
+	}
+
+	testConstant(t, callExpression.Arguments[0], "Foo")
+	testStringLiteral(t, callExpression.Arguments[1], "bar")
+
+	exp := callExpression.Block.Statements[0].(*ast.ExpressionStatement).Expression
+	testIdentifier(t, exp, "baz")
+}
+
 func TestAssignInfixExpressionWithLiteralValue(t *testing.T) {
 	tests := []struct {
 		input              string
@@ -23,18 +23,41 @@ const (
 	MethodDefinitionError
 	// InvalidAssignmentError means user assigns value to wrong type of expressions
 	InvalidAssignmentError
+	// UnterminatedStringError means a string isn't closed before the end of input
+	UnterminatedStringError
 )
 
 // Error represents parser's parsing error
 type Error struct {
 	// Message contains the readable message of error
 	Message string
+	// Description is the message without the error's position
+	Description string
+	// Line and Column locate the token where the error occurs, and Length is the token's length
+	Line    int
+	Column  int
+	Length  int
 	errType int
 }
 
-// IsEOF checks if error is end of file error
+var errorKinds = map[int]string{
+	EndOfFileError:          "EndOfFileError",
+	WrongTokenError:         "WrongTokenError",
+	UnexpectedTokenError:    "UnexpectedTokenError",
+	UnexpectedEndError:      "UnexpectedEndError",
+	MethodDefinitionError:   "MethodDefinitionError",
+	InvalidAssignmentError:  "InvalidAssignmentError",
+	UnterminatedStringError: "UnterminatedStringError",
+}
+
+// Kind returns the name of the error's type, like `WrongTokenError`
+func (e *Error) Kind() string {
+	return errorKinds[e.errType]
+}
+
+// IsEOF checks if error is end of file error, including the end of a string that isn't closed
 func (e *Error) IsEOF() bool {
-	return e.errType == EndOfFileError
+	return e.errType == EndOfFileError || e.errType == UnterminatedStringError
 }
 
 // IsUnexpectedEnd checks if error is unexpected "end" keyword error
@@ -46,6 +69,15 @@ func (e *Error) IsUnexpectedEnd() bool {
 type Parser struct {
 	Lexer *lexer.Lexer
 	error *Error
+	// errors are all errors of the program, and recovered is the number of them the parser has recovered from
+	errors    []*Error
+	recovered int
+	// blockDepth is the number of blocks opened by tokens up to curToken that aren't closed yet,
+	// and curDepthChange is how curToken changed it. They let the parser skip erroneous blocks.
+	blockDepth     int
+	curDepthChange int
+	// whileLine is the line of the last `while`, whose `do` doesn't open another block
+	whileLine int
 
 	curToken  token.Token
 	peekToken token.Token
@@ -134,31 +166,102 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
-// ParseProgram update program statements and return program
+// ParseProgram update program statements and return program.
+// When the program has syntax errors, the parser skips erroneous statements to find the rest of them,
+// which are returned by Errors. The returned error is the one that stops the first erroneous statement.
 func (p *Parser) ParseProgram() (*ast.Program, *Error) {
 	p.error = nil
+	p.errors = nil
+	p.recovered = 0
+	p.blockDepth = 0
+	p.whileLine = -1
 	// Read two tokens, so curToken and peekToken are both set.
 	p.nextToken()
 	p.nextToken()
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 
+	var err *Error
+
 	for !p.curTokenIs(token.EOF) {
+		start := p.curToken
+		depth, errs := p.statementDepth(), len(p.errors)
 		stmt := p.parseStatement()
 
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
+
+		if p.error != nil && err == nil {
+			err = p.error
+		}
+
+		p.synchronize(depth, errs)
 		p.nextToken()
 
-		if p.error != nil {
-			return nil, p.error
+		// Recovery can't go on if the lexer is stuck at the same token
+		if p.curToken == start && err != nil {
+			break
 		}
 	}
 
+	if err != nil {
+		return nil, err
+	}
+
 	return program, nil
 }
 
+// Errors returns all errors found by the last ParseProgram call
+func (p *Parser) Errors() []*Error {
+	return p.errors
+}
+
+// addError records an error at the token. Parsing goes on after errors, so later ones can be found too.
+func (p *Parser) addError(errType int, tok token.Token, description, message string) {
+	// The rest of input after an unterminated string belongs to the string, so it has no other errors
+	if n := len(p.errors); n > 0 && p.errors[n-1].errType == UnterminatedStringError {
+		return
+	}
+
+	p.error = &Error{Message: message, Description: description, Line: tok.Line, Column: tok.Column, Length: len(tok.Literal), errType: errType}
+
+	// An error can make following parsing functions fail at the same token
+	if n := len(p.errors); n > 0 && p.errors[n-1].Line == tok.Line && p.errors[n-1].Column == tok.Column {
+		return
+	}
+
+	p.errors = append(p.errors, p.error)
+}
+
+// synchronize skips the rest of current statement if it has new errors,
+// so parsing goes on from the next line or the token after a semicolon.
+// If the statement stopped in a block, like a method definition with wrong parameters,
+// the block is skipped to its `end` first. depth is the block depth where the statement starts,
+// and errs is the number of errors before it. Errors of an enclosing statement are left to that statement,
+// and the `end` of the block the statement is in isn't skipped, so the block still ends at its depth.
+func (p *Parser) synchronize(depth, errs int) {
+	if len(p.errors) == errs {
+		return
+	}
+
+	p.recovered = len(p.errors)
+
+	for p.blockDepth > depth && !p.curTokenIs(token.EOF) {
+		p.nextToken()
+	}
+
+	line := p.curToken.Line
+
+	for !p.curTokenIs(token.Semicolon) && !p.peekTokenIs(token.EOF) && p.peekToken.Line == line {
+		if p.peekTokenIs(token.End) && p.blockDepth == depth && depth > 0 {
+			break
+		}
+
+		p.nextToken()
+	}
+}
+
 func (p *Parser) parseSemicolon() ast.Expression {
 	return nil
 }
@@ -182,6 +285,31 @@ func (p *Parser) curPrecedence() int {
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.Lexer.NextToken()
+
+	switch p.curToken.Type {
+	case token.While:
+		p.whileLine = p.curToken.Line
+		p.curDepthChange = 1
+	case token.Def, token.Class, token.Module, token.If:
+		p.curDepthChange = 1
+	case token.Do:
+		if p.curToken.Line == p.whileLine {
+			p.curDepthChange = 0
+		} else {
+			p.curDepthChange = 1
+		}
+	case token.End:
+		p.curDepthChange = -1
+	default:
+		p.curDepthChange = 0
+	}
+
+	p.blockDepth += p.curDepthChange
+}
+
+// statementDepth returns the block depth before curToken, where a statement starting at it begins
+func (p *Parser) statementDepth() int {
+	return p.blockDepth - p.curDepthChange
 }
 
 func (p *Parser) curTokenIs(t token.Type) bool {
@@ -210,17 +338,30 @@ func (p *Parser) registerInfix(tokenType token.Type, fn infixParseFn) {
 }
 
 func (p *Parser) peekError(t token.Type) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead. Line: %d", t, p.peekToken.Type, p.peekToken.Line)
-	p.error = &Error{Message: msg, errType: WrongTokenError}
+	desc := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
+	p.addError(WrongTokenError, p.peekToken, desc, fmt.Sprintf("%s. Line: %d", desc, p.peekToken.Line))
 }
 
 func (p *Parser) noPrefixParseFnError(t token.Type) {
 	msg := fmt.Sprintf("unexpected %s Line: %d", p.curToken.Literal, p.curToken.Line)
+	desc := fmt.Sprintf("unexpected %s", p.curToken.Literal)
+
+	if t == token.EOF {
+		desc = "unexpected EOF"
+	}
+
+	// The lexer returns an unterminated string's opening quote as an illegal token
+	if t == token.Illegal && (p.curToken.Literal == `"` || p.curToken.Literal == "'") {
+		desc = "unterminated string"
+		msg = fmt.Sprintf("unterminated string Line: %d", p.curToken.Line)
+		p.addError(UnterminatedStringError, p.curToken, desc, msg)
+		return
+	}
 
 	if t == token.End {
-		p.error = &Error{Message: msg, errType: UnexpectedEndError}
+		p.addError(UnexpectedEndError, p.curToken, desc, msg)
 	} else {
-		p.error = &Error{Message: msg, errType: UnexpectedTokenError}
+		p.addError(UnexpectedTokenError, p.curToken, desc, msg)
 	}
 }
 
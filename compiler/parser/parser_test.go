@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/goby-lang/goby/compiler/ast"
 	"github.com/goby-lang/goby/compiler/lexer"
+	"strings"
 	"testing"
 )
 
@@ -364,3 +365,106 @@ func testLiteralExpression(
 	t.Errorf("type of exp not handled. got=%T", exp)
 	return false
 }
+
+func TestParseErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`
+		def foo(a, b
+		  a + b
+		end
+
+		x = 1 +
+		  bar(1, 2 3)
+		`, []string{
+			"2:4 WrongTokenError: expected next token to be ), got IDENT instead",
+			"6:13 WrongTokenError: expected next token to be ), got INT instead",
+		}},
+		{`
+		class Foo
+		  def bar
+		    @a = )
+		    @b = 1
+		  end
+
+		  def baz x
+		  end
+		end
+		end
+		`, []string{
+			"3:11 UnexpectedTokenError: unexpected )",
+			"7:12 MethodDefinitionError: Please add parentheses around method \"baz\"'s parameters",
+			"10:2 UnexpectedEndError: unexpected end",
+		}},
+		{`
+		1 = 2; foo(
+		`, []string{
+			"1:4 InvalidAssignmentError: Can't assign value to 1",
+			"2:2 UnexpectedTokenError: unexpected EOF",
+		}},
+		{`
+		x = 1
+		@
+		y = 2 +
+		`, []string{
+			"2:2 UnexpectedTokenError: unexpected @",
+			"4:2 UnexpectedTokenError: unexpected EOF",
+		}},
+		{"x = 1\n@", []string{
+			"1:0 UnexpectedTokenError: unexpected @",
+		}},
+		{"x = 1\ny = \"", []string{
+			"1:4 UnterminatedStringError: unterminated string",
+		}},
+		{"puts('a)\nx = 1", []string{
+			"0:5 UnterminatedStringError: unterminated string",
+		}},
+		{"x = 1 / @ = 2", []string{
+			"0:8 UnexpectedTokenError: unexpected @",
+		}},
+		{`
+		true (1); "a" (2)
+		`, []string{
+			"1:7 UnexpectedTokenError: unexpected (",
+			"1:16 UnexpectedTokenError: unexpected (",
+		}},
+		{`
+		puts(blk do 7 end)
+		puts(1)
+		`, []string{
+			"1:11 WrongTokenError: expected next token to be ), got DO instead",
+		}},
+		{`
+		foo do x = ) end
+		bar(1 2)
+		`, []string{
+			"1:13 UnexpectedTokenError: unexpected )",
+			"2:8 WrongTokenError: expected next token to be ), got INT instead",
+		}},
+	}
+
+	for i, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program, err := p.ParseProgram()
+
+		if program != nil || err == nil {
+			t.Fatalf("At case %d: Expect parser to fail", i)
+		}
+
+		var actual []string
+
+		for _, e := range p.Errors() {
+			actual = append(actual, fmt.Sprintf("%d:%d %s: %s", e.Line, e.Column, e.Kind(), e.Description))
+		}
+
+		if len(actual) != len(tt.expected) {
+			t.Fatalf("At case %d: Expect %d errors. got: %d\n%s", i, len(tt.expected), len(actual), strings.Join(actual, "\n"))
+		}
+
+		if strings.Join(actual, "\n") != strings.Join(tt.expected, "\n") {
+			t.Fatalf("At case %d: Expect errors:\n%s\ngot:\n%s", i, strings.Join(tt.expected, "\n"), strings.Join(actual, "\n"))
+		}
+	}
+}
@@ -45,7 +45,8 @@ func (p *Parser) parseDefMethodStatement() *ast.DefStatement {
 		case token.Self:
 			stmt.Receiver = &ast.SelfExpression{Token: p.curToken}
 		default:
-			p.error = &Error{Message: fmt.Sprintf("Invalid method receiver: %s. Line: %d", p.curToken.Literal, p.curToken.Line), errType: MethodDefinitionError}
+			desc := fmt.Sprintf("Invalid method receiver: %s", p.curToken.Literal)
+			p.addError(MethodDefinitionError, p.curToken, desc, fmt.Sprintf("%s. Line: %d", desc, p.curToken.Line))
 		}
 
 		p.nextToken() // .
@@ -63,7 +64,8 @@ func (p *Parser) parseDefMethodStatement() *ast.DefStatement {
 	}
 
 	if p.peekTokenIs(token.Ident) && p.peekTokenAtSameLine() { // def foo x, next token is x and at same line
-		p.error = &Error{Message: fmt.Sprintf("Please add parentheses around method \"%s\"'s parameters. Line: %d", stmt.Name.Value, p.curToken.Line), errType: MethodDefinitionError}
+		desc := fmt.Sprintf("Please add parentheses around method \"%s\"'s parameters", stmt.Name.Value)
+		p.addError(MethodDefinitionError, p.peekToken, desc, fmt.Sprintf("%s. Line: %d", desc, p.curToken.Line))
 	}
 
 	if p.peekTokenIs(token.LParen) {
@@ -193,16 +195,20 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	for !p.curTokenIs(token.End) && !p.curTokenIs(token.Else) {
 
 		if p.curTokenIs(token.EOF) {
-			p.error = &Error{Message: "Unexpected EOF", errType: EndOfFileError}
+			p.addError(EndOfFileError, p.curToken, "Unexpected EOF", "Unexpected EOF")
 			return bs
 		}
+		depth, errs := p.statementDepth(), len(p.errors)
 		stmt := p.parseStatement()
 		if stmt != nil {
 			bs.Statements = append(bs.Statements, stmt)
 		}
+		p.synchronize(depth, errs)
 		p.nextToken()
 	}
 
+	bs.End = p.curToken
+
 	return bs
 }
 
@@ -8,6 +8,8 @@ type Token struct {
 	Type    Type
 	Literal string
 	Line    int
+	// Column is the token's byte offset in its line, which starts from 0 like Line
+	Column int
 }
 
 // Literals
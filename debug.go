@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/goby-lang/goby/compiler"
+	"github.com/goby-lang/goby/debugger"
+	"github.com/goby-lang/goby/vm"
+)
+
+// runDebug runs `goby debug script.gb [args...]`, which evaluates the script under the debugger.
+// It stops at the script's first line, where breakpoints can be set with the console's commands.
+func runDebug(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: goby debug script.gb [args...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	path := flags.Arg(0)
+	file, ok := readFile(path)
+
+	if !ok {
+		return 1
+	}
+
+	instructionSets, err := compiler.CompileFileToInstructions(path, string(file))
+
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+
+	dir, _, _ := extractFileInfo(path)
+	d := vm.NewDebugger()
+	vm.New(dir, flags.Args()[1:], vm.WithDebugger(d))
+
+	if err := debugger.NewConsole(d, os.Stdin, os.Stdout).Run(instructionSets, path); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,334 @@
+// Package debugger is the command line front end of vm.Debugger, which `goby debug` runs.
+// Front ends like Debug Adapter Protocol servers can use vm.Debugger directly.
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/goby-lang/goby/compiler/bytecode"
+	"github.com/goby-lang/goby/vm"
+)
+
+// Console reads commands from its input and prints what the debugger finds to its output.
+// The program's own output isn't captured, it goes to stdout as usual.
+type Console struct {
+	debugger *vm.Debugger
+	in       *bufio.Scanner
+	out      io.Writer
+	// file is the program's file, which breakpoints without file names are set in
+	file string
+	// sources caches lines of files, so stops can show their lines
+	sources map[string][]string
+	// frame is the selected frame, which locals and expressions are looked up in
+	frame int
+	// last is the last command, which an empty line repeats
+	last string
+}
+
+const usage = `Commands:
+  break [file:]line     Stop at the line, which is in the program's file if the file isn't given (b)
+  clear [file:]line     Remove the breakpoint at the line
+  watch @name           Stop when the instance variable is about to change, it still has the old value then
+  unwatch @name         Stop watching the instance variable
+  continue              Resume until a breakpoint, a watched change or an error (c)
+  step                  Resume until another line, including lines of called methods and blocks (s)
+  next                  Resume until another line of the current frame or its callers (n)
+  finish                Resume until the current frame returns (f)
+  backtrace             Print the frames being evaluated (bt)
+  frame n               Select the nth frame, where 0 is the innermost one
+  locals                Print local variables of the selected frame
+  ivars                 Print instance variables of the selected frame's self
+  print expression      Evaluate the expression in the selected frame (p)
+  help                  Print this message (h)
+  quit                  Stop debugging (q)
+An empty line repeats the last command.`
+
+// commands are handlers of commands by their names, they return where the program stops if they resume it
+var commands = map[string]func(c *Console, arg string) (resumed bool, stop *vm.Stop){
+	"break":     (*Console).setBreakpoint,
+	"clear":     (*Console).clearBreakpoint,
+	"watch":     (*Console).watch,
+	"unwatch":   (*Console).unwatch,
+	"continue":  resume((*vm.Debugger).Continue),
+	"step":      resume((*vm.Debugger).StepIn),
+	"next":      resume((*vm.Debugger).StepOver),
+	"finish":    resume((*vm.Debugger).StepOut),
+	"backtrace": (*Console).backtrace,
+	"frame":     (*Console).selectFrame,
+	"locals":    (*Console).locals,
+	"ivars":     (*Console).ivars,
+	"print":     (*Console).print,
+	"help":      (*Console).help,
+}
+
+var aliases = map[string]string{
+	"b":  "break",
+	"c":  "continue",
+	"s":  "step",
+	"n":  "next",
+	"f":  "finish",
+	"bt": "backtrace",
+	"p":  "print",
+	"h":  "help",
+}
+
+// NewConsole returns a console of the debugger, which is given to the vm with vm.WithDebugger
+func NewConsole(d *vm.Debugger, in io.Reader, out io.Writer) *Console {
+	return &Console{debugger: d, in: bufio.NewScanner(in), out: out, sources: map[string][]string{}}
+}
+
+// Run evaluates the program in the file, and reads commands whenever it stops.
+// It returns once the program ends, or the input ends or has `quit`.
+func (c *Console) Run(sets []*bytecode.InstructionSet, file string) error {
+	c.file = file
+	stop := c.debugger.Run(sets, file)
+
+	for stop != nil {
+		c.printStop(stop)
+		c.frame = 0
+
+		var ok bool
+
+		if stop, ok = c.readCommands(); !ok {
+			return c.in.Err()
+		}
+	}
+
+	fmt.Fprintln(c.out, "The program exited")
+	return nil
+}
+
+// readCommands evaluates commands until one of them resumes the program, and returns where it stops next.
+// It returns false if the input ends or has `quit`.
+func (c *Console) readCommands() (*vm.Stop, bool) {
+	for {
+		fmt.Fprint(c.out, "(goby) ")
+
+		if !c.in.Scan() {
+			fmt.Fprintln(c.out)
+			return nil, false
+		}
+
+		line := strings.TrimSpace(c.in.Text())
+
+		if line == "" {
+			line = c.last
+		}
+
+		c.last = line
+		name, arg := line, ""
+
+		if n := strings.IndexAny(line, " \t"); n >= 0 {
+			name, arg = line[:n], strings.TrimSpace(line[n:])
+		}
+
+		if alias, ok := aliases[name]; ok {
+			name = alias
+		}
+
+		switch name {
+		case "":
+			continue
+		case "quit", "q":
+			return nil, false
+		}
+
+		command, ok := commands[name]
+
+		if !ok {
+			fmt.Fprintf(c.out, "Unknown command: %s, see `help`\n", name)
+			continue
+		}
+
+		if resumed, stop := command(c, arg); resumed {
+			return stop, true
+		}
+	}
+}
+
+func (c *Console) printStop(stop *vm.Stop) {
+	reason := string(stop.Reason)
+
+	if stop.Description != "" {
+		reason += " " + stop.Description
+	}
+
+	frames := c.debugger.Frames()
+	fmt.Fprintf(c.out, "Stopped in %s at %s:%d (%s)\n", frames[0].Name, stop.File, stop.Line, reason)
+	c.printLine(stop.File, stop.Line)
+}
+
+// printLine prints the line of the file if it can be read
+func (c *Console) printLine(file string, line int) {
+	lines, ok := c.sources[file]
+
+	if !ok {
+		source, _ := ioutil.ReadFile(file)
+		lines = strings.Split(string(source), "\n")
+		c.sources[file] = lines
+	}
+
+	if line > 0 && line <= len(lines) {
+		fmt.Fprintf(c.out, "%5d  %s\n", line, lines[line-1])
+	}
+}
+
+func resume(step func(d *vm.Debugger) *vm.Stop) func(c *Console, arg string) (bool, *vm.Stop) {
+	return func(c *Console, arg string) (bool, *vm.Stop) {
+		return true, step(c.debugger)
+	}
+}
+
+// location parses `file:line` or `line`, which is in the program's file
+func (c *Console) location(arg string) (file string, line int, ok bool) {
+	file = c.file
+
+	if n := strings.LastIndex(arg, ":"); n >= 0 {
+		file, arg = arg[:n], arg[n+1:]
+	}
+
+	line, err := strconv.Atoi(arg)
+
+	if err != nil || line < 1 || file == "" {
+		fmt.Fprintln(c.out, "Expect a location like `12` or `file.gb:12`")
+		return "", 0, false
+	}
+
+	return file, line, true
+}
+
+func (c *Console) setBreakpoint(arg string) (bool, *vm.Stop) {
+	if file, line, ok := c.location(arg); ok {
+		c.debugger.SetBreakpoint(file, line)
+		fmt.Fprintf(c.out, "Breakpoint at %s:%d\n", file, line)
+	}
+
+	return false, nil
+}
+
+func (c *Console) clearBreakpoint(arg string) (bool, *vm.Stop) {
+	file, line, ok := c.location(arg)
+
+	switch {
+	case !ok:
+	case c.debugger.ClearBreakpoint(file, line):
+		fmt.Fprintf(c.out, "Cleared breakpoint at %s:%d\n", file, line)
+	default:
+		fmt.Fprintf(c.out, "No breakpoint at %s:%d\n", file, line)
+	}
+
+	return false, nil
+}
+
+func (c *Console) watch(arg string) (bool, *vm.Stop) {
+	if name, ok := c.instanceVariable(arg); ok {
+		c.debugger.Watch(name)
+		fmt.Fprintf(c.out, "Watching %s\n", name)
+	}
+
+	return false, nil
+}
+
+func (c *Console) unwatch(arg string) (bool, *vm.Stop) {
+	name, ok := c.instanceVariable(arg)
+
+	switch {
+	case !ok:
+	case c.debugger.Unwatch(name):
+		fmt.Fprintf(c.out, "Stopped watching %s\n", name)
+	default:
+		fmt.Fprintf(c.out, "%s isn't watched\n", name)
+	}
+
+	return false, nil
+}
+
+func (c *Console) instanceVariable(arg string) (string, bool) {
+	if len(arg) < 2 || arg[0] != '@' || strings.ContainsAny(arg, " \t") {
+		fmt.Fprintln(c.out, "Expect an instance variable like `@count`")
+		return "", false
+	}
+
+	return arg, true
+}
+
+func (c *Console) backtrace(arg string) (bool, *vm.Stop) {
+	for n, frame := range c.debugger.Frames() {
+		marker := " "
+
+		if n == c.frame {
+			marker = ">"
+		}
+
+		fmt.Fprintf(c.out, "%s #%d %s at %s:%d\n", marker, n, frame.Name, frame.File, frame.Line)
+	}
+
+	return false, nil
+}
+
+func (c *Console) selectFrame(arg string) (bool, *vm.Stop) {
+	frames := c.debugger.Frames()
+	n, err := strconv.Atoi(arg)
+
+	if err != nil || n < 0 || n >= len(frames) {
+		fmt.Fprintf(c.out, "Expect a frame from 0 to %d\n", len(frames)-1)
+		return false, nil
+	}
+
+	c.frame = n
+	fmt.Fprintf(c.out, "#%d %s at %s:%d\n", n, frames[n].Name, frames[n].File, frames[n].Line)
+	c.printLine(frames[n].File, frames[n].Line)
+	return false, nil
+}
+
+func (c *Console) locals(arg string) (bool, *vm.Stop) {
+	locals, err := c.debugger.Locals(c.frame)
+	c.printVariables(locals, err, "No local variables")
+	return false, nil
+}
+
+func (c *Console) ivars(arg string) (bool, *vm.Stop) {
+	ivars, err := c.debugger.InstanceVariables(c.frame)
+	c.printVariables(ivars, err, "No instance variables")
+	return false, nil
+}
+
+func (c *Console) printVariables(variables []vm.Variable, err error, none string) {
+	switch {
+	case err != nil:
+		fmt.Fprintln(c.out, err.Error())
+	case len(variables) == 0:
+		fmt.Fprintln(c.out, none)
+	}
+
+	for _, v := range variables {
+		fmt.Fprintf(c.out, "%s = %s\n", v.Name, v.Value)
+	}
+}
+
+func (c *Console) print(arg string) (bool, *vm.Stop) {
+	if arg == "" {
+		fmt.Fprintln(c.out, "Expect an expression like `print count + 1`")
+		return false, nil
+	}
+
+	result, err := c.debugger.Eval(c.frame, arg)
+
+	if err != nil {
+		fmt.Fprintln(c.out, err.Error())
+		return false, nil
+	}
+
+	fmt.Fprintln(c.out, result)
+	return false, nil
+}
+
+func (c *Console) help(arg string) (bool, *vm.Stop) {
+	fmt.Fprintln(c.out, usage)
+	return false, nil
+}
@@ -0,0 +1,150 @@
+package debugger
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/goby-lang/goby/compiler"
+	"github.com/goby-lang/goby/vm"
+)
+
+func TestConsole(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`c`, `
+Stopped in <main> at testdata/counter.gb:1 (entry)
+    1  class Counter
+(goby) The program exited
+`},
+		{`break 9
+continue
+bt
+locals
+frame 1
+locals
+ivars
+print sum + v
+print sum +
+continue
+clear 9
+continue`, `
+Stopped in <main> at testdata/counter.gb:1 (entry)
+    1  class Counter
+(goby) Breakpoint at testdata/counter.gb:9
+(goby) Stopped in block in add at testdata/counter.gb:9 (breakpoint)
+    9        sum += v
+(goby) > #0 block in add at testdata/counter.gb:9
+  #1 add at testdata/counter.gb:8
+  #2 <main> at testdata/counter.gb:17
+(goby) v = 1
+values = [1, 2]
+sum = 0
+(goby) #1 add at testdata/counter.gb:8
+    8      values.each do |v|
+(goby) values = [1, 2]
+sum = 0
+(goby) @count = 0
+(goby) UndefinedMethodError: Undefined Method 'v' for <Instance of: Counter>
+(goby) 1:6: UnexpectedTokenError: unexpected EOF
+   1 | sum +
+     |      ^
+(goby) Stopped in block in add at testdata/counter.gb:9 (breakpoint)
+    9        sum += v
+(goby) Cleared breakpoint at testdata/counter.gb:9
+(goby) The program exited
+`},
+		{`watch @count
+c
+p @count
+s
+
+n
+finish
+unwatch @count
+unwatch @count
+c`, `
+Stopped in <main> at testdata/counter.gb:1 (entry)
+    1  class Counter
+(goby) Watching @count
+(goby) Stopped in initialize at testdata/counter.gb:3 (watch @count: nil -> 0)
+    3      @count = 0
+(goby) nil
+(goby) Stopped in <main> at testdata/counter.gb:17 (step)
+   17  total = c.add([1, 2])
+(goby) Stopped in add at testdata/counter.gb:7 (step)
+    7      sum = 0
+(goby) Stopped in add at testdata/counter.gb:8 (step)
+    8      values.each do |v|
+(goby) Stopped in add at testdata/counter.gb:11 (watch @count: 0 -> 3)
+   11      @count += sum
+(goby) Stopped watching @count
+(goby) @count isn't watched
+(goby) The program exited
+`},
+		{`break 7
+c
+clear 7
+finish
+c`, `
+Stopped in <main> at testdata/counter.gb:1 (entry)
+    1  class Counter
+(goby) Breakpoint at testdata/counter.gb:7
+(goby) Stopped in add at testdata/counter.gb:7 (breakpoint)
+    7      sum = 0
+(goby) Cleared breakpoint at testdata/counter.gb:7
+(goby) Stopped in <main> at testdata/counter.gb:18 (return)
+   18  c.add([total])
+(goby) The program exited
+`},
+		{`break x
+break testdata/counter.gb:0
+clear 3
+frame 5
+watch count
+print
+foo
+help
+quit`, `
+Stopped in <main> at testdata/counter.gb:1 (entry)
+    1  class Counter
+(goby) Expect a location like ` + "`12` or `file.gb:12`" + `
+(goby) Expect a location like ` + "`12` or `file.gb:12`" + `
+(goby) No breakpoint at testdata/counter.gb:3
+(goby) Expect a frame from 0 to 0
+(goby) Expect an instance variable like ` + "`@count`" + `
+(goby) Expect an expression like ` + "`print count + 1`" + `
+(goby) Unknown command: foo, see ` + "`help`" + `
+(goby) ` + usage + `
+(goby) `},
+	}
+
+	source, err := ioutil.ReadFile("testdata/counter.gb")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for i, tt := range tests {
+		sets, err := compiler.CompileFileToInstructions("testdata/counter.gb", string(source))
+
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		d := vm.NewDebugger()
+		vm.New("./testdata", []string{}, vm.WithDebugger(d))
+		var out bytes.Buffer
+
+		if err := NewConsole(d, strings.NewReader(tt.input), &out).Run(sets, "testdata/counter.gb"); err != nil {
+			t.Fatalf("At case %d expect no error. got: %s", i, err.Error())
+		}
+
+		if expected := strings.TrimPrefix(tt.expected, "\n"); out.String() != expected {
+			t.Fatalf("At case %d expect output:\n%s\ngot:\n%s", i, expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/goby-lang/goby/doc"
+)
+
+// runDoc runs `goby doc [-o dir] [-test] [-vm dir] [paths...]`, which generates API documents of built-in classes
+// from the VM's Go files, and of Goby files in the paths, which are $GOBY_ROOT/lib if there are no paths.
+// With -test it runs the documents' examples instead, and returns 1 if any of them failed.
+func runDoc(args []string) int {
+	root := os.Getenv("GOBY_ROOT")
+	flags := flag.NewFlagSet("doc", flag.ExitOnError)
+	outputOptionPtr := flags.String("o", "docs", "Directory of the HTML site and "+doc.JSONFile)
+	testOptionPtr := flags.Bool("test", false, "Run examples that have expected results as doctests, except ones in ```ruby nodoctest blocks")
+	vmOptionPtr := flags.String("vm", filepath.Join(root, "vm"), "Directory of the VM's Go files, built-in classes are skipped if it's empty")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: goby doc [-o dir] [-test] [-vm dir] [paths...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	paths := flags.Args()
+
+	if len(paths) == 0 {
+		paths = []string{filepath.Join(root, "lib")}
+	}
+
+	classes, err := doc.Load(*vmOptionPtr, paths)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	if *testOptionPtr {
+		count, failures := doc.Doctest(classes)
+		doc.ReportDoctest(os.Stdout, count, failures)
+
+		if len(failures) > 0 {
+			return 1
+		}
+
+		return 0
+	}
+
+	if err := doc.WriteSite(*outputOptionPtr, classes); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	fmt.Printf("Wrote documents of %d classes to %s\n", len(classes), *outputOptionPtr)
+	return 0
+}
@@ -0,0 +1,543 @@
+// Package doc extracts API documents of built-in classes from the VM's Go files, and of Goby libraries from
+// comments above their `class`, `module` and `def` statements. It's what `goby doc` runs.
+//
+// Documents are comments whose `@param name [Type] description` and `@return [Type] description` lines
+// describe methods, and whose ```ruby blocks are examples, which run as doctests unless they're ```ruby nodoctest blocks.
+package doc
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/goby-lang/goby/compiler"
+	gobyast "github.com/goby-lang/goby/compiler/ast"
+	"github.com/goby-lang/goby/compiler/lexer"
+	gobytoken "github.com/goby-lang/goby/compiler/token"
+)
+
+// Class is a class or module with its methods
+type Class struct {
+	// Name is the full name, like `Net::HTTP::Request`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Examples    []Example `json:"examples"`
+	File        string    `json:"file"`
+	Line        int       `json:"line"`
+	Builtin     bool      `json:"builtin"`
+	Methods     []*Method `json:"methods"`
+}
+
+// Method is a method's document
+type Method struct {
+	Name        string `json:"name"`
+	ClassMethod bool   `json:"class_method"`
+	// Signature is like `def foo(a, b = 1)`, it's empty for built-in methods
+	Signature   string    `json:"signature,omitempty"`
+	Description string    `json:"description"`
+	Params      []Param   `json:"params"`
+	Return      *Param    `json:"return,omitempty"`
+	Examples    []Example `json:"examples"`
+	File        string    `json:"file"`
+	Line        int       `json:"line"`
+}
+
+// Param is a `@param` or `@return` line, whose name is empty for `@return`
+type Param struct {
+	Name        string `json:"name,omitempty"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// Example is a ```ruby block, Line is the line of its first line of code in the file
+type Example struct {
+	Code string `json:"code"`
+	Line int    `json:"line"`
+	// NoDoctest is true for ```ruby nodoctest blocks, whose expected results Doctest doesn't check
+	NoDoctest bool `json:"nodoctest,omitempty"`
+}
+
+// FullName returns the method's name with its class, like `Array#each` or `File.open`
+func (m *Method) FullName(class string) string {
+	if m.ClassMethod {
+		return class + "." + m.Name
+	}
+
+	return class + "#" + m.Name
+}
+
+// commentLine is a line of a comment without its marker, and the line number in the file
+type commentLine struct {
+	text string
+	line int
+}
+
+// Load returns documents of built-in classes in the VM's Go files of vmDir, and of classes in Goby files of paths,
+// where directories are searched recursively. Library classes are merged into built-in ones of the same name,
+// like `Net::SimpleServer` into `SimpleServer`. vmDir is skipped if it's empty.
+func Load(vmDir string, paths []string) ([]*Class, error) {
+	classes := map[string]*Class{}
+
+	if vmDir != "" {
+		builtins, err := LoadBuiltins(vmDir)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range builtins {
+			classes[c.Name] = c
+		}
+	}
+
+	libraries, err := LoadFiles(paths)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range libraries {
+		names := strings.Split(c.Name, "::")
+
+		// Built-in classes are named without their namespaces, like SimpleServer
+		if builtin, ok := classes[names[len(names)-1]]; ok && builtin.Builtin && len(names) > 1 {
+			delete(classes, builtin.Name)
+			builtin.Name = c.Name
+			classes[c.Name] = builtin
+		}
+
+		existing, ok := classes[c.Name]
+
+		if !ok {
+			classes[c.Name] = c
+			continue
+		}
+
+		existing.Methods = append(existing.Methods, c.Methods...)
+
+		if existing.Description == "" {
+			existing.Description, existing.Examples = c.Description, c.Examples
+		}
+	}
+
+	result := []*Class{}
+
+	for _, c := range classes {
+		sortMethods(c.Methods)
+		result = append(result, c)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+// sortMethods puts class methods first, and sorts them by names
+func sortMethods(methods []*Method) {
+	sort.SliceStable(methods, func(i, j int) bool {
+		if methods[i].ClassMethod != methods[j].ClassMethod {
+			return methods[i].ClassMethod
+		}
+
+		return methods[i].Name < methods[j].Name
+	})
+}
+
+// methodListName matches functions and variables that return built-in methods, like builtinIntegerInstanceMethods
+var methodListName = regexp.MustCompile(`^built[iI]n(\w+?)(Class|Instance)Methods$`)
+
+// LoadBuiltins reads built-in methods' comments from the VM's Go files in dir, which is usually $GOBY_ROOT/vm.
+// Classes' descriptions are the comments of their Go types, like IntegerObject's.
+func LoadBuiltins(dir string) ([]*Class, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("No Go files in %s", dir)
+	}
+
+	classes := map[string]*Class{}
+	// types are Go types' declarations by their names
+	types := map[string]goType{}
+	fset := token.NewFileSet()
+
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				addBuiltinMethods(classes, fset, file, path, decl.Name.Name, decl.Body)
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.ValueSpec:
+						if len(spec.Names) == 1 && len(spec.Values) == 1 {
+							addBuiltinMethods(classes, fset, file, path, spec.Names[0].Name, spec.Values[0])
+						}
+					case *ast.TypeSpec:
+						if decl.Doc != nil {
+							types[spec.Name.Name] = goType{doc: decl.Doc, path: path, line: fset.Position(spec.Pos()).Line}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	result := []*Class{}
+
+	for name, c := range classes {
+		for _, typeName := range []string{name + "Object", "R" + name} {
+			if t, ok := types[typeName]; ok {
+				doc := parseComment(goCommentLines(fset, t.doc))
+				c.Description, c.Examples, c.File, c.Line = renameLeadIn(doc.description, typeName, name), doc.examples, t.path, t.line
+				break
+			}
+		}
+
+		result = append(result, c)
+	}
+
+	return result, nil
+}
+
+// goType is a Go type's declaration with its doc comment
+type goType struct {
+	doc  *ast.CommentGroup
+	path string
+	line int
+}
+
+// addBuiltinMethods adds documents of methods in node to their class if name is a method list's name
+func addBuiltinMethods(classes map[string]*Class, fset *token.FileSet, file *ast.File, path, name string, node ast.Node) {
+	match := methodListName.FindStringSubmatch(name)
+
+	if match == nil || node == nil {
+		return
+	}
+
+	className := match[1]
+
+	// Common methods are Object's
+	if className == "Common" {
+		className = "Object"
+	}
+
+	c, ok := classes[className]
+
+	if !ok {
+		c = &Class{Name: className, Examples: []Example{}, File: path, Line: fset.Position(node.Pos()).Line, Builtin: true}
+		classes[className] = c
+	}
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		lit, ok := n.(*ast.CompositeLit)
+
+		// Methods are elements of []*BuiltInMethodObject literals, so their types are elided
+		if !ok || lit.Type != nil {
+			return true
+		}
+
+		methodName, pos, ok := nameField(lit)
+
+		if !ok {
+			return true
+		}
+
+		m := &Method{Name: methodName, ClassMethod: match[2] == "Class", File: path, Line: fset.Position(pos).Line}
+
+		lines := []commentLine{}
+
+		// Comments right above the Name field are the method's
+		for _, cg := range file.Comments {
+			if cg.Pos() > lit.Lbrace && fset.Position(cg.End()).Line == m.Line-1 {
+				lines = goCommentLines(fset, cg)
+				break
+			}
+		}
+
+		m.setComment(lines)
+
+		c.Methods = append(c.Methods, m)
+		return false
+	})
+}
+
+// nameField returns the value and position of the literal's Name field
+func nameField(lit *ast.CompositeLit) (string, token.Pos, bool) {
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+
+		if !ok {
+			continue
+		}
+
+		key, ok := kv.Key.(*ast.Ident)
+		value, isString := kv.Value.(*ast.BasicLit)
+
+		if !ok || key.Name != "Name" || !isString || value.Kind != token.STRING {
+			continue
+		}
+
+		name, err := strconv.Unquote(value.Value)
+		return name, kv.Pos(), err == nil
+	}
+
+	return "", token.NoPos, false
+}
+
+// goCommentLines returns lines of `//` comments without their markers and the first spaces
+func goCommentLines(fset *token.FileSet, cg *ast.CommentGroup) []commentLine {
+	lines := []commentLine{}
+
+	for _, c := range cg.List {
+		text := strings.TrimPrefix(c.Text, "//")
+		lines = append(lines, commentLine{text: strings.TrimPrefix(text, " "), line: fset.Position(c.Slash).Line})
+	}
+
+	return lines
+}
+
+// LoadFiles reads documents of classes, modules and methods in Goby files of paths, where directories are searched recursively.
+// Methods defined outside classes are Object's.
+func LoadFiles(paths []string) ([]*Class, error) {
+	classes := []*Class{}
+
+	for _, path := range paths {
+		err := filepath.Walk(path, func(fp string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || filepath.Ext(fp) != ".gb" {
+				return err
+			}
+
+			input, err := ioutil.ReadFile(fp)
+
+			if err != nil {
+				return err
+			}
+
+			program, err := compiler.ParseFile(fp, string(input))
+
+			if err != nil {
+				return err
+			}
+
+			f := &gobyFile{path: fp, comments: map[int]string{}, classes: map[string]*Class{}}
+			f.readComments(string(input))
+			f.addStatements(program.Statements, "")
+
+			for _, c := range f.classes {
+				classes = append(classes, c)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return classes, nil
+}
+
+// gobyFile collects documents of a Goby file
+type gobyFile struct {
+	path string
+	// comments map lines to comments that have their own lines
+	comments map[int]string
+	classes  map[string]*Class
+}
+
+func (f *gobyFile) readComments(input string) {
+	l := lexer.New(input)
+	line := 0
+
+	for tok := l.NextToken(); tok.Type != gobytoken.EOF; tok = l.NextToken() {
+		if tok.Type != gobytoken.Comment {
+			line = tok.Line
+			continue
+		}
+
+		if line != tok.Line {
+			f.comments[tok.Line] = strings.TrimPrefix(strings.TrimPrefix(tok.Literal, "#"), " ")
+		}
+	}
+}
+
+// docComment returns lines of comments right above the line, whose numbers start from 1
+func (f *gobyFile) docComment(line int) []commentLine {
+	lines := []commentLine{}
+
+	for n := line - 1; ; n-- {
+		text, ok := f.comments[n]
+
+		if !ok {
+			break
+		}
+
+		lines = append([]commentLine{{text: text, line: n + 1}}, lines...)
+	}
+
+	return lines
+}
+
+// class returns the class of the name, which is defined at the token if it's new
+func (f *gobyFile) class(name string, tok gobytoken.Token) *Class {
+	c, ok := f.classes[name]
+
+	if !ok {
+		c = &Class{Name: name, Examples: []Example{}, File: f.path, Line: tok.Line + 1, Methods: []*Method{}}
+		f.classes[name] = c
+	}
+
+	return c
+}
+
+// addStatements adds documents of classes and methods in the statements, which are in the owner class
+func (f *gobyFile) addStatements(stmts []gobyast.Statement, owner string) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *gobyast.ClassStatement:
+			f.addClass(owner, stmt.Name.Value, stmt.Token, stmt.Body)
+		case *gobyast.ModuleStatement:
+			f.addClass(owner, stmt.Name.Value, stmt.Token, stmt.Body)
+		case *gobyast.DefStatement:
+			m := &Method{Name: stmt.Name.Value, File: f.path, Line: stmt.Token.Line + 1}
+			_, m.ClassMethod = stmt.Receiver.(*gobyast.SelfExpression)
+			params := []string{}
+
+			for _, param := range stmt.Parameters {
+				switch param := param.(type) {
+				case *gobyast.Identifier:
+					params = append(params, param.Value)
+				case *gobyast.AssignExpression:
+					params = append(params, param.Variables[0].String()+" = "+param.Value.String())
+				}
+			}
+
+			name := m.Name
+
+			if stmt.Receiver != nil {
+				name = stmt.Receiver.String() + "." + name
+			}
+
+			m.Signature = "def " + name + "(" + strings.Join(params, ", ") + ")"
+			m.setComment(f.docComment(stmt.Token.Line))
+
+			if owner == "" {
+				owner = "Object"
+			}
+
+			c := f.class(owner, stmt.Token)
+			c.Methods = append(c.Methods, m)
+		}
+	}
+}
+
+func (f *gobyFile) addClass(owner, name string, tok gobytoken.Token, body *gobyast.BlockStatement) {
+	if owner != "" {
+		name = owner + "::" + name
+	}
+
+	c := f.class(name, tok)
+
+	if doc := parseComment(f.docComment(tok.Line)); doc.description != "" || len(doc.examples) > 0 {
+		c.Description, c.Examples = doc.description, doc.examples
+	}
+
+	f.addStatements(body.Statements, name)
+}
+
+// comment is a parsed document
+type comment struct {
+	description string
+	params      []Param
+	ret         *Param
+	examples    []Example
+}
+
+func (m *Method) setComment(lines []commentLine) {
+	c := parseComment(lines)
+	m.Description, m.Params, m.Return, m.Examples = c.description, c.params, c.ret, c.examples
+}
+
+// tagLine matches `@param name [Type] description` and `@return [Type] description`
+var tagLine = regexp.MustCompile(`^@(param|return)\s+(?:([^\s\[]+)\s*)?(?:\[([^\]]*)\])?\s*(.*)$`)
+
+// renameLeadIn replaces the Go type's name that Go comments start with, like `ArrayObject represents ...`,
+// with the class's name, since readers of the documents don't know Go types
+func renameLeadIn(description, typeName, className string) string {
+	rest := strings.TrimPrefix(description, typeName)
+
+	if rest == description || (rest != "" && (unicode.IsLetter(rune(rest[0])) || unicode.IsDigit(rune(rest[0])))) {
+		return description
+	}
+
+	return className + rest
+}
+
+// noDoctestFence matches fences of examples that don't run as doctests, like ```ruby nodoctest
+var noDoctestFence = regexp.MustCompile("(?i)^```ruby\\s+nodoctest\\b")
+
+// parseComment splits the comment into its description, tags and examples
+func parseComment(lines []commentLine) comment {
+	c := comment{params: []Param{}, examples: []Example{}}
+	description := []string{}
+	var example *Example
+
+	for _, l := range lines {
+		trimmed := strings.TrimSpace(l.text)
+
+		switch {
+		case example != nil && strings.HasPrefix(trimmed, "```"):
+			c.examples = append(c.examples, *example)
+			example = nil
+		case example != nil:
+			if example.Line == 0 {
+				example.Line = l.line
+			}
+
+			example.Code += l.text + "\n"
+		case strings.HasPrefix(strings.ToLower(trimmed), "```ruby"):
+			example = &Example{NoDoctest: noDoctestFence.MatchString(trimmed)}
+		case tagLine.MatchString(trimmed):
+			match := tagLine.FindStringSubmatch(trimmed)
+			p := Param{Name: match[2], Type: match[3], Description: match[4]}
+
+			if match[1] == "return" {
+				p.Name = ""
+				c.ret = &p
+			} else {
+				c.params = append(c.params, p)
+			}
+		default:
+			description = append(description, l.text)
+		}
+	}
+
+	c.description = strings.TrimSpace(strings.Join(description, "\n"))
+	return c
+}
@@ -0,0 +1,193 @@
+package doc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func load(t *testing.T) []*Class {
+	t.Helper()
+	classes, err := Load("testdata/vm", []string{"testdata/lib"})
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return classes
+}
+
+func TestLoad(t *testing.T) {
+	classes := load(t)
+	names := []string{}
+	methods := map[string]*Method{}
+
+	for _, c := range classes {
+		names = append(names, c.Name)
+
+		for _, m := range c.Methods {
+			methods[m.FullName(c.Name)] = m
+		}
+	}
+
+	expected := []string{"Counter", "Net", "Net::Server", "Object"}
+
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expect classes %v. got: %v", expected, names)
+	}
+
+	counter := classes[0]
+
+	if !counter.Builtin || counter.Description != "Counter counts things." || counter.File != "testdata/vm/counter.go" || counter.Line != 8 {
+		t.Fatalf("Expect Counter to be documented by CounterObject's comment. got: %+v", counter)
+	}
+
+	if len(counter.Methods) != 4 || counter.Methods[0].Name != "new" || counter.Methods[3].Name != "reset" {
+		t.Fatalf("Expect Counter's built-in and library methods with class methods first. got: %v", counter.Methods)
+	}
+
+	tests := []struct {
+		name        string
+		signature   string
+		description string
+		params      []Param
+		ret         *Param
+		examples    int
+		exampleLine int
+	}{
+		{"Counter.new", "", "Returns a new counter.", []Param{}, &Param{Type: "Counter"}, 0, 0},
+		{"Counter#add", "", "Adds the number to the sum.", []Param{{"n", "Integer", "the number"}}, &Param{Type: "Integer", Description: "the sum"}, 1, 27},
+		{"Counter#count", "", "", []Param{}, nil, 0, 0},
+		{"Net::Server#start", `def start(port, host = "localhost")`, "Starts the server.", []Param{{"port", "Integer", ""}}, nil, 1, 7},
+		{"Net::Server.run", "def self.run()", "", []Param{}, nil, 1, 15},
+		{"Object#helper", "def helper()", "", []Param{}, nil, 1, 32},
+	}
+
+	for i, tt := range tests {
+		m, ok := methods[tt.name]
+
+		if !ok {
+			t.Fatalf("At case %d expect method %s", i, tt.name)
+		}
+
+		if m.Signature != tt.signature || m.Description != tt.description {
+			t.Fatalf("At case %d expect signature %q and description %q. got: %q %q", i, tt.signature, tt.description, m.Signature, m.Description)
+		}
+
+		if !reflect.DeepEqual(m.Params, tt.params) || !reflect.DeepEqual(m.Return, tt.ret) {
+			t.Fatalf("At case %d expect params %v and return %v. got: %v %v", i, tt.params, tt.ret, m.Params, m.Return)
+		}
+
+		if len(m.Examples) != tt.examples || (tt.examples > 0 && (m.Examples[0].Line != tt.exampleLine || m.Examples[0].NoDoctest)) {
+			t.Fatalf("At case %d expect %d examples at line %d. got: %+v", i, tt.examples, tt.exampleLine, m.Examples)
+		}
+	}
+
+	if reset := counter.Methods[3]; len(reset.Examples) != 1 || !reset.Examples[0].NoDoctest {
+		t.Fatalf("Expect Counter#reset's example not to be a doctest. got: %+v", reset.Examples)
+	}
+
+	if _, err := Load("testdata/lib", nil); err == nil {
+		t.Fatal("Expect an error of the directory without Go files")
+	}
+}
+
+func TestWriteSite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goby-doc")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	defer os.RemoveAll(dir)
+
+	classes := load(t)
+
+	if err := WriteSite(dir, classes); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"index.html", `<a href="Net-Server.html">Net::Server</a>`},
+		{"Counter.html", `<li><code>n</code> <span class="type">[Integer]</span> the number</li>`},
+		{"Counter.html", `<pre><code class="language-ruby">1 &#43; 2 # =&gt; 3`},
+		{"Net-Server.html", `<h2>Net::Server.run</h2>`},
+		{"Net-Server.html", `<pre><code>def start(port, host = &#34;localhost&#34;)</code></pre>`},
+	}
+
+	for i, tt := range tests {
+		content, err := ioutil.ReadFile(filepath.Join(dir, tt.file))
+
+		if err != nil {
+			t.Fatalf("At case %d: %s", i, err.Error())
+		}
+
+		if !strings.Contains(string(content), tt.expected) {
+			t.Fatalf("At case %d expect %s to contain %s. got:\n%s", i, tt.file, tt.expected, content)
+		}
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, JSONFile))
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var decoded []*Class
+
+	if err := json.Unmarshal(content, &decoded); err != nil {
+		t.Fatalf("Expect valid JSON. got: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(decoded, classes) {
+		t.Fatalf("Expect JSON of the classes. got:\n%s", content)
+	}
+}
+
+func TestDoctest(t *testing.T) {
+	count, failures := Doctest(load(t))
+
+	tests := []struct {
+		name     string
+		line     int
+		code     string
+		expected string
+		got      string
+	}{
+		{"Counter", 6, "Counter.new.count", "0", "NameError: uninitialized constant Counter"},
+		{"Net::Server.run", 15, "1.foo", "1", "UndefinedMethodError: Undefined Method 'foo' for 1"},
+		{"Net::Server#start", 7, `"a" * 2`, `"aaa"`, `"aa"`},
+	}
+
+	if count != 5 || len(failures) != len(tests) {
+		t.Fatalf("Expect 5 examples and %d failures. got: %d %v", len(tests), count, failures)
+	}
+
+	for i, tt := range tests {
+		f := failures[i]
+
+		if f.Name != tt.name || f.Line != tt.line || f.Code != tt.code || f.Expected != tt.expected || f.Got != tt.got {
+			t.Fatalf("At case %d expect %s to fail at line %d: %s # => %s. got: %s %+v", i, tt.name, tt.line, tt.code, tt.expected, tt.got, f)
+		}
+	}
+
+	var out bytes.Buffer
+	ReportDoctest(&out, count, failures[2:])
+
+	expected := `--- FAIL: Net::Server#start
+    testdata/lib/net/server.gb:7: "a" * 2 # => "aaa". got: "aa"
+FAIL	5 examples, 1 failed
+`
+
+	if out.String() != expected {
+		t.Fatalf("Expect report:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,238 @@
+package doc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/goby-lang/goby/compiler"
+	"github.com/goby-lang/goby/compiler/bytecode"
+	"github.com/goby-lang/goby/compiler/lexer"
+	"github.com/goby-lang/goby/compiler/parser"
+	"github.com/goby-lang/goby/vm"
+)
+
+// resultMarker starts expected results in examples, like `1 + 2 # => 3`
+const resultMarker = "# =>"
+
+// DoctestTimeout is how long an example can run
+var DoctestTimeout = 5 * time.Second
+
+// DoctestFailure is a line of an example whose result isn't the expected one
+type DoctestFailure struct {
+	// Name is the method's or class's name, like `Array#at`
+	Name     string
+	File     string
+	Line     int
+	Code     string
+	Expected string
+	// Got is the result's inspection, or the error the code raised
+	Got string
+}
+
+func (f DoctestFailure) String() string {
+	return fmt.Sprintf("%s:%d: %s %s %s. got: %s", f.File, f.Line, f.Code, resultMarker, f.Expected, f.Got)
+}
+
+// Doctest runs examples that have expected results, each in its own REPL session like igb's.
+// It returns the number of examples it ran and the failures, where an example stops at its first failure.
+// Examples in ```ruby nodoctest blocks aren't run, since their results can be descriptions, like `# => error`.
+//
+// A line's result is expected after `# =>` at its end, or on the next line if the comment has its own line.
+// Runs of such lines are printed output instead, which isn't checked.
+func Doctest(classes []*Class) (int, []DoctestFailure) {
+	count := 0
+	failures := []DoctestFailure{}
+
+	// Output that examples print, like `puts`'s, isn't checked, so it shouldn't mix with reports
+	if null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+		stdout := os.Stdout
+		os.Stdout = null
+
+		defer func() {
+			os.Stdout = stdout
+			null.Close()
+		}()
+	}
+
+	run := func(name string, examples []Example, file string) {
+		for _, e := range examples {
+			if e.NoDoctest || !strings.Contains(e.Code, resultMarker) {
+				continue
+			}
+
+			count++
+
+			if f, ok := runExample(e); !ok {
+				f.Name, f.File = name, file
+				failures = append(failures, f)
+			}
+		}
+	}
+
+	for _, c := range classes {
+		run(c.Name, c.Examples, c.File)
+
+		for _, m := range c.Methods {
+			run(m.FullName(c.Name), m.Examples, m.File)
+		}
+	}
+
+	return count, failures
+}
+
+// ReportDoctest prints failures like `go test` does, grouped by their methods
+func ReportDoctest(w io.Writer, count int, failures []DoctestFailure) {
+	name := ""
+
+	for _, f := range failures {
+		if f.Name != name {
+			name = f.Name
+			fmt.Fprintf(w, "--- FAIL: %s\n", name)
+		}
+
+		fmt.Fprintf(w, "    %s\n", f)
+	}
+
+	status := "ok"
+
+	if len(failures) > 0 {
+		status = "FAIL"
+	}
+
+	fmt.Fprintf(w, "%s\t%d examples, %d failed\n", status, count, len(failures))
+}
+
+// session is a REPL session an example runs in
+type session struct {
+	v *vm.VM
+	p *parser.Parser
+	g *bytecode.Generator
+}
+
+func newSession() *session {
+	v := vm.New(os.Getenv("GOBY_ROOT"), []string{}, vm.WithSandbox(vm.Sandbox{Timeout: DoctestTimeout}))
+	v.InitForREPL()
+	s := &session{v: v, p: parser.New(lexer.New(""))}
+	program, _ := s.p.ParseProgram()
+	s.g = bytecode.NewGenerator()
+	s.g.REPL = true
+	s.g.InitTopLevelScope(program)
+
+	// Examples use classes like File without requiring them
+	for _, name := range vm.StandardLibraries() {
+		s.eval(fmt.Sprintf("require %q", name))
+	}
+
+	return s
+}
+
+// eval evaluates the code, and reports if it's incomplete, like a block without its `end`
+func (s *session) eval(code string) (result string, incomplete bool, err error) {
+	s.p.Lexer = lexer.New(code)
+	program, perr := s.p.ParseProgram()
+
+	if perr != nil {
+		if perr.IsEOF() || perr.IsUnexpectedEnd() {
+			return "", true, nil
+		}
+
+		return "", false, fmt.Errorf("%s", perr.Message)
+	}
+
+	instructions := s.g.GenerateInstructions(program.Statements)
+	compiler.Optimizer.Optimize(instructions)
+	defer s.g.ResetInstructionSets()
+	result, err = s.v.REPLEval(instructions)
+	return result, false, err
+}
+
+// printsOutput matches calls of `puts` and `print`, whose expected results are printed output
+var printsOutput = regexp.MustCompile(`^(puts|print)\b`)
+
+// matches reports if the result is the expected one. Expected results that are Goby expressions are compared with
+// their inspections, so `{}` matches `{  }` and "a\nb" matches a string with a newline.
+// Strings can be expected without quotes too, like they're printed.
+func (s *session) matches(result, expected string) bool {
+	if result == expected || result == `"`+expected+`"` {
+		return true
+	}
+
+	evaluated, incomplete, err := s.eval(expected)
+	return !incomplete && err == nil && evaluated == result
+}
+
+// runExample runs the example's code in chunks that end with expected results, and compares their results
+func runExample(e Example) (DoctestFailure, bool) {
+	s := newSession()
+	lines := strings.Split(strings.TrimRight(e.Code, "\n"), "\n")
+	standalone := func(i int) bool {
+		return i >= 0 && i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), resultMarker)
+	}
+
+	chunk := []string{}
+	// last is the chunk's last line of code, which is shown in failures
+	last := ""
+
+	for i, line := range lines {
+		code, expected, checked := line, "", false
+
+		switch {
+		case standalone(i) && (standalone(i-1) || standalone(i+1)):
+			continue
+		case standalone(i):
+			code, expected, checked = "", strings.TrimSpace(line)[len(resultMarker):], true
+		case strings.Contains(line, resultMarker):
+			n := strings.LastIndex(line, resultMarker)
+			code, expected, checked = line[:n], line[n+len(resultMarker):], true
+		}
+
+		if strings.TrimSpace(code) != "" {
+			chunk = append(chunk, code)
+			last = strings.TrimSpace(code)
+		}
+
+		printed := printsOutput.MatchString(last)
+
+		if !checked {
+			continue
+		}
+
+		result, incomplete, err := s.eval(strings.Join(chunk, "\n"))
+
+		// Results in blocks can't be checked until their ends
+		if incomplete {
+			continue
+		}
+
+		chunk = nil
+		expected = strings.TrimSpace(expected)
+		f := DoctestFailure{Line: e.Line + i, Code: last, Expected: expected, Got: result}
+
+		if err != nil {
+			f.Got = err.Error()
+
+			// Examples can show the errors they raise
+			if f.Got != expected {
+				return f, false
+			}
+
+			continue
+		}
+
+		if !printed && !s.matches(result, expected) {
+			return f, false
+		}
+	}
+
+	if len(chunk) > 0 {
+		if _, _, err := s.eval(strings.Join(chunk, "\n")); err != nil {
+			return DoctestFailure{Line: e.Line + len(lines) - 1, Code: last, Got: err.Error()}, false
+		}
+	}
+
+	return DoctestFailure{}, true
+}
@@ -0,0 +1,176 @@
+package doc
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// JSONFile is the name of the JSON document WriteSite writes
+const JSONFile = "api.json"
+
+// WriteJSON writes the classes as a JSON array
+func WriteJSON(w io.Writer, classes []*Class) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(classes)
+}
+
+// WriteSite writes a static HTML site to dir, with an index page, a page for each class and the JSON document
+func WriteSite(dir string, classes []*Class) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	if err := writeFile(filepath.Join(dir, "index.html"), func(w io.Writer) error {
+		return pages.ExecuteTemplate(w, "index", classes)
+	}); err != nil {
+		return err
+	}
+
+	for _, c := range classes {
+		if err := writeFile(filepath.Join(dir, pageName(c.Name)), func(w io.Writer) error {
+			return pages.ExecuteTemplate(w, "class", struct {
+				*Class
+				Classes []*Class
+			}{c, classes})
+		}); err != nil {
+			return err
+		}
+	}
+
+	return writeFile(filepath.Join(dir, JSONFile), func(w io.Writer) error {
+		return WriteJSON(w, classes)
+	})
+}
+
+func writeFile(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+
+	if err != nil {
+		return err
+	}
+
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// pageName returns the file name of the class's page, like `Net-HTTP.html` for `Net::HTTP`
+func pageName(class string) string {
+	return strings.Replace(class, "::", "-", -1) + ".html"
+}
+
+// anchor returns the id of the method's section, like `c-new` or `i-each`
+func anchor(m *Method) string {
+	if m.ClassMethod {
+		return "c-" + m.Name
+	}
+
+	return "i-" + m.Name
+}
+
+var pages = template.Must(template.New("pages").Funcs(template.FuncMap{
+	"page":   pageName,
+	"anchor": anchor,
+	// summary is the first paragraph of a description
+	"summary": func(s string) string {
+		return strings.SplitN(s, "\n\n", 2)[0]
+	},
+	"paragraphs": func(s string) []string {
+		if s == "" {
+			return nil
+		}
+
+		return strings.Split(s, "\n\n")
+	},
+}).Parse(`{{define "head"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.}} - Goby API</title>
+<style>
+body { display: flex; margin: 0; font-family: sans-serif; line-height: 1.5; }
+nav { width: 14em; flex-shrink: 0; height: 100vh; overflow: auto; position: sticky; top: 0; padding: 1em; background: #f6f6f6; }
+nav ul { list-style: none; padding: 0; }
+main { padding: 1em 2em; max-width: 50em; }
+pre { background: #f6f6f6; padding: 0.5em 1em; overflow: auto; }
+.method { border-top: 1px solid #ddd; }
+.type { color: #777; }
+</style>
+</head>
+<body>
+{{end}}
+
+{{define "nav"}}<nav>
+<a href="index.html">Index</a>
+<ul>
+{{- range .}}
+<li><a href="{{page .Name}}">{{.Name}}</a></li>
+{{- end}}
+</ul>
+</nav>
+{{end}}
+
+{{define "examples"}}{{range .}}<pre><code class="language-ruby">{{.Code}}</code></pre>
+{{end}}{{end}}
+
+{{define "index"}}{{template "head" "Index"}}{{template "nav" .}}<main>
+<h1>Goby API</h1>
+<dl>
+{{- range .}}
+<dt><a href="{{page .Name}}">{{.Name}}</a>{{if .Builtin}} <span class="type">built-in</span>{{end}}</dt>
+<dd>{{summary .Description}}</dd>
+{{- end}}
+</dl>
+</main>
+</body>
+</html>
+{{end}}
+
+{{define "class"}}{{template "head" .Name}}{{template "nav" .Classes}}<main>
+<h1>{{.Name}}</h1>
+<p class="type">{{.File}}:{{.Line}}</p>
+{{range paragraphs .Description}}<p>{{.}}</p>
+{{end}}
+{{template "examples" .Examples}}
+<ul>
+{{- $class := .Name}}
+{{- range .Methods}}
+<li><a href="#{{anchor .}}">{{.FullName $class}}</a></li>
+{{- end}}
+</ul>
+{{- range .Methods}}
+<section class="method" id="{{anchor .}}">
+<h2>{{.FullName $class}}</h2>
+{{- if .Signature}}
+<pre><code>{{.Signature}}</code></pre>
+{{- end}}
+{{range paragraphs .Description}}<p>{{.}}</p>
+{{end}}
+{{- if .Params}}
+<h3>Parameters</h3>
+<ul>
+{{- range .Params}}
+<li><code>{{.Name}}</code>{{if .Type}} <span class="type">[{{.Type}}]</span>{{end}} {{.Description}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- with .Return}}
+<h3>Returns</h3>
+<p>{{if .Type}}<span class="type">[{{.Type}}]</span> {{end}}{{.Description}}</p>
+{{- end}}
+{{template "examples" .Examples}}
+</section>
+{{- end}}
+</main>
+</body>
+</html>
+{{end}}`))
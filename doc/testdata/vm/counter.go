@@ -0,0 +1,48 @@
+package vm
+
+// CounterObject counts things.
+//
+// ```ruby
+// Counter.new.count # => 0
+// ```
+type CounterObject struct{}
+
+func builtinCounterClassMethods() []*BuiltinMethodObject {
+	return []*BuiltinMethodObject{
+		{
+			// Returns a new counter.
+			//
+			// @return [Counter]
+			Name: "new",
+		},
+	}
+}
+
+func builtinCounterInstanceMethods() []*BuiltinMethodObject {
+	return []*BuiltinMethodObject{
+		{
+			// Adds the number to the sum.
+			//
+			// ```ruby
+			// 1 + 2 # => 3
+			// a = [1, 2].map do |i|
+			//   i * 2 # => 2
+			// end
+			// a # => [2, 4]
+			// puts(a)
+			// # => 2
+			// # => 4
+			// "a" # => a
+			// { a: 1 }.keys
+			// # => ["a"]
+			// ```
+			//
+			// @param n [Integer] the number
+			// @return [Integer] the sum
+			Name: "add",
+		},
+		{
+			Name: "count",
+		},
+	}
+}
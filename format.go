@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/goby-lang/goby/compiler/format"
+)
+
+// runFmt runs `goby fmt [-w] [-l] [files...]`, which prints formatted Goby files.
+// Goby files in given directories are formatted recursively, and stdin is formatted if there are no files.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	writeOptionPtr := flags.Bool("w", false, "Write results to source files instead of printing them")
+	listOptionPtr := flags.Bool("l", false, "List files whose formatting differs from goby fmt's")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: goby fmt [-w] [-l] [files...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		input, err := ioutil.ReadAll(os.Stdin)
+
+		if err == nil {
+			var output string
+			output, err = format.Source("<stdin>", string(input))
+			fmt.Print(output)
+		}
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+
+		return 0
+	}
+
+	return walkGobyFiles(flags.Args(), func(path string) error {
+		return formatFile(path, *writeOptionPtr, *listOptionPtr)
+	})
+}
+
+func formatFile(path string, write, list bool) error {
+	input, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	output, err := format.Source(path, string(input))
+
+	if err != nil {
+		return err
+	}
+
+	if list && output != string(input) {
+		fmt.Println(path)
+	}
+
+	if write {
+		if output == string(input) {
+			return nil
+		}
+
+		return ioutil.WriteFile(path, []byte(output), 0644)
+	}
+
+	if !list {
+		fmt.Print(output)
+	}
+
+	return nil
+}
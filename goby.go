@@ -1,14 +1,17 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/goby-lang/goby/compiler"
+	"github.com/goby-lang/goby/compiler/bytecode"
 	"github.com/goby-lang/goby/igb"
 	"github.com/goby-lang/goby/vm"
 	"github.com/pkg/profile"
@@ -16,13 +19,53 @@ import (
 
 const Version string = vm.Version
 
+// subcommands are tools that take their own arguments, like `goby fmt -w foo.gb`
+var subcommands = map[string]func(args []string) int{
+	"debug": runDebug,
+	"doc":   runDoc,
+	"fmt":   runFmt,
+	"lint":  runLint,
+	"lsp":   runLsp,
+	"test":  runTest,
+}
+
 func main() {
-	profileOptionPtr := flag.Bool("p", false, "Profile program execution")
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+
+	profileOptionPtr := flag.Bool("p", false, "Profile the Go interpreter with pprof")
+	gobyProfileOptionPtr := flag.String("profile", "", "Profile Goby methods, blocks and lines, write a pprof profile to the path and print a summary")
 	versionOptionPtr := flag.Bool("v", false, "Show current Goby version")
 	interactiveOptionPtr := flag.Bool("i", false, "Run interactive goby")
+	cacheStatsOptionPtr := flag.Bool("cache-stats", false, "Print inline method cache hits and misses after execution")
+	maxCallDepthOptionPtr := flag.Int("max-call-depth", vm.DefaultMaxCallDepth, "Maximum depth of method calls and blocks before StackOverflowError, 0 means no limit")
+	coverOptionPtr := flag.Bool("cover", false, "Record covered lines and branches, and merge them into lcov.info and index.html in -cover-dir")
+	coverDirOptionPtr := flag.String("cover-dir", "coverage", "Directory of coverage reports")
+	disableTCOOptionPtr := flag.Bool("disable-tco", false, "Disable tail call optimization, so backtraces keep every call")
+	disableOptOptionPtr := flag.String("disable-opt", "", "Disable comma separated bytecode optimization passes, or \"all\" of them")
+	enableOptOptionPtr := flag.String("enable-opt", "", "Enable comma separated bytecode optimization passes, like constant_folding which is off by default")
+	disassembleOptionPtr := flag.Bool("d", false, "Print the file's instruction sets instead of running it")
+	jsonOptionPtr := flag.Bool("json", false, "Print instruction sets of -d as JSON")
 
 	flag.Parse()
 
+	if *enableOptOptionPtr != "" {
+		if err := compiler.Optimizer.Enable(strings.Split(*enableOptOptionPtr, ",")...); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if *disableOptOptionPtr != "" {
+		if err := compiler.Optimizer.Disable(strings.Split(*disableOptOptionPtr, ",")...); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+
 	if *interactiveOptionPtr {
 		igb.StartIgb(Version)
 		os.Exit(0)
@@ -55,20 +98,159 @@ func main() {
 
 	switch fileExt {
 	case "gb", "rb":
-		instructionSets, err := compiler.CompileToInstructions(string(file))
+		instructionSets, err := compiler.CompileFileToInstructions(filepath, string(file))
 
 		if err != nil {
 			fmt.Println(err.Error())
 			return
 		}
 
-		v := vm.New(dir, args)
+		if *disassembleOptionPtr {
+			if !disassemble(instructionSets, filepath, *jsonOptionPtr) {
+				os.Exit(1)
+			}
+
+			return
+		}
+
+		options := []vm.Option{vm.WithMaxCallDepth(*maxCallDepthOptionPtr), vm.WithTailCallOptimization(!*disableTCOOptionPtr)}
+		var profiler *vm.Profiler
+		var coverage *vm.Coverage
+
+		if *gobyProfileOptionPtr != "" {
+			profiler = vm.NewProfiler(0)
+			options = append(options, vm.WithProfiler(profiler))
+		}
+
+		if *coverOptionPtr {
+			coverage = vm.NewCoverage()
+			options = append(options, vm.WithCoverage(coverage))
+		}
+
+		v := vm.New(dir, args, options...)
 		v.ExecInstructions(instructionSets, filepath)
+
+		if profiler != nil {
+			profiler.Stop()
+			writeProfile(profiler, *gobyProfileOptionPtr)
+		}
+
+		if coverage != nil {
+			writeCoverage(coverage, *coverDirOptionPtr)
+		}
+
+		if *cacheStatsOptionPtr {
+			printMethodCacheStats(v.MethodCacheStats())
+		}
 	default:
 		fmt.Printf("Unknown file extension: %s", fileExt)
 	}
 }
 
+// disassemble prints the instruction sets as text or JSON, it returns false if they can't be disassembled
+func disassemble(sets []*bytecode.InstructionSet, file string, asJSON bool) bool {
+	disassembly, err := vm.Disassemble(sets, file)
+
+	if err == nil {
+		if asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			encoder.SetEscapeHTML(false)
+			err = encoder.Encode(disassembly)
+		} else {
+			err = vm.WriteDisassembly(os.Stdout, disassembly)
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return false
+	}
+
+	return true
+}
+
+func printMethodCacheStats(stats vm.MethodCacheStats) {
+	var rate float64
+
+	if total := stats.Hits + stats.Misses; total > 0 {
+		rate = float64(stats.Hits) / float64(total) * 100
+	}
+
+	fmt.Fprintf(os.Stderr, "Method cache: %d hits, %d misses (%.1f%% hit rate)\n", stats.Hits, stats.Misses, rate)
+}
+
+// writeProfile writes the pprof profile to the path, and prints the summary to stderr
+func writeProfile(p *vm.Profiler, path string) {
+	p.WriteSummary(os.Stderr)
+	f, err := os.Create(path)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return
+	}
+
+	defer f.Close()
+
+	if err := p.WritePprof(f); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}
+
+// writeCoverage merges the coverage with the directory's lcov.info, writes lcov.info and index.html,
+// and prints the total coverage to stderr
+func writeCoverage(c *vm.Coverage, dir string) {
+	lcovPath := filepath.Join(dir, "lcov.info")
+
+	if f, err := os.Open(lcovPath); err == nil {
+		err = c.MergeLCOV(f)
+		f.Close()
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return
+	}
+
+	reports := []struct {
+		path  string
+		write func(w io.Writer) error
+	}{
+		{lcovPath, c.WriteLCOV},
+		{filepath.Join(dir, "index.html"), c.WriteHTML},
+	}
+
+	for _, report := range reports {
+		f, err := os.Create(report.path)
+
+		if err == nil {
+			err = report.write(f)
+			f.Close()
+		}
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return
+		}
+	}
+
+	var total vm.FileCoverage
+
+	for _, f := range c.Files() {
+		total.Lines += f.Lines
+		total.LinesHit += f.LinesHit
+		total.Branches += f.Branches
+		total.BranchesTaken += f.BranchesTaken
+	}
+
+	fmt.Fprintf(os.Stderr, "Coverage: %d/%d lines, %d/%d branches, see %s\n", total.LinesHit, total.Lines, total.BranchesTaken, total.Branches, filepath.Join(dir, "index.html"))
+}
+
 func extractFileInfo(fp string) (dir, filename, fileExt string) {
 	dir, filename = filepath.Split(fp)
 	dir, _ = filepath.Abs(dir)
@@ -78,6 +260,38 @@ func extractFileInfo(fp string) (dir, filename, fileExt string) {
 	return
 }
 
+// walkGobyFiles calls fn with given files, and Goby files in given directories recursively.
+// It prints errors fn returns, and returns 1 if there is any, or 0 if there's none.
+func walkGobyFiles(paths []string, fn func(path string) error) int {
+	status := 0
+
+	for _, path := range paths {
+		err := filepath.Walk(path, func(fp string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() || fp != path && filepath.Ext(fp) != ".gb" {
+				return nil
+			}
+
+			if err := fn(fp); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				status = 1
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			status = 1
+		}
+	}
+
+	return status
+}
+
 func readFile(filepath string) (file []byte, ok bool) {
 	file, err := ioutil.ReadFile(filepath)
 
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/chzyer/readline"
+	"github.com/goby-lang/goby/compiler"
 	"github.com/goby-lang/goby/compiler/bytecode"
 	"github.com/goby-lang/goby/compiler/lexer"
 	"github.com/goby-lang/goby/compiler/parser"
@@ -29,6 +30,7 @@ const (
 	exit      = "exit"
 	help      = "help"
 	reset     = "reset"
+	disasm    = "disasm"
 
 	readyToExec = "readyToExec"
 	Waiting     = "waiting"
@@ -119,6 +121,10 @@ reset:
 			println(prompt(igb.indents) + igb.lines)
 			usage(igb.rl.Stderr(), igb.completer)
 			continue
+		case igb.lines == disasm || strings.HasPrefix(igb.lines, disasm+" "):
+			println(prompt(igb.indents) + igb.lines)
+			disassemble(os.Stdout, strings.TrimSpace(strings.TrimPrefix(igb.lines, disasm)))
+			continue
 		case igb.lines == reset:
 			igb.rl = nil
 			igb.cmds = nil
@@ -202,7 +208,10 @@ reset:
 		if igb.sm.Is(readyToExec) {
 			println(prompt(igb.indents) + igb.lines)
 			instructions := ivm.g.GenerateInstructions(program.Statements)
+			compiler.Optimizer.Optimize(instructions)
 			ivm.v.REPLExec(instructions)
+			// Sets are already evaluated, so next input only needs its own ones
+			ivm.g.ResetInstructionSets()
 
 			r := ivm.v.GetREPLResult()
 
@@ -243,6 +252,7 @@ func newIgb() *iGb {
 		),
 		completer: readline.NewPrefixCompleter(
 			readline.PcItem(help),
+			readline.PcItem(disasm),
 			readline.PcItem(reset),
 			readline.PcItem(exit),
 		),
@@ -254,8 +264,6 @@ func newIgb() *iGb {
 func newIVM() iVM {
 	ivm := iVM{}
 	ivm.v = vm.New(os.Getenv("GOBY_ROOT"), []string{})
-	ivm.v.SetClassISIndexTable("")
-	ivm.v.SetMethodISIndexTable("")
 	ivm.v.InitForREPL()
 	// Initialize parser, lexer is not important here
 	ivm.p = parser.New(lexer.New(""))
@@ -295,6 +303,30 @@ func usage(w io.Writer, c *readline.PrefixCompleter) {
 	io.WriteString(w, c.Tree("   "))
 }
 
+// disassemble prints instruction sets of the code, which is compiled on its own
+// so it doesn't see variables defined in iGb.
+func disassemble(w io.Writer, code string) {
+	if code == "" {
+		fmt.Fprintln(w, "usage: disasm <code>")
+		return
+	}
+
+	sets, err := compiler.CompileToInstructions(code)
+
+	if err == nil {
+		var disassembly []vm.DisassembledSet
+		disassembly, err = vm.Disassemble(sets, "")
+
+		if err == nil {
+			err = vm.WriteDisassembly(w, disassembly)
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintln(w, err.Error())
+	}
+}
+
 // indent performs indentation with space padding.
 func indent(c int) string {
 	var s string
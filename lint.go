@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/goby-lang/goby/compiler"
+	"github.com/goby-lang/goby/lint"
+)
+
+// runLint runs `goby lint [-json] [-disable rules] [files...]`, which prints problems of Goby files.
+// Goby files in given directories are checked recursively, and stdin is checked if there are no files.
+// It returns 1 if there's any problem.
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	jsonOptionPtr := flags.Bool("json", false, "Print problems and syntax errors as a JSON array")
+	disableOptionPtr := flags.String("disable", "", "Disable comma separated rules: "+strings.Join(lint.Rules, ", "))
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: goby lint [-json] [-disable rules] [files...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	config := lint.Config{}
+
+	if *disableOptionPtr != "" {
+		config.Disabled = strings.Split(*disableOptionPtr, ",")
+	}
+
+	if err := config.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	problems := []*lint.Problem{}
+
+	lintFile := func(path string) error {
+		var input []byte
+		var err error
+
+		if path == "<stdin>" {
+			input, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			input, err = ioutil.ReadFile(path)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		ps, err := lint.Source(path, string(input), config)
+
+		// Syntax errors are problems too in JSON, so CI can show all of them
+		if ds, ok := err.(compiler.Diagnostics); ok && *jsonOptionPtr {
+			for _, d := range ds {
+				ps = append(ps, &lint.Problem{File: d.File, Line: d.Line, Column: d.Column, Rule: "syntax-error", Message: d.Message})
+			}
+
+			err = nil
+		}
+
+		problems = append(problems, ps...)
+		return err
+	}
+
+	status := 0
+
+	if flags.NArg() == 0 {
+		if err := lintFile("<stdin>"); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			status = 1
+		}
+	} else {
+		status = walkGobyFiles(flags.Args(), lintFile)
+	}
+
+	if *jsonOptionPtr {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(problems)
+	} else {
+		for _, p := range problems {
+			fmt.Println(p.String())
+		}
+	}
+
+	if len(problems) > 0 {
+		return 1
+	}
+
+	return status
+}
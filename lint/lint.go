@@ -0,0 +1,521 @@
+// Package lint checks Goby programs for common mistakes, which `goby lint` reports.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/goby-lang/goby/compiler"
+	"github.com/goby-lang/goby/compiler/ast"
+	"github.com/goby-lang/goby/compiler/bytecode"
+	"github.com/goby-lang/goby/compiler/lexer"
+	"github.com/goby-lang/goby/compiler/token"
+	"github.com/goby-lang/goby/vm"
+)
+
+// Names of rules
+const (
+	// UnusedVariable reports local variables that are assigned but never used.
+	// Parameters and variables starting with `_` are ignored.
+	UnusedVariable = "unused-variable"
+	// UseBeforeAssignment reports local variables that are used before they are assigned in the same scope,
+	// which are method calls there
+	UseBeforeAssignment = "use-before-assignment"
+	// UnreachableCode reports statements after `return`, `next` or `break`
+	UnreachableCode = "unreachable-code"
+	// DuplicateMethod reports methods that are defined twice in a class, module or the program
+	DuplicateMethod = "duplicate-method"
+	// UndefinedMethod reports methods that literals and built-in classes don't have, like `1.lenght`,
+	// unless the program defines methods with the name
+	UndefinedMethod = "undefined-method"
+)
+
+// Rules are all rules, which are checked unless Config disables them
+var Rules = []string{UnusedVariable, UseBeforeAssignment, UnreachableCode, DuplicateMethod, UndefinedMethod}
+
+// Config configures which rules are checked
+type Config struct {
+	// Disabled rules aren't checked
+	Disabled []string
+}
+
+// Validate returns an error if Config has unknown rules
+func (c *Config) Validate() error {
+	for _, rule := range c.Disabled {
+		if !isRule(rule) {
+			return fmt.Errorf("Unknown lint rule: %s", rule)
+		}
+	}
+
+	return nil
+}
+
+func isRule(name string) bool {
+	for _, rule := range Rules {
+		if rule == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Problem is a mistake found by a rule
+type Problem struct {
+	File string `json:"file"`
+	// Line and Column start from 1, like editors show them
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (p *Problem) String() string {
+	position := fmt.Sprintf("%d:%d", p.Line, p.Column)
+
+	if p.File != "" {
+		position = p.File + ":" + position
+	}
+
+	return fmt.Sprintf("%s: %s: %s", position, p.Rule, p.Message)
+}
+
+// Source checks input source code, and returns problems sorted by their positions.
+// Comments like `# lint:ignore rule...` ignore the rules' problems at their line,
+// or the next line if they have their own lines, and `# lint:file-ignore rule...` ignore them in the file.
+// Problems of all rules are ignored if the comments don't have rules.
+// Syntax errors are returned as compiler.Diagnostics.
+func Source(filename, input string, config Config) ([]*Problem, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	program, err := compiler.ParseFile(filename, input)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &linter{
+		filename:  filename,
+		variables: map[variableKey]*variable{},
+		methods:   map[string]bool{},
+	}
+
+	l.checkStatements(program.Statements, bytecode.NewLocalTable())
+	l.checkVariables()
+	l.checkCalls()
+
+	disabled := newIgnores(input)
+
+	for _, rule := range config.Disabled {
+		disabled.file[rule] = true
+	}
+
+	var problems []*Problem
+
+	for _, p := range l.problems {
+		if !disabled.ignores(p) {
+			problems = append(problems, p)
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line || problems[i].Line == problems[j].Line && problems[i].Column < problems[j].Column
+	})
+
+	return problems, nil
+}
+
+type variableKey struct {
+	table *bytecode.LocalTable
+	index int
+}
+
+type variable struct {
+	name string
+	tok  token.Token
+	used bool
+}
+
+// read is an identifier that isn't a local variable where it's used
+type read struct {
+	tok   token.Token
+	table *bytecode.LocalTable
+}
+
+// call is a method call on a literal or built-in class
+type call struct {
+	tok         token.Token
+	className   string
+	method      string
+	classMethod bool
+}
+
+type linter struct {
+	filename string
+	problems []*Problem
+	// variables are local variables, order is assigned ones in the order they are assigned,
+	// and reads are identifiers that aren't variables
+	variables map[variableKey]*variable
+	order     []*variable
+	reads     []read
+	// calls are checked after all methods the program defines are found
+	calls   []call
+	methods map[string]bool
+}
+
+func (l *linter) report(tok token.Token, rule, format string, args ...interface{}) {
+	l.problems = append(l.problems, &Problem{
+		File:    l.filename,
+		Line:    tok.Line + 1,
+		Column:  tok.Column + 1,
+		Rule:    rule,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// checkStatements checks statements of a body, whose local variables are in the table
+func (l *linter) checkStatements(stmts []ast.Statement, table *bytecode.LocalTable) {
+	var exit ast.Statement
+
+	for _, stmt := range stmts {
+		// Semicolons are parsed as empty statements
+		if s, ok := stmt.(*ast.ExpressionStatement); ok && s.Expression == nil {
+			continue
+		}
+
+		if exit != nil {
+			// Only the first unreachable statement is reported
+			l.report(statementToken(stmt), UnreachableCode, "unreachable code after %s", statementToken(exit).Literal)
+			exit = nil
+		}
+
+		l.checkStatement(stmt, table)
+
+		switch stmt.(type) {
+		case *ast.ReturnStatement, *ast.NextStatement, *ast.BreakStatement:
+			exit = stmt
+		}
+	}
+
+	l.checkDuplicateMethods(stmts)
+}
+
+func (l *linter) checkStatement(stmt ast.Statement, table *bytecode.LocalTable) {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		l.checkExpression(stmt.Expression, table)
+	case *ast.ReturnStatement:
+		l.checkExpression(stmt.ReturnValue, table)
+	case *ast.DefStatement:
+		l.methods[stmt.Name.Value] = true
+		methodTable := bytecode.NewLocalTable()
+
+		for _, param := range stmt.Parameters {
+			switch param := param.(type) {
+			case *ast.Identifier:
+				l.define(param, methodTable)
+			case *ast.AssignExpression:
+				l.checkExpression(param.Value, methodTable)
+				l.define(param.Variables[0].(*ast.Identifier), methodTable)
+			}
+		}
+
+		l.checkStatements(stmt.BlockStatement.Statements, methodTable)
+	case *ast.ClassStatement:
+		l.checkExpression(stmt.SuperClass, table)
+		l.checkStatements(stmt.Body.Statements, bytecode.NewLocalTable())
+	case *ast.ModuleStatement:
+		l.checkStatements(stmt.Body.Statements, bytecode.NewLocalTable())
+	case *ast.WhileStatement:
+		l.checkExpression(stmt.Condition, table)
+		l.checkStatements(stmt.Body.Statements, table)
+	}
+}
+
+func (l *linter) checkExpression(exp ast.Expression, table *bytecode.LocalTable) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		index, depth, ok := table.Lookup(exp.Value)
+
+		if !ok {
+			l.reads = append(l.reads, read{tok: exp.Token, table: table})
+			return
+		}
+
+		l.variables[key(table, index, depth)].used = true
+	case *ast.AssignExpression:
+		l.checkExpression(exp.Value, table)
+
+		for _, v := range exp.Variables {
+			if ident, ok := v.(*ast.Identifier); ok {
+				index, depth := table.Assign(ident.Value)
+				k := key(table, index, depth)
+
+				if _, ok := l.variables[k]; !ok {
+					v := &variable{name: ident.Value, tok: ident.Token}
+					l.variables[k] = v
+					l.order = append(l.order, v)
+				}
+			}
+		}
+	case *ast.CallExpression:
+		l.checkExpression(exp.Receiver, table)
+
+		for _, arg := range exp.Arguments {
+			l.checkExpression(arg, table)
+		}
+
+		l.addCall(exp)
+
+		if exp.Block != nil {
+			blockTable := table.NewBlockTable()
+
+			for _, arg := range exp.BlockArguments {
+				l.define(arg, blockTable)
+			}
+
+			l.checkStatements(exp.Block.Statements, blockTable)
+		}
+	case *ast.YieldExpression:
+		for _, arg := range exp.Arguments {
+			l.checkExpression(arg, table)
+		}
+	case *ast.IfExpression:
+		l.checkExpression(exp.Condition, table)
+		l.checkStatements(exp.Consequence.Statements, table)
+
+		if exp.Alternative != nil {
+			l.checkStatements(exp.Alternative.Statements, table)
+		}
+	case *ast.InfixExpression:
+		l.checkExpression(exp.Left, table)
+		l.checkExpression(exp.Right, table)
+	case *ast.PrefixExpression:
+		l.checkExpression(exp.Right, table)
+	case *ast.RangeExpression:
+		l.checkExpression(exp.Start, table)
+		l.checkExpression(exp.End, table)
+	case *ast.ArrayExpression:
+		for _, elem := range exp.Elements {
+			l.checkExpression(elem, table)
+		}
+	case *ast.HashExpression:
+		for _, k := range exp.Keys {
+			l.checkExpression(exp.Data[k], table)
+		}
+	}
+}
+
+// define sets a parameter in the table
+func (l *linter) define(param *ast.Identifier, table *bytecode.LocalTable) {
+	index := table.Define(param.Value)
+	// Parameters aren't in order, since they aren't reported if they're unused
+	l.variables[variableKey{table: table, index: index}] = &variable{name: param.Value, tok: param.Token}
+}
+
+// key returns the key of a variable, which is in the table's depth-th upper table
+func key(table *bytecode.LocalTable, index, depth int) variableKey {
+	for i := 0; i < depth; i++ {
+		table = table.Upper()
+	}
+
+	return variableKey{table: table, index: index}
+}
+
+func (l *linter) checkVariables() {
+	for _, v := range l.order {
+		if !v.used && !strings.HasPrefix(v.name, "_") {
+			l.report(v.tok, UnusedVariable, "%s is assigned but never used", v.name)
+		}
+	}
+
+	// Identifiers that aren't variables where they're used are method calls,
+	// which are probably mistakes if variables with their names are assigned later.
+	for _, r := range l.reads {
+		if _, _, ok := r.table.Lookup(r.tok.Literal); ok {
+			l.report(r.tok, UseBeforeAssignment, "%s is used before it's assigned, so it's a method call here", r.tok.Literal)
+		}
+	}
+}
+
+func (l *linter) checkDuplicateMethods(stmts []ast.Statement) {
+	defined := map[string]token.Token{}
+
+	for _, stmt := range stmts {
+		def, ok := stmt.(*ast.DefStatement)
+
+		if !ok {
+			continue
+		}
+
+		name := def.Name.Value
+
+		if def.Receiver != nil {
+			name = def.Receiver.String() + "." + name
+		}
+
+		if tok, ok := defined[name]; ok {
+			l.report(def.Name.Token, DuplicateMethod, "%s is already defined at line %d", name, tok.Line+1)
+			continue
+		}
+
+		defined[name] = def.Name.Token
+	}
+}
+
+// attrMethods are methods that define methods of their arguments' names
+var attrMethods = map[string]bool{"attr_reader": true, "attr_writer": true, "attr_accessor": true}
+
+// addCall records calls on literals or constants, or methods defined by attr methods
+func (l *linter) addCall(exp *ast.CallExpression) {
+	c := call{tok: exp.Token, method: exp.Method}
+
+	switch receiver := exp.Receiver.(type) {
+	case *ast.Constant:
+		c.className = receiver.Value
+		c.classMethod = true
+	case *ast.IntegerLiteral:
+		c.className = "Integer"
+	case *ast.StringLiteral:
+		c.className = "String"
+	case *ast.BooleanExpression:
+		c.className = "Boolean"
+	case *ast.NilExpression:
+		c.className = "Null"
+	case *ast.ArrayExpression:
+		c.className = "Array"
+	case *ast.HashExpression:
+		c.className = "Hash"
+	case *ast.RangeExpression:
+		c.className = "Range"
+	case *ast.SelfExpression:
+		if attrMethods[exp.Method] {
+			for _, arg := range exp.Arguments {
+				if name, ok := arg.(*ast.StringLiteral); ok {
+					l.methods[name.Value] = true
+					l.methods[name.Value+"="] = true
+				}
+			}
+		}
+
+		return
+	default:
+		return
+	}
+
+	l.calls = append(l.calls, c)
+}
+
+func (l *linter) checkCalls() {
+	if len(l.calls) == 0 {
+		return
+	}
+
+	v := vm.New("", []string{})
+
+	for _, c := range l.calls {
+		// Programs can add methods to built-in classes
+		if l.methods[c.method] {
+			continue
+		}
+
+		if has, ok := v.HasBuiltInMethod(c.className, c.method, c.classMethod); ok && !has {
+			receiver := c.className
+
+			if !c.classMethod {
+				receiver = "an instance of " + receiver
+			}
+
+			l.report(c.tok, UndefinedMethod, "undefined method %s for %s", c.method, receiver)
+		}
+	}
+}
+
+func statementToken(stmt ast.Statement) token.Token {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		return stmt.Token
+	case *ast.ReturnStatement:
+		return stmt.Token
+	case *ast.DefStatement:
+		return stmt.Token
+	case *ast.ClassStatement:
+		return stmt.Token
+	case *ast.ModuleStatement:
+		return stmt.Token
+	case *ast.WhileStatement:
+		return stmt.Token
+	case *ast.NextStatement:
+		return stmt.Token
+	case *ast.BreakStatement:
+		return stmt.Token
+	}
+
+	return token.Token{}
+}
+
+// ignores are rules ignored by comments
+type ignores struct {
+	// lines map lines to their ignored rules, and an empty rule means all rules
+	lines map[int]map[string]bool
+	file  map[string]bool
+}
+
+func newIgnores(input string) *ignores {
+	ig := &ignores{lines: map[int]map[string]bool{}, file: map[string]bool{}}
+	l := lexer.New(input)
+	// last is the line of the last token that isn't a comment
+	last := -1
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type != token.Comment {
+			last = tok.Line + strings.Count(tok.Literal, "\n")
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimPrefix(tok.Literal, "#"))
+
+		if len(fields) == 0 {
+			continue
+		}
+
+		rules := map[string]bool{}
+
+		for _, field := range fields[1:] {
+			rules[strings.TrimSuffix(field, ",")] = true
+		}
+
+		if len(rules) == 0 {
+			rules[""] = true
+		}
+
+		switch fields[0] {
+		case "lint:file-ignore":
+			for rule := range rules {
+				ig.file[rule] = true
+			}
+		case "lint:ignore":
+			line := tok.Line
+
+			// A comment in its own line ignores problems at the next line
+			if last != tok.Line {
+				line++
+			}
+
+			ig.lines[line] = rules
+		}
+	}
+
+	return ig
+}
+
+func (ig *ignores) ignores(p *Problem) bool {
+	if ig.file[""] || ig.file[p.Rule] {
+		return true
+	}
+
+	rules := ig.lines[p.Line-1]
+	return rules[""] || rules[p.Rule]
+}
@@ -0,0 +1,177 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/goby-lang/goby/compiler"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`
+x = 1
+_y = 2
+z = 3
+i = 0
+i += 1
+puts(z)
+`, []string{"2:1 unused-variable"}},
+		{`
+def foo(a, b = 1)
+  a
+end
+
+foo(1) do |c|
+  d = 1
+  e = 2
+  foo do
+    e = 3
+  end
+end
+`, []string{"7:3 unused-variable", "8:3 unused-variable"}},
+		{`
+puts(a)
+a = 1
+a
+
+def bar
+  b
+  b = 2
+  b
+end
+
+bar do
+  c
+end
+c = 3
+c
+`, []string{"2:6 use-before-assignment", "7:3 use-before-assignment", "13:3 use-before-assignment"}},
+		{`
+def foo
+  return 1
+  puts(2)
+  puts(3)
+end
+
+[1].each do |i|
+  if i > 0
+    next
+    puts(i)
+  end
+end
+
+while true do
+  break
+  puts(1)
+end
+`, []string{"4:3 unreachable-code", "11:5 unreachable-code", "17:3 unreachable-code"}},
+		{`
+def foo; end
+def foo; end
+
+class Foo
+  def bar; end
+  def self.bar; end
+  def bar; end
+
+  module Baz
+    def bar; end
+  end
+end
+`, []string{"3:5 duplicate-method", "8:7 duplicate-method"}},
+		{`
+1.to_s
+1.lenght
+"s".size + [].pusj(1) + {}.length
+Channel.new
+Channel.neww
+Foo.bar
+foo.bar
+nil.to_s
+`, []string{"3:2 undefined-method", "4:14 undefined-method", "6:8 undefined-method"}},
+		{`
+class String
+  def shout; end
+  attr_reader :loudness
+end
+
+"s".shout
+"s".loudness
+"s".loudness = 1
+`, nil},
+	}
+
+	for i, tt := range tests {
+		problems, err := Source("", tt.input, Config{})
+
+		if err != nil {
+			t.Fatalf("At case %d: %s", i, err.Error())
+		}
+
+		checkProblems(t, i, problems, tt.expected)
+	}
+}
+
+func TestSourceIgnores(t *testing.T) {
+	input := `# lint:file-ignore duplicate-method
+x = 1 # lint:ignore
+y = 2 # lint:ignore undefined-method
+# lint:ignore unused-variable, undefined-method
+z = 3
+w = 1.bar # lint:ignore unused-variable
+def foo; end
+def foo; end
+`
+
+	problems, err := Source("", input, Config{})
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	checkProblems(t, 0, problems, []string{"3:1 unused-variable", "6:6 undefined-method"})
+
+	problems, _ = Source("", input, Config{Disabled: []string{UnusedVariable}})
+	checkProblems(t, 1, problems, []string{"6:6 undefined-method"})
+}
+
+func TestSourceErrors(t *testing.T) {
+	_, err := Source("", "x = 1", Config{Disabled: []string{"foo"}})
+
+	if err == nil || err.Error() != "Unknown lint rule: foo" {
+		t.Fatalf("Expect unknown rule error. got: %v", err)
+	}
+
+	_, err = Source("foo.gb", "def foo(a\nend", Config{})
+
+	if _, ok := err.(compiler.Diagnostics); !ok {
+		t.Fatalf("Expect Diagnostics. got: %T", err)
+	}
+}
+
+func TestProblemString(t *testing.T) {
+	problems, _ := Source("foo.gb", "\n  x = 1", Config{})
+	expected := "foo.gb:2:3: unused-variable: x is assigned but never used"
+
+	if len(problems) != 1 || problems[0].String() != expected {
+		t.Fatalf("Expect problem %q. got: %v", expected, problems)
+	}
+}
+
+func checkProblems(t *testing.T, index int, problems []*Problem, expected []string) {
+	t.Helper()
+	var got []string
+
+	for _, p := range problems {
+		got = append(got, fmt.Sprintf("%d:%d %s", p.Line, p.Column, p.Rule))
+	}
+
+	if strings.Join(got, ", ") != strings.Join(expected, ", ") {
+		t.Fatalf("At case %d expect problems: %v. got: %v", index, expected, problems)
+	}
+}
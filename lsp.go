@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/goby-lang/goby/lsp"
+	"github.com/goby-lang/goby/vm"
+)
+
+// runLsp runs `goby lsp`, which is a language server that talks with editors through stdin and stdout.
+// Built-in methods' comments are read from the VM's source code in $GOBY_ROOT/vm.
+func runLsp(args []string) int {
+	flags := flag.NewFlagSet("lsp", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: goby lsp")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	// This is the same root the VM loads standard libraries from
+	root := os.Getenv("GOBY_ROOT")
+
+	if root == "" {
+		root = fmt.Sprintf("/usr/local/Cellar/goby/%s/", vm.Version)
+	}
+
+	docs, err := lsp.LoadDocs(filepath.Join(root, "vm"))
+
+	// Editors show stderr in their logs, and the server works without the comments
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Can't load built-in methods' comments: "+err.Error())
+	}
+
+	if err := lsp.NewServer(os.Stdin, os.Stdout, docs).Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	return 0
+}
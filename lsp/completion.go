@@ -0,0 +1,185 @@
+package lsp
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/goby-lang/goby/compiler/lexer"
+	"github.com/goby-lang/goby/compiler/token"
+)
+
+// completer collects completion items whose labels start with prefix
+type completer struct {
+	server *Server
+	index  *index
+	prefix string
+	items  []*CompletionItem
+	seen   map[string]bool
+}
+
+// completion completes method names after dots, and method, class and module names elsewhere.
+// Methods of literals and built-in classes are completed after their dots, and methods of other receivers are guessed,
+// which are methods defined in the document and Object's methods.
+func (s *Server) completion(params json.RawMessage) (interface{}, error) {
+	p := &textDocumentPositionParams{}
+
+	if err := decode(params, p); err != nil {
+		return nil, err
+	}
+
+	doc, ok := s.documents[p.TextDocument.URI]
+
+	if !ok {
+		return []*CompletionItem{}, nil
+	}
+
+	// The line is from the current text, which may not parse yet since the user is typing it
+	before := lineText(doc.lines, p.Position.Line)[:fromPosition(doc.lines, p.Position)]
+	prefix := before[len(strings.TrimRightFunc(before, isNameRune)):]
+	before = before[:len(before)-len(prefix)]
+
+	c := &completer{server: s, index: doc.index, prefix: prefix, items: []*CompletionItem{}, seen: map[string]bool{}}
+
+	if strings.HasSuffix(before, ".") {
+		receiver, classMethod := receiverClass(strings.TrimSuffix(before, "."))
+
+		switch {
+		case receiver == "":
+			c.addMethods("", false)
+			c.addBuiltInMethods("Object", false)
+		case classMethod:
+			c.addMethods(receiver, true)
+
+			if !c.addBuiltInMethods(receiver, true) {
+				// Classes defined in the document have the methods every class has
+				c.addBuiltInMethods("Object", true)
+			}
+		default:
+			c.addMethods(receiver, false)
+			c.addBuiltInMethods(receiver, false)
+		}
+	} else {
+		c.addMethods("", false)
+		c.addBuiltInMethods("Object", false)
+		c.addClasses()
+	}
+
+	sort.SliceStable(c.items, func(i, j int) bool {
+		return c.items[i].Label < c.items[j].Label
+	})
+
+	return c.items, nil
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// receiverClass returns the class of the receiver at the end of the text if it's a literal,
+// or the constant at the end of the text, whose class methods are called
+func receiverClass(text string) (className string, classMethod bool) {
+	var last token.Token
+	l := lexer.New(text)
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type != token.Comment {
+			last = tok
+		}
+	}
+
+	switch last.Type {
+	case token.Constant:
+		return last.Literal, true
+	case token.Int:
+		return "Integer", false
+	case token.String:
+		return "String", false
+	case token.True, token.False:
+		return "Boolean", false
+	case token.Null:
+		return "Null", false
+	}
+
+	return "", false
+}
+
+func (c *completer) add(item *CompletionItem) {
+	if !strings.HasPrefix(item.Label, c.prefix) || c.seen[item.Label] {
+		return
+	}
+
+	c.seen[item.Label] = true
+	c.items = append(c.items, item)
+}
+
+// addMethods adds methods defined in the document, which are the owner's if it's not empty
+func (c *completer) addMethods(owner string, classMethod bool) {
+	if c.index == nil {
+		return
+	}
+
+	// Names are sorted, so the first method with a name in the document is completed if there are many
+	for _, name := range sortedKeys(c.index.methods) {
+		for _, m := range c.index.methods[name] {
+			if m.classMethod != classMethod || owner != "" && m.owner != owner {
+				continue
+			}
+
+			item := &CompletionItem{Label: m.name.Literal, Kind: completionMethod, Detail: m.signature}
+
+			if m.doc != "" {
+				item.Documentation = &MarkupContent{Kind: "markdown", Value: m.doc}
+			}
+
+			c.add(item)
+		}
+	}
+}
+
+// addBuiltInMethods adds the built-in class's methods, and returns false if there's no such class
+func (c *completer) addBuiltInMethods(className string, classMethod bool) bool {
+	names := c.server.builtInMethods(className, classMethod)
+
+	for _, name := range names {
+		item := &CompletionItem{Label: name, Kind: completionMethod, Detail: methodName(className, name, classMethod)}
+
+		if doc, ok := c.server.docs.method(className, name, classMethod); ok {
+			item.Documentation = &MarkupContent{Kind: "markdown", Value: doc}
+		}
+
+		c.add(item)
+	}
+
+	return names != nil
+}
+
+// addClasses adds classes and modules defined in the document
+func (c *completer) addClasses() {
+	if c.index == nil {
+		return
+	}
+
+	for _, name := range sortedKeys(c.index.classes) {
+		classes := c.index.classes[name]
+		kind := completionClass
+
+		if strings.HasPrefix(classes[0].signature, "module ") {
+			kind = completionModule
+		}
+
+		c.add(&CompletionItem{Label: name, Kind: kind, Detail: classes[0].signature})
+	}
+}
+
+func sortedKeys(definitions map[string][]*definition) []string {
+	var keys []string
+
+	for key := range definitions {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,219 @@
+package lsp
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Docs are built-in methods' comments, which are in the VM's source code like:
+//
+//	{
+//		// Returns the sum of self and another Integer.
+//		// @return [Integer]
+//		Name: "+",
+//		Fn: ...
+//	},
+type Docs struct {
+	// methods map names like "Integer#to_s" and "Integer.new" to Markdown
+	methods map[string]string
+}
+
+// methodListName matches functions and variables that return built-in methods, like builtinIntegerInstanceMethods
+var methodListName = regexp.MustCompile(`^built[iI]n(\w+?)(Class|Instance)Methods$`)
+
+// LoadDocs reads built-in methods' comments from the VM's Go files in dir, which is usually $GOBY_ROOT/vm
+func LoadDocs(dir string) (*Docs, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("No Go files in %s", dir)
+	}
+
+	d := &Docs{methods: map[string]string{}}
+	fset := token.NewFileSet()
+
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				d.addMethods(fset, file, decl.Name.Name, decl.Body)
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					if spec, ok := spec.(*ast.ValueSpec); ok && len(spec.Names) == 1 && len(spec.Values) == 1 {
+						d.addMethods(fset, file, spec.Names[0].Name, spec.Values[0])
+					}
+				}
+			}
+		}
+	}
+
+	return d, nil
+}
+
+// addMethods adds comments of methods in node if name is a method list's name
+func (d *Docs) addMethods(fset *token.FileSet, file *ast.File, name string, node ast.Node) {
+	match := methodListName.FindStringSubmatch(name)
+
+	if match == nil || node == nil {
+		return
+	}
+
+	className := match[1]
+	separator := "#"
+
+	// Common methods are Object's
+	if className == "Common" {
+		className = "Object"
+	}
+
+	if match[2] == "Class" {
+		separator = "."
+	}
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		lit, ok := n.(*ast.CompositeLit)
+
+		// Methods are elements of []*BuiltInMethodObject literals, so their types are elided
+		if !ok || lit.Type != nil {
+			return true
+		}
+
+		methodName, pos, ok := nameField(lit)
+
+		if !ok {
+			return true
+		}
+
+		// Comments right above the Name field are the method's
+		line := fset.Position(pos).Line
+
+		for _, cg := range file.Comments {
+			if cg.Pos() > lit.Lbrace && fset.Position(cg.End()).Line == line-1 {
+				d.methods[className+separator+methodName] = markdown(cg.Text())
+				break
+			}
+		}
+
+		return false
+	})
+
+	// Common methods are class methods too
+	if className == "Object" && separator == "#" {
+		for key, doc := range d.methods {
+			if strings.HasPrefix(key, "Object#") {
+				d.methods["Object."+strings.TrimPrefix(key, "Object#")] = doc
+			}
+		}
+	}
+}
+
+// nameField returns the value and position of the literal's Name field
+func nameField(lit *ast.CompositeLit) (string, token.Pos, bool) {
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+
+		if !ok {
+			continue
+		}
+
+		key, ok := kv.Key.(*ast.Ident)
+		value, isString := kv.Value.(*ast.BasicLit)
+
+		if !ok || key.Name != "Name" || !isString || value.Kind != token.STRING {
+			continue
+		}
+
+		name, err := strconv.Unquote(value.Value)
+		return name, kv.Pos(), err == nil
+	}
+
+	return "", token.NoPos, false
+}
+
+// markdown turns a method comment into Markdown, which shows `@param` and `@return` lines as a list
+func markdown(comment string) string {
+	lines := strings.Split(strings.TrimSpace(comment), "\n")
+
+	for i, line := range lines {
+		switch {
+		// Fences keep only their languages, without markers like ```ruby nodoctest
+		case strings.HasPrefix(line, "```"):
+			lines[i] = strings.Fields(strings.ToLower(line))[0]
+		case strings.HasPrefix(line, "@"):
+			fields := strings.SplitN(line, " ", 2)
+			lines[i] = "- `" + fields[0] + "`"
+
+			if len(fields) > 1 {
+				lines[i] += " " + fields[1]
+			}
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// method returns the method's comment. Instance methods are looked up in Object too,
+// and class methods are looked up in Class and Object, since all classes inherit them.
+func (d *Docs) method(className, methodName string, classMethod bool) (string, bool) {
+	if d == nil {
+		return "", false
+	}
+
+	keys := []string{className + "#" + methodName, "Object#" + methodName}
+
+	if classMethod {
+		keys = []string{className + "." + methodName, "Class." + methodName, "Object." + methodName}
+	}
+
+	for _, key := range keys {
+		if doc, ok := d.methods[key]; ok {
+			return doc, true
+		}
+	}
+
+	return "", false
+}
+
+// methodsNamed returns the names and comments of all methods with the name, like "Array#each", sorted by names
+func (d *Docs) methodsNamed(methodName string) (names []string, docs []string) {
+	if d == nil {
+		return nil, nil
+	}
+
+	for key := range d.methods {
+		i := strings.IndexAny(key, "#.")
+
+		if key[i+1:] == methodName && !strings.HasPrefix(key, "Object.") {
+			names = append(names, key)
+		}
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		docs = append(docs, d.methods[name])
+	}
+
+	return names, docs
+}
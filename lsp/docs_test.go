@@ -0,0 +1,40 @@
+package lsp
+
+import "testing"
+
+func TestLoadDocs(t *testing.T) {
+	docs, err := LoadDocs("../vm")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tests := []struct {
+		className   string
+		methodName  string
+		classMethod bool
+		expected    string
+	}{
+		{"Integer", "+", false, "Returns the sum of self and another Integer.\n\n```ruby\n1 + 2 # => 3\n```\n- `@return` [Integer]"},
+		// Inherited methods are Object's, and class methods are Class's too
+		{"Integer", "is_nil", false, "Returns true if Object is nil"},
+		{"Foo", "puts", true, "Puts string literals"},
+		{"Foo", "new", true, "Creates and returns a new anonymous class"},
+	}
+
+	for i, tt := range tests {
+		doc, ok := docs.method(tt.className, tt.methodName, tt.classMethod)
+
+		if !ok || len(doc) < len(tt.expected) || doc[:len(tt.expected)] != tt.expected {
+			t.Fatalf("At case %d expect doc to start with %q. got: %q", i, tt.expected, doc)
+		}
+	}
+
+	if _, ok := docs.method("Integer", "lenght", false); ok {
+		t.Fatal("Expect no doc of Integer#lenght")
+	}
+
+	if _, err := LoadDocs("testdata"); err == nil || err.Error() != "No Go files in testdata" {
+		t.Fatalf("Expect an error about no Go files. got: %v", err)
+	}
+}
@@ -0,0 +1,447 @@
+package lsp
+
+import (
+	"strings"
+
+	"github.com/goby-lang/goby/compiler/ast"
+	"github.com/goby-lang/goby/compiler/bytecode"
+	"github.com/goby-lang/goby/compiler/lexer"
+	"github.com/goby-lang/goby/compiler/token"
+)
+
+// Kinds of references
+const (
+	localReference = iota
+	methodReference
+	constantReference
+)
+
+// reference is a name in the program, which is a local variable, a method or a constant
+type reference struct {
+	tok  token.Token
+	kind int
+	// definition is where a local variable is assigned or defined as a parameter first
+	definition token.Token
+	// receiver is the class of a method call's receiver if it's a literal or a constant,
+	// and implicit is true if the call doesn't have a receiver
+	receiver    string
+	classMethod bool
+	implicit    bool
+}
+
+// definition is a method defined by `def`, or a class or module
+type definition struct {
+	name token.Token
+	// signature is like `def foo(a, b = 1)` or `class Foo < Bar`, and doc is the comments above it
+	signature string
+	doc       string
+	// owner is the name of the class or module that defines the method, which is empty at top level
+	owner       string
+	classMethod bool
+}
+
+type position struct {
+	line, column int
+}
+
+// index is what the server knows about a program that parsed, which stays while the document has syntax errors
+type index struct {
+	lines []string
+	// tokens are the program's tokens without comments, and comments map lines to comments that have their own lines
+	tokens   []token.Token
+	comments map[int]string
+	symbols  []*DocumentSymbol
+	methods  map[string][]*definition
+	classes  map[string][]*definition
+	// references are keyed by their tokens' positions
+	references map[position]*reference
+	variables  map[variableKey]token.Token
+}
+
+type variableKey struct {
+	table *bytecode.LocalTable
+	index int
+}
+
+func newIndex(input string, program *ast.Program) *index {
+	ix := &index{
+		lines:      strings.Split(input, "\n"),
+		comments:   map[int]string{},
+		methods:    map[string][]*definition{},
+		classes:    map[string][]*definition{},
+		references: map[position]*reference{},
+		variables:  map[variableKey]token.Token{},
+	}
+
+	l := lexer.New(input)
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type != token.Comment {
+			ix.tokens = append(ix.tokens, tok)
+			continue
+		}
+
+		if n := len(ix.tokens); n == 0 || ix.tokens[n-1].Line != tok.Line {
+			ix.comments[tok.Line] = strings.TrimPrefix(strings.TrimPrefix(tok.Literal, "#"), " ")
+		}
+	}
+
+	ix.symbols = []*DocumentSymbol{}
+	ix.indexStatements(program.Statements, bytecode.NewLocalTable(), "", &ix.symbols)
+	return ix
+}
+
+// indexStatements indexes statements of a body, whose local variables are in the table.
+// owner is the class or module the statements are in, and symbols they define are appended to symbols.
+func (ix *index) indexStatements(stmts []ast.Statement, table *bytecode.LocalTable, owner string, symbols *[]*DocumentSymbol) {
+	for _, stmt := range stmts {
+		ix.indexStatement(stmt, table, owner, symbols)
+	}
+}
+
+func (ix *index) indexStatement(stmt ast.Statement, table *bytecode.LocalTable, owner string, symbols *[]*DocumentSymbol) {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		ix.indexExpression(stmt.Expression, table, owner, symbols)
+	case *ast.ReturnStatement:
+		ix.indexExpression(stmt.ReturnValue, table, owner, symbols)
+	case *ast.DefStatement:
+		m := &definition{name: stmt.Name.Token, owner: owner, doc: ix.docComment(stmt.Token.Line)}
+		_, m.classMethod = stmt.Receiver.(*ast.SelfExpression)
+		ix.methods[m.name.Literal] = append(ix.methods[m.name.Literal], m)
+		ix.addReference(&reference{tok: m.name, kind: methodReference})
+
+		methodTable := bytecode.NewLocalTable()
+		var params []string
+
+		for _, param := range stmt.Parameters {
+			switch param := param.(type) {
+			case *ast.Identifier:
+				ix.define(param, methodTable)
+				params = append(params, param.Value)
+			case *ast.AssignExpression:
+				ix.indexExpression(param.Value, methodTable, owner, symbols)
+				ix.define(param.Variables[0].(*ast.Identifier), methodTable)
+				params = append(params, param.Variables[0].String()+" = "+param.Value.String())
+			}
+		}
+
+		name := m.name.Literal
+
+		if stmt.Receiver != nil {
+			name = stmt.Receiver.String() + "." + name
+		}
+
+		m.signature = "def " + name + "(" + strings.Join(params, ", ") + ")"
+		symbol := ix.symbol(name, m.signature, symbolMethod, stmt.Token, m.name, stmt.BlockStatement.End)
+		*symbols = append(*symbols, symbol)
+		ix.indexStatements(stmt.BlockStatement.Statements, methodTable, owner, &symbol.Children)
+	case *ast.ClassStatement:
+		ix.indexExpression(stmt.Name, table, owner, symbols)
+		ix.indexExpression(stmt.SuperClass, table, owner, symbols)
+
+		c := &definition{name: stmt.Name.Token, signature: "class " + stmt.Name.Value, doc: ix.docComment(stmt.Token.Line), owner: owner}
+
+		if stmt.SuperClass != nil {
+			c.signature += " < " + stmt.SuperClass.String()
+		}
+
+		ix.classes[stmt.Name.Value] = append(ix.classes[stmt.Name.Value], c)
+		symbol := ix.symbol(stmt.Name.Value, c.signature, symbolClass, stmt.Token, stmt.Name.Token, stmt.Body.End)
+		*symbols = append(*symbols, symbol)
+		ix.indexStatements(stmt.Body.Statements, bytecode.NewLocalTable(), stmt.Name.Value, &symbol.Children)
+	case *ast.ModuleStatement:
+		ix.indexExpression(stmt.Name, table, owner, symbols)
+
+		m := &definition{name: stmt.Name.Token, signature: "module " + stmt.Name.Value, doc: ix.docComment(stmt.Token.Line), owner: owner}
+		ix.classes[stmt.Name.Value] = append(ix.classes[stmt.Name.Value], m)
+		symbol := ix.symbol(stmt.Name.Value, m.signature, symbolModule, stmt.Token, stmt.Name.Token, stmt.Body.End)
+		*symbols = append(*symbols, symbol)
+		ix.indexStatements(stmt.Body.Statements, bytecode.NewLocalTable(), stmt.Name.Value, &symbol.Children)
+	case *ast.WhileStatement:
+		ix.indexExpression(stmt.Condition, table, owner, symbols)
+		ix.indexStatements(stmt.Body.Statements, table, owner, symbols)
+	}
+}
+
+func (ix *index) indexExpression(exp ast.Expression, table *bytecode.LocalTable, owner string, symbols *[]*DocumentSymbol) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if index, depth, ok := table.Lookup(exp.Value); ok {
+			ix.addReference(&reference{tok: exp.Token, kind: localReference, definition: ix.variables[key(table, index, depth)]})
+			return
+		}
+
+		// Identifiers that aren't local variables are method calls without arguments
+		ix.addReference(&reference{tok: exp.Token, kind: methodReference, implicit: true})
+	case *ast.Constant:
+		ix.addReference(&reference{tok: exp.Token, kind: constantReference})
+	case *ast.AssignExpression:
+		ix.indexExpression(exp.Value, table, owner, symbols)
+
+		for _, v := range exp.Variables {
+			switch v := v.(type) {
+			case *ast.Identifier:
+				index, depth := table.Assign(v.Value)
+				k := key(table, index, depth)
+
+				if _, ok := ix.variables[k]; !ok {
+					ix.variables[k] = v.Token
+				}
+
+				ix.addReference(&reference{tok: v.Token, kind: localReference, definition: ix.variables[k]})
+			case *ast.Constant:
+				ix.indexExpression(v, table, owner, symbols)
+			}
+		}
+	case *ast.CallExpression:
+		ix.indexExpression(exp.Receiver, table, owner, symbols)
+
+		for _, arg := range exp.Arguments {
+			ix.indexExpression(arg, table, owner, symbols)
+		}
+
+		ix.addCall(exp)
+
+		if exp.Block != nil {
+			blockTable := table.NewBlockTable()
+
+			for _, arg := range exp.BlockArguments {
+				ix.define(arg, blockTable)
+			}
+
+			ix.indexStatements(exp.Block.Statements, blockTable, owner, symbols)
+		}
+	case *ast.YieldExpression:
+		for _, arg := range exp.Arguments {
+			ix.indexExpression(arg, table, owner, symbols)
+		}
+	case *ast.IfExpression:
+		ix.indexExpression(exp.Condition, table, owner, symbols)
+		ix.indexStatements(exp.Consequence.Statements, table, owner, symbols)
+
+		if exp.Alternative != nil {
+			ix.indexStatements(exp.Alternative.Statements, table, owner, symbols)
+		}
+	case *ast.InfixExpression:
+		ix.indexExpression(exp.Left, table, owner, symbols)
+		ix.indexExpression(exp.Right, table, owner, symbols)
+	case *ast.PrefixExpression:
+		ix.indexExpression(exp.Right, table, owner, symbols)
+	case *ast.RangeExpression:
+		ix.indexExpression(exp.Start, table, owner, symbols)
+		ix.indexExpression(exp.End, table, owner, symbols)
+	case *ast.ArrayExpression:
+		for _, elem := range exp.Elements {
+			ix.indexExpression(elem, table, owner, symbols)
+		}
+	case *ast.HashExpression:
+		for _, k := range exp.Keys {
+			ix.indexExpression(exp.Data[k], table, owner, symbols)
+		}
+	}
+}
+
+// addCall adds a reference to the method name of a call
+func (ix *index) addCall(exp *ast.CallExpression) {
+	ref := &reference{tok: exp.Token, kind: methodReference}
+
+	// The tokens of calls with dots are the dots, so their method names are the next tokens
+	if exp.Token.Type == token.Dot {
+		tok, ok := ix.tokenAfter(exp.Token)
+
+		if !ok || tok.Literal != exp.Method {
+			return
+		}
+
+		ref.tok = tok
+	}
+
+	// Operators like `[]` and `++` aren't names
+	if ref.tok.Type != token.Ident {
+		return
+	}
+
+	switch receiver := exp.Receiver.(type) {
+	case *ast.Constant:
+		ref.receiver = receiver.Value
+		ref.classMethod = true
+	case *ast.IntegerLiteral:
+		ref.receiver = "Integer"
+	case *ast.StringLiteral:
+		ref.receiver = "String"
+	case *ast.BooleanExpression:
+		ref.receiver = "Boolean"
+	case *ast.NilExpression:
+		ref.receiver = "Null"
+	case *ast.ArrayExpression:
+		ref.receiver = "Array"
+	case *ast.HashExpression:
+		ref.receiver = "Hash"
+	case *ast.RangeExpression:
+		ref.receiver = "Range"
+	case *ast.SelfExpression:
+		ref.implicit = exp.Token.Type != token.Dot
+	}
+
+	ix.addReference(ref)
+}
+
+func (ix *index) addReference(ref *reference) {
+	ix.references[position{ref.tok.Line, ref.tok.Column}] = ref
+}
+
+// define sets a parameter in the table
+func (ix *index) define(param *ast.Identifier, table *bytecode.LocalTable) {
+	index := table.Define(param.Value)
+	ix.variables[variableKey{table: table, index: index}] = param.Token
+	ix.addReference(&reference{tok: param.Token, kind: localReference, definition: param.Token})
+}
+
+// key returns the key of a variable, which is in the table's depth-th upper table
+func key(table *bytecode.LocalTable, index, depth int) variableKey {
+	for i := 0; i < depth; i++ {
+		table = table.Upper()
+	}
+
+	return variableKey{table: table, index: index}
+}
+
+// docComment returns the comments right above the line
+func (ix *index) docComment(line int) string {
+	var comments []string
+
+	for l := line - 1; l >= 0; l-- {
+		comment, ok := ix.comments[l]
+
+		if !ok {
+			break
+		}
+
+		comments = append([]string{comment}, comments...)
+	}
+
+	return strings.Join(comments, "\n")
+}
+
+// tokenAfter returns the token after tok
+func (ix *index) tokenAfter(tok token.Token) (token.Token, bool) {
+	for i, t := range ix.tokens {
+		if t.Line == tok.Line && t.Column == tok.Column && i+1 < len(ix.tokens) {
+			return ix.tokens[i+1], true
+		}
+	}
+
+	return token.Token{}, false
+}
+
+// referenceAt returns the reference whose token is at the position, or just before it
+func (ix *index) referenceAt(pos Position) (*reference, bool) {
+	line, column := pos.Line, fromPosition(ix.lines, pos)
+
+	for _, tok := range ix.tokens {
+		if tok.Line == line && tok.Column <= column && column <= tok.Column+len(tok.Literal) {
+			if ref, ok := ix.references[position{tok.Line, tok.Column}]; ok {
+				return ref, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// problemRange returns the range of the token that a lint problem is at, which includes the method name if it's a dot
+func (ix *index) problemRange(line, column int) Range {
+	for i, tok := range ix.tokens {
+		if tok.Line != line || tok.Column != column {
+			continue
+		}
+
+		r := ix.tokenRange(tok)
+
+		if tok.Type == token.Dot && i+1 < len(ix.tokens) && ix.tokens[i+1].Line == line {
+			r.End = ix.tokenEnd(ix.tokens[i+1])
+		}
+
+		return r
+	}
+
+	p := ix.position(line, column)
+	return Range{Start: p, End: p}
+}
+
+func (ix *index) symbol(name, detail string, kind int, start, selection, end token.Token) *DocumentSymbol {
+	// Blocks of statements that don't parse completely may not have their ends
+	if end.Literal == "" {
+		end = selection
+	}
+
+	return &DocumentSymbol{
+		Name:           name,
+		Detail:         detail,
+		Kind:           kind,
+		Range:          Range{Start: ix.position(start.Line, start.Column), End: ix.tokenEnd(end)},
+		SelectionRange: ix.tokenRange(selection),
+		Children:       []*DocumentSymbol{},
+	}
+}
+
+func (ix *index) tokenRange(tok token.Token) Range {
+	return Range{Start: ix.position(tok.Line, tok.Column), End: ix.tokenEnd(tok)}
+}
+
+func (ix *index) tokenEnd(tok token.Token) Position {
+	return ix.position(tok.Line, tok.Column+len(tok.Literal))
+}
+
+func (ix *index) position(line, column int) Position {
+	return toPosition(ix.lines, line, column)
+}
+
+// toPosition converts a line and a byte column to a Position, whose character counts UTF-16 code units
+func toPosition(lines []string, line, column int) Position {
+	text := lineText(lines, line)
+	units := 0
+
+	for i, r := range text {
+		if i >= column {
+			break
+		}
+
+		units += runeUnits(r)
+	}
+
+	return Position{Line: line, Character: units}
+}
+
+// fromPosition converts a Position to a byte column in its line
+func fromPosition(lines []string, pos Position) int {
+	text := lineText(lines, pos.Line)
+	units := 0
+
+	for i, r := range text {
+		if units >= pos.Character {
+			return i
+		}
+
+		units += runeUnits(r)
+	}
+
+	return len(text)
+}
+
+func lineText(lines []string, line int) string {
+	if line < 0 || line >= len(lines) {
+		return ""
+	}
+
+	return strings.TrimRight(lines[line], "\r")
+}
+
+func runeUnits(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+
+	return 1
+}
@@ -0,0 +1,495 @@
+// Package lsp is a Language Server Protocol server for Goby, which `goby lsp` runs with stdio.
+// It reports syntax errors and lint problems, lists classes, modules and methods,
+// finds definitions, and shows and completes methods, including built-in ones.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/goby-lang/goby/compiler"
+	"github.com/goby-lang/goby/lint"
+	"github.com/goby-lang/goby/vm"
+)
+
+// Server talks JSON-RPC with a client, and each message has a Content-Length header like HTTP
+type Server struct {
+	in   *bufio.Reader
+	out  io.Writer
+	docs *Docs
+	// vm knows built-in methods, and it's initialized when it's needed first
+	vm        *vm.VM
+	documents map[string]*document
+	shutdown  bool
+}
+
+// document is an open file, and index is from its last text that parsed
+type document struct {
+	uri   string
+	lines []string
+	index *index
+}
+
+type handler func(s *Server, params json.RawMessage) (interface{}, error)
+
+// requestHandlers handle requests, whose results are sent to the client
+var requestHandlers = map[string]handler{
+	"initialize":                  (*Server).initialize,
+	"shutdown":                    (*Server).shutdownServer,
+	"textDocument/documentSymbol": (*Server).documentSymbol,
+	"textDocument/definition":     (*Server).definition,
+	"textDocument/hover":          (*Server).hover,
+	"textDocument/completion":     (*Server).completion,
+}
+
+// notificationHandlers handle notifications, which don't have results
+var notificationHandlers = map[string]handler{
+	"textDocument/didOpen":   (*Server).didOpen,
+	"textDocument/didChange": (*Server).didChange,
+	"textDocument/didClose":  (*Server).didClose,
+}
+
+// NewServer returns a server that reads messages from in and writes messages to out.
+// docs can be nil, and then hover and completion don't have built-in methods' comments.
+func NewServer(in io.Reader, out io.Writer, docs *Docs) *Server {
+	return &Server{in: bufio.NewReader(in), out: out, docs: docs, documents: map[string]*document{}}
+}
+
+// Run handles messages until the client sends `exit` or closes the input.
+// It returns an error if reading or writing messages fails, or the client exits without shutting down the server.
+func (s *Server) Run() error {
+	for {
+		body, err := s.read()
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		req := &request{}
+
+		if err := json.Unmarshal(body, req); err != nil {
+			if err := s.reply(nil, nil, &responseError{Code: parseError, Message: err.Error()}); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("the client exited before shutting down the server")
+			}
+
+			return nil
+		}
+
+		if err := s.handle(req); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(req *request) error {
+	// Notifications don't have responses, so unknown or invalid ones are ignored
+	if req.ID == nil {
+		if h, ok := notificationHandlers[req.Method]; ok && !s.shutdown {
+			_, err := call(h, s, req.Params)
+
+			// Notifications don't have responses, so a panic is logged to the client instead
+			if p, ok := err.(*handlerPanic); ok {
+				return s.notify("window/logMessage", &logMessageParams{Type: 1, Message: fmt.Sprintf("internal error of %s: %v", req.Method, p.value)})
+			}
+
+			return err
+		}
+
+		return nil
+	}
+
+	h, ok := requestHandlers[req.Method]
+
+	switch {
+	case s.shutdown:
+		return s.reply(req.ID, nil, &responseError{Code: invalidRequest, Message: "the server is shut down"})
+	case !ok:
+		return s.reply(req.ID, nil, &responseError{Code: methodNotFound, Message: "method not found: " + req.Method})
+	}
+
+	// Handlers only fail if they can't decode the params, or panic
+	result, err := call(h, s, req.Params)
+
+	if p, ok := err.(*handlerPanic); ok {
+		return s.reply(req.ID, nil, &responseError{Code: internalError, Message: fmt.Sprintf("internal error of %s: %v", req.Method, p.value)})
+	}
+
+	if err != nil {
+		return s.reply(req.ID, nil, &responseError{Code: invalidParams, Message: "invalid params of " + req.Method})
+	}
+
+	return s.reply(req.ID, result, nil)
+}
+
+// handlerPanic is a panic of a handler. Handlers shouldn't panic, even for invalid text,
+// so this is only the last resort that keeps the server running.
+type handlerPanic struct {
+	value interface{}
+}
+
+func (p *handlerPanic) Error() string {
+	return fmt.Sprint(p.value)
+}
+
+// call runs the handler, and returns its panic as a *handlerPanic, so the server keeps running
+func call(h handler, s *Server, params json.RawMessage) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, &handlerPanic{value: r}
+		}
+	}()
+
+	return h(s, params)
+}
+
+// read reads a message's body
+func (s *Server) read() ([]byte, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+
+	if err != nil {
+		if err == io.EOF && len(header) == 0 {
+			return nil, io.EOF
+		}
+
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length: %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+func (s *Server) write(message interface{}) error {
+	body, err := json.Marshal(message)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, e *responseError) error {
+	if e != nil {
+		return s.write(&errorResponse{JSONRPC: "2.0", ID: id, Error: e})
+	}
+
+	return s.write(&response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return s.write(&notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func decode(params json.RawMessage, v interface{}) error {
+	return json.Unmarshal(params, v)
+}
+
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	return &initializeResult{
+		Capabilities: serverCapabilities{
+			TextDocumentSync:       1,
+			DocumentSymbolProvider: true,
+			DefinitionProvider:     true,
+			HoverProvider:          true,
+			CompletionProvider:     completionOptions{TriggerCharacters: []string{"."}},
+		},
+		ServerInfo: serverInfo{Name: "goby lsp", Version: vm.Version},
+	}, nil
+}
+
+func (s *Server) shutdownServer(params json.RawMessage) (interface{}, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) (interface{}, error) {
+	p := &didOpenParams{}
+
+	if err := decode(params, p); err != nil {
+		return nil, nil
+	}
+
+	doc := &document{uri: p.TextDocument.URI}
+	s.documents[doc.uri] = doc
+	return nil, s.update(doc, p.TextDocument.Text)
+}
+
+func (s *Server) didChange(params json.RawMessage) (interface{}, error) {
+	p := &didChangeParams{}
+
+	if err := decode(params, p); err != nil {
+		return nil, nil
+	}
+
+	doc, ok := s.documents[p.TextDocument.URI]
+
+	if !ok || len(p.ContentChanges) == 0 {
+		return nil, nil
+	}
+
+	return nil, s.update(doc, p.ContentChanges[len(p.ContentChanges)-1].Text)
+}
+
+func (s *Server) didClose(params json.RawMessage) (interface{}, error) {
+	p := &didCloseParams{}
+
+	if err := decode(params, p); err != nil {
+		return nil, nil
+	}
+
+	delete(s.documents, p.TextDocument.URI)
+	return nil, s.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []*Diagnostic{}})
+}
+
+// update parses the document's new text, and publishes its syntax errors, or lint problems if it parses
+func (s *Server) update(doc *document, text string) error {
+	doc.lines = strings.Split(text, "\n")
+	diagnostics := []*Diagnostic{}
+	program, err := compiler.ParseFile("", text)
+
+	if ds, ok := err.(compiler.Diagnostics); ok {
+		// The index of the last text that parsed is kept, so other features still work while the user is typing
+		for _, d := range ds {
+			start := toPosition(doc.lines, d.Line-1, d.Column-1)
+			end := toPosition(doc.lines, d.Line-1, d.Column-1+d.Length())
+			diagnostics = append(diagnostics, &Diagnostic{
+				Range:    Range{Start: start, End: end},
+				Severity: severityError,
+				Code:     d.Kind,
+				Source:   "goby",
+				Message:  d.Message,
+			})
+		}
+	} else {
+		doc.index = newIndex(text, program)
+		problems, _ := lint.Source("", text, lint.Config{})
+
+		for _, p := range problems {
+			diagnostics = append(diagnostics, &Diagnostic{
+				Range:    doc.index.problemRange(p.Line-1, p.Column-1),
+				Severity: severityWarning,
+				Code:     p.Rule,
+				Source:   "goby lint",
+				Message:  p.Message,
+			})
+		}
+	}
+
+	return s.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{URI: doc.uri, Diagnostics: diagnostics})
+}
+
+// documentIndex returns the index of the document, which is nil if the document isn't open or has never parsed
+func (s *Server) documentIndex(uri string) *index {
+	if doc, ok := s.documents[uri]; ok {
+		return doc.index
+	}
+
+	return nil
+}
+
+func (s *Server) documentSymbol(params json.RawMessage) (interface{}, error) {
+	p := &textDocumentParams{}
+
+	if err := decode(params, p); err != nil {
+		return nil, err
+	}
+
+	ix := s.documentIndex(p.TextDocument.URI)
+
+	if ix == nil {
+		return []*DocumentSymbol{}, nil
+	}
+
+	return ix.symbols, nil
+}
+
+// definition finds where a local variable is assigned first, and where methods and classes with the name are defined
+func (s *Server) definition(params json.RawMessage) (interface{}, error) {
+	p := &textDocumentPositionParams{}
+
+	if err := decode(params, p); err != nil {
+		return nil, err
+	}
+
+	locations := []*Location{}
+	ix := s.documentIndex(p.TextDocument.URI)
+
+	if ix == nil {
+		return locations, nil
+	}
+
+	ref, ok := ix.referenceAt(p.Position)
+
+	if !ok {
+		return locations, nil
+	}
+
+	switch ref.kind {
+	case localReference:
+		locations = append(locations, &Location{URI: p.TextDocument.URI, Range: ix.tokenRange(ref.definition)})
+	case methodReference:
+		for _, m := range ix.methodsFor(ref) {
+			locations = append(locations, &Location{URI: p.TextDocument.URI, Range: ix.tokenRange(m.name)})
+		}
+	case constantReference:
+		for _, c := range ix.classes[ref.tok.Literal] {
+			locations = append(locations, &Location{URI: p.TextDocument.URI, Range: ix.tokenRange(c.name)})
+		}
+	}
+
+	return locations, nil
+}
+
+// hover shows the signatures and comments of methods and classes defined in the document, or built-in methods' comments
+func (s *Server) hover(params json.RawMessage) (interface{}, error) {
+	p := &textDocumentPositionParams{}
+
+	if err := decode(params, p); err != nil {
+		return nil, err
+	}
+
+	ix := s.documentIndex(p.TextDocument.URI)
+
+	if ix == nil {
+		return nil, nil
+	}
+
+	ref, ok := ix.referenceAt(p.Position)
+
+	if !ok {
+		return nil, nil
+	}
+
+	var sections []string
+
+	switch ref.kind {
+	case methodReference:
+		sections = s.methodSections(ix, ref)
+	case constantReference:
+		for _, c := range ix.classes[ref.tok.Literal] {
+			sections = append(sections, section(c.signature, c.doc))
+		}
+	}
+
+	if len(sections) == 0 {
+		return nil, nil
+	}
+
+	r := ix.tokenRange(ref.tok)
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: strings.Join(sections, "\n\n---\n\n")}, Range: &r}, nil
+}
+
+// methodSections returns the comments of the referenced method.
+// Built-in methods are preferred if the receiver is a literal or a built-in class,
+// and methods defined in the document are preferred otherwise.
+func (s *Server) methodSections(ix *index, ref *reference) (sections []string) {
+	name := ref.tok.Literal
+
+	if ref.receiver != "" {
+		if doc, ok := s.docs.method(ref.receiver, name, ref.classMethod); ok {
+			return []string{section(methodName(ref.receiver, name, ref.classMethod), doc)}
+		}
+	}
+
+	for _, m := range ix.methodsFor(ref) {
+		sections = append(sections, section(m.signature, m.doc))
+	}
+
+	if len(sections) > 0 || ref.receiver != "" {
+		return sections
+	}
+
+	// Calls without receivers are sent to self, which is usually the main object or an instance of a class
+	if ref.implicit {
+		if doc, ok := s.docs.method("Object", name, false); ok {
+			return []string{section(methodName("Object", name, false), doc)}
+		}
+	}
+
+	names, docs := s.docs.methodsNamed(name)
+
+	for i, name := range names {
+		sections = append(sections, section(name, docs[i]))
+	}
+
+	return sections
+}
+
+// methodsFor returns methods the reference can call, which are in the receiver's class if it's known
+func (ix *index) methodsFor(ref *reference) []*definition {
+	methods := ix.methods[ref.tok.Literal]
+
+	if ref.receiver == "" {
+		return methods
+	}
+
+	var owned []*definition
+
+	for _, m := range methods {
+		if m.owner == ref.receiver && m.classMethod == ref.classMethod {
+			owned = append(owned, m)
+		}
+	}
+
+	return owned
+}
+
+// section is a Markdown section that shows a method or class's signature as code, followed by its comment
+func section(signature, doc string) string {
+	s := "```ruby\n" + signature + "\n```"
+
+	if doc != "" {
+		s += "\n\n" + doc
+	}
+
+	return s
+}
+
+// methodName returns names like `Integer#to_s`, or `Integer.new` if it's a class method
+func methodName(className, name string, classMethod bool) string {
+	if classMethod {
+		return className + "." + name
+	}
+
+	return className + "#" + name
+}
+
+// builtInMethods returns the built-in class's methods, or nil if there's no such class
+func (s *Server) builtInMethods(className string, classMethod bool) []string {
+	if s.vm == nil {
+		s.vm = vm.New("", []string{})
+	}
+
+	names, _ := s.vm.BuiltInMethods(className, classMethod)
+	return names
+}
@@ -0,0 +1,161 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestSessions runs the scripted sessions in testdata. Lines starting with `-->` are messages sent to the server,
+// and lines starting with `<--` are messages it's expected to send, in the same order.
+func TestSessions(t *testing.T) {
+	paths, err := filepath.Glob("testdata/*.session")
+
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("Can't find sessions: %v", err)
+	}
+
+	docs, err := LoadDocs("../vm")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, path := range paths {
+		script, err := ioutil.ReadFile(path)
+
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		var in bytes.Buffer
+		var expected []string
+
+		for i, line := range strings.Split(string(script), "\n") {
+			switch {
+			case strings.HasPrefix(line, "-->"):
+				body := strings.TrimSpace(strings.TrimPrefix(line, "-->"))
+				fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+			case strings.HasPrefix(line, "<--"):
+				expected = append(expected, strings.TrimSpace(strings.TrimPrefix(line, "<--")))
+			case strings.TrimSpace(line) != "" && !strings.HasPrefix(line, "#"):
+				t.Fatalf("Invalid line %d in %s: %s", i+1, path, line)
+			}
+		}
+
+		var out bytes.Buffer
+
+		if err := NewServer(&in, &out, docs).Run(); err != nil {
+			t.Fatalf("Session %s failed: %s", path, err.Error())
+		}
+
+		got := readMessages(t, &out)
+
+		for i, message := range expected {
+			if i >= len(got) {
+				t.Fatalf("Session %s expects message:\n%s\ngot no more messages", path, message)
+			}
+
+			if !equalJSON(t, message, got[i]) {
+				t.Fatalf("Session %s expects message %d:\n%s\ngot:\n%s", path, i+1, message, got[i])
+			}
+		}
+
+		if len(got) > len(expected) {
+			t.Fatalf("Session %s got unexpected message:\n%s", path, got[len(expected)])
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Content-Length: 40\r\n\r\n" + `{"jsonrpc":"2.0","method":"exit"}`, "unexpected EOF"},
+		{"Content-Length: 33\r\n\r\n" + `{"jsonrpc":"2.0","method":"exit"}`, "the client exited before shutting down the server"},
+		{"Content-Length: abc\r\n\r\n{}", `invalid Content-Length: "abc"`},
+	}
+
+	for i, tt := range tests {
+		err := NewServer(strings.NewReader(tt.input), ioutil.Discard, nil).Run()
+
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("At case %d expect error %q. got: %v", i, tt.expected, err)
+		}
+	}
+}
+
+func TestHandlerPanics(t *testing.T) {
+	panics := func(s *Server, params json.RawMessage) (interface{}, error) {
+		panic("boom")
+	}
+
+	requestHandlers["test/panic"] = panics
+	notificationHandlers["test/panic"] = panics
+
+	defer delete(requestHandlers, "test/panic")
+	defer delete(notificationHandlers, "test/panic")
+
+	tests := []struct {
+		body     string
+		expected string
+	}{
+		{`{"jsonrpc":"2.0","id":1,"method":"test/panic"}`,
+			`{"jsonrpc":"2.0","id":1,"error":{"code":-32603,"message":"internal error of test/panic: boom"}}`},
+		{`{"jsonrpc":"2.0","method":"test/panic"}`,
+			`{"jsonrpc":"2.0","method":"window/logMessage","params":{"type":1,"message":"internal error of test/panic: boom"}}`},
+	}
+
+	for i, tt := range tests {
+		var out bytes.Buffer
+		input := fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(tt.body), tt.body)
+
+		if err := NewServer(strings.NewReader(input), &out, nil).Run(); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if got := readMessages(t, &out); len(got) != 1 || !equalJSON(t, tt.expected, got[0]) {
+			t.Fatalf("At case %d expect an internal error. got: %v", i, got)
+		}
+	}
+}
+
+func readMessages(t *testing.T, r io.Reader) []string {
+	t.Helper()
+	data, _ := ioutil.ReadAll(r)
+	var messages []string
+
+	for len(data) > 0 {
+		header := bytes.SplitN(data, []byte("\r\n\r\n"), 2)
+		length, err := strconv.Atoi(strings.TrimPrefix(string(header[0]), "Content-Length: "))
+
+		if err != nil || len(header) < 2 || len(header[1]) < length {
+			t.Fatalf("Invalid message: %q", data)
+		}
+
+		messages = append(messages, string(header[1][:length]))
+		data = header[1][length:]
+	}
+
+	return messages
+}
+
+func equalJSON(t *testing.T, expected, got string) bool {
+	t.Helper()
+	var e, g interface{}
+
+	if err := json.Unmarshal([]byte(expected), &e); err != nil {
+		t.Fatalf("Invalid expected message %s: %s", expected, err.Error())
+	}
+
+	json.Unmarshal([]byte(got), &g)
+	return reflect.DeepEqual(e, g)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/goby-lang/goby/testrunner"
+)
+
+// runTest runs `goby test [-v] [-parallel n] [-junit report.xml] [paths...]`, which runs examples of `*_test.gb` files.
+// Directories are searched recursively, and the current directory is searched if there are no paths.
+// It returns 1 if any example or file failed.
+func runTest(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	verboseOptionPtr := flags.Bool("v", false, "Print passed examples too")
+	parallelOptionPtr := flags.Int("parallel", 1, "Number of examples of a file that run at once on their own threads")
+	junitOptionPtr := flags.String("junit", "", "Write results to the path as JUnit XML")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: goby test [-v] [-parallel n] [-junit report.xml] [paths...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	paths := flags.Args()
+
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := testrunner.Find(paths)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "No test files")
+		return 1
+	}
+
+	results := []testrunner.FileResult{}
+	status := 0
+
+	for _, file := range files {
+		result := testrunner.RunFile(file, *parallelOptionPtr)
+		testrunner.Report(os.Stdout, []testrunner.FileResult{result}, *verboseOptionPtr)
+		results = append(results, result)
+
+		if !result.Passed() {
+			status = 1
+		}
+	}
+
+	if *junitOptionPtr != "" {
+		f, err := os.Create(*junitOptionPtr)
+
+		if err == nil {
+			err = testrunner.WriteJUnit(f, results)
+			f.Close()
+		}
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+	}
+
+	return status
+}
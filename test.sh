@@ -31,6 +31,10 @@ fi
 # Test if libs that require built in Goby script would work.
 # TODO: Write a test for this specific case
 go install .
+
+# Examples of API documents run as doctests
+goby doc -test
+
 goby test_fixtures/server.gb & PID=$!
 echo "Sleeping for $SLEEP sec to wait server.gb being ready..."; sleep $SLEEP
 
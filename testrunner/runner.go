@@ -0,0 +1,244 @@
+// Package testrunner runs examples of `*_test.gb` files, which are written with the `test` library.
+// It's what `goby test` runs.
+package testrunner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goby-lang/goby/compiler"
+	"github.com/goby-lang/goby/vm"
+)
+
+// FileResult has results of a file's examples
+type FileResult struct {
+	File     string
+	Results  []vm.TestResult
+	Duration time.Duration
+	// Err is set if the file can't be compiled, or it raised an error outside examples
+	Err error
+}
+
+// Failures returns the number of examples that failed
+func (f FileResult) Failures() int {
+	n := 0
+
+	for _, r := range f.Results {
+		if !r.Passed() {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Passed reports whether the file and all of its examples passed
+func (f FileResult) Passed() bool {
+	return f.Err == nil && f.Failures() == 0
+}
+
+// Find returns `*_test.gb` files in the paths, where directories are searched recursively.
+// Files given directly are returned even if their names don't end with `_test.gb`.
+func Find(paths []string) ([]string, error) {
+	files := []string{}
+
+	for _, path := range paths {
+		err := filepath.Walk(path, func(fp string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if fp == path && !info.IsDir() || !info.IsDir() && strings.HasSuffix(fp, "_test.gb") {
+				files = append(files, fp)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// RunFile evaluates the file on a new vm, and then runs its examples on at most workers threads at once
+func RunFile(file string, workers int) FileResult {
+	result := FileResult{File: file}
+	start := time.Now()
+
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
+
+	source, err := ioutil.ReadFile(file)
+
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	sets, err := compiler.CompileFileToInstructions(file, string(source))
+
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	dir, _ := filepath.Abs(filepath.Dir(file))
+	v := vm.New(dir, []string{})
+	v.ExecInstructions(sets, file)
+
+	if e, ok := v.GetExecResult().(*vm.Error); ok {
+		result.Err = fmt.Errorf("%s", e.Message)
+		return result
+	}
+
+	result.Results = v.RunTests(workers)
+	return result
+}
+
+// Report prints failed examples with where they failed, and a line for each file like `go test`.
+// Passed examples are printed too if verbose is set.
+func Report(w io.Writer, results []FileResult, verbose bool) {
+	for _, f := range results {
+		for _, r := range f.Results {
+			switch {
+			case !r.Passed():
+				fmt.Fprintf(w, "--- FAIL: %s (%.2fs)\n", r.FullName(), r.Duration.Seconds())
+
+				if r.FailureFile != "" {
+					fmt.Fprintf(w, "    %s:%d: %s\n", r.FailureFile, r.FailureLine, r.Failure)
+				} else {
+					fmt.Fprintf(w, "    %s\n", r.Failure)
+				}
+			case verbose:
+				fmt.Fprintf(w, "--- PASS: %s (%.2fs)\n", r.FullName(), r.Duration.Seconds())
+			}
+		}
+
+		summary := fmt.Sprintf("%d examples", len(f.Results))
+
+		if failures := f.Failures(); failures > 0 {
+			summary += fmt.Sprintf(", %d failed", failures)
+		}
+
+		switch {
+		case f.Err != nil:
+			fmt.Fprintf(w, "FAIL\t%s\t%s\n", f.File, f.Err.Error())
+		case f.Passed():
+			fmt.Fprintf(w, "ok  \t%s\t%s\t%.3fs\n", f.File, summary, f.Duration.Seconds())
+		default:
+			fmt.Fprintf(w, "FAIL\t%s\t%s\t%.3fs\n", f.File, summary, f.Duration.Seconds())
+		}
+	}
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// WriteJUnit writes the results in JUnit's XML format, which CI services read.
+// Each file is a test suite, whose test cases are its examples, and their class names are their `describe` blocks.
+// A file that failed outside examples has an erroneous test case named after the file.
+func WriteJUnit(w io.Writer, results []FileResult) error {
+	suites := junitTestSuites{}
+	var total time.Duration
+
+	for _, f := range results {
+		suite := junitTestSuite{Name: f.File, Tests: len(f.Results), Time: junitTime(f.Duration)}
+		total += f.Duration
+
+		for _, r := range f.Results {
+			c := junitTestCase{ClassName: strings.Join(r.Groups, " "), Name: r.Name, File: r.File, Line: r.Line, Time: junitTime(r.Duration)}
+
+			if !r.Passed() {
+				failure := &junitFailure{Message: r.Failure, Type: strings.SplitN(r.Failure, ":", 2)[0], Text: r.Failure}
+
+				if r.FailureFile != "" {
+					failure.Text = fmt.Sprintf("%s:%d: %s", r.FailureFile, r.FailureLine, r.Failure)
+				}
+
+				if r.Error {
+					c.Error = failure
+					suite.Errors++
+				} else {
+					c.Failure = failure
+					suite.Failures++
+				}
+			}
+
+			suite.Cases = append(suite.Cases, c)
+		}
+
+		if f.Err != nil {
+			suite.Tests++
+			suite.Errors++
+			suite.Cases = append(suite.Cases, junitTestCase{ClassName: f.File, Name: f.File, File: f.File, Time: junitTime(f.Duration), Error: &junitFailure{Message: f.Err.Error(), Type: "Error", Text: f.Err.Error()}})
+		}
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	suites.Time = junitTime(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
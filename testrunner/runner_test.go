@@ -0,0 +1,158 @@
+package testrunner
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		paths    []string
+		expected []string
+	}{
+		{[]string{"testdata"}, []string{"testdata/counter_test.gb"}},
+		{[]string{"testdata/counter.gb", "testdata"}, []string{"testdata/counter.gb", "testdata/counter_test.gb"}},
+	}
+
+	for i, tt := range tests {
+		files, err := Find(tt.paths)
+
+		if err != nil {
+			t.Fatalf("At case %d: %s", i, err.Error())
+		}
+
+		if !reflect.DeepEqual(files, tt.expected) {
+			t.Fatalf("At case %d expect files %v. got: %v", i, tt.expected, files)
+		}
+	}
+
+	if _, err := Find([]string{"testdata/missing"}); err == nil {
+		t.Fatal("Expect an error of the missing path")
+	}
+}
+
+// durations replaces durations in reports with 0
+var durations = regexp.MustCompile(`\d+\.\d+s`)
+
+func TestReport(t *testing.T) {
+	results := []FileResult{RunFile("testdata/counter_test.gb", 2)}
+	var out bytes.Buffer
+	Report(&out, results, true)
+
+	expected := `--- PASS: Counter #add sums values (0s)
+--- FAIL: Counter #add fails (0s)
+    testdata/counter_test.gb:20: ExpectationError: Expect 1 to eq 2
+--- PASS: Counter #add raises errors of invalid values (0s)
+--- FAIL: Counter #add raises errors (0s)
+    testdata/counter.gb:9: UndefinedMethodError: Undefined Method 'each' for 1
+--- PASS: Counter matches values (0s)
+FAIL	testdata/counter_test.gb	5 examples, 2 failed	0s
+`
+
+	if report := durations.ReplaceAllString(out.String(), "0s"); report != expected {
+		t.Fatalf("Expect report:\n%s\ngot:\n%s", expected, report)
+	}
+
+	out.Reset()
+	Report(&out, results, false)
+
+	if report := out.String(); strings.Contains(report, "PASS") {
+		t.Fatalf("Expect passed examples not to be reported. got:\n%s", report)
+	}
+}
+
+func TestRunFileErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goby-test")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"require \"test\"\nit \"x\" do\n  1\nend", ""},
+		{"require \"test\"\n1.foo", "UndefinedMethodError: Undefined Method 'foo' for 1"},
+		{"def foo(", "unexpected EOF"},
+	}
+
+	for i, tt := range tests {
+		file := filepath.Join(dir, "foo_test.gb")
+		ioutil.WriteFile(file, []byte(tt.source), 0644)
+		result := RunFile(file, 1)
+
+		switch {
+		case tt.expected == "" && !result.Passed():
+			t.Fatalf("At case %d expect the file to pass. got: %v %v", i, result.Err, result.Results)
+		case tt.expected != "" && (result.Err == nil || !strings.Contains(result.Err.Error(), tt.expected)):
+			t.Fatalf("At case %d expect error %q. got: %v", i, tt.expected, result.Err)
+		}
+	}
+
+	if result := RunFile(filepath.Join(dir, "missing_test.gb"), 1); result.Err == nil {
+		t.Fatal("Expect an error of the missing file")
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	results := []FileResult{RunFile("testdata/counter_test.gb", 1)}
+	var out bytes.Buffer
+
+	if err := WriteJUnit(&out, results); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var suites junitTestSuites
+
+	if err := xml.Unmarshal(out.Bytes(), &suites); err != nil {
+		t.Fatalf("Expect valid XML. got: %s\n%s", err.Error(), out.String())
+	}
+
+	if suites.Tests != 5 || suites.Failures != 1 || suites.Errors != 1 || len(suites.Suites) != 1 {
+		t.Fatalf("Expect 5 tests, 1 failure and 1 error in 1 suite. got:\n%s", out.String())
+	}
+
+	tests := []struct {
+		className string
+		name      string
+		failure   string
+		isError   bool
+	}{
+		{"Counter #add", "sums values", "", false},
+		{"Counter #add", "fails", "testdata/counter_test.gb:20: ExpectationError: Expect 1 to eq 2", false},
+		{"Counter #add", "raises errors of invalid values", "", false},
+		{"Counter #add", "raises errors", "testdata/counter.gb:9: UndefinedMethodError: Undefined Method 'each' for 1", true},
+		{"Counter", "matches values", "", false},
+	}
+
+	for i, tt := range tests {
+		c := suites.Suites[0].Cases[i]
+
+		if c.ClassName != tt.className || c.Name != tt.name {
+			t.Fatalf("At case %d expect test case %q %q. got: %q %q", i, tt.className, tt.name, c.ClassName, c.Name)
+		}
+
+		failure := c.Failure
+
+		if tt.isError {
+			failure = c.Error
+		}
+
+		switch {
+		case tt.failure == "" && (c.Failure != nil || c.Error != nil):
+			t.Fatalf("At case %d expect the test case to pass", i)
+		case tt.failure != "" && (failure == nil || failure.Text != tt.failure):
+			t.Fatalf("At case %d expect failure %q. got: %+v", i, tt.failure, failure)
+		}
+	}
+}
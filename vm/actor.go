@@ -0,0 +1,534 @@
+package vm
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	actorClass = "Actor"
+
+	actorMailboxSize       = 100
+	actorDefaultMaxRestart = 3
+	actorDefaultAskTimeout = 5 * time.Second
+)
+
+// ActorObject is a handle of an actor, which processes messages in its mailbox one at a time on its own thread.
+// The mailbox is a buffered `Channel`, and each message is delivered with the channel its reply goes to.
+// Actors are created by `Actor.spawn` with a block that returns the actor's behaviour,
+// which is an object responding to `receive(message)`.
+// If `receive` returns an error, the actor restarts by calling the block again to get a fresh behaviour.
+//
+// ```ruby
+// require "actor"
+//
+// class Counter
+//   def initialize
+//     @count = 0
+//   end
+//
+//   def receive(msg)
+//     if msg == "incr"
+//       @count += 1
+//     end
+//     @count
+//   end
+// end
+//
+// counter = Actor.start(Counter, "counter")
+// counter.tell("incr")
+// counter.ask("get") # => 1
+// Actor.lookup("counter").ask("get") # => 1
+// counter.stop
+// ```
+type ActorObject struct {
+	*baseObj
+	name        string
+	mailbox     *ChannelObject
+	stop        chan struct{}
+	maxRestarts int
+	restarts    int
+	isStopped   bool
+	sync.Mutex
+}
+
+// actorRegistry holds actors spawned with a name.
+type actorRegistry struct {
+	actors map[string]*ActorObject
+	sync.RWMutex
+}
+
+func initActorClass(vm *VM) {
+	registry := &actorRegistry{actors: make(map[string]*ActorObject)}
+	class := vm.initializeClass(actorClass, false)
+	class.setBuiltInMethods(builtinActorClassMethods(registry), true)
+	class.setBuiltInMethods(builtinActorInstanceMethods(registry), false)
+	vm.objectClass.setClassConstant(class)
+
+	vm.execGobyLib("actor.gb")
+}
+
+func (vm *VM) initActorObject(name string, maxRestarts int) *ActorObject {
+	return &ActorObject{
+		baseObj:     &baseObj{class: vm.topLevelClass(actorClass)},
+		name:        name,
+		mailbox:     vm.initBufferedChannelObject(actorMailboxSize),
+		stop:        make(chan struct{}),
+		maxRestarts: maxRestarts,
+	}
+}
+
+func builtinActorClassMethods(registry *actorRegistry) []*BuiltInMethodObject {
+	return []*BuiltInMethodObject{
+		{
+			// Returns the running actor registered with given name, or `nil` if there's no such actor.
+			//
+			// ```ruby
+			// class Echo
+			//   def receive(msg)
+			//     msg
+			//   end
+			// end
+			//
+			// Actor.spawn("echo") do
+			//   Echo.new
+			// end
+			//
+			// Actor.lookup("echo").ask("Hi") # => "Hi"
+			// ```
+			//
+			// @param name [String]
+			// @return [Actor]
+			Name: "lookup",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					if len(args) != 1 {
+						return t.vm.initErrorObject(ArgumentError, "Expect 1 argument. got: %d", len(args))
+					}
+
+					name, ok := args[0].(*StringObject)
+
+					if !ok {
+						return t.vm.initErrorObject(TypeError, WrongArgumentTypeFormat, stringClass, args[0].Class().Name)
+					}
+
+					registry.RLock()
+					defer registry.RUnlock()
+
+					if a, ok := registry.actors[name.Value]; ok {
+						return a
+					}
+
+					return NULL
+				}
+			},
+		},
+		{
+			Name: "new",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return t.UnsupportedMethodError("#new", receiver)
+				}
+			},
+		},
+		{
+			// Spawns an actor on a new thread. The block is called to create the actor's behaviour,
+			// an object that handles every message with its `receive` method.
+			//
+			// When `receive` returns an error, the actor calls the block again and continues with the new behaviour.
+			// It stops after restarting `max_restarts` times, which defaults to 3.
+			//
+			// The actor can be given a name, which registers it for `Actor.lookup` until it stops.
+			//
+			// ```ruby
+			// a = Actor.spawn do
+			//   Counter.new
+			// end
+			//
+			// a = Actor.spawn("counter") do
+			//   Counter.new
+			// end
+			//
+			// a = Actor.spawn({ name: "counter", max_restarts: 10 }) do
+			//   Counter.new
+			// end
+			// ```
+			//
+			// @param options [String] the name, or [Hash] with `name` and `max_restarts`. Optional
+			// @return [Actor]
+			Name: "spawn",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					if blockFrame == nil {
+						return t.vm.initErrorObject(InternalError, CantYieldWithoutBlockFormat)
+					}
+
+					if len(args) > 1 {
+						return t.vm.initErrorObject(ArgumentError, "Expect 0..1 argument. got: %d", len(args))
+					}
+
+					name, maxRestarts, err := t.vm.actorOptions(args)
+
+					if err != nil {
+						return err
+					}
+
+					a := t.vm.initActorObject(name, maxRestarts)
+
+					if name != "" {
+						registry.Lock()
+
+						if _, ok := registry.actors[name]; ok {
+							registry.Unlock()
+							return t.vm.initErrorObject(ArgumentError, "Actor %s is already registered", name)
+						}
+
+						registry.actors[name] = a
+						registry.Unlock()
+					}
+
+					t.detachBlockFrame()
+
+					go a.run(t.vm, blockFrame, registry)
+
+					return a
+				}
+			},
+		},
+	}
+}
+
+func builtinActorInstanceMethods(registry *actorRegistry) []*BuiltInMethodObject {
+	return []*BuiltInMethodObject{
+		{
+			// Sends the message and waits for the result of the actor's `receive`.
+			// Returns a `CancelledError` if there's no reply within the timeout, which defaults to 5 sec.
+			// If `receive` fails, the error is returned and the actor restarts.
+			//
+			// ```ruby
+			// class Echo
+			//   def receive(msg)
+			//     msg
+			//   end
+			// end
+			//
+			// a = Actor.start(Echo)
+			// a.ask("Hi")          # => "Hi"
+			// a.ask("Hi", "300ms") # => "Hi"
+			// ```
+			//
+			// @param message [Object]
+			// @param timeout [Integer] in sec, or [String] like "300ms". Optional
+			// @return [Object]
+			Name: "ask",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					if len(args) < 1 || len(args) > 2 {
+						return t.vm.initErrorObject(ArgumentError, "Expect 1..2 arguments. got: %d", len(args))
+					}
+
+					timeout := actorDefaultAskTimeout
+
+					if len(args) == 2 {
+						d, err := t.vm.toDuration(args[1])
+
+						if err != nil {
+							return err
+						}
+
+						timeout = d
+					}
+
+					a := receiver.(*ActorObject)
+					// The reply is buffered, so the actor won't be blocked if we have given up waiting.
+					reply := t.vm.initBufferedChannelObject(1)
+
+					if err := a.send(t.vm, args[0], reply); err != nil {
+						return err
+					}
+
+					timer := time.NewTimer(timeout)
+					defer timer.Stop()
+
+					select {
+					case id := <-reply.Chan:
+						return t.vm.channelObjectMap.retrieveObj(id)
+					case <-a.stop:
+						// The actor replies before it stops, so the reply may have arrived already.
+						select {
+						case id := <-reply.Chan:
+							return t.vm.channelObjectMap.retrieveObj(id)
+						default:
+							return t.vm.initErrorObject(InternalError, "%s is stopped", a.toString())
+						}
+					case <-timer.C:
+						return t.vm.initErrorObject(CancelledError, "%s didn't reply in %s", a.toString(), timeout)
+					}
+				}
+			},
+		},
+		{
+			// Returns true if the actor is still processing messages.
+			//
+			// @return [Boolean]
+			Name: "is_alive",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					a := receiver.(*ActorObject)
+					a.Lock()
+					defer a.Unlock()
+
+					if a.isStopped {
+						return FALSE
+					}
+
+					return TRUE
+				}
+			},
+		},
+		{
+			// Returns the actor's name, or `nil` if it's spawned without a name.
+			//
+			// @return [String]
+			Name: "name",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					a := receiver.(*ActorObject)
+
+					if a.name == "" {
+						return NULL
+					}
+
+					return t.vm.initStringObject(a.name)
+				}
+			},
+		},
+		{
+			// Returns how many times the actor has restarted.
+			//
+			// @return [Integer]
+			Name: "restarts",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					a := receiver.(*ActorObject)
+					a.Lock()
+					defer a.Unlock()
+
+					return t.vm.initIntegerObject(a.restarts)
+				}
+			},
+		},
+		{
+			// Stops the actor after it finishes current message, and removes it from the registry.
+			// Messages left in the mailbox are dropped. Returns false if the actor was already stopped.
+			//
+			// @return [Boolean]
+			Name: "stop",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					if receiver.(*ActorObject).halt(registry) {
+						return TRUE
+					}
+
+					return FALSE
+				}
+			},
+		},
+		{
+			// Sends the message to the actor's mailbox without waiting for it to be processed.
+			// Returns the actor, so calls can be chained.
+			//
+			// ```ruby
+			// a.tell("incr").tell("incr")
+			// ```
+			//
+			// @param message [Object]
+			// @return [Actor]
+			Name: "tell",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					if len(args) != 1 {
+						return t.vm.initErrorObject(ArgumentError, "Expect 1 argument. got: %d", len(args))
+					}
+
+					a := receiver.(*ActorObject)
+
+					if err := a.send(t.vm, args[0], NULL); err != nil {
+						return err
+					}
+
+					return a
+				}
+			},
+		},
+	}
+}
+
+// actorOptions extracts the name and the restart limit from `Actor.spawn`'s arguments.
+func (vm *VM) actorOptions(args []Object) (string, int, *Error) {
+	if len(args) == 0 {
+		return "", actorDefaultMaxRestart, nil
+	}
+
+	switch opt := args[0].(type) {
+	case *StringObject:
+		return opt.Value, actorDefaultMaxRestart, nil
+	case *HashObject:
+		name := ""
+		maxRestarts := actorDefaultMaxRestart
+
+		if n, ok := opt.Pairs["name"]; ok {
+			s, ok := n.(*StringObject)
+
+			if !ok {
+				return "", 0, vm.initErrorObject(TypeError, WrongArgumentTypeFormat, stringClass, n.Class().Name)
+			}
+
+			name = s.Value
+		}
+
+		if m, ok := opt.Pairs["max_restarts"]; ok {
+			i, ok := m.(*IntegerObject)
+
+			if !ok {
+				return "", 0, vm.initErrorObject(TypeError, WrongArgumentTypeFormat, integerClass, m.Class().Name)
+			}
+
+			if i.Value < 0 {
+				return "", 0, vm.initErrorObject(ArgumentError, "Expect max_restarts to be non-negative. got: %d", i.Value)
+			}
+
+			maxRestarts = i.Value
+		}
+
+		return name, maxRestarts, nil
+	default:
+		return "", 0, vm.initErrorObject(TypeError, WrongArgumentTypeFormat, "String or Hash", args[0].Class().Name)
+	}
+}
+
+// send delivers the message and the reply channel to the mailbox as an envelope.
+// The reply is nil if the sender doesn't wait for the result.
+func (a *ActorObject) send(vm *VM, message, reply Object) *Error {
+	select {
+	case <-a.stop:
+		return vm.initErrorObject(InternalError, "Can't send to a stopped actor")
+	default:
+	}
+
+	id := vm.channelObjectMap.storeObj(vm.initArrayObject([]Object{message, reply}))
+
+	select {
+	case a.mailbox.Chan <- id:
+		return nil
+	case <-a.stop:
+		vm.channelObjectMap.retrieveObj(id)
+		return vm.initErrorObject(InternalError, "Can't send to a stopped actor")
+	}
+}
+
+// run creates the behaviour with the block and processes messages until the actor is stopped.
+// Messages are handled one by one on the actor's thread. A thread that failed keeps its frames,
+// so the actor moves to a new thread when it restarts.
+func (a *ActorObject) run(vm *VM, blockFrame *callFrame, registry *actorRegistry) {
+	defer a.halt(registry)
+
+	t := vm.newThread()
+	behaviour := t.yieldBlock(blockFrame)
+
+	if errorOf(behaviour) != nil {
+		return
+	}
+
+	sp := t.sp
+
+	for {
+		select {
+		case <-a.stop:
+			return
+		case id := <-a.mailbox.Chan:
+			envelope := vm.channelObjectMap.retrieveObj(id).(*ArrayObject)
+			message, reply := envelope.Elements[0], envelope.Elements[1]
+
+			result := t.sendMethod(behaviour, "receive", message)
+			failed := errorOf(result) != nil
+			restarting := failed && a.restart()
+
+			if r, ok := reply.(*ChannelObject); ok {
+				r.Chan <- vm.channelObjectMap.storeObj(result)
+			}
+
+			if !failed {
+				// Drop the result, so the stack doesn't grow with every message
+				t.sp = sp
+				continue
+			}
+
+			if !restarting {
+				return
+			}
+
+			t = vm.newThread()
+			behaviour = t.yieldBlock(blockFrame)
+
+			if errorOf(behaviour) != nil {
+				return
+			}
+
+			sp = t.sp
+		}
+	}
+}
+
+// restart counts a restart, it returns false if the actor has reached its restart limit.
+func (a *ActorObject) restart() bool {
+	a.Lock()
+	defer a.Unlock()
+
+	if a.restarts >= a.maxRestarts {
+		return false
+	}
+
+	a.restarts++
+	return true
+}
+
+// halt stops the actor and unregisters it, returns false if it's already stopped.
+func (a *ActorObject) halt(registry *actorRegistry) bool {
+	a.Lock()
+	defer a.Unlock()
+
+	if a.isStopped {
+		return false
+	}
+
+	a.isStopped = true
+	close(a.stop)
+
+	if a.name != "" {
+		registry.Lock()
+		if registry.actors[a.name] == a {
+			delete(registry.actors, a.name)
+		}
+		registry.Unlock()
+	}
+
+	return true
+}
+
+// Polymorphic helper functions -----------------------------------------
+
+// toString returns the actor's name or address.
+func (a *ActorObject) toString() string {
+	if a.name != "" {
+		return fmt.Sprintf("<Actor: %s>", a.name)
+	}
+
+	return fmt.Sprintf("<Actor: %p>", a)
+}
+
+// toJSON converts the receiver into JSON string.
+func (a *ActorObject) toJSON() string {
+	return a.toString()
+}
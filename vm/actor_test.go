@@ -0,0 +1,272 @@
+package vm
+
+import "testing"
+
+func TestActorMethods(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`
+		require "actor"
+
+		class Counter
+		  def initialize
+		    @count = 0
+		  end
+
+		  def receive(msg)
+		    if msg == "incr"
+		      @count += 1
+		    end
+		    @count
+		  end
+		end
+
+		a = Actor.start(Counter)
+		a.tell("incr").tell("incr")
+		a.ask("get")
+		`, 2},
+		{`
+		require "actor"
+
+		class Echo
+		  def receive(msg)
+		    msg
+		  end
+		end
+
+		Actor.spawn("echo") do
+		  Echo.new
+		end
+
+		Actor.lookup("echo").ask("Hi")
+		`, "Hi"},
+		{`
+		require "actor"
+
+		class Sum
+		  def initialize
+		    @sum = 0
+		  end
+
+		  def receive(msg)
+		    if msg.is_a(Channel)
+		      msg.receive
+		    else
+		      @sum += msg
+		    end
+		    @sum
+		  end
+		end
+
+		a = Actor.start(Sum)
+		gate = Channel.new
+		a.tell(gate)
+
+		50.times do
+		  a.tell(1)
+		end
+
+		c = Channel.new
+
+		thread do
+		  1100.times do |i|
+		    c.deliver(i)
+		  end
+		end
+
+		1100.times do
+		  c.receive
+		end
+
+		gate.deliver(1)
+		a.ask(0)
+		`, 50},
+		{`
+		require "actor"
+
+		class Sum
+		  def initialize
+		    @sum = 0
+		  end
+
+		  def receive(msg)
+		    @sum += msg
+		    @sum
+		  end
+		end
+
+		a = Actor.start(Sum)
+		done = Channel.new
+
+		3.times do
+		  thread do
+		    400.times do
+		      a.tell(1)
+		    end
+		    done.deliver(1)
+		  end
+		end
+
+		3.times do
+		  done.receive
+		end
+
+		a.ask(0)
+		`, 1200},
+		{`
+		require "actor"
+
+		class Echo
+		  def receive(msg)
+		    msg
+		  end
+		end
+
+		a = Actor.start(Echo, { name: "echo" })
+		a.stop
+		Actor.lookup("echo")
+		`, nil},
+		{`
+		require "actor"
+
+		Actor.lookup("foo")
+		`, nil},
+		{`
+		require "actor"
+
+		class Echo
+		  def receive(msg)
+		    msg
+		  end
+		end
+
+		a = Actor.start(Echo, "echo")
+		a.name
+		`, "echo"},
+		{`
+		require "actor"
+
+		class Echo
+		  def receive(msg)
+		    msg
+		  end
+		end
+
+		a = Actor.start(Echo)
+		a.stop
+		a.is_alive
+		`, false},
+		{`
+		require "actor"
+
+		class Counter
+		  def initialize
+		    @count = 0
+		  end
+
+		  def receive(msg)
+		    if msg == "fail"
+		      msg.foo
+		    end
+		    @count += 1
+		    @count
+		  end
+		end
+
+		a = Actor.start(Counter)
+		a.ask("incr")
+		a.ask("incr")
+		a.tell("fail")
+		a.ask("incr") + a.restarts * 10
+		`, 11},
+		{`
+		require "actor"
+
+		class Failing
+		  def receive(msg)
+		    msg.foo
+		  end
+		end
+
+		a = Actor.start(Failing, { max_restarts: 1 })
+		a.tell(1).tell(2).tell(3)
+
+		while a.is_alive do
+		  sleep("1ms")
+		end
+		a.restarts
+		`, 1},
+	}
+
+	for i, tt := range tests {
+		vm := initTestVM()
+		evaluated := vm.testEval(t, tt.input)
+		checkExpected(t, i, evaluated, tt.expected)
+		vm.checkCFP(t, i, 0)
+	}
+}
+
+func TestActorMethodsFail(t *testing.T) {
+	testsFail := []struct {
+		input    string
+		errType  string
+		expected string
+	}{
+		{`
+		require "actor"
+
+		class Sleepy
+		  def receive(msg)
+		    sleep(1)
+		  end
+		end
+
+		a = Actor.start(Sleepy, "sleepy")
+		a.ask(1, "10ms")
+		`, CancelledError, "CancelledError: <Actor: sleepy> didn't reply in 10ms"},
+		{`
+		require "actor"
+
+		class Echo
+		  def receive(msg)
+		    msg
+		  end
+		end
+
+		a = Actor.start(Echo)
+		a.stop
+		a.tell(1)
+		`, InternalError, "InternalError: Can't send to a stopped actor"},
+		{`
+		require "actor"
+
+		class Echo
+		  def receive(msg)
+		    msg
+		  end
+		end
+
+		Actor.start(Echo, "echo")
+		Actor.start(Echo, "echo")
+		`, ArgumentError, "ArgumentError: Actor echo is already registered"},
+		{`
+		require "actor"
+
+		Actor.spawn(1) do
+		end
+		`, TypeError, "TypeError: Expect argument to be String or Hash. got: Integer"},
+		{`
+		require "actor"
+
+		Actor.new
+		`, UnsupportedMethodError, "UnsupportedMethodError: Unsupported Method #new for Actor"},
+	}
+
+	for i, tt := range testsFail {
+		v := initTestVM()
+		evaluated := v.testEval(t, tt.input)
+		checkError(t, i, evaluated, tt.errType, tt.expected)
+	}
+}
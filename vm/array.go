@@ -14,6 +14,8 @@ type ArrayObject struct {
 }
 
 func (vm *VM) initArrayObject(elements []Object) *ArrayObject {
+	vm.countObject()
+
 	return &ArrayObject{
 		baseObj:  &baseObj{class: vm.topLevelClass(arrayClass)},
 		Elements: elements,
@@ -96,7 +98,8 @@ func builtinArrayInstanceMethods() []*BuiltInMethodObject {
 			// a[0] = 10  # => 10
 			// a[3] = 20  # => 20
 			// a          # => [10, nil, nil, 20]
-			// a[-2] = 5  # => [10, nil, 5, 20]
+			// a[-2] = 5  # => 5
+			// a          # => [10, nil, 5, 20]
 			// ```
 			Name: "[]=",
 			Fn: func(receiver Object) builtinMethodBody {
@@ -204,7 +207,7 @@ func builtinArrayInstanceMethods() []*BuiltInMethodObject {
 			//
 			// ```ruby
 			// a = [1, 2, 3]
-			// a.concat(4, 5, 6)
+			// a.concat([4, 5], [6])
 			// a # => [1, 2, 3, 4, 5, 6]
 			// ```
 			Name: "concat",
@@ -253,6 +256,11 @@ func builtinArrayInstanceMethods() []*BuiltInMethodObject {
 					if blockFrame != nil {
 						for _, obj := range arr.Elements {
 							result := t.builtInMethodYield(blockFrame, obj)
+
+							if t.yieldError != nil {
+								break
+							}
+
 							if result.Target.(*BooleanObject).Value {
 								count++
 							}
@@ -297,6 +305,7 @@ func builtinArrayInstanceMethods() []*BuiltInMethodObject {
 		},
 		{
 			// Loop through each element with the given block.
+			// Returns an enumerator if no block is given.
 			//
 			// ```ruby
 			// a = ["a", "b", "c"]
@@ -307,6 +316,8 @@ func builtinArrayInstanceMethods() []*BuiltInMethodObject {
 			// # => "aa"
 			// # => "bb"
 			// # => "cc"
+			//
+			// a.each.next # => "a"
 			// ```
 			Name: "each",
 			Fn: func(receiver Object) builtinMethodBody {
@@ -314,11 +325,15 @@ func builtinArrayInstanceMethods() []*BuiltInMethodObject {
 					arr := receiver.(*ArrayObject)
 
 					if blockFrame == nil {
-						t.vm.initErrorObject(InternalError, CantYieldWithoutBlockFormat)
+						return t.vm.arrayEnumerator(arr)
 					}
 
 					for _, obj := range arr.Elements {
 						t.builtInMethodYield(blockFrame, obj)
+
+						if t.yieldError != nil {
+							break
+						}
 					}
 					return arr
 				}
@@ -336,6 +351,10 @@ func builtinArrayInstanceMethods() []*BuiltInMethodObject {
 
 					for i := range arr.Elements {
 						t.builtInMethodYield(blockFrame, t.vm.initIntegerObject(i))
+
+						if t.yieldError != nil {
+							break
+						}
 					}
 					return arr
 				}
@@ -405,6 +424,7 @@ func builtinArrayInstanceMethods() []*BuiltInMethodObject {
 		},
 		{
 			// Loop through each element with the given block. Return a new array with each yield element.
+			// Returns an enumerator if no block is given.
 			//
 			// ```ruby
 			// a = ["a", "b", "c"]
@@ -421,11 +441,16 @@ func builtinArrayInstanceMethods() []*BuiltInMethodObject {
 					var elements = make([]Object, len(arr.Elements))
 
 					if blockFrame == nil {
-						t.vm.initErrorObject(InternalError, CantYieldWithoutBlockFormat)
+						return t.vm.arrayEnumerator(arr)
 					}
 
 					for i, obj := range arr.Elements {
 						result := t.builtInMethodYield(blockFrame, obj)
+
+						if t.yieldError != nil {
+							break
+						}
+
 						elements[i] = result.Target
 					}
 
@@ -433,6 +458,86 @@ func builtinArrayInstanceMethods() []*BuiltInMethodObject {
 				}
 			},
 		},
+		{
+			// Loop through each element with the given block on multiple threads.
+			// The number of threads running at the same time can be limited by `workers`,
+			// which defaults to the number of CPUs.
+			// If any block fails, remaining elements won't be scheduled and the first error is returned.
+			//
+			// ```ruby
+			// c = Channel.new
+			//
+			// [1, 2, 3].parallel_each({ workers: 2 }) do |i|
+			//   c.deliver(i)
+			// end
+			// ```
+			//
+			// @param workers [Integer] or [Hash] like `{ workers: 2 }`
+			// @return [Array]
+			Name: "parallel_each",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					arr := receiver.(*ArrayObject)
+
+					if blockFrame == nil {
+						return t.vm.initErrorObject(InternalError, CantYieldWithoutBlockFormat)
+					}
+
+					workers, err := t.vm.workerCount(args)
+
+					if err != nil {
+						return err
+					}
+
+					_, err = t.parallelYield(blockFrame, arr.Elements, workers)
+
+					if err != nil {
+						return err
+					}
+
+					return arr
+				}
+			},
+		},
+		{
+			// Like `map` but yields elements on multiple threads.
+			// The returned array keeps the order of the receiver.
+			// If any block fails, remaining elements won't be scheduled and the first error is returned.
+			//
+			// ```ruby
+			// [1, 2, 3].parallel_map(2) do |i|
+			//   i * 2
+			// end
+			// # => [2, 4, 6]
+			// ```
+			//
+			// @param workers [Integer] or [Hash] like `{ workers: 2 }`
+			// @return [Array]
+			Name: "parallel_map",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					arr := receiver.(*ArrayObject)
+
+					if blockFrame == nil {
+						return t.vm.initErrorObject(InternalError, CantYieldWithoutBlockFormat)
+					}
+
+					workers, err := t.vm.workerCount(args)
+
+					if err != nil {
+						return err
+					}
+
+					elements, err := t.parallelYield(blockFrame, arr.Elements, workers)
+
+					if err != nil {
+						return err
+					}
+
+					return t.vm.initArrayObject(elements)
+				}
+			},
+		},
 		{
 			// Removes the last element in the array and returns it.
 			//
@@ -530,6 +635,11 @@ func builtinArrayInstanceMethods() []*BuiltInMethodObject {
 
 					for _, obj := range arr.Elements {
 						result := t.builtInMethodYield(blockFrame, obj)
+
+						if t.yieldError != nil {
+							break
+						}
+
 						if result.Target.(*BooleanObject).Value {
 							elements = append(elements, obj)
 						}
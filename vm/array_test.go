@@ -542,6 +542,105 @@ func TestArrayMapMethod(t *testing.T) {
 	}
 }
 
+func TestArrayParallelMapMethod(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []interface{}
+	}{
+		{`
+		a = [1, 2, 7]
+		a.parallel_map do |i|
+			i + 3
+		end
+		`, []interface{}{4, 5, 10}},
+		{`
+		a = ["1", "sss", "qwe", "a", "b"]
+		a.parallel_map(2) do |i|
+			i + "1"
+		end
+		`, []interface{}{"11", "sss1", "qwe1", "a1", "b1"}},
+		{`
+		a = [1, 2, 3, 4]
+		a.parallel_map({ workers: 1 }) do |i|
+			i * i
+		end
+		`, []interface{}{1, 4, 9, 16}},
+		{`
+		[].parallel_map(3) do |i|
+			i
+		end
+		`, []interface{}{}},
+	}
+
+	for i, tt := range tests {
+		vm := initTestVM()
+		evaluated := vm.testEval(t, tt.input)
+		testArrayObject(t, i, evaluated, tt.expected)
+		vm.checkCFP(t, i, 0)
+	}
+}
+
+func TestArrayParallelEachMethod(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`
+		c = Channel.new
+		a = [1, 2, 3, 4, 5]
+
+		thread do
+		  a.parallel_each({ workers: 2 }) do |i|
+		    c.deliver(i)
+		  end
+		end
+
+		sum = 0
+		5.times do
+		  sum = sum + c.receive
+		end
+		sum
+		`, 15},
+		{`
+		[1, 2, 3].parallel_each(3) do |i|
+		  i
+		end.length
+		`, 3},
+	}
+
+	for i, tt := range tests {
+		vm := initTestVM()
+		evaluated := vm.testEval(t, tt.input)
+		checkExpected(t, i, evaluated, tt.expected)
+		vm.checkCFP(t, i, 0)
+	}
+}
+
+func TestArrayParallelMethodFail(t *testing.T) {
+	testsFail := []struct {
+		input    string
+		errType  string
+		expected string
+	}{
+		{`
+		[1, 2, 3].parallel_map(1) do |i|
+		  i.foo
+		end
+		`, UndefinedMethodError, "UndefinedMethodError: Undefined Method 'foo' for 1"},
+		{`
+		[1, 2, 3].parallel_each(0) do |i|
+		  i
+		end
+		`, ArgumentError, "ArgumentError: Expect workers to be positive. got: 0"},
+	}
+
+	for i, tt := range testsFail {
+		v := initTestVM()
+		evaluated := v.testEval(t, tt.input)
+		checkError(t, i, evaluated, tt.errType, tt.expected)
+	}
+}
+
 func TestArrayPopMethod(t *testing.T) {
 	tests := []struct {
 		input    string
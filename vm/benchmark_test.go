@@ -0,0 +1,134 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/goby-lang/goby/compiler"
+)
+
+func BenchmarkFib(b *testing.B) {
+	benchmarkProgram(b, `
+	def fib(n)
+	  if n < 2
+	    n
+	  else
+	    fib(n - 1) + fib(n - 2)
+	  end
+	end
+
+	fib(18)
+	`)
+}
+
+func BenchmarkWhileLoop(b *testing.B) {
+	benchmarkProgram(b, `
+	i = 0
+	sum = 0
+	while i < 20000 do
+	  sum = sum + i
+	  i = i + 1
+	end
+	sum
+	`)
+}
+
+func BenchmarkBlockLoop(b *testing.B) {
+	benchmarkProgram(b, `
+	sum = 0
+	10000.times do |i|
+	  sum = sum + i
+	end
+	sum
+	`)
+}
+
+func BenchmarkStringBuilding(b *testing.B) {
+	benchmarkProgram(b, `
+	s = ""
+	i = 0
+	while i < 2000 do
+	  s = s + "a" + i.to_s
+	  i = i + 1
+	end
+	s.length
+	`)
+}
+
+func BenchmarkInheritedMethodCall(b *testing.B) {
+	benchmarkProgram(b, `
+	class A
+	  def v
+	    1
+	  end
+	end
+	class B < A; end
+	class C < B; end
+	class D < C; end
+	class E < D; end
+
+	e = E.new
+	i = 0
+	while i < 5000 do
+	  e.v
+	  i = i + 1
+	end
+	i
+	`)
+}
+
+func BenchmarkMethodCall(b *testing.B) {
+	benchmarkProgram(b, `
+	def add(a, b)
+	  c = a + b
+	  c
+	end
+
+	i = 0
+	while i < 5000 do
+	  add(i, 1)
+	  i = i + 1
+	end
+	i
+	`)
+}
+
+func BenchmarkYield(b *testing.B) {
+	benchmarkProgram(b, `
+	def call_block(x)
+	  yield(x)
+	end
+
+	i = 0
+	while i < 5000 do
+	  call_block(i) do |n|
+	    n
+	  end
+	  i = i + 1
+	end
+	i
+	`)
+}
+
+// benchmarkProgram compiles the program once and measures its evaluation on a fresh VM.
+func benchmarkProgram(b *testing.B, input string) {
+	iss, err := compiler.CompileToInstructions(input)
+
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		v := initTestVM()
+		b.StartTimer()
+
+		v.ExecInstructions(iss, "./")
+
+		if isError(v.mainThread.stack.top().Target) {
+			b.Fatal(v.mainThread.stack.top().Target.toString())
+		}
+	}
+}
@@ -30,6 +30,15 @@ func (vm *VM) initBoolClass() *RClass {
 	return b
 }
 
+// toBooleanObject returns the shared boolean object of the Go bool
+func toBooleanObject(value bool) *BooleanObject {
+	if value {
+		return TRUE
+	}
+
+	return FALSE
+}
+
 func builtInBooleanClassMethods() []*BuiltInMethodObject {
 	return []*BuiltInMethodObject{
 		{
@@ -1,6 +1,9 @@
 package vm
 
-import "sync"
+import (
+	"github.com/goby-lang/goby/compiler/bytecode"
+	"sync"
+)
 
 type callFrameStack struct {
 	callFrames []*callFrame
@@ -19,9 +22,26 @@ type callFrame struct {
 	lPr        int
 	isBlock    bool
 	blockFrame *callFrame
+	// stackBase is the stack pointer when a method frame starts, tail calls drop values above it
+	stackBase int
+	// captured is set when a block frame takes this frame as its ep, so the frame may outlive its evaluation
+	captured bool
+	// caller is the frame the loop returns to once this frame leaves, it's nil for frames evaluated by Go code
+	caller *callFrame
+	// resultPr is where the frame's result goes in the data stack when it returns to its caller
+	resultPr int
+	// result replaces the frame's last value as its result, like the class of a class body
+	result Object
 	sync.RWMutex
 }
 
+// callFramePool keeps evaluated frames, so method calls and yields can reuse them and their locals
+var callFramePool = sync.Pool{
+	New: func() interface{} {
+		return &callFrame{}
+	},
+}
+
 // We use lock on every local variable retrieval and insertion.
 // The main scenario is when multiple threads want to access local variables outside it's block
 // Since they share same block frame, they will all access to that frame's locals.
@@ -32,6 +52,10 @@ func (cf *callFrame) getLCL(index, depth int) *Pointer {
 
 		defer cf.RUnlock()
 
+		if index >= len(cf.locals) {
+			return nil
+		}
+
 		return cf.locals[index]
 	}
 
@@ -39,6 +63,11 @@ func (cf *callFrame) getLCL(index, depth int) *Pointer {
 }
 
 func (cf *callFrame) insertLCL(index, depth int, value Object) {
+	if depth > 0 {
+		cf.blockFrame.ep.insertLCL(index, depth-1, value)
+		return
+	}
+
 	existedLCL := cf.getLCL(index, depth)
 
 	if existedLCL != nil {
@@ -50,8 +79,8 @@ func (cf *callFrame) insertLCL(index, depth int, value Object) {
 
 	defer cf.Unlock()
 
-	cf.locals = append(cf.locals, nil)
-	copy(cf.locals[index:], cf.locals[index:])
+	// Frames are sized by their instruction sets, but REPL's main frame gets new locals after it's created
+	cf.growLocals(index + 1)
 	cf.locals[index] = &Pointer{Target: value}
 
 	if index >= cf.lPr {
@@ -59,6 +88,38 @@ func (cf *callFrame) insertLCL(index, depth int, value Object) {
 	}
 }
 
+// backtraceName describes what the frame evaluates, like `foo`, `block in foo` or `class Foo`
+func (cf *callFrame) backtraceName() string {
+	switch cf.instructionSet.isType {
+	case bytecode.Block:
+		if cf.ep != nil {
+			return "block in " + cf.ep.backtraceName()
+		}
+
+		return "block"
+	case bytecode.ClassDef:
+		return "class " + cf.instructionSet.name
+	case bytecode.Program:
+		return "<main>"
+	default:
+		return cf.instructionSet.name
+	}
+}
+
+// sourceLine returns the source line of the instruction at pc.
+// Lines are unknown for instructions like default values of parameters, so the nearest known line before it is used.
+func (cf *callFrame) sourceLine(pc int) int {
+	instructions := cf.instructionSet.instructions
+
+	for ; pc >= 0; pc-- {
+		if pc < len(instructions) && instructions[pc].sourceLine != 0 {
+			return instructions[pc].sourceLine
+		}
+	}
+
+	return 0
+}
+
 func (cf *callFrame) storeConstant(constName string, constant interface{}) *Pointer {
 	var ptr *Pointer
 
@@ -134,6 +195,52 @@ func (cfs *callFrameStack) top() *callFrame {
 	return nil
 }
 
+// growLocals makes sure the frame can hold n locals
+func (cf *callFrame) growLocals(n int) {
+	if n <= len(cf.locals) {
+		return
+	}
+
+	if n <= cap(cf.locals) {
+		cf.locals = cf.locals[:n]
+		return
+	}
+
+	locals := make([]*Pointer, n)
+	copy(locals, cf.locals)
+	cf.locals = locals
+}
+
 func newCallFrame(is *instructionSet) *callFrame {
-	return &callFrame{locals: make([]*Pointer, 100), instructionSet: is, pc: 0, lPr: 0}
+	cf := callFramePool.Get().(*callFrame)
+	cf.instructionSet = is
+	cf.growLocals(is.localCount)
+
+	return cf
+}
+
+// releaseCallFrame puts an evaluated frame back to the pool.
+// Frames captured by blocks can still be used through those blocks, so they're left to the garbage collector.
+func releaseCallFrame(cf *callFrame) {
+	if cf.captured {
+		return
+	}
+
+	for i := range cf.locals {
+		cf.locals[i] = nil
+	}
+
+	cf.locals = cf.locals[:0]
+	cf.instructionSet = nil
+	cf.pc = 0
+	cf.ep = nil
+	cf.self = nil
+	cf.lPr = 0
+	cf.stackBase = 0
+	cf.caller = nil
+	cf.resultPr = 0
+	cf.result = nil
+	cf.isBlock = false
+	cf.blockFrame = nil
+	callFramePool.Put(cf)
 }
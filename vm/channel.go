@@ -24,14 +24,22 @@ func (vm *VM) initChannelClass() *RClass {
 	return class
 }
 
+func (vm *VM) initChannelObject() *ChannelObject {
+	return &ChannelObject{baseObj: &baseObj{class: vm.topLevelClass(channelClass)}, Chan: make(chan int)}
+}
+
+// initBufferedChannelObject creates a channel that holds at most size objects without being received.
+func (vm *VM) initBufferedChannelObject(size int) *ChannelObject {
+	return &ChannelObject{baseObj: &baseObj{class: vm.topLevelClass(channelClass)}, Chan: make(chan int, size)}
+}
+
 func builtinChannelClassMethods() []*BuiltInMethodObject {
 	return []*BuiltInMethodObject{
 		{
 			Name: "new",
 			Fn: func(receiver Object) builtinMethodBody {
 				return func(t *thread, args []Object, blockFrame *callFrame) Object {
-					c := &ChannelObject{baseObj: &baseObj{class: t.vm.topLevelClass(channelClass)}, Chan: make(chan int)}
-					return c
+					return t.vm.initChannelObject()
 				}
 			},
 		},
@@ -53,28 +61,58 @@ func builtinChannelInstanceMethods() []*BuiltInMethodObject {
 			},
 		},
 		{
+			// Sends the object to the channel, blocking until another thread receives it.
+			// An optional context can be given to stop waiting once it's done.
+			//
+			// @param object [Object]
+			// @param ctx [Context] Optional
+			// @return [Object] The delivered object
 			Name: "deliver",
 			Fn: func(receiver Object) builtinMethodBody {
 				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					ctx, args := t.extractContext(args, 1)
+
+					if len(args) != 1 {
+						return t.vm.initErrorObject(ArgumentError, "Expect 1 argument. got: %d", len(args))
+					}
+
 					id := t.vm.channelObjectMap.storeObj(args[0])
 
 					c := receiver.(*ChannelObject)
 
-					c.Chan <- id
-
-					return args[0]
+					select {
+					case c.Chan <- id:
+						return args[0]
+					case <-ctx.Done():
+						t.vm.channelObjectMap.retrieveObj(id)
+						return t.vm.initCancelledError(ctx)
+					}
 				}
 			},
 		},
 		{
+			// Receives an object from the channel, blocking until another thread delivers one.
+			// Returns `nil` if the channel is closed.
+			// An optional context can be given to stop waiting once it's done.
+			//
+			// @param ctx [Context] Optional
+			// @return [Object]
 			Name: "receive",
 			Fn: func(receiver Object) builtinMethodBody {
 				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					ctx, _ := t.extractContext(args, 0)
 					c := receiver.(*ChannelObject)
 
-					num := <-c.Chan
+					select {
+					case num, ok := <-c.Chan:
+						if !ok {
+							return NULL
+						}
 
-					return t.vm.channelObjectMap.retrieveObj(num)
+						return t.vm.channelObjectMap.retrieveObj(num)
+					case <-ctx.Done():
+						return t.vm.initCancelledError(ctx)
+					}
 				}
 			},
 		},
@@ -101,27 +139,23 @@ func (m *objectMap) storeObj(obj Object) int {
 	m.Lock()
 	defer m.Unlock()
 
-	m.store[m.counter] = obj
+	// counter here can be considered as deliveries' id.
+	// Ids are never reused, so a delivery that stays in a buffered channel for long
+	// can't be overwritten by later deliveries.
 	i := m.counter
-
-	// containerCount here can be considered as deliveries' id
-	// And this id will be unused once the delivery is completed (which will be really quick)
-	// So we can assume that if we reach 1000th delivery,
-	// previous deliveries are all completed and they don't need their id anymore.
-	if m.counter > 1000 {
-		m.counter = 0
-	} else {
-		m.counter++
-	}
+	m.store[i] = obj
+	m.counter++
 
 	return i
 }
 
-// storeObj store objects into the container map
-// and update containerCount at the same time
+// retrieveObj takes the delivered object out of the container map,
+// the id is released once the delivery is completed
 func (m *objectMap) retrieveObj(num int) Object {
-	m.RLock()
+	m.Lock()
+	defer m.Unlock()
 
-	defer m.RUnlock()
-	return m.store[num]
+	obj := m.store[num]
+	delete(m.store, num)
+	return obj
 }
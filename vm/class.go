@@ -271,6 +271,7 @@ func builtinCommonInstanceMethods() []*BuiltInMethodObject {
 			//
 			// Currently, only the following embedded Goby libraries are targeted:
 			//
+			// - "actor"
 			// - "file"
 			// - "net/http"
 			// - "net/simple_server"
@@ -289,6 +290,11 @@ func builtinCommonInstanceMethods() []*BuiltInMethodObject {
 			Fn: func(receiver Object) builtinMethodBody {
 				return func(t *thread, args []Object, blockFrame *callFrame) Object {
 					libName := args[0].(*StringObject).Value
+
+					if t.vm.sandbox != nil && !t.vm.sandbox.allowsLibrary(libName) {
+						return t.vm.initErrorObject(ForbiddenLibraryError, "Library \"%s\" is not allowed", libName)
+					}
+
 					initFunc, ok := standardLibraries[libName]
 
 					if !ok {
@@ -296,6 +302,7 @@ func builtinCommonInstanceMethods() []*BuiltInMethodObject {
 					}
 
 					initFunc(t.vm)
+					t.vm.invalidateMethodCaches()
 
 					return TRUE
 				}
@@ -319,15 +326,16 @@ func builtinCommonInstanceMethods() []*BuiltInMethodObject {
 					callerDir := path.Dir(t.vm.currentFilePath())
 					filepath := args[0].(*StringObject).Value
 
-					filepath = path.Join(callerDir, filepath)
+					filepath = path.Join(callerDir, filepath) + ".gb"
 
-					file, err := ioutil.ReadFile(filepath + ".gb")
+					file, err := ioutil.ReadFile(filepath)
 
 					if err != nil {
 						return t.vm.initErrorObject(InternalError, err.Error())
 					}
 
 					t.vm.execRequiredFile(filepath, file)
+					t.vm.invalidateMethodCaches()
 
 					return TRUE
 				}
@@ -409,28 +417,118 @@ func builtinCommonInstanceMethods() []*BuiltInMethodObject {
 			},
 		},
 		{
-			// Suspends the current thread for duration (sec).
+			// Suspends the current thread for duration, which can be Integer (in sec)
+			// or String with a unit like "1.5s" and "300ms".
+			// An optional context can be given to wake up once it's done.
 			//
-			// **Note:** currently, parameter cannot be omitted, and only Integer can be specified.
+			// **Note:** currently, parameter cannot be omitted.
 			//
 			// ```ruby
 			// a = sleep(2)
 			// puts(a)     # => 2
+			//
+			// sleep("0.5s")
+			// sleep(10, Context.with_timeout(1)) # => CancelledError: context deadline exceeded
 			// ```
 			//
-			// @param sec [Integer] time to wait in sec
-			// @return [Integer] actual time slept in sec
+			// @param duration [Integer] time to wait in sec, or [String] with a unit
+			// @param ctx [Context] Optional
+			// @return [Object] the given duration
 			Name: "sleep",
 			Fn: func(receiver Object) builtinMethodBody {
 				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					ctx, args := t.extractContext(args, 1)
+
 					if len(args) != 1 {
 						return t.vm.initErrorObject(ArgumentError, "Expect 1 argument. got: %d", len(args))
 					}
 
-					int := args[0].(*IntegerObject)
-					seconds := int.Value
-					time.Sleep(time.Duration(seconds) * time.Second)
-					return int
+					d, err := t.vm.toDuration(args[0])
+
+					if err != nil {
+						return err
+					}
+
+					timer := time.NewTimer(d)
+					defer timer.Stop()
+
+					select {
+					case <-timer.C:
+						return args[0]
+					case <-ctx.Done():
+						return t.vm.initCancelledError(ctx)
+					}
+				}
+			},
+		},
+		{
+			// Evaluates the block and returns its result. If the block raises an error that is one of the given classes,
+			// the script goes on and the error is returned instead, which can be told apart from results with `is_a`.
+			// Without classes, every error is rescued.
+			//
+			// ```ruby
+			// def deep(n)
+			//   deep(n + 1) + 1
+			// end
+			//
+			// err = rescue(StackOverflowError) do
+			//   deep(0)
+			// end
+			// err.is_a(StackOverflowError) # => true
+			// err.message.start_with("StackOverflowError") # => true
+			//
+			// rescue(Object) do
+			//   1.foo
+			// end.is_a(UndefinedMethodError) # => true
+			//
+			// rescue(TypeError) do
+			//   1.foo
+			// end # => UndefinedMethodError: Undefined Method 'foo' for 1
+			// ```
+			//
+			// @param *classes [Class] Errors to rescue. Optional
+			// @return [Object] the block's result or the rescued error
+			Name: "rescue",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					if blockFrame == nil {
+						return t.vm.initErrorObject(InternalError, CantYieldWithoutBlockFormat)
+					}
+
+					for _, arg := range args {
+						if _, ok := arg.(*RClass); !ok {
+							return t.vm.initErrorObject(TypeError, WrongArgumentTypeFormat, classClass, arg.Class().Name)
+						}
+					}
+
+					t.detachBlockFrame()
+
+					// The block runs on a new thread, so frames an error stops don't stay on this thread
+					// and the block can nest as deep as the max call depth again
+					bt := t.vm.newThread()
+					bt.ctx = t.ctx
+					bt.silent = true
+					result := bt.yieldBlock(blockFrame)
+					err := errorOf(result)
+
+					if err == nil {
+						return result
+					}
+
+					for _, arg := range args {
+						if isA(err, arg.(*RClass)) {
+							err.rescued = true
+							return err
+						}
+					}
+
+					if len(args) == 0 {
+						err.rescued = true
+						return err
+					}
+
+					// Other errors are raised from here
+					return err
 				}
 			},
 		},
@@ -480,6 +578,23 @@ func builtinCommonInstanceMethods() []*BuiltInMethodObject {
 			},
 		},
 		{
+			// Runs the given block on a new thread. Arguments are passed to the block.
+			// If the first argument is a context, the thread stops once the context is done.
+			//
+			// ```ruby
+			// ctx = Context.with_cancel
+			//
+			// thread(ctx) do
+			//   while true do
+			//     # ...
+			//   end
+			// end
+			//
+			// ctx.cancel
+			// ```
+			//
+			// @param *args [Object] Arguments passed to the block
+			// @return [Null]
 			Name: "thread",
 			Fn: func(receiver Object) builtinMethodBody {
 				return func(t *thread, args []Object, blockFrame *callFrame) Object {
@@ -489,13 +604,17 @@ func builtinCommonInstanceMethods() []*BuiltInMethodObject {
 
 					newT := t.vm.newThread()
 
+					if len(args) > 0 {
+						if c, ok := args[0].(*ContextObject); ok {
+							newT.ctx = t.vm.sandboxContext(c.Context)
+						}
+					}
+
 					go func() {
 						newT.builtInMethodYield(blockFrame, args...)
 					}()
 
-					// We need to pop this frame from main thread manually,
-					// because the block's 'leave' instruction is running on other process
-					t.callFrameStack.pop()
+					t.detachBlockFrame()
 
 					return NULL
 				}
@@ -531,32 +650,19 @@ func builtinCommonInstanceMethods() []*BuiltInMethodObject {
 						return t.vm.initErrorObject(TypeError, WrongArgumentTypeFormat, classClass, c.Class().Name)
 					}
 
-					receiverClass := receiver.Class()
-
-					for {
-						if receiverClass.Name == gobyClass.Name {
-							return TRUE
-						}
-
-						if receiverClass.Name == objectClass {
-							break
-						}
-
-						receiverClass = receiverClass.superClass
-					}
-					return FALSE
+					return toBooleanObject(isA(receiver, gobyClass))
 				}
 			},
 		},
 		{
-			// Returns true if Object is nil
+			// Returns true if Object is nil. See the implementation of Null#is_nil in vm/null.go file for nil's.
 			//
 			// ```ruby
 			// 123.is_nil            # => false
 			// "String".is_nil       # => false
 			// { a: 1, b: 2 }.is_nil # => false
 			// (3..5).is_nil         # => false
-			// nil.is_nil            # => true  (See the implementation of Null#is_nil in vm/null.go file)
+			// nil.is_nil            # => true
 			// ```
 			//
 			// @param n/a []
@@ -653,6 +759,7 @@ func builtinClassClassMethods() []*BuiltInMethodObject {
 				return func(t *thread, args []Object, blockFrame *callFrame) Object {
 					r := receiver.(*RClass)
 					r.setAttrAccessor(args)
+					t.vm.invalidateMethodCaches()
 
 					return r
 				}
@@ -689,6 +796,7 @@ func builtinClassClassMethods() []*BuiltInMethodObject {
 				return func(t *thread, args []Object, blockFrame *callFrame) Object {
 					r := receiver.(*RClass)
 					r.setAttrReader(args)
+					t.vm.invalidateMethodCaches()
 
 					return r
 				}
@@ -725,6 +833,7 @@ func builtinClassClassMethods() []*BuiltInMethodObject {
 				return func(t *thread, args []Object, blockFrame *callFrame) Object {
 					r := receiver.(*RClass)
 					r.setAttrWriter(args)
+					t.vm.invalidateMethodCaches()
 
 					return r
 				}
@@ -765,7 +874,7 @@ func builtinClassClassMethods() []*BuiltInMethodObject {
 			//
 			// You cannot use string literal, or pass two or more arguments to `include`.
 			//
-			// ```ruby
+			// ```ruby nodoctest
 			//   include("Foo")    # => error
 			//   include(Foo, Bar) # => error
 			// ```
@@ -808,6 +917,7 @@ func builtinClassClassMethods() []*BuiltInMethodObject {
 
 					module.superClass = class.superClass
 					class.superClass = module
+					t.vm.invalidateMethodCaches()
 
 					return class
 				}
@@ -855,7 +965,7 @@ func builtinClassClassMethods() []*BuiltInMethodObject {
 			// Note that the built-in classes such as Class or String are not open for creating instances
 			// and you can't call `new` against them.
 			//
-			// ```ruby
+			// ```ruby nodoctest
 			// a = Class.new  # => error
 			// a = String.new # => error
 			// ```
@@ -875,6 +985,7 @@ func builtinClassClassMethods() []*BuiltInMethodObject {
 					}
 
 					instance := class.initializeInstance()
+					t.vm.countObject()
 					initMethod := class.lookupMethod("initialize")
 
 					if initMethod != nil {
@@ -907,7 +1018,7 @@ func builtinClassClassMethods() []*BuiltInMethodObject {
 			//
 			// - instance objects or object literals
 			//
-			// ```ruby
+			// ```ruby nodoctest
 			// puts("string".superclass) # => error
 			// puts(Class.superclass)    # => error
 			// puts(Object.superclass)   # => error
@@ -1067,6 +1178,23 @@ func (c *RClass) alreadyInherit(constant *RClass) bool {
 	return c.superClass.alreadyInherit(constant)
 }
 
+// isA reports whether the object is an instance of the class or of its subclasses
+func isA(obj Object, class *RClass) bool {
+	c := obj.Class()
+
+	for {
+		if c.Name == class.Name {
+			return true
+		}
+
+		if c.Name == objectClass {
+			return false
+		}
+
+		c = c.superClass
+	}
+}
+
 // ReturnName returns the name of the class
 func (c *RClass) ReturnName() string {
 	return c.Name
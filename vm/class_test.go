@@ -464,6 +464,31 @@ func TestRequireRelative(t *testing.T) {
 	vm.checkCFP(t, 0, 0)
 }
 
+func TestRequireRelativeWithSameClassNames(t *testing.T) {
+	input := `
+	class Bar
+	  def self.qux
+	    1
+	  end
+	end
+
+	require_relative("../test_fixtures/require_test/foo")
+
+	class Bar
+	  def self.qux
+	    2
+	  end
+	end
+
+	Bar.qux + Bar.baz
+	`
+
+	vm := initTestVM()
+	evaluated := vm.testEval(t, input)
+	checkExpected(t, 0, evaluated, 12)
+	vm.checkCFP(t, 0, 0)
+}
+
 func TestRequireSuccess(t *testing.T) {
 	input := `
 	require "file"
@@ -769,6 +794,38 @@ func TestClassSuperclassClassMethodFail(t *testing.T) {
 	}
 }
 
+func TestClassNewClassMethodFail(t *testing.T) {
+	testsFail := []struct {
+		input       string
+		errType     string
+		errMsg      string
+		expectedCFP int
+	}{
+		{`
+		class Foo
+		  def initialize(a); end
+		end
+		Foo.new
+		`, ArgumentError, "ArgumentError: Expect at least 1 args for method 'initialize'. got: 0", 1},
+		{`
+		class Foo
+		  def initialize
+		    bar
+		  end
+		end
+		Foo.new
+		10
+		`, UndefinedMethodError, "UndefinedMethodError: Undefined Method 'bar' for <Instance of: Foo>", 2},
+	}
+
+	for i, tt := range testsFail {
+		vm := initTestVM()
+		evaluated := vm.testEval(t, tt.input)
+		checkError(t, i, evaluated, tt.errType, tt.errMsg)
+		vm.checkCFP(t, i, tt.expectedCFP)
+	}
+}
+
 func TestClassSingletonClassClassMethod(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -0,0 +1,296 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+const contextClass = "Context"
+
+// ContextObject carries a golang context, which can be used to cancel threads and blocking methods.
+// Blocking methods like `sleep`, `Channel#receive`, `Net::HTTP.get` and `File#read` accept a context
+// as their last argument and return a `CancelledError` when the context is cancelled or timed out.
+// The context has to follow the method's other arguments, so `channel.deliver(ctx)` delivers the context itself.
+//
+// ```ruby
+// ctx = Context.with_timeout(1)
+// sleep(10, ctx) # => CancelledError: context deadline exceeded
+// ```
+//
+// A thread that receives a context as its first argument stops once the context is done.
+//
+// ```ruby
+// ctx = Context.with_cancel
+//
+// thread(ctx) do
+//   while true do
+//     # ...
+//   end
+// end
+//
+// ctx.cancel
+// ```
+type ContextObject struct {
+	*baseObj
+	Context context.Context
+	cancel  context.CancelFunc
+	// done is the channel `done` returns, which is made once so calls don't start more goroutines
+	done     *ChannelObject
+	doneOnce sync.Once
+}
+
+func (vm *VM) initContextClass() *RClass {
+	class := vm.initializeClass(contextClass, false)
+	class.setBuiltInMethods(builtinContextClassMethods(), true)
+	class.setBuiltInMethods(builtinContextInstanceMethods(), false)
+	return class
+}
+
+func (vm *VM) initContextObject(ctx context.Context, cancel context.CancelFunc) *ContextObject {
+	return &ContextObject{
+		baseObj: &baseObj{class: vm.topLevelClass(contextClass)},
+		Context: ctx,
+		cancel:  cancel,
+	}
+}
+
+func builtinContextClassMethods() []*BuiltInMethodObject {
+	return []*BuiltInMethodObject{
+		{
+			// Returns an empty context which is never cancelled.
+			//
+			// @return [Context]
+			Name: "background",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return t.vm.initContextObject(context.Background(), nil)
+				}
+			},
+		},
+		{
+			Name: "new",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return t.UnsupportedMethodError("#new", receiver)
+				}
+			},
+		},
+		{
+			// Returns a context that is done when `cancel` is called on it or its parent is done.
+			//
+			// ```ruby
+			// ctx = Context.with_cancel
+			// child = Context.with_cancel(ctx)
+			// ctx.cancel
+			// child.is_done # => true
+			// ```
+			//
+			// @param parent [Context] Optional, defaults to `Context.background`
+			// @return [Context]
+			Name: "with_cancel",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					if len(args) > 1 {
+						return t.vm.initErrorObject(ArgumentError, "Expect 0..1 argument. got: %d", len(args))
+					}
+
+					parent, err := t.vm.parentContext(args)
+
+					if err != nil {
+						return err
+					}
+
+					ctx, cancel := context.WithCancel(parent)
+					return t.vm.initContextObject(ctx, cancel)
+				}
+			},
+		},
+		{
+			// Returns a context that is done after given duration, or when `cancel` is called on it.
+			//
+			// ```ruby
+			// ctx = Context.with_timeout(5)
+			// ctx = Context.with_timeout("300ms")
+			// ```
+			//
+			// @param duration [Integer] timeout in sec, or [String] like "300ms"
+			// @param parent [Context] Optional, defaults to `Context.background`
+			// @return [Context]
+			Name: "with_timeout",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					if len(args) < 1 || len(args) > 2 {
+						return t.vm.initErrorObject(ArgumentError, "Expect 1..2 arguments. got: %d", len(args))
+					}
+
+					d, err := t.vm.toDuration(args[0])
+
+					if err != nil {
+						return err
+					}
+
+					parent, err := t.vm.parentContext(args[1:])
+
+					if err != nil {
+						return err
+					}
+
+					ctx, cancel := context.WithTimeout(parent, d)
+					return t.vm.initContextObject(ctx, cancel)
+				}
+			},
+		},
+	}
+}
+
+func builtinContextInstanceMethods() []*BuiltInMethodObject {
+	return []*BuiltInMethodObject{
+		{
+			// Cancels the context and all contexts derived from it.
+			//
+			// @return [Null]
+			Name: "cancel",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					c := receiver.(*ContextObject)
+
+					if c.cancel != nil {
+						c.cancel()
+					}
+
+					return NULL
+				}
+			},
+		},
+		{
+			// Returns a channel that is closed when the context is done.
+			// Receiving from the channel blocks until then and returns `nil`.
+			//
+			// ```ruby
+			// ctx = Context.with_timeout(1)
+			//
+			// # Returns after 1 sec
+			// ctx.done.receive # => nil
+			// ```
+			//
+			// @return [Channel]
+			Name: "done",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					c := receiver.(*ContextObject)
+
+					c.doneOnce.Do(func() {
+						c.done = t.vm.initChannelObject()
+
+						// Contexts that are never done, like `Context.background`, don't need a goroutine
+						if c.Context.Done() == nil {
+							return
+						}
+
+						go func() {
+							<-c.Context.Done()
+							close(c.done.Chan)
+						}()
+					})
+
+					return c.done
+				}
+			},
+		},
+		{
+			// Returns the reason why the context is done, or `nil` if it's not done yet.
+			//
+			// @return [String]
+			Name: "err",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					err := receiver.(*ContextObject).Context.Err()
+
+					if err == nil {
+						return NULL
+					}
+
+					return t.vm.initStringObject(err.Error())
+				}
+			},
+		},
+		{
+			// Returns true if the context is cancelled or timed out.
+			//
+			// @return [Boolean]
+			Name: "is_done",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					if receiver.(*ContextObject).Context.Err() != nil {
+						return TRUE
+					}
+
+					return FALSE
+				}
+			},
+		},
+	}
+}
+
+// parentContext returns the context in args if there's one, otherwise a background context.
+func (vm *VM) parentContext(args []Object) (context.Context, *Error) {
+	if len(args) == 0 {
+		return context.Background(), nil
+	}
+
+	c, ok := args[0].(*ContextObject)
+
+	if !ok {
+		return nil, vm.initErrorObject(TypeError, WrongArgumentTypeFormat, contextClass, args[0].Class().Name)
+	}
+
+	return c.Context, nil
+}
+
+// extractContext takes the trailing context argument out of args, which is still bound by the sandbox's timeout.
+// Only an argument after the method's first argc arguments is taken, so a context can still be one of them,
+// like `channel.deliver(ctx)`. If there's no such argument, it returns the thread's context and original args.
+func (t *thread) extractContext(args []Object, argc int) (context.Context, []Object) {
+	if len(args) > argc {
+		if c, ok := args[len(args)-1].(*ContextObject); ok {
+			return t.vm.sandboxContext(c.Context), args[:len(args)-1]
+		}
+	}
+
+	return t.currentContext(), args
+}
+
+// currentContext returns the thread's context, or a background context if the thread isn't bound to any
+func (t *thread) currentContext() context.Context {
+	if t.ctx != nil {
+		return t.ctx
+	}
+
+	return context.Background()
+}
+
+func (vm *VM) initCancelledError(ctx context.Context) *Error {
+	// Blocking methods are also interrupted by the sandbox's timeout
+	if vm.sandbox != nil && vm.sandbox.timedOut() {
+		return vm.initTimeLimitError()
+	}
+
+	return vm.initErrorObject(CancelledError, ctx.Err().Error())
+}
+
+// Polymorphic helper functions -----------------------------------------
+
+// toString returns the context's state.
+func (c *ContextObject) toString() string {
+	if err := c.Context.Err(); err != nil {
+		return fmt.Sprintf("<Context: %s>", err.Error())
+	}
+
+	return "<Context>"
+}
+
+// toJSON converts the receiver into JSON string.
+func (c *ContextObject) toJSON() string {
+	return c.toString()
+}
@@ -0,0 +1,162 @@
+package vm
+
+import "testing"
+
+func TestContextMethods(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`
+		ctx = Context.with_cancel
+		ctx.is_done
+		`, false},
+		{`
+		ctx = Context.with_cancel
+		ctx.cancel
+		ctx.is_done
+		`, true},
+		{`
+		ctx = Context.with_cancel
+		ctx.err
+		`, nil},
+		{`
+		ctx = Context.with_cancel
+		ctx.cancel
+		ctx.err
+		`, "context canceled"},
+		{`
+		ctx = Context.with_cancel
+		child = Context.with_timeout(100, ctx)
+		ctx.cancel
+		child.is_done
+		`, true},
+		{`
+		ctx = Context.with_cancel
+		done = ctx.done
+		ctx.cancel
+		done.receive
+		`, nil},
+		{`
+		ctx = Context.with_cancel
+		ctx.done == ctx.done
+		`, true},
+		{`
+		Context.background.is_done
+		`, false},
+		{`
+		ctx = Context.with_cancel
+		ctx.cancel
+		c = Channel.new
+
+		thread do
+		  c.deliver(ctx)
+		end
+
+		c.receive(Context.with_timeout(1)).is_done
+		`, true},
+		{`
+		ctx = Context.with_cancel
+		c = Channel.new
+
+		thread do
+		  c.deliver(ctx, Context.background)
+		end
+
+		received = c.receive(Context.with_timeout(1))
+		received == ctx
+		`, true},
+	}
+
+	for i, tt := range tests {
+		vm := initTestVM()
+		evaluated := vm.testEval(t, tt.input)
+		checkExpected(t, i, evaluated, tt.expected)
+		vm.checkCFP(t, i, 0)
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`
+		ctx = Context.with_cancel
+		c = Channel.new
+
+		thread(ctx) do
+		  running = true
+		  c.deliver(1)
+
+		  while running do
+		    running = running
+		  end
+		end
+
+		c.receive
+		ctx.cancel
+		ctx.is_done
+		`, true},
+		{`
+		ctx = Context.with_cancel
+		c = Channel.new
+
+		thread(ctx) do
+		  c.deliver(1)
+		  # Uses the thread's context implicitly
+		  sleep(100)
+		end
+
+		c.receive
+		ctx.cancel
+		10
+		`, 10},
+	}
+
+	for i, tt := range tests {
+		vm := initTestVM()
+		evaluated := vm.testEval(t, tt.input)
+		checkExpected(t, i, evaluated, tt.expected)
+		vm.checkCFP(t, i, 0)
+	}
+}
+
+func TestContextCancellationFail(t *testing.T) {
+	testsFail := []struct {
+		input    string
+		errType  string
+		expected string
+	}{
+		{`
+		ctx = Context.with_cancel
+		ctx.cancel
+		sleep(10, ctx)
+		`, CancelledError, "CancelledError: context canceled"},
+		{`
+		sleep(10, Context.with_timeout("10ms"))
+		`, CancelledError, "CancelledError: context deadline exceeded"},
+		{`
+		ctx = Context.with_cancel
+		ctx.cancel
+		Channel.new.receive(ctx)
+		`, CancelledError, "CancelledError: context canceled"},
+		{`
+		ctx = Context.with_cancel
+		ctx.cancel
+		Channel.new.deliver(1, ctx)
+		`, CancelledError, "CancelledError: context canceled"},
+		{`
+		Context.with_timeout(true)
+		`, TypeError, "TypeError: Expect argument to be Integer or String. got: Boolean"},
+		{`
+		Context.with_cancel(1)
+		`, TypeError, "TypeError: Expect argument to be Context. got: Integer"},
+	}
+
+	for i, tt := range testsFail {
+		v := initTestVM()
+		evaluated := v.testEval(t, tt.input)
+		checkError(t, i, evaluated, tt.errType, tt.expected)
+	}
+}
@@ -0,0 +1,484 @@
+package vm
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Coverage records which source lines a vm evaluates, and which way its branches go.
+// It's given to New with WithCoverage, and covers every file the vm evaluates,
+// including files loaded by `require_relative` and the standard library:
+//
+// ```go
+// c := vm.NewCoverage()
+// v := vm.New(dir, args, vm.WithCoverage(c))
+// v.ExecInstructions(sets, "script.gb")
+// c.WriteLCOV(file)
+// ```
+//
+// Results of earlier runs can be merged with MergeLCOV before writing reports.
+type Coverage struct {
+	mu    sync.Mutex
+	files map[string]*fileCoverage
+	// lines are counters of instructions that start lines
+	lines map[*instruction]*int64
+	// branches are counters of `branchif` and `branchunless` instructions
+	branches map[*instruction]*branchCoverage
+}
+
+// fileCoverage has counters of a file's lines and branches
+type fileCoverage struct {
+	lines    map[int]*int64
+	branches map[branchKey]*branchCoverage
+}
+
+// branchKey is the nth branch instruction of a line
+type branchKey struct {
+	line int
+	n    int
+}
+
+// branchCoverage counts how many times a branch jumped, and how many times it didn't
+type branchCoverage struct {
+	jumped int64
+	passed int64
+}
+
+// NewCoverage returns an empty coverage
+func NewCoverage() *Coverage {
+	return &Coverage{files: map[string]*fileCoverage{}, lines: map[*instruction]*int64{}, branches: map[*instruction]*branchCoverage{}}
+}
+
+// WithCoverage records the coverage of the vm's programs
+func WithCoverage(c *Coverage) Option {
+	return func(vm *VM) {
+		vm.coverage = c
+	}
+}
+
+func (c *Coverage) file(name string) *fileCoverage {
+	if abs, err := filepath.Abs(name); err == nil {
+		name = abs
+	}
+
+	f, ok := c.files[name]
+
+	if !ok {
+		f = &fileCoverage{lines: map[int]*int64{}, branches: map[branchKey]*branchCoverage{}}
+		c.files[name] = f
+	}
+
+	return f
+}
+
+// register adds the lines and branches of a file's translated sets, so lines that never run are reported too
+func (c *Coverage) register(file filename, sets []*instructionSet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f := c.file(string(file))
+	branches := map[int]int{}
+
+	for _, is := range sets {
+		for _, ins := range is.instructions {
+			if ins.lineStart {
+				counter, ok := f.lines[ins.sourceLine]
+
+				if !ok {
+					counter = new(int64)
+					f.lines[ins.sourceLine] = counter
+				}
+
+				c.lines[ins] = counter
+			}
+
+			if (ins.opcode == opBranchIf || ins.opcode == opBranchUnless) && ins.sourceLine != 0 {
+				key := branchKey{ins.sourceLine, branches[ins.sourceLine]}
+				branches[ins.sourceLine]++
+				counter, ok := f.branches[key]
+
+				if !ok {
+					counter = &branchCoverage{}
+					f.branches[key] = counter
+				}
+
+				c.branches[ins] = counter
+			}
+		}
+	}
+}
+
+// hit counts the line the instruction starts, instructions of unregistered sets like the debugger's are ignored
+func (c *Coverage) hit(ins *instruction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if counter, ok := c.lines[ins]; ok {
+		*counter++
+	}
+}
+
+// branch counts which way the branch instruction went
+func (c *Coverage) branch(ins *instruction, jumped bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counter, ok := c.branches[ins]
+
+	switch {
+	case !ok:
+	case jumped:
+		counter.jumped++
+	default:
+		counter.passed++
+	}
+}
+
+// MergeLCOV adds line and branch counts of an LCOV report, like one WriteLCOV wrote in an earlier run
+func (c *Coverage) MergeLCOV(r io.Reader) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var f *fileCoverage
+	scanner := bufio.NewScanner(r)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		n := n
+		invalid := func() error {
+			return fmt.Errorf("Invalid LCOV record at line %d: %s", n, line)
+		}
+
+		field, value := line, ""
+
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], line[i+1:]
+		}
+
+		switch field {
+		case "SF":
+			f = c.file(value)
+		case "DA", "BRDA":
+			values := strings.Split(value, ",")
+
+			if f == nil || field == "DA" && len(values) != 2 || field == "BRDA" && len(values) != 4 {
+				return invalid()
+			}
+
+			numbers := make([]int64, len(values))
+
+			for i, v := range values {
+				// Branches of lines that never ran are counted as `-`
+				if v == "-" {
+					continue
+				}
+
+				number, err := strconv.ParseInt(v, 10, 64)
+
+				if err != nil {
+					return invalid()
+				}
+
+				numbers[i] = number
+			}
+
+			if field == "DA" {
+				counter, ok := f.lines[int(numbers[0])]
+
+				if !ok {
+					counter = new(int64)
+					f.lines[int(numbers[0])] = counter
+				}
+
+				*counter += numbers[1]
+				continue
+			}
+
+			key := branchKey{int(numbers[0]), int(numbers[1])}
+			counter, ok := f.branches[key]
+
+			if !ok {
+				counter = &branchCoverage{}
+				f.branches[key] = counter
+			}
+
+			if numbers[2] == 0 {
+				counter.jumped += numbers[3]
+			} else {
+				counter.passed += numbers[3]
+			}
+		case "end_of_record":
+			f = nil
+		}
+	}
+
+	return scanner.Err()
+}
+
+// FileCoverage is the coverage summary of a file
+type FileCoverage struct {
+	File          string
+	Lines         int
+	LinesHit      int
+	Branches      int
+	BranchesTaken int
+}
+
+// Files returns the coverage summaries of files sorted by their names
+func (c *Coverage) Files() []FileCoverage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files := []FileCoverage{}
+
+	for _, name := range c.fileNames() {
+		f := c.files[name]
+		summary := FileCoverage{File: name, Lines: len(f.lines), Branches: len(f.branches) * 2}
+
+		for _, hits := range f.lines {
+			if *hits > 0 {
+				summary.LinesHit++
+			}
+		}
+
+		for _, b := range f.branches {
+			if b.jumped > 0 {
+				summary.BranchesTaken++
+			}
+
+			if b.passed > 0 {
+				summary.BranchesTaken++
+			}
+		}
+
+		files = append(files, summary)
+	}
+
+	return files
+}
+
+func (c *Coverage) fileNames() []string {
+	names := []string{}
+
+	for name := range c.files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func (f *fileCoverage) sortedLines() []int {
+	lines := []int{}
+
+	for line := range f.lines {
+		lines = append(lines, line)
+	}
+
+	sort.Ints(lines)
+	return lines
+}
+
+func (f *fileCoverage) sortedBranches() []branchKey {
+	keys := []branchKey{}
+
+	for key := range f.branches {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].line < keys[j].line || keys[i].line == keys[j].line && keys[i].n < keys[j].n
+	})
+
+	return keys
+}
+
+// WriteLCOV writes the coverage as an LCOV tracefile, which genhtml and most coverage services read.
+// Each branch instruction is a block of two branches, 0 is its jump and 1 is falling through.
+func (c *Coverage) WriteLCOV(w io.Writer) error {
+	files := c.Files()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := bufio.NewWriter(w)
+
+	for _, summary := range files {
+		f := c.files[summary.File]
+		fmt.Fprintf(b, "TN:\nSF:%s\n", summary.File)
+
+		for _, key := range f.sortedBranches() {
+			branch := f.branches[key]
+
+			// LCOV expects `-` for branches whose lines never ran
+			if hits, ok := f.lines[key.line]; ok && *hits == 0 && branch.jumped == 0 && branch.passed == 0 {
+				fmt.Fprintf(b, "BRDA:%d,%d,0,-\nBRDA:%d,%d,1,-\n", key.line, key.n, key.line, key.n)
+				continue
+			}
+
+			fmt.Fprintf(b, "BRDA:%d,%d,0,%d\nBRDA:%d,%d,1,%d\n", key.line, key.n, branch.jumped, key.line, key.n, branch.passed)
+		}
+
+		fmt.Fprintf(b, "BRF:%d\nBRH:%d\n", summary.Branches, summary.BranchesTaken)
+
+		for _, line := range f.sortedLines() {
+			fmt.Fprintf(b, "DA:%d,%d\n", line, *f.lines[line])
+		}
+
+		fmt.Fprintf(b, "LF:%d\nLH:%d\nend_of_record\n", summary.Lines, summary.LinesHit)
+	}
+
+	return b.Flush()
+}
+
+// coverageLine is a source line of the HTML report
+type coverageLine struct {
+	Number int
+	Source string
+	// Class is `hit`, `miss` or `partial` for lines with untaken branches, and empty for lines that can't run
+	Class    string
+	Hits     string
+	Branches string
+}
+
+// coverageFile is a file of the HTML report
+type coverageFile struct {
+	FileCoverage
+	ID     int
+	Source []coverageLine
+	Error  string
+}
+
+func (f coverageFile) LinePercentage() string {
+	return coveragePercentage(f.LinesHit, f.Lines)
+}
+
+func (f coverageFile) BranchPercentage() string {
+	return coveragePercentage(f.BranchesTaken, f.Branches)
+}
+
+func coveragePercentage(part, total int) string {
+	if total == 0 {
+		return "-"
+	}
+
+	return fmt.Sprintf("%.1f%%", float64(part)/float64(total)*100)
+}
+
+var coverageTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Goby coverage</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+td, th { padding: 2px 8px; text-align: left; }
+.source td { font-family: monospace; white-space: pre; padding: 0 8px; }
+.source .number, .source .hits, .source .branches { color: #888; text-align: right; }
+.hit { background: #dfd; }
+.miss { background: #fdd; }
+.partial { background: #ffd; }
+</style>
+</head>
+<body>
+<h1>Goby coverage</h1>
+<table>
+<tr><th>File</th><th>Lines</th><th></th><th>Branches</th><th></th></tr>
+{{range .}}<tr><td><a href="#file{{.ID}}">{{.File}}</a></td><td>{{.LinePercentage}}</td><td>{{.LinesHit}}/{{.Lines}}</td><td>{{.BranchPercentage}}</td><td>{{.BranchesTaken}}/{{.Branches}}</td></tr>
+{{end}}</table>
+{{range .}}<h2 id="file{{.ID}}">{{.File}}</h2>
+{{if .Error}}<p>{{.Error}}</p>
+{{else}}<table class="source">
+{{range .Source}}<tr class="{{.Class}}"><td class="number">{{.Number}}</td><td class="hits">{{.Hits}}</td><td class="branches">{{.Branches}}</td><td>{{.Source}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}</body>
+</html>
+`))
+
+// WriteHTML writes the coverage as an HTML page, which lists files and then their sources with hit counts of lines.
+// Lines with branches also show how many of their branches were taken.
+func (c *Coverage) WriteHTML(w io.Writer) error {
+	files := []coverageFile{}
+
+	for id, summary := range c.Files() {
+		file := coverageFile{FileCoverage: summary, ID: id}
+		source, err := ioutil.ReadFile(summary.File)
+
+		if err != nil {
+			file.Error = err.Error()
+		} else {
+			file.Source = c.htmlLines(summary.File, strings.Split(strings.TrimSuffix(string(source), "\n"), "\n"))
+		}
+
+		files = append(files, file)
+	}
+
+	return coverageTemplate.Execute(w, files)
+}
+
+func (c *Coverage) htmlLines(file string, source []string) []coverageLine {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f := c.files[file]
+	lines := make([]coverageLine, len(source))
+
+	for n, s := range source {
+		lines[n] = coverageLine{Number: n + 1, Source: s}
+	}
+
+	for line, hits := range f.lines {
+		if line < 1 || line > len(lines) {
+			continue
+		}
+
+		l := &lines[line-1]
+		l.Hits = strconv.FormatInt(*hits, 10)
+		l.Class = "hit"
+
+		if *hits == 0 {
+			l.Class = "miss"
+		}
+	}
+
+	taken := map[int][2]int{}
+
+	for key, b := range f.branches {
+		counts := taken[key.line]
+		counts[1] += 2
+
+		if b.jumped > 0 {
+			counts[0]++
+		}
+
+		if b.passed > 0 {
+			counts[0]++
+		}
+
+		taken[key.line] = counts
+	}
+
+	for line, counts := range taken {
+		if line < 1 || line > len(lines) {
+			continue
+		}
+
+		l := &lines[line-1]
+		l.Branches = fmt.Sprintf("%d/%d", counts[0], counts[1])
+
+		if l.Class == "hit" && counts[0] < counts[1] {
+			l.Class = "partial"
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,170 @@
+package vm
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goby-lang/goby/compiler"
+)
+
+const coverageTestProgram = `def sign(n)
+  if n > 0
+    "positive"
+  else
+    "negative"
+  end
+end
+
+x = 2
+while x > 0 do
+  sign(x)
+  x -= 1
+end`
+
+// cover evaluates the program in coverage_test.gb with the coverage
+func cover(t *testing.T, c *Coverage, input string) {
+	t.Helper()
+	sets, err := compiler.CompileFileToInstructions("coverage_test.gb", input)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	v := New("./", []string{}, WithCoverage(c))
+	v.ExecInstructions(sets, "coverage_test.gb")
+}
+
+// lcovRecord returns the LCOV record of the file, without its TN and SF lines
+func lcovRecord(t *testing.T, c *Coverage, file string) string {
+	t.Helper()
+	var out bytes.Buffer
+
+	if err := c.WriteLCOV(&out); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	path, _ := filepath.Abs(file)
+
+	for _, record := range strings.SplitAfter(out.String(), "end_of_record\n") {
+		if strings.HasPrefix(record, "TN:\nSF:"+path+"\n") {
+			return strings.TrimPrefix(record, "TN:\nSF:"+path+"\n")
+		}
+	}
+
+	t.Fatalf("Expect a record of %s. got:\n%s", path, out.String())
+	return ""
+}
+
+func TestCoverageLCOV(t *testing.T) {
+	c := NewCoverage()
+	cover(t, c, coverageTestProgram)
+
+	// Line 10 runs once before the loop and three times to check the condition
+	expected := `BRDA:2,0,0,0
+BRDA:2,0,1,2
+BRDA:10,0,0,2
+BRDA:10,0,1,1
+BRF:4
+BRH:3
+DA:1,1
+DA:2,2
+DA:3,2
+DA:5,0
+DA:9,1
+DA:10,4
+DA:11,2
+DA:12,2
+LF:8
+LH:7
+end_of_record
+`
+
+	if record := lcovRecord(t, c, "coverage_test.gb"); record != expected {
+		t.Fatalf("Expect LCOV record:\n%s\ngot:\n%s", expected, record)
+	}
+}
+
+func TestCoverageRequiredFiles(t *testing.T) {
+	c := NewCoverage()
+	cover(t, c, `require_relative("../test_fixtures/require_test/foo")
+Foo.bar(5)`)
+
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"../test_fixtures/require_test/foo.gb", "DA:6,1\nDA:10,1\nDA:11,0\n"},
+		{"../test_fixtures/require_test/bar.gb", "LH:"},
+	}
+
+	for i, tt := range tests {
+		if record := lcovRecord(t, c, tt.file); !strings.Contains(record, tt.expected) {
+			t.Fatalf("At case %d expect %s's record to contain %q. got:\n%s", i, tt.file, tt.expected, record)
+		}
+	}
+}
+
+func TestCoverageMergeLCOV(t *testing.T) {
+	first := NewCoverage()
+	cover(t, first, coverageTestProgram)
+	var report bytes.Buffer
+	first.WriteLCOV(&report)
+
+	c := NewCoverage()
+
+	if err := c.MergeLCOV(&report); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cover(t, c, strings.Replace(coverageTestProgram, "x = 2", "x = -1", 1))
+	record := lcovRecord(t, c, "coverage_test.gb")
+
+	for i, expected := range []string{"BRDA:2,0,0,0\n", "BRDA:10,0,1,2\n", "BRH:3\n", "DA:5,0\n", "DA:9,2\n", "DA:10,6\n", "LH:7\n"} {
+		if !strings.Contains(record, expected) {
+			t.Fatalf("At case %d expect the merged record to contain %q. got:\n%s", i, expected, record)
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"DA:1,1", "Invalid LCOV record at line 1: DA:1,1"},
+		{"SF:foo.gb\nDA:1", "Invalid LCOV record at line 2: DA:1"},
+		{"SF:foo.gb\nBRDA:1,0,x,1", "Invalid LCOV record at line 2: BRDA:1,0,x,1"},
+	}
+
+	for i, tt := range errorTests {
+		if err := NewCoverage().MergeLCOV(strings.NewReader(tt.input)); err == nil || err.Error() != tt.expected {
+			t.Fatalf("At case %d expect error %q. got: %v", i, tt.expected, err)
+		}
+	}
+}
+
+func TestCoverageHTML(t *testing.T) {
+	c := NewCoverage()
+	cover(t, c, `require_relative("../test_fixtures/require_test/foo")
+Foo.bar(5)`)
+
+	var out bytes.Buffer
+
+	if err := c.WriteHTML(&out); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []string{
+		`<tr class="hit"><td class="number">6</td><td class="hits">1</td><td class="branches"></td><td>      x * ten</td></tr>`,
+		`<tr class="miss"><td class="number">11</td><td class="hits">0</td><td class="branches"></td><td>    yield(100)</td></tr>`,
+		`<tr class=""><td class="number">12</td><td class="hits"></td><td class="branches"></td><td>  end</td></tr>`,
+		// The program's file doesn't exist
+		"coverage_test.gb: no such file or directory",
+	}
+
+	for i, s := range expected {
+		if !strings.Contains(out.String(), s) {
+			t.Fatalf("At case %d expect the report to contain %q. got:\n%s", i, s, out.String())
+		}
+	}
+}
@@ -0,0 +1,559 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/goby-lang/goby/compiler"
+	"github.com/goby-lang/goby/compiler/bytecode"
+)
+
+// Debugger stops a vm's main thread at breakpoints and after steps, so its frames can be inspected.
+// It's given to New with WithDebugger, and Run evaluates a program under it:
+//
+// ```go
+// d := vm.NewDebugger()
+// v := vm.New(dir, args, vm.WithDebugger(d))
+// d.SetBreakpoint("script.gb", 3)
+// for stop := d.Run(sets, "script.gb"); stop != nil; stop = d.Continue() {
+// 	locals, err := d.Locals(0)
+// }
+// ```
+//
+// Run and the methods that resume the program return where it stops next, or nil once it ends.
+// Frames can only be inspected while the program is stopped, and frame 0 is the innermost one.
+// Threads other than the main thread, like fibers and the ones `thread` starts, never stop.
+type Debugger struct {
+	vm *VM
+
+	mu          sync.Mutex
+	breakpoints map[breakpoint]bool
+	watches     map[string]bool
+	// paths caches absolute paths of file names, which breakpoints are compared with
+	paths map[filename]string
+
+	// pause is set by Pause, and the program stops at the next line once it's set
+	pause int32
+	// mode decides where the current step stops, and depth is the number of frames when it started
+	mode  stepMode
+	depth int
+
+	// stops receives where the program stops, and nil once it ends
+	stops chan *Stop
+	// requests are evaluated by the main thread while it's stopped, until one of them returns true to resume it
+	requests chan func() bool
+	// stopped is set while the program is stopped, it's only used by the goroutine that controls the debugger
+	stopped bool
+
+	// thread, frame and pc are where the program is stopped
+	thread *thread
+	frame  *callFrame
+	pc     int
+	// evaluating is set while an expression is evaluated for Eval, so it never stops and its errors aren't printed
+	evaluating bool
+}
+
+// StopReason is why the program stopped
+type StopReason string
+
+// Reasons of stops
+const (
+	// StopEntry is the stop at the program's first line
+	StopEntry StopReason = "entry"
+	// StopBreakpoint is a stop at a line with a breakpoint
+	StopBreakpoint StopReason = "breakpoint"
+	// StopStep is the stop after StepIn or StepOver
+	StopStep StopReason = "step"
+	// StopReturn is the stop after StepOut, at the caller of the frame that returned
+	StopReturn StopReason = "return"
+	// StopPause is the stop after Pause
+	StopPause StopReason = "pause"
+	// StopWatch is a stop before a watched instance variable changes
+	StopWatch StopReason = "watch"
+	// StopError is the stop where an error is raised and not rescued
+	StopError StopReason = "error"
+)
+
+// Stop describes where and why the program stopped
+type Stop struct {
+	Reason StopReason
+	File   string
+	// Line starts from 1, it's the line of the statement that's evaluated next
+	Line int
+	// Description is the error message of StopError, or the change of StopWatch's instance variable
+	Description string
+}
+
+// Frame is a method, block, class body or program being evaluated
+type Frame struct {
+	// Name is like `foo`, `block in foo`, `class Foo` or `<main>`
+	Name string
+	File string
+	Line int
+}
+
+// Variable is a local or instance variable with its inspected value
+type Variable struct {
+	Name  string
+	Value string
+}
+
+type breakpoint struct {
+	file string
+	line int
+}
+
+type stepMode int
+
+const (
+	stepNone stepMode = iota
+	// stepEntry stops at the first line
+	stepEntry
+	stepIn
+	stepOver
+	stepOut
+)
+
+var errNotStopped = errors.New("The program isn't stopped")
+
+// NewDebugger returns a debugger without breakpoints, it's given to New with WithDebugger
+func NewDebugger() *Debugger {
+	return &Debugger{
+		breakpoints: map[breakpoint]bool{},
+		watches:     map[string]bool{},
+		paths:       map[filename]string{},
+		stops:       make(chan *Stop),
+		requests:    make(chan func() bool),
+	}
+}
+
+// WithDebugger evaluates the vm's programs under the debugger, see Debugger.
+func WithDebugger(d *Debugger) Option {
+	return func(vm *VM) {
+		vm.debugger = d
+		d.vm = vm
+	}
+}
+
+// SetBreakpoint stops the program before it evaluates the line of the file. Lines start from 1.
+// It can be set while the program is running.
+func (d *Debugger) SetBreakpoint(file string, line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints[breakpoint{absPath(file), line}] = true
+}
+
+// ClearBreakpoint removes the breakpoint, and reports whether there was one
+func (d *Debugger) ClearBreakpoint(file string, line int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	b := breakpoint{absPath(file), line}
+	ok := d.breakpoints[b]
+	delete(d.breakpoints, b)
+	return ok
+}
+
+// Watch stops the program before an instance variable with the name, like `@count`, changes to another value
+func (d *Debugger) Watch(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.watches[name] = true
+}
+
+// Unwatch stops watching the instance variable, and reports whether it was watched
+func (d *Debugger) Unwatch(name string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ok := d.watches[name]
+	delete(d.watches, name)
+	return ok
+}
+
+// Run evaluates the instruction sets in a new goroutine and stops at their first line
+func (d *Debugger) Run(sets []*bytecode.InstructionSet, fn string) *Stop {
+	d.mode = stepEntry
+
+	go func() {
+		d.vm.ExecInstructions(sets, fn)
+		d.stops <- nil
+	}()
+
+	return d.wait()
+}
+
+// Continue resumes the program until it reaches a breakpoint or a watched change, or an error is raised
+func (d *Debugger) Continue() *Stop {
+	return d.resume(stepNone)
+}
+
+// StepIn resumes the program until it reaches another line, including lines of the methods and blocks it calls
+func (d *Debugger) StepIn() *Stop {
+	return d.resume(stepIn)
+}
+
+// StepOver resumes the program until it reaches another line of the current frame or its callers
+func (d *Debugger) StepOver() *Stop {
+	return d.resume(stepOver)
+}
+
+// StepOut resumes the program until the current frame returns, and stops at the next line of its callers
+func (d *Debugger) StepOut() *Stop {
+	return d.resume(stepOut)
+}
+
+// Pause stops the running program at the next line it reaches, and Run or the call that resumed it returns the stop.
+// It can be called from any goroutine.
+func (d *Debugger) Pause() {
+	atomic.StoreInt32(&d.pause, 1)
+}
+
+// Frames returns the frames being evaluated, it's nil if the program isn't stopped
+func (d *Debugger) Frames() []Frame {
+	var frames []Frame
+
+	d.do(func() {
+		for _, cf := range d.frames() {
+			frames = append(frames, Frame{Name: cf.backtraceName(), File: string(cf.instructionSet.filename), Line: d.frameLine(cf)})
+		}
+	})
+
+	return frames
+}
+
+// Locals returns the local variables the frame can use, including its blocks' outer variables
+func (d *Debugger) Locals(frame int) (locals []Variable, err error) {
+	err = d.doInFrame(frame, func(cf *callFrame) {
+		seen := map[string]bool{}
+		depth := 0
+
+		for _, scope := range frameScopes(cf) {
+			for index, name := range scope.instructionSet.localNames {
+				if seen[name] {
+					continue
+				}
+
+				seen[name] = true
+				value := Object(NULL)
+
+				if p := cf.getLCL(index, depth); p != nil && p.Target != nil {
+					value = p.Target
+				}
+
+				locals = append(locals, Variable{Name: name, Value: inspectObject(value)})
+			}
+
+			depth++
+		}
+	})
+
+	return
+}
+
+// InstanceVariables returns instance variables of the frame's self, sorted by their names
+func (d *Debugger) InstanceVariables(frame int) (ivars []Variable, err error) {
+	err = d.doInFrame(frame, func(cf *callFrame) {
+		b, ok := cf.self.(interface{ instanceVariableNames() []string })
+
+		if !ok {
+			return
+		}
+
+		for _, name := range b.instanceVariableNames() {
+			value, _ := cf.self.instanceVariableGet(name)
+			ivars = append(ivars, Variable{Name: name, Value: inspectObject(value)})
+		}
+	})
+
+	return
+}
+
+// Eval evaluates the expression in the frame, where its local variables, instance variables and self can be used.
+// Assignments to the frame's variables change them, but new local variables are discarded.
+func (d *Debugger) Eval(frame int, expr string) (result string, err error) {
+	e := d.doInFrame(frame, func(cf *callFrame) {
+		result, err = d.eval(cf, expr)
+	})
+
+	if e != nil {
+		return "", e
+	}
+
+	return
+}
+
+// wait returns the next stop
+func (d *Debugger) wait() *Stop {
+	stop := <-d.stops
+	d.stopped = stop != nil
+	return stop
+}
+
+func (d *Debugger) resume(mode stepMode) *Stop {
+	if !d.stopped {
+		return nil
+	}
+
+	d.stopped = false
+	d.requests <- func() bool {
+		d.mode = mode
+		d.depth = len(d.frames())
+		return true
+	}
+
+	return d.wait()
+}
+
+// do evaluates the request with the main thread while the program is stopped, and reports whether it's stopped
+func (d *Debugger) do(request func()) bool {
+	if !d.stopped {
+		return false
+	}
+
+	done := make(chan struct{})
+	d.requests <- func() bool {
+		request()
+		close(done)
+		return false
+	}
+
+	<-done
+	return true
+}
+
+func (d *Debugger) doInFrame(frame int, request func(cf *callFrame)) error {
+	var err error
+
+	ok := d.do(func() {
+		frames := d.frames()
+
+		if frame < 0 || frame >= len(frames) {
+			err = fmt.Errorf("No frame %d, there are %d frames", frame, len(frames))
+			return
+		}
+
+		request(frames[frame])
+	})
+
+	if !ok {
+		return errNotStopped
+	}
+
+	return err
+}
+
+// check is called by the main thread before it evaluates an instruction, and stops the program if it should
+func (d *Debugger) check(t *thread, cf *callFrame) {
+	if t != d.vm.mainThread || d.evaluating {
+		return
+	}
+
+	i := cf.instructionSet.instructions[cf.pc]
+
+	if i.opcode == opSetInstanceVariable && d.watching(i.name) {
+		old, _ := cf.self.instanceVariableGet(i.name)
+		value := t.stack.top().Target
+
+		if old != value && inspectObject(old) != inspectObject(value) {
+			d.stop(t, cf, cf.pc, StopWatch, fmt.Sprintf("%s: %s -> %s", i.name, inspectObject(old), inspectObject(value)))
+			return
+		}
+	}
+
+	if !i.lineStart {
+		return
+	}
+
+	switch {
+	case d.hasBreakpoint(cf.instructionSet.filename, i.sourceLine):
+		d.stop(t, cf, cf.pc, StopBreakpoint, "")
+	case atomic.LoadInt32(&d.pause) == 1:
+		d.stop(t, cf, cf.pc, StopPause, "")
+	case d.mode == stepEntry:
+		d.stop(t, cf, cf.pc, StopEntry, "")
+	case d.mode == stepIn:
+		d.stop(t, cf, cf.pc, StopStep, "")
+	case d.mode == stepOver && len(t.evaluatingFrames()) <= d.depth:
+		d.stop(t, cf, cf.pc, StopStep, "")
+	case d.mode == stepOut && len(t.evaluatingFrames()) < d.depth:
+		d.stop(t, cf, cf.pc, StopReturn, "")
+	}
+}
+
+// raised is called by the main thread before it reports the error raised by the instruction before the frame's pc
+func (d *Debugger) raised(t *thread, cf *callFrame, err *Error) {
+	if t != d.vm.mainThread || d.evaluating || err.reported {
+		return
+	}
+
+	d.stop(t, cf, cf.pc-1, StopError, err.Message)
+}
+
+// stop blocks the main thread and evaluates requests until one of them resumes it
+func (d *Debugger) stop(t *thread, cf *callFrame, pc int, reason StopReason, description string) {
+	d.thread, d.frame, d.pc = t, cf, pc
+	d.mode = stepNone
+	atomic.StoreInt32(&d.pause, 0)
+
+	d.stops <- &Stop{Reason: reason, File: string(cf.instructionSet.filename), Line: d.frameLine(cf), Description: description}
+
+	for request := range d.requests {
+		if request() {
+			break
+		}
+	}
+
+	d.thread, d.frame = nil, nil
+}
+
+func (d *Debugger) hasBreakpoint(file filename, line int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.breakpoints) == 0 {
+		return false
+	}
+
+	path, ok := d.paths[file]
+
+	if !ok {
+		path = absPath(string(file))
+		d.paths[file] = path
+	}
+
+	return d.breakpoints[breakpoint{path, line}]
+}
+
+func (d *Debugger) watching(name string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.watches[name]
+}
+
+// frames returns the frames of the stopped thread, starting from the innermost one
+func (d *Debugger) frames() []*callFrame {
+	return d.thread.evaluatingFrames()
+}
+
+// frameLine returns the line the frame is evaluating, which is the line of its last instruction if it's a caller
+func (d *Debugger) frameLine(cf *callFrame) int {
+	if cf == d.frame {
+		return cf.sourceLine(d.pc)
+	}
+
+	return cf.sourceLine(cf.pc - 1)
+}
+
+// eval evaluates the expression in a new frame, which shares the locals, self and outer frames of the frame
+func (d *Debugger) eval(frame *callFrame, expr string) (string, error) {
+	sets, err := compiler.CompileInScope(expr, scopeTable(frame))
+
+	if err != nil {
+		return "", err
+	}
+
+	// The expression can refer to outer frames' locals, which bytecode.Verify can't check, so it's evaluated without verification
+	p := newInstructionTranslator(frame.instructionSet.filename)
+	p.vm = d.vm
+	p.transferInstructionSets(sets)
+
+	t := d.thread
+	cf := newCallFrame(p.program)
+	cf.locals = frame.locals
+	cf.self = frame.self
+	cf.ep = frame.ep
+	cf.blockFrame = frame.blockFrame
+	// The frame shares the locals, so it must not be released
+	cf.captured = true
+
+	cfp, sp, yieldError := t.cfp, t.sp, t.yieldError
+	d.evaluating = true
+	t.evalFrame(cf)
+	d.evaluating = false
+	value := Object(NULL)
+
+	// Expressions like assignments leave nothing on the stack
+	if t.sp > sp {
+		value = t.stack.top().Target
+	}
+
+	// Frames stay on the call frame stack if an error stops them
+	for t.cfp > cfp {
+		t.callFrameStack.pop()
+	}
+
+	for t.sp > sp {
+		t.stack.pop()
+	}
+
+	t.yieldError = yieldError
+
+	if err, ok := value.(*Error); ok {
+		return "", errors.New(err.Message)
+	}
+
+	return inspectObject(value), nil
+}
+
+// frameScopes returns the frame and the outer frames whose locals its block can use, starting from the frame
+func frameScopes(cf *callFrame) []*callFrame {
+	scopes := []*callFrame{cf}
+
+	for cf.instructionSet.isType == bytecode.Block && cf.blockFrame != nil && cf.blockFrame.ep != nil {
+		cf = cf.blockFrame.ep
+		scopes = append(scopes, cf)
+	}
+
+	return scopes
+}
+
+// scopeTable returns a local table with the variables of the frame and its outer frames, for expressions evaluated in it
+func scopeTable(cf *callFrame) *bytecode.LocalTable {
+	scopes := frameScopes(cf)
+	var table *bytecode.LocalTable
+
+	for n := len(scopes) - 1; n >= 0; n-- {
+		if table == nil {
+			table = bytecode.NewLocalTable()
+		} else {
+			table = table.NewBlockTable()
+		}
+
+		for _, name := range scopes[n].instructionSet.localNames {
+			table.Define(name)
+		}
+	}
+
+	return table
+}
+
+// inspectObject returns the object's string representation, where strings are quoted like in arrays
+func inspectObject(obj Object) string {
+	if s, ok := obj.(*StringObject); ok {
+		return "\"" + s.Value + "\""
+	}
+
+	return obj.toString()
+}
+
+func absPath(file string) string {
+	if path, err := filepath.Abs(file); err == nil {
+		return path
+	}
+
+	return filepath.Clean(file)
+}
+
+// instanceVariableNames returns the names of the object's instance variables, sorted
+func (b *baseObj) instanceVariableNames() []string {
+	if b.InstanceVariables == nil {
+		return []string{}
+	}
+
+	names := b.InstanceVariables.names()
+	sort.Strings(names)
+	return names
+}
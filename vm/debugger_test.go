@@ -0,0 +1,250 @@
+package vm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goby-lang/goby/compiler"
+)
+
+const debuggerTestProgram = `class Counter
+  def initialize
+    @count = 0
+  end
+
+  def add(values)
+    sum = 0
+    values.each do |v|
+      sum += v
+    end
+    @count += sum
+    sum
+  end
+end
+
+c = Counter.new
+total = c.add([1, 2])
+total = c.add([3])
+c`
+
+// startDebugger starts the program under a new debugger and returns its first stop
+func startDebugger(t *testing.T, input string, setup func(d *Debugger)) (*Debugger, *Stop) {
+	t.Helper()
+	sets, err := compiler.CompileFileToInstructions("debugger_test.gb", input)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	d := NewDebugger()
+	New("./", []string{}, WithDebugger(d))
+
+	if setup != nil {
+		setup(d)
+	}
+
+	return d, d.Run(sets, "debugger_test.gb")
+}
+
+func formatStop(stop *Stop) string {
+	if stop == nil {
+		return "exit"
+	}
+
+	if stop.Description != "" {
+		return fmt.Sprintf("%s %d %s", stop.Reason, stop.Line, stop.Description)
+	}
+
+	return fmt.Sprintf("%s %d", stop.Reason, stop.Line)
+}
+
+func TestDebuggerStops(t *testing.T) {
+	tests := []struct {
+		breakpoints []int
+		watches     []string
+		steps       string
+		expected    []string
+	}{
+		{nil, nil, "continue", []string{"entry 1", "exit"}},
+		{[]int{9}, nil, "continue continue continue continue", []string{"entry 1", "breakpoint 9", "breakpoint 9", "breakpoint 9", "exit"}},
+		{nil, nil, "over over over over", []string{"entry 1", "step 16", "step 17", "step 18", "step 19"}},
+		{nil, nil, "over over in in in in in in", []string{"entry 1", "step 16", "step 17", "step 7", "step 8", "step 9", "step 9", "step 11", "step 12"}},
+		// Stepping over the block's last line stops at its next iteration
+		{nil, nil, "over over in in in over over over", []string{"entry 1", "step 16", "step 17", "step 7", "step 8", "step 9", "step 9", "step 11", "step 12"}},
+		{[]int{9}, nil, "continue over over", []string{"entry 1", "breakpoint 9", "breakpoint 9", "step 11"}},
+		{nil, nil, "over over in in in out out", []string{"entry 1", "step 16", "step 17", "step 7", "step 8", "step 9", "return 11", "return 18"}},
+		{[]int{7}, []string{"@count"}, "continue continue continue continue", []string{"entry 1", "watch 3 @count: nil -> 0", "breakpoint 7", "watch 11 @count: 0 -> 3", "breakpoint 7"}},
+	}
+
+	for i, tt := range tests {
+		d, stop := startDebugger(t, debuggerTestProgram, func(d *Debugger) {
+			for _, line := range tt.breakpoints {
+				d.SetBreakpoint("debugger_test.gb", line)
+			}
+
+			for _, name := range tt.watches {
+				d.Watch(name)
+			}
+		})
+
+		stops := []string{formatStop(stop)}
+
+		for _, step := range strings.Fields(tt.steps) {
+			switch step {
+			case "continue":
+				stop = d.Continue()
+			case "in":
+				stop = d.StepIn()
+			case "over":
+				stop = d.StepOver()
+			case "out":
+				stop = d.StepOut()
+			}
+
+			stops = append(stops, formatStop(stop))
+		}
+
+		if !reflect.DeepEqual(stops, tt.expected) {
+			t.Fatalf("At case %d expect stops %v. got: %v", i, tt.expected, stops)
+		}
+
+		for stop != nil {
+			stop = d.Continue()
+		}
+	}
+}
+
+func TestDebuggerClearBreakpoint(t *testing.T) {
+	d, _ := startDebugger(t, debuggerTestProgram, func(d *Debugger) {
+		d.SetBreakpoint("debugger_test.gb", 9)
+		d.SetBreakpoint("debugger_test.gb", 11)
+	})
+
+	if stop := d.Continue(); formatStop(stop) != "breakpoint 9" {
+		t.Fatalf("Expect to stop at line 9. got: %s", formatStop(stop))
+	}
+
+	if !d.ClearBreakpoint("./debugger_test.gb", 9) || d.ClearBreakpoint("debugger_test.gb", 10) {
+		t.Fatal("Expect only the breakpoint at line 9 to be cleared")
+	}
+
+	if stop := d.Continue(); formatStop(stop) != "breakpoint 11" {
+		t.Fatalf("Expect to stop at line 11. got: %s", formatStop(stop))
+	}
+
+	d.Continue()
+}
+
+func TestDebuggerInspection(t *testing.T) {
+	d, _ := startDebugger(t, debuggerTestProgram, func(d *Debugger) {
+		d.SetBreakpoint("debugger_test.gb", 9)
+	})
+
+	d.Continue()
+	d.Continue()
+
+	expectedFrames := []Frame{
+		{"block in add", "debugger_test.gb", 9},
+		{"add", "debugger_test.gb", 8},
+		{"<main>", "debugger_test.gb", 17},
+	}
+
+	if frames := d.Frames(); !reflect.DeepEqual(frames, expectedFrames) {
+		t.Fatalf("Expect frames %v. got: %v", expectedFrames, frames)
+	}
+
+	localTests := []struct {
+		frame    int
+		expected []Variable
+	}{
+		{0, []Variable{{"v", "2"}, {"values", "[1, 2]"}, {"sum", "1"}}},
+		{1, []Variable{{"values", "[1, 2]"}, {"sum", "1"}}},
+		{2, []Variable{{"c", "<Instance of: Counter>"}, {"total", "nil"}}},
+	}
+
+	for i, tt := range localTests {
+		locals, err := d.Locals(tt.frame)
+
+		if err != nil || !reflect.DeepEqual(locals, tt.expected) {
+			t.Fatalf("At case %d expect locals %v. got: %v, %v", i, tt.expected, locals, err)
+		}
+	}
+
+	ivars, err := d.InstanceVariables(1)
+
+	if expected := []Variable{{"@count", "0"}}; err != nil || !reflect.DeepEqual(ivars, expected) {
+		t.Fatalf("Expect instance variables %v. got: %v, %v", expected, ivars, err)
+	}
+
+	evalTests := []struct {
+		frame    int
+		input    string
+		expected string
+		err      string
+	}{
+		{0, "sum + v", "3", ""},
+		{0, "values.map do |x| x * v end", "[2, 4]", ""},
+		{1, "@count", "0", ""},
+		{2, `"total: " + total.to_s`, `"total: nil"`, ""},
+		{0, "sum = 10", "nil", ""},
+		{1, "sum", "10", ""},
+		{0, "foo", "", "UndefinedMethodError: Undefined Method 'foo' for <Instance of: Counter>"},
+		{3, "sum", "", "No frame 3, there are 3 frames"},
+	}
+
+	for i, tt := range evalTests {
+		result, err := d.Eval(tt.frame, tt.input)
+
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("At case %d expect error %q. got: %v", i, tt.err, err)
+			}
+
+			continue
+		}
+
+		if err != nil || result != tt.expected {
+			t.Fatalf("At case %d expect %s. got: %s, %v", i, tt.expected, result, err)
+		}
+	}
+
+	for stop := d.Continue(); stop != nil; stop = d.Continue() {
+	}
+
+	if _, err := d.Locals(0); err != errNotStopped {
+		t.Fatalf("Expect errNotStopped. got: %v", err)
+	}
+
+	if frames := d.Frames(); frames != nil {
+		t.Fatalf("Expect no frames. got: %v", frames)
+	}
+
+	// The assignment changed the first sum from 1 to 10, so it's 12 and the second one is 3
+	count, _ := d.vm.GetExecResult().instanceVariableGet("@count")
+	testIntegerObject(t, 0, count, 15)
+}
+
+func TestDebuggerErrorStop(t *testing.T) {
+	input := `def foo(x)
+  x.bar
+end
+
+foo(1)`
+
+	d, _ := startDebugger(t, input, nil)
+	stop := d.Continue()
+
+	if expected := "error 2 UndefinedMethodError: Undefined Method 'bar' for 1"; formatStop(stop) != expected {
+		t.Fatalf("Expect stop %s. got: %s", expected, formatStop(stop))
+	}
+
+	if result, err := d.Eval(0, "x + 1"); err != nil || result != "2" {
+		t.Fatalf("Expect 2. got: %s, %v", result, err)
+	}
+
+	if stop := d.Continue(); stop != nil {
+		t.Fatalf("Expect the program to exit. got: %s", formatStop(stop))
+	}
+}
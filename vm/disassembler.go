@@ -0,0 +1,333 @@
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/goby-lang/goby/compiler/bytecode"
+)
+
+// Operand types of disassembled instructions
+const (
+	IntegerOperand          = "integer"
+	ObjectOperand           = "object"
+	StringOperand           = "string"
+	ConstantOperand         = "constant"
+	NamespaceOperand        = "namespace"
+	InstanceVariableOperand = "instance_variable"
+	DepthOperand            = "depth"
+	LocalOperand            = "local"
+	OptionalOperand         = "optional"
+	CountOperand            = "count"
+	ArgcOperand             = "argc"
+	TargetOperand           = "target"
+	ClassOperand            = "class"
+	ModuleOperand           = "module"
+	SuperClassOperand       = "superclass"
+	MethodOperand           = "method"
+	BodyOperand             = "body"
+	BlockOperand            = "block"
+)
+
+// DisassembledSet is an instruction set as the vm evaluates it: a program, method, class or block body
+type DisassembledSet struct {
+	// Index identifies the set, body and block operands refer to it
+	Index int    `json:"index"`
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	File  string `json:"file"`
+	// Parent is the index of the set that defines the body, or -1 for the program
+	Parent       int                       `json:"parent"`
+	Locals       []DisassembledLocal       `json:"locals"`
+	Instructions []DisassembledInstruction `json:"instructions"`
+}
+
+// Label returns the set's type and name, like `<Def:foo>`
+func (s DisassembledSet) Label() string {
+	if s.Type == bytecode.Program {
+		return "<" + s.Type + ">"
+	}
+
+	return fmt.Sprintf("<%s:%s>", s.Type, s.Name)
+}
+
+// DisassembledLocal is an entry of a set's local table
+type DisassembledLocal struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	// Param is "required" or "optional" for parameters, and empty for other variables
+	Param string `json:"param,omitempty"`
+}
+
+// DisassembledInstruction is an instruction with its decoded operands
+type DisassembledInstruction struct {
+	Index  int    `json:"index"`
+	Opcode string `json:"opcode"`
+	// SourceLine is 0 if the instruction isn't compiled from a statement, like default values of parameters
+	SourceLine int       `json:"source_line"`
+	Operands   []Operand `json:"operands"`
+	// JumpTarget is set if branches or jumps of the set go to the instruction
+	JumpTarget bool `json:"jump_target,omitempty"`
+	// TailCall is set for sends that reuse their caller's frame
+	TailCall bool `json:"tail_call,omitempty"`
+}
+
+// Operand is a typed operand. Label is what it refers to, like a local variable's name or a body's label
+type Operand struct {
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+	Label string      `json:"label,omitempty"`
+}
+
+func (o Operand) String() string {
+	if o.Label != "" {
+		return fmt.Sprintf("%s:%v(%s)", o.Type, o.Value, o.Label)
+	}
+
+	switch v := o.Value.(type) {
+	case string:
+		if o.Type == StringOperand {
+			return fmt.Sprintf("%s:%q", o.Type, v)
+		}
+	case int:
+		// Targets look like instructions' indexes
+		if o.Type == TargetOperand {
+			return fmt.Sprintf("%s:%04d", o.Type, v)
+		}
+	}
+
+	return fmt.Sprintf("%s:%v", o.Type, o.Value)
+}
+
+// Disassemble translates compiled sets like ExecInstructions does, and returns them with resolved operands.
+// Sets are in the order they're compiled, which puts bodies before the program.
+func Disassemble(sets []*bytecode.InstructionSet, file string) ([]DisassembledSet, error) {
+	if err := bytecode.Verify(sets); err != nil {
+		return nil, err
+	}
+
+	iss := newInstructionTranslator(filename(file)).transferInstructionSets(sets)
+	indexes := map[*instructionSet]int{}
+	parents := map[*instructionSet]*instructionSet{}
+
+	for n, is := range iss {
+		indexes[is] = n
+
+		for _, ins := range is.instructions {
+			if ins.body != nil {
+				parents[ins.body] = is
+			}
+		}
+	}
+
+	result := []DisassembledSet{}
+
+	for n, is := range iss {
+		s := DisassembledSet{Index: n, Type: is.isType, Name: is.name, File: string(is.filename), Parent: -1, Locals: []DisassembledLocal{}}
+
+		if parent, ok := parents[is]; ok {
+			s.Parent = indexes[parent]
+		}
+
+		for index, name := range is.localNames {
+			local := DisassembledLocal{Index: index, Name: name}
+
+			if index < len(is.argTypes) {
+				local.Param = "required"
+
+				if is.argTypes[index] == bytecode.OptionedArg {
+					local.Param = "optional"
+				}
+			}
+
+			s.Locals = append(s.Locals, local)
+		}
+
+		targets := map[int]bool{}
+
+		for _, ins := range is.instructions {
+			switch ins.opcode {
+			case opBranchUnless, opBranchIf, opJump:
+				targets[ins.target] = true
+			}
+		}
+
+		for index, ins := range is.instructions {
+			d := DisassembledInstruction{
+				Index:      index,
+				Opcode:     ins.opcode.String(),
+				SourceLine: ins.sourceLine,
+				Operands:   disassembleOperands(is, ins, parents, indexes),
+				JumpTarget: targets[index],
+				TailCall:   ins.tailCall,
+			}
+
+			s.Instructions = append(s.Instructions, d)
+		}
+
+		result = append(result, s)
+	}
+
+	return result, nil
+}
+
+func disassembleOperands(is *instructionSet, ins *instruction, parents map[*instructionSet]*instructionSet, indexes map[*instructionSet]int) []Operand {
+	operands := []Operand{}
+	add := func(t string, v interface{}) {
+		operands = append(operands, Operand{Type: t, Value: v})
+	}
+
+	body := func(t string) {
+		if ins.body == nil {
+			return
+		}
+
+		label := DisassembledSet{Type: ins.body.isType, Name: ins.body.name}.Label()
+		operands = append(operands, Operand{Type: t, Value: indexes[ins.body], Label: label})
+	}
+
+	switch ins.opcode {
+	case opPutObject:
+		if ins.object != nil {
+			add(ObjectOperand, ins.object.toString())
+		} else {
+			add(IntegerOperand, ins.integer)
+		}
+	case opPutString:
+		add(StringOperand, ins.name)
+	case opGetConstant:
+		add(ConstantOperand, ins.name)
+		add(NamespaceOperand, ins.flag)
+	case opSetConstant:
+		add(ConstantOperand, ins.name)
+	case opGetInstanceVariable, opSetInstanceVariable:
+		add(InstanceVariableOperand, ins.name)
+	case opGetLocal, opSetLocal:
+		add(DepthOperand, ins.depth)
+		operands = append(operands, Operand{Type: LocalOperand, Value: ins.index, Label: localName(is, ins.depth, ins.index, parents)})
+
+		if ins.opcode == opSetLocal && ins.flag {
+			add(OptionalOperand, true)
+		}
+	case opNewArray, opExpandArray, opNewHash, opInvokeBlock:
+		add(CountOperand, ins.count)
+	case opDefMethod, opDefSingletonMethod:
+		add(ArgcOperand, ins.count)
+		body(BodyOperand)
+	case opBranchUnless, opBranchIf, opJump:
+		add(TargetOperand, ins.target)
+	case opDefClass:
+		if ins.flag {
+			add(ModuleOperand, ins.name)
+		} else {
+			add(ClassOperand, ins.name)
+		}
+
+		if ins.superClass != "" {
+			add(SuperClassOperand, ins.superClass)
+		}
+
+		body(BodyOperand)
+	case opSend:
+		add(MethodOperand, ins.name)
+		add(ArgcOperand, ins.count)
+		body(BlockOperand)
+	default:
+		if _, ok := optOperators[ins.opcode]; ok {
+			add(MethodOperand, ins.name)
+			add(ArgcOperand, ins.count)
+		}
+	}
+
+	return operands
+}
+
+// localName finds the name of the local variable in the set, or in its outer set of the depth
+func localName(is *instructionSet, depth, index int, parents map[*instructionSet]*instructionSet) string {
+	for ; depth > 0 && is != nil; depth-- {
+		is = parents[is]
+	}
+
+	if is == nil || index >= len(is.localNames) {
+		return ""
+	}
+
+	return is.localNames[index]
+}
+
+// WriteDisassembly prints the sets with their local tables and instructions.
+// Each instruction has its index, source line, opcode and typed operands, and `>` marks jump targets.
+func WriteDisassembly(w io.Writer, sets []DisassembledSet) error {
+	var out bytes.Buffer
+	tw := tabwriter.NewWriter(&out, 0, 4, 2, ' ', 0)
+
+	for n, s := range sets {
+		if n > 0 {
+			fmt.Fprintln(tw)
+		}
+
+		parent := ""
+
+		if s.Parent >= 0 {
+			parent = fmt.Sprintf(" parent:#%d", s.Parent)
+		}
+
+		fmt.Fprintf(tw, "#%d %s file:%s%s\n", s.Index, s.Label(), s.File, parent)
+
+		if len(s.Locals) > 0 {
+			locals := []string{}
+
+			for _, l := range s.Locals {
+				local := fmt.Sprintf("%d:%s", l.Index, l.Name)
+
+				if l.Param != "" {
+					local += "(" + l.Param + ")"
+				}
+
+				locals = append(locals, local)
+			}
+
+			fmt.Fprintf(tw, "locals: %s\n", strings.Join(locals, " "))
+		}
+
+		for _, ins := range s.Instructions {
+			marker, line := " ", ""
+
+			if ins.JumpTarget {
+				marker = ">"
+			}
+
+			if ins.SourceLine > 0 {
+				line = fmt.Sprintf("L%d", ins.SourceLine)
+			}
+
+			operands := []string{}
+
+			for _, o := range ins.Operands {
+				operands = append(operands, o.String())
+			}
+
+			if ins.TailCall {
+				operands = append(operands, "(tail call)")
+			}
+
+			fmt.Fprintf(tw, "%s %04d\t%s\t%s\t%s\n", marker, ins.Index, line, ins.Opcode, strings.Join(operands, " "))
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// Instructions without operands are padded for the empty column
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		if _, err := io.WriteString(w, strings.TrimRight(line, " ")+"\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
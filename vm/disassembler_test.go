@@ -0,0 +1,138 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/goby-lang/goby/compiler"
+)
+
+const disassemblerTestProgram = `class Foo
+  def bar(x, y = 2)
+    [1].each do |i|
+      x = x + i
+    end
+    if x > y
+      puts("big")
+    end
+    baz(x)
+  end
+end`
+
+func disassemble(t *testing.T, input string) []DisassembledSet {
+	t.Helper()
+	sets, err := compiler.CompileFileToInstructions("disassembler_test.gb", input)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	disassembly, err := Disassemble(sets, "disassembler_test.gb")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return disassembly
+}
+
+func TestWriteDisassembly(t *testing.T) {
+	var out bytes.Buffer
+
+	if err := WriteDisassembly(&out, disassemble(t, disassemblerTestProgram)); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := `#0 <Block:2> file:disassembler_test.gb parent:#1
+locals: 0:i
+  0000  L4  getlocal  depth:1 local:0(x)
+  0001  L4  getlocal  depth:0 local:0(i)
+  0002  L4  opt_plus  method:+ argc:1
+  0003  L4  setlocal  depth:1 local:0(x)
+  0004  L4  leave
+
+#1 <Def:bar> file:disassembler_test.gb parent:#2
+locals: 0:x(required) 1:y(optional)
+  0000      putobject     integer:2
+  0001      setlocal      depth:0 local:1(y) optional:true
+  0002  L3  putobject     integer:1
+  0003  L3  newarray      count:1
+  0004  L3  send          method:each argc:0 block:0(<Block:2>)
+  0005  L6  getlocal      depth:0 local:0(x)
+  0006  L6  getlocal      depth:0 local:1(y)
+  0007  L6  opt_gt        method:> argc:1
+  0008  L6  branchunless  target:0013
+  0009  L7  putself
+  0010  L7  putstring     string:"big"
+  0011  L7  send          method:puts argc:1
+  0012  L7  jump          target:0014
+> 0013  L7  putnil
+> 0014  L9  putself
+  0015  L9  getlocal      depth:0 local:0(x)
+  0016  L9  send          method:baz argc:1 (tail call)
+  0017  L9  leave
+
+#2 <DefClass:Foo> file:disassembler_test.gb parent:#3
+  0000  L2  putself
+  0001  L2  putstring   string:"bar"
+  0002  L2  def_method  argc:2 body:1(<Def:bar>)
+  0003  L2  leave
+
+#3 <ProgramStart> file:disassembler_test.gb
+  0000  L1  putself
+  0001  L1  def_class  class:Foo body:2(<DefClass:Foo>)
+  0002  L1  pop
+  0003  L1  leave
+`
+
+	if out.String() != expected {
+		t.Fatalf("Expect disassembly:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestDisassembleJSON(t *testing.T) {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+
+	err := encoder.Encode(disassemble(t, `module Foo; end
+a = 1
+[2].each do |b|
+  a = @c
+end`))
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []string{
+		`{"index":0,"type":"DefClass","name":"Foo","file":"disassembler_test.gb","parent":2,"locals":[],"instructions":[{"index":0,"opcode":"leave","source_line":1,"operands":[]}]}`,
+		`{"index":1,"type":"Block","name":"1","file":"disassembler_test.gb","parent":2,"locals":[{"index":0,"name":"b"}],`,
+		`{"index":0,"opcode":"getinstancevariable","source_line":4,"operands":[{"type":"instance_variable","value":"@c"}]}`,
+		`{"index":1,"opcode":"setlocal","source_line":4,"operands":[{"type":"depth","value":1},{"type":"local","value":0,"label":"a"}]}`,
+		`{"type":"module","value":"Foo"},{"type":"body","value":0,"label":"<DefClass:Foo>"}`,
+		`{"type":"method","value":"each"},{"type":"argc","value":0},{"type":"block","value":1,"label":"<Block:1>"}`,
+	}
+
+	for i, s := range expected {
+		if !strings.Contains(out.String(), s) {
+			t.Fatalf("At case %d expect JSON to contain %s. got:\n%s", i, s, out.String())
+		}
+	}
+}
+
+func TestDisassembleInvalidBytecode(t *testing.T) {
+	sets, err := compiler.CompileToInstructions("1 + 1")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	sets[0].Instructions[0].Action = "foo"
+
+	if _, err := Disassemble(sets, ""); err == nil || !strings.Contains(err.Error(), "unknown action") {
+		t.Fatalf("Expect an error of the unknown action. got: %v", err)
+	}
+}
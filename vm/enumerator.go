@@ -0,0 +1,536 @@
+package vm
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	enumeratorClass = "Enumerator"
+	yielderClass    = "Yielder"
+)
+
+// iteration runs a whole iteration and passes every element to yield.
+// It stops and returns the error once yield returns one.
+type iteration func(t *thread, yield func(values ...Object) *Error) *Error
+
+// EnumeratorObject represents a lazy sequence of values, which can be iterated externally with `next`.
+// Calling `each` or `map` without a block on Array, Hash or Range returns an enumerator.
+//
+// ```ruby
+// e = [1, 2, 3].each
+// e.next # => 1
+// e.peek # => 2
+// e.next # => 2
+// e.rewind
+// e.next # => 1
+// ```
+//
+// `map` and `select` on an enumerator return new enumerators without evaluating the elements,
+// so they also work on infinite sequences created by `Enumerator.new`.
+//
+// ```ruby
+// naturals = Enumerator.new do |y|
+//   i = 0
+//   while true do
+//     i += 1
+//     y.yield(i)
+//   end
+// end
+//
+// naturals.select do |i|
+//   i % 2 == 0
+// end.map do |i|
+//   i * i
+// end.first(3) # => [4, 16, 36]
+// ```
+type EnumeratorObject struct {
+	*baseObj
+	iterate iteration
+	fiber   *FiberObject
+	peeked  Object
+	sync.Mutex
+}
+
+// YielderObject is passed to the block of `Enumerator.new` to produce values.
+type YielderObject struct {
+	*baseObj
+	yield func(values ...Object) *Error
+}
+
+func (vm *VM) initEnumeratorClass() *RClass {
+	class := vm.initializeClass(enumeratorClass, false)
+	class.setBuiltInMethods(builtinEnumeratorClassMethods(), true)
+	class.setBuiltInMethods(builtinEnumeratorInstanceMethods(), false)
+
+	yielder := vm.initializeClass(yielderClass, false)
+	yielder.setBuiltInMethods(builtinYielderInstanceMethods(), false)
+	class.setClassConstant(yielder)
+
+	return class
+}
+
+func (vm *VM) initEnumeratorObject(iterate iteration) *EnumeratorObject {
+	return &EnumeratorObject{
+		baseObj: &baseObj{class: vm.topLevelClass(enumeratorClass)},
+		iterate: iterate,
+	}
+}
+
+func (vm *VM) initYielderObject(yield func(values ...Object) *Error) *YielderObject {
+	class := vm.topLevelClass(enumeratorClass).getClassConstant(yielderClass)
+	return &YielderObject{baseObj: &baseObj{class: class}, yield: yield}
+}
+
+func builtinEnumeratorClassMethods() []*BuiltInMethodObject {
+	return []*BuiltInMethodObject{
+		{
+			// Creates an enumerator whose values are produced by calling `yield` on the yielder
+			// passed to the block. The block only runs when values are requested.
+			//
+			// ```ruby
+			// e = Enumerator.new do |y|
+			//   y.yield(1)
+			//   y.yield(2)
+			// end
+			// e.to_a # => [1, 2]
+			// ```
+			//
+			// @return [Enumerator]
+			Name: "new",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					if blockFrame == nil {
+						return t.vm.initErrorObject(InternalError, CantYieldWithoutBlockFormat)
+					}
+
+					t.detachBlockFrame()
+
+					return t.vm.initEnumeratorObject(func(t *thread, yield func(values ...Object) *Error) *Error {
+						result := t.yieldBlock(blockFrame, t.vm.initYielderObject(yield))
+
+						if err, ok := result.(*Error); ok {
+							return err
+						}
+
+						return nil
+					})
+				}
+			},
+		},
+	}
+}
+
+func builtinEnumeratorInstanceMethods() []*BuiltInMethodObject {
+	return []*BuiltInMethodObject{
+		{
+			// Loop through each value with the given block and returns the enumerator.
+			//
+			// @return [Enumerator]
+			Name: "each",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					e := receiver.(*EnumeratorObject)
+
+					if blockFrame == nil {
+						return e
+					}
+
+					err := e.iterate(t, func(values ...Object) *Error {
+						return errorOf(t.yieldBlock(blockFrame, values...))
+					})
+
+					t.popBlockFrame(blockFrame)
+
+					if err != nil {
+						return err
+					}
+
+					return e
+				}
+			},
+		},
+		{
+			// Returns the first value, or an array of the first n values.
+			// It always starts from the beginning and doesn't affect `next`.
+			//
+			// ```ruby
+			// e = [1, 2, 3].each
+			// e.first    # => 1
+			// e.first(2) # => [1, 2]
+			// ```
+			//
+			// @param n [Integer] Optional
+			// @return [Object]
+			Name: "first",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					e := receiver.(*EnumeratorObject)
+
+					if len(args) == 0 {
+						values, err := e.take(t, 1)
+
+						if err != nil {
+							return err
+						}
+
+						if len(values) == 0 {
+							return NULL
+						}
+
+						return values[0]
+					}
+
+					n, ok := args[0].(*IntegerObject)
+
+					if !ok {
+						return t.vm.initErrorObject(TypeError, WrongArgumentTypeFormat, integerClass, args[0].Class().Name)
+					}
+
+					values, err := e.take(t, n.Value)
+
+					if err != nil {
+						return err
+					}
+
+					return t.vm.initArrayObject(values)
+				}
+			},
+		},
+		{
+			// Returns the enumerator itself, since enumerators are always lazy.
+			//
+			// @return [Enumerator]
+			Name: "lazy",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return receiver
+				}
+			},
+		},
+		{
+			// Returns a new enumerator whose values are the block's results.
+			// The block is evaluated only when values are requested.
+			//
+			// ```ruby
+			// e = [1, 2].each.map do |i|
+			//   i * 2
+			// end
+			// e.next # => 2
+			// ```
+			//
+			// @return [Enumerator]
+			Name: "map",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					e := receiver.(*EnumeratorObject)
+
+					if blockFrame == nil {
+						return e
+					}
+
+					t.detachBlockFrame()
+
+					return t.vm.initEnumeratorObject(func(t *thread, yield func(values ...Object) *Error) *Error {
+						return e.iterate(t, func(values ...Object) *Error {
+							result := t.yieldBlock(blockFrame, values...)
+
+							if err := errorOf(result); err != nil {
+								return err
+							}
+
+							return yield(result)
+						})
+					})
+				}
+			},
+		},
+		{
+			// Returns the next value and moves forward.
+			// Returns a StopIteration error if there're no more values.
+			//
+			// @return [Object]
+			Name: "next",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					e := receiver.(*EnumeratorObject)
+					e.Lock()
+					defer e.Unlock()
+
+					return e.next(t)
+				}
+			},
+		},
+		{
+			// Returns the next value without moving forward.
+			// Returns a StopIteration error if there're no more values.
+			//
+			// @return [Object]
+			Name: "peek",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					e := receiver.(*EnumeratorObject)
+					e.Lock()
+					defer e.Unlock()
+
+					v := e.next(t)
+
+					if _, ok := v.(*Error); !ok {
+						e.peeked = v
+					}
+
+					return v
+				}
+			},
+		},
+		{
+			// Moves back to the beginning, so `next` starts over.
+			//
+			// @return [Enumerator]
+			Name: "rewind",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					e := receiver.(*EnumeratorObject)
+					e.Lock()
+					defer e.Unlock()
+
+					if e.fiber != nil {
+						e.fiber.kill()
+						e.fiber = nil
+					}
+
+					e.peeked = nil
+
+					return e
+				}
+			},
+		},
+		{
+			// Returns a new enumerator with values that make the block return a truthy value.
+			// The block is evaluated only when values are requested.
+			//
+			// @return [Enumerator]
+			Name: "select",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					e := receiver.(*EnumeratorObject)
+
+					if blockFrame == nil {
+						return e
+					}
+
+					t.detachBlockFrame()
+
+					return t.vm.initEnumeratorObject(func(t *thread, yield func(values ...Object) *Error) *Error {
+						return e.iterate(t, func(values ...Object) *Error {
+							result := t.yieldBlock(blockFrame, values...)
+
+							if err := errorOf(result); err != nil {
+								return err
+							}
+
+							if result == FALSE || result == NULL {
+								return nil
+							}
+
+							return yield(values...)
+						})
+					})
+				}
+			},
+		},
+		{
+			// Returns an array of all values. It never returns for infinite enumerators.
+			//
+			// @return [Array]
+			Name: "to_a",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					e := receiver.(*EnumeratorObject)
+					values := []Object{}
+
+					err := e.iterate(t, func(vs ...Object) *Error {
+						values = append(values, packValues(t.vm, vs))
+						return nil
+					})
+
+					if err != nil {
+						return err
+					}
+
+					return t.vm.initArrayObject(values)
+				}
+			},
+		},
+	}
+}
+
+func builtinYielderInstanceMethods() []*BuiltInMethodObject {
+	return []*BuiltInMethodObject{
+		{
+			// Produces given values to the enumerator.
+			//
+			// @param *values [Object]
+			// @return [Null]
+			Name: "yield",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					if err := receiver.(*YielderObject).yield(args...); err != nil {
+						return err
+					}
+
+					return NULL
+				}
+			},
+		},
+	}
+}
+
+// next resumes the enumerator's fiber for next value, it should be called with the enumerator locked.
+func (e *EnumeratorObject) next(t *thread) Object {
+	if e.peeked != nil {
+		v := e.peeked
+		e.peeked = nil
+		return v
+	}
+
+	if e.fiber == nil {
+		e.fiber = e.newFiber(t)
+	}
+
+	v, done := e.fiber.resume(t, nil)
+
+	if !done {
+		return v
+	}
+
+	if err, ok := v.(*Error); ok {
+		return err
+	}
+
+	return t.vm.initErrorObject(StopIteration, "iteration reached an end")
+}
+
+// take returns at most n values from the beginning.
+func (e *EnumeratorObject) take(t *thread, n int) ([]Object, *Error) {
+	values := []Object{}
+	f := e.newFiber(t)
+	defer f.kill()
+
+	for len(values) < n {
+		v, done := f.resume(t, nil)
+
+		if err := errorOf(v); err != nil {
+			return nil, err
+		}
+
+		if done {
+			break
+		}
+
+		values = append(values, v)
+	}
+
+	return values, nil
+}
+
+// newFiber returns a fiber that yields the enumerator's values one by one.
+// The fiber doesn't refer to the enumerator, so a dropped enumerator and its fiber can be collected.
+func (e *EnumeratorObject) newFiber(t *thread) *FiberObject {
+	iterate := e.iterate
+
+	return t.vm.initFiberObject(t.currentContext(), func(ft *thread, args []Object) Object {
+		err := iterate(ft, func(values ...Object) *Error {
+			return errorOf(ft.fiberYield(values))
+		})
+
+		if err != nil {
+			return err
+		}
+
+		return NULL
+	})
+}
+
+// errorOf returns the object as an error if it is one. Rescued errors are values like other objects.
+func errorOf(obj Object) *Error {
+	if err, ok := obj.(*Error); ok && !err.rescued {
+		return err
+	}
+
+	return nil
+}
+
+// popBlockFrame pops the block frame if it hasn't been popped by yielding the block.
+func (t *thread) popBlockFrame(blockFrame *callFrame) {
+	if t.callFrameStack.top() == blockFrame {
+		t.callFrameStack.pop()
+	}
+}
+
+// Enumerator sources ---------------------------------------------------
+
+func (vm *VM) arrayEnumerator(arr *ArrayObject) *EnumeratorObject {
+	return vm.initEnumeratorObject(func(t *thread, yield func(values ...Object) *Error) *Error {
+		for _, elem := range arr.Elements {
+			if err := yield(elem); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (vm *VM) hashEnumerator(h *HashObject) *EnumeratorObject {
+	return vm.initEnumeratorObject(func(t *thread, yield func(values ...Object) *Error) *Error {
+		for _, k := range h.sortedKeys() {
+			if err := yield(t.vm.initStringObject(k), h.Pairs[k]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (vm *VM) rangeEnumerator(ran *RangeObject) *EnumeratorObject {
+	return vm.initEnumeratorObject(func(t *thread, yield func(values ...Object) *Error) *Error {
+		start, end := ran.Start, ran.End
+
+		// Same as Range#each, values are always in ascending order
+		if start > end {
+			start, end = end, start
+		}
+
+		for i := start; i <= end; i++ {
+			if err := yield(t.vm.initIntegerObject(i)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Polymorphic helper functions -----------------------------------------
+
+// toString returns the enumerator's address.
+func (e *EnumeratorObject) toString() string {
+	return fmt.Sprintf("<Enumerator: %p>", e)
+}
+
+// toJSON converts the receiver into JSON string.
+func (e *EnumeratorObject) toJSON() string {
+	return e.toString()
+}
+
+// toString returns the yielder's address.
+func (y *YielderObject) toString() string {
+	return fmt.Sprintf("<Yielder: %p>", y)
+}
+
+// toJSON converts the receiver into JSON string.
+func (y *YielderObject) toJSON() string {
+	return y.toString()
+}
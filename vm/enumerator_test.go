@@ -0,0 +1,116 @@
+package vm
+
+import "testing"
+
+func TestEnumeratorMethods(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`
+		e = [1, 2, 3].each
+		e.next + e.next * 10
+		`, 21},
+		{`
+		e = [1, 2, 3].each
+		a = e.peek
+		b = e.next
+		c = e.next
+		a + b * 10 + c * 100
+		`, 211},
+		{`
+		e = [1, 2, 3].each
+		e.next
+		e.next
+		e.rewind
+		e.next
+		`, 1},
+		{`
+		e = [1, 2, 3].map
+		e.to_a.to_s
+		`, "[1, 2, 3]"},
+		{`
+		e = (1..3).each.map do |i|
+		  i * 2
+		end
+		e.to_a.to_s
+		`, "[2, 4, 6]"},
+		{`
+		e = (3..1).each
+		e.first(2).to_s
+		`, "[1, 2]"},
+		{`
+		e = { b: 2, a: 1 }.each
+		e.next.to_s
+		`, `["a", 1]`},
+		{`
+		naturals = Enumerator.new do |y|
+		  i = 0
+		  while true do
+		    i += 1
+		    y.yield(i)
+		  end
+		end
+
+		naturals.lazy.select do |i|
+		  i % 2 == 0
+		end.map do |i|
+		  i * i
+		end.first(3).to_s
+		`, "[4, 16, 36]"},
+		{`
+		e = Enumerator.new do |y|
+		  y.yield(1)
+		  y.yield(2)
+		end
+
+		sum = 0
+		e.each do |i|
+		  sum = sum + i
+		end
+		sum
+		`, 3},
+		{`
+		e = Enumerator.new do |y|
+		  y.yield(1)
+		end
+		e.first
+		`, 1},
+		{`
+		e = [].each
+		e.first
+		`, nil},
+	}
+
+	for i, tt := range tests {
+		vm := initTestVM()
+		evaluated := vm.testEval(t, tt.input)
+		checkExpected(t, i, evaluated, tt.expected)
+		vm.checkCFP(t, i, 0)
+	}
+}
+
+func TestEnumeratorMethodsFail(t *testing.T) {
+	testsFail := []struct {
+		input    string
+		errType  string
+		expected string
+	}{
+		{`
+		e = [1].each
+		e.next
+		e.next
+		`, StopIteration, "StopIteration: iteration reached an end"},
+		{`
+		e = [].each
+		e.peek
+		`, StopIteration, "StopIteration: iteration reached an end"},
+		{`[1].each.first("a")`, TypeError, "TypeError: Expect argument to be Integer. got: String"},
+	}
+
+	for i, tt := range testsFail {
+		v := initTestVM()
+		evaluated := v.testEval(t, tt.input)
+		checkError(t, i, evaluated, tt.errType, tt.expected)
+	}
+}
@@ -1,17 +1,25 @@
 package vm
 
+import "sync"
+
 func newEnvironment() *environment {
 	s := make(map[string]Object)
 	return &environment{store: s, outer: nil}
 }
 
+// environment holds instance variables and methods, which threads like the test library's workers can share.
+// This is why it's locked like stack.
 type environment struct {
 	store map[string]Object
 	outer *environment
+	sync.RWMutex
 }
 
 func (e *environment) get(name string) (Object, bool) {
+	e.RLock()
 	obj, ok := e.store[name]
+	e.RUnlock()
+
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.get(name)
 	}
@@ -19,6 +27,23 @@ func (e *environment) get(name string) (Object, bool) {
 }
 
 func (e *environment) set(name string, val Object) Object {
+	e.Lock()
+	defer e.Unlock()
+
 	e.store[name] = val
 	return val
 }
+
+// names returns the names in the environment, without those of its outer environments
+func (e *environment) names() []string {
+	e.RLock()
+	defer e.RUnlock()
+
+	names := make([]string, 0, len(e.store))
+
+	for name := range e.store {
+		names = append(names, name)
+	}
+
+	return names
+}
@@ -1,6 +1,7 @@
 package vm
 
 import (
+	"bytes"
 	"fmt"
 )
 
@@ -17,6 +18,26 @@ const (
 	UndefinedMethodError = "UndefinedMethodError"
 	// UnsupportedMethodError is for an intentionally unsupported-method error
 	UnsupportedMethodError = "UnsupportedMethodError"
+	// CancelledError is for a blocking operation interrupted by a cancelled or timed out context
+	CancelledError = "CancelledError"
+	// StopIteration is for calling `next` on an enumerator that reached its end
+	StopIteration = "StopIteration"
+	// StackOverflowError is for calls nested deeper than the thread's max call depth
+	StackOverflowError = "StackOverflowError"
+	// InstructionLimitError is for evaluating more instructions than the sandbox allows
+	InstructionLimitError = "InstructionLimitError"
+	// TimeLimitError is for running longer than the sandbox allows
+	TimeLimitError = "TimeLimitError"
+	// ObjectLimitError is for creating more objects than the sandbox allows
+	ObjectLimitError = "ObjectLimitError"
+	// ForbiddenLibraryError is for requiring a library the sandbox doesn't allow
+	ForbiddenLibraryError = "ForbiddenLibraryError"
+	// ForbiddenMethodError is for calling a built-in method the sandbox doesn't allow
+	ForbiddenMethodError = "ForbiddenMethodError"
+	// BytecodeError is for instruction sets rejected by bytecode.Verify
+	BytecodeError = "BytecodeError"
+	// ExpectationError is for failed expectations of the test library
+	ExpectationError = "ExpectationError"
 )
 
 /*
@@ -40,10 +61,24 @@ const (
 // * `TypeError`: a type-related error
 // * `UndefinedMethodError`: undefined-method error
 // * `UnsupportedMethodError`: intentionally unsupported-method error
+// * `CancelledError`: a blocking operation interrupted by a cancelled or timed out context
+// * `StopIteration`: calling `next` on an enumerator that reached its end
+// * `StackOverflowError`: calls nested deeper than the max call depth, scripts can go on after it with `rescue`
+// * `InstructionLimitError`, `TimeLimitError`, `ObjectLimitError`, `ForbiddenLibraryError` and `ForbiddenMethodError`:
+//   violations of the vm's sandbox, see Sandbox
+// * `BytecodeError`: malformed instruction sets, which are rejected before they're evaluated
+// * `ExpectationError`: failed expectations of the `test` library, like `expect(1).to eq(2)`
 //
 type Error struct {
 	*baseObj
 	Message string
+	// Backtrace lists frames that were being evaluated when the error occurred, starting from the innermost one.
+	// Only StackOverflowError has it, and long ones are truncated.
+	Backtrace []string
+	// reported is set once the error is printed, so frames it passes through don't print it again
+	reported bool
+	// rescued is set once `rescue` returns the error, it's a value scripts handle then and doesn't stop threads
+	rescued bool
 }
 
 func (vm *VM) initErrorObject(errorType, format string, args ...interface{}) *Error {
@@ -56,14 +91,39 @@ func (vm *VM) initErrorObject(errorType, format string, args ...interface{}) *Er
 }
 
 func (vm *VM) initErrorClasses() {
-	errTypes := []string{InternalError, ArgumentError, NameError, TypeError, UndefinedMethodError, UnsupportedMethodError}
+	errTypes := []string{InternalError, ArgumentError, NameError, TypeError, UndefinedMethodError, UnsupportedMethodError, CancelledError, StopIteration, StackOverflowError,
+		InstructionLimitError, TimeLimitError, ObjectLimitError, ForbiddenLibraryError, ForbiddenMethodError, BytecodeError, ExpectationError}
 
 	for _, errType := range errTypes {
 		c := vm.initializeClass(errType, false)
+		c.setBuiltInMethods(builtinErrorInstanceMethods(), false)
 		vm.topLevelClass(objectClass).setClassConstant(c)
 	}
 }
 
+func builtinErrorInstanceMethods() []*BuiltInMethodObject {
+	return []*BuiltInMethodObject{
+		{
+			// Returns the error's message, which starts with its class name.
+			//
+			// ```ruby
+			// err = rescue do
+			//   1.foo
+			// end
+			// err.message # => "UndefinedMethodError: Undefined Method 'foo' for 1"
+			// ```
+			//
+			// @return [String]
+			Name: "message",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return t.vm.initStringObject(receiver.(*Error).Message)
+				}
+			},
+		},
+	}
+}
+
 // Polymorphic helper functions -----------------------------------------
 
 // toString converts error messages into string.
@@ -75,3 +135,17 @@ func (e *Error) toString() string {
 func (e *Error) toJSON() string {
 	return e.toString()
 }
+
+// report returns the error message followed by its backtrace.
+func (e *Error) report() string {
+	var out bytes.Buffer
+
+	out.WriteString(e.Message)
+
+	for _, frame := range e.Backtrace {
+		out.WriteString("\n    from ")
+		out.WriteString(frame)
+	}
+
+	return out.String()
+}
@@ -1,6 +1,9 @@
 package vm
 
-import "testing"
+import (
+	"github.com/goby-lang/goby/compiler"
+	"testing"
+)
 
 func TestUndefinedMethodError(t *testing.T) {
 	tests := []struct {
@@ -128,3 +131,201 @@ func checkError(t *testing.T, index int, evaluated Object, expectedErrType, expe
 		t.Fatalf("At test case %d: Expect error message to be:\n  %s. got: \n%s", index, expectedErrMsg, err.Message)
 	}
 }
+
+func TestStackOverflowError(t *testing.T) {
+	tests := []struct {
+		input     string
+		maxDepth  int
+		errMsg    string
+		backtrace []string
+	}{
+		{`
+		def foo(n)
+		  foo(n + 1)
+		end
+
+		foo(0)
+		`, 5,
+			"StackOverflowError: Stack level too deep. Max call depth is 5",
+			[]string{"foo", "foo", "foo", "foo", "<main>"}},
+		{`
+		class Foo
+		  def bar
+		    [1].each do |i|
+		      bar
+		    end
+		  end
+		end
+
+		Foo.new.bar
+		`, 6,
+			"StackOverflowError: Stack level too deep. Max call depth is 6",
+			[]string{"bar", "block in bar", "bar", "<main>"}},
+		{`
+		def foo(n)
+		  foo(n + 1)
+		end
+
+		foo(0)
+		`, 100,
+			"StackOverflowError: Stack level too deep. Max call depth is 100",
+			[]string{
+				"foo", "foo", "foo", "foo", "foo", "foo", "foo", "foo", "foo", "foo",
+				"... 80 levels ...",
+				"foo", "foo", "foo", "foo", "foo", "foo", "foo", "foo", "foo", "<main>",
+			}},
+	}
+
+	for i, tt := range tests {
+		v := New("./", []string{}, WithMaxCallDepth(tt.maxDepth), WithTailCallOptimization(false))
+		evaluated := v.testEval(t, tt.input)
+		checkError(t, i, evaluated, StackOverflowError, tt.errMsg)
+
+		backtrace := evaluated.(*Error).Backtrace
+
+		if len(backtrace) != len(tt.backtrace) {
+			t.Fatalf("At test case %d: Expect backtrace to be %v. got: %v", i, tt.backtrace, backtrace)
+		}
+
+		for n := range backtrace {
+			if backtrace[n] != tt.backtrace[n] {
+				t.Fatalf("At test case %d: Expect backtrace to be %v. got: %v", i, tt.backtrace, backtrace)
+			}
+		}
+	}
+}
+
+func TestRescue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`
+		def foo(n)
+		  foo(n + 1) + 1
+		end
+
+		err = rescue(StackOverflowError) do
+		  foo(0)
+		end
+		err.message
+		`, "StackOverflowError: Stack level too deep. Max call depth is 100"},
+		{`
+		def foo(n)
+		  foo(n + 1) + 1
+		end
+
+		10.times do
+		  rescue(StackOverflowError) do
+		    foo(0)
+		  end
+		end
+
+		rescue do
+		  foo(0)
+		end.message
+		`, "StackOverflowError: Stack level too deep. Max call depth is 100"},
+		{`
+		err = rescue do
+		  1.foo
+		end
+		err.is_a(UndefinedMethodError)
+		`, true},
+		// Errors that are instances of subclasses of the given classes are rescued
+		{`
+		err = rescue(TypeError, Object) do
+		  1.foo
+		end
+		[err].first.message
+		`, "UndefinedMethodError: Undefined Method 'foo' for 1"},
+		// Results that look like errors' messages are still results
+		{`
+		result = rescue(UndefinedMethodError) do
+		  "UndefinedMethodError: Undefined Method 'foo' for 1"
+		end
+		result.is_a(String)
+		`, true},
+		{`
+		x = 1
+		rescue(TypeError, NameError) do
+		  x + 10
+		end
+		`, 11},
+	}
+
+	for i, tt := range tests {
+		v := New("./", []string{}, WithMaxCallDepth(100))
+		evaluated := v.testEval(t, tt.input)
+		checkExpected(t, i, evaluated, tt.expected)
+		v.checkCFP(t, i, 0)
+	}
+}
+
+func TestRescueFail(t *testing.T) {
+	testsFail := []struct {
+		input    string
+		errType  string
+		expected string
+	}{
+		{`
+		rescue(TypeError) do
+		  1.foo
+		end
+		`, UndefinedMethodError, "UndefinedMethodError: Undefined Method 'foo' for 1"},
+		{`rescue(1) do end`, TypeError, "TypeError: Expect argument to be Class. got: Integer"},
+	}
+
+	for i, tt := range testsFail {
+		v := initTestVM()
+		evaluated := v.testEval(t, tt.input)
+		checkError(t, i, evaluated, tt.errType, tt.expected)
+	}
+}
+
+func TestDeepRecursionWithinMaxCallDepth(t *testing.T) {
+	input := `
+	def count(n)
+	  if n == 0
+	    0
+	  else
+	    count(n - 1) + 1
+	  end
+	end
+
+	count(3000)
+	`
+
+	v := initTestVM()
+	evaluated := v.testEval(t, input)
+	checkExpected(t, 0, evaluated, 3000)
+	v.checkCFP(t, 0, 0)
+
+	v = New("./", []string{}, WithMaxCallDepth(0))
+	evaluated = v.testEval(t, input)
+	checkExpected(t, 0, evaluated, 3000)
+	v.checkCFP(t, 0, 0)
+}
+
+func TestBytecodeError(t *testing.T) {
+	iss, err := compiler.CompileToInstructions(`
+	Foo = 1
+	foo(1)
+	`)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// The send pops more values than the set pushed
+	iss[0].Instructions[len(iss[0].Instructions)-2].Params[1] = "3"
+
+	v := initTestVM()
+	v.ExecInstructions(iss, "./")
+	checkError(t, 0, v.GetExecResult(), BytecodeError, "BytecodeError: Invalid bytecode in <ProgramStart> at 4 send: pops 4 values, but the stack only has 2")
+	v.checkCFP(t, 0, 0)
+
+	// The program is rejected before it runs
+	if c := v.objectClass.constants["Foo"]; c != nil {
+		t.Fatalf("Expect Foo not to be defined. got: %s", c.Target.toString())
+	}
+}
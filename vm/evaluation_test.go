@@ -1,6 +1,9 @@
 package vm
 
 import (
+	"fmt"
+	"runtime/debug"
+	"strings"
 	"testing"
 )
 
@@ -1247,6 +1250,294 @@ func TestAssignmentByOperationEvaluation(t *testing.T) {
 	}
 }
 
+func TestLocalVariableSlots(t *testing.T) {
+	var locals []string
+
+	for n := 0; n < 150; n++ {
+		locals = append(locals, fmt.Sprintf("v%d = %d", n, n))
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// Frames are as large as their locals need, not a fixed size
+		{fmt.Sprintf(`
+		def foo
+		  %s
+		  v0 + v149
+		end
+
+		foo
+		`, strings.Join(locals, "\n")), 149},
+		// Blocks can be yielded more arguments than their parameters
+		{`
+		def foo
+		  yield(1, 2, 3)
+		end
+
+		foo do |a|
+		  a
+		end
+		`, 1},
+		// Outer locals assigned in blocks belong to the outer frame, even if they're not set yet
+		{`
+		if false
+		  x = 0
+		end
+
+		[1, 2].each do |i|
+		  x = i
+		end
+
+		x
+		`, 2},
+		// Frames are reused after calls, so locals from previous calls shouldn't be seen
+		{`
+		def foo(set)
+		  if set
+		    a = 10
+		  end
+		  a
+		end
+
+		foo(true)
+		foo(false)
+		`, nil},
+	}
+
+	for i, tt := range tests {
+		vm := initTestVM()
+		evaluated := vm.testEval(t, tt.input)
+		checkExpected(t, i, evaluated, tt.expected)
+		vm.checkCFP(t, i, 0)
+	}
+}
+
+func TestTailCallOptimization(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`
+		def sum(n, acc)
+		  if n == 0
+		    acc
+		  else
+		    n.to_s
+		    sum(n - 1, acc + n)
+		  end
+		end
+
+		sum(100000, 0)
+		`, 5000050000},
+		{`
+		def is_even(n)
+		  if n == 0
+		    true
+		  else
+		    is_odd(n - 1)
+		  end
+		end
+
+		def is_odd(n)
+		  if n == 0
+		    false
+		  else
+		    is_even(n - 1)
+		  end
+		end
+
+		is_even(100001)
+		`, false},
+		{`
+		class Counter
+		  def initialize(limit)
+		    @limit = limit
+		  end
+
+		  def count(n, step = 1)
+		    if n >= @limit
+		      return n
+		    end
+
+		    count(n + step)
+		  end
+		end
+
+		Counter.new(50000).count(0)
+		`, 50000},
+		// Frames captured by blocks aren't reused
+		{`
+		def foo(n, acc)
+		  if n == 0
+		    return acc
+		  end
+
+		  x = n
+		  [1].each do |i|
+		    acc = acc + x * i
+		  end
+		  foo(n - 1, acc)
+		end
+
+		foo(10, 0)
+		`, 55},
+		// Calls that aren't in tail positions are normal calls
+		{`
+		def fact(n)
+		  if n <= 1
+		    1
+		  else
+		    n * fact(n - 1)
+		  end
+		end
+
+		fact(10)
+		`, 3628800},
+	}
+
+	for i, tt := range tests {
+		v := New("./", []string{}, WithMaxCallDepth(100))
+		evaluated := v.testEval(t, tt.input)
+		checkExpected(t, i, evaluated, tt.expected)
+		v.checkCFP(t, i, 0)
+
+		// Tail calls drop values their callers left on the stack
+		if len(v.mainThread.stack.Data) > 100 {
+			t.Fatalf("At case %d: Expect stack to stay small. got: %d", i, len(v.mainThread.stack.Data))
+		}
+	}
+}
+
+func TestTailCallOptimizationOptOut(t *testing.T) {
+	input := `
+	def foo(n)
+	  if n == 0
+	    0
+	  else
+	    foo(n - 1)
+	  end
+	end
+
+	foo(1000)
+	`
+
+	v := New("./", []string{}, WithMaxCallDepth(100), WithTailCallOptimization(false))
+	evaluated := v.testEval(t, input)
+	checkError(t, 0, evaluated, StackOverflowError, "StackOverflowError: Stack level too deep. Max call depth is 100")
+
+	backtrace := evaluated.(*Error).Backtrace
+
+	if backtrace[0] != "foo" || backtrace[len(backtrace)-1] != "<main>" {
+		t.Fatalf("Expect backtrace to have every call. got: %v", backtrace)
+	}
+}
+
+// Tail calls with wrong numbers of arguments raise errors like normal calls
+func TestTailCallArgumentError(t *testing.T) {
+	input := `
+	def foo(n)
+	  bar(n, 1)
+	end
+
+	def bar(n)
+	  n
+	end
+
+	foo(1)
+	`
+
+	v := initTestVM()
+	evaluated := v.testEval(t, input)
+	checkError(t, 0, evaluated, ArgumentError, "ArgumentError: Expect at most 1 args for method 'bar'. got: 2")
+}
+
+// Goby calls are evaluated by the same loop, so deep recursion doesn't need a deep Go stack
+func TestDeepRecursionWithSmallGoStack(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`
+		def depth(n)
+		  if n == 0
+		    0
+		  else
+		    depth(n - 1) + 1
+		  end
+		end
+
+		depth(100000)
+		`, 100000},
+		{`
+		def down(n)
+		  if n == 0
+		    yield(0)
+		  else
+		    down(n - 1) do |x|
+		      yield(x + 1)
+		    end
+		  end
+		end
+
+		down(50000) do |x|
+		  x
+		end
+		`, 50000},
+		{`
+		class Node
+		  attr_reader :size
+
+		  def initialize(n)
+		    @size = 1
+
+		    if n > 0
+		      @size = Node.new(n - 1).size + 1
+		    end
+		  end
+		end
+
+		Node.new(50000).size
+		`, 50001},
+	}
+
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+
+	for i, tt := range tests {
+		v := New("./", []string{}, WithMaxCallDepth(0), WithTailCallOptimization(false))
+		evaluated := v.testEval(t, tt.input)
+		checkExpected(t, i, evaluated, tt.expected)
+		v.checkCFP(t, i, 0)
+	}
+}
+
+// Frames of blocks given to built-in methods that don't yield are popped after the calls
+func TestUnusedBlockFrames(t *testing.T) {
+	input := `
+	def foo
+	  [].each do |x|
+	  end
+
+	  1
+	end
+
+	i = 0
+
+	while i < 1000 do
+	  foo
+	  i += 1
+	end
+
+	i
+	`
+
+	v := New("./", []string{}, WithMaxCallDepth(100))
+	evaluated := v.testEval(t, input)
+	checkExpected(t, 0, evaluated, 1000)
+	v.checkCFP(t, 0, 0)
+}
+
 func TestIfExpressionEvaluation(t *testing.T) {
 	tests := []struct {
 		input      string
@@ -1453,7 +1744,7 @@ func TestMultiVarAssignment(t *testing.T) {
 		f.bar([10, 100, 200])
 		f.a
 		`, 10,
-			3},
+			2},
 		{`
 		class Foo
 		  attr_reader :a, :b, :c
@@ -1469,7 +1760,7 @@ func TestMultiVarAssignment(t *testing.T) {
 		f.bar([10, 100, 200])
 		f.b
 		`, 100,
-			3},
+			2},
 		{`
 		class Foo
 		  attr_reader :a, :b, :c
@@ -1485,7 +1776,7 @@ func TestMultiVarAssignment(t *testing.T) {
 		f.bar([10, 100, 200])
 		f.c
 		`, 310,
-			3},
+			2},
 	}
 
 	for i, tt := range tests {
@@ -0,0 +1,290 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+const fiberClass = "Fiber"
+
+// FiberObject represents a block that can be suspended with `Fiber.yield` and continued with `resume`.
+// Each fiber runs on its own goby thread, but only one of the fiber and its caller runs at a time.
+//
+// ```ruby
+// f = Fiber.new do |x|
+//   y = Fiber.yield(x + 1)
+//   y * 10
+// end
+//
+// f.resume(1) # => 2
+// f.resume(5) # => 50
+// f.is_alive  # => false
+// ```
+type FiberObject struct {
+	*baseObj
+	*fiber
+}
+
+// fiber is the state a FiberObject shares with its goroutine. The goroutine only refers to this,
+// so a dropped FiberObject can be collected and its finalizer stops the goroutine.
+type fiber struct {
+	body       func(t *thread, args []Object) Object
+	in         chan Object
+	out        chan fiberResult
+	ctx        context.Context
+	cancel     context.CancelFunc
+	isStarted  bool
+	isRunning  bool
+	isFinished bool
+	sync.Mutex
+}
+
+// fiberResult is what a fiber passes back to its caller, done is true if the fiber has finished.
+type fiberResult struct {
+	value Object
+	done  bool
+}
+
+func (vm *VM) initFiberClass() *RClass {
+	class := vm.initializeClass(fiberClass, false)
+	class.setBuiltInMethods(builtinFiberClassMethods(), true)
+	class.setBuiltInMethods(builtinFiberInstanceMethods(), false)
+	return class
+}
+
+// initFiberObject creates a fiber whose goroutine stops once parent is done or the fiber is garbage collected,
+// so fibers that are dropped before they finish don't block forever. A fiber its own block can still see,
+// like one assigned to a local variable of the block's scope, is only stopped by parent.
+func (vm *VM) initFiberObject(parent context.Context, body func(t *thread, args []Object) Object) *FiberObject {
+	ctx, cancel := context.WithCancel(parent)
+
+	f := &FiberObject{
+		baseObj: &baseObj{class: vm.topLevelClass(fiberClass)},
+		fiber: &fiber{
+			body:   body,
+			in:     make(chan Object),
+			out:    make(chan fiberResult),
+			ctx:    ctx,
+			cancel: cancel,
+		},
+	}
+
+	runtime.SetFinalizer(f, func(f *FiberObject) {
+		f.cancel()
+	})
+
+	return f
+}
+
+func builtinFiberClassMethods() []*BuiltInMethodObject {
+	return []*BuiltInMethodObject{
+		{
+			// Creates a fiber with given block. The block starts running on the first `resume`.
+			//
+			// @return [Fiber]
+			Name: "new",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					if blockFrame == nil {
+						return t.vm.initErrorObject(InternalError, CantYieldWithoutBlockFormat)
+					}
+
+					t.detachBlockFrame()
+
+					return t.vm.initFiberObject(t.currentContext(), func(ft *thread, args []Object) Object {
+						return ft.yieldBlock(blockFrame, args...)
+					})
+				}
+			},
+		},
+		{
+			// Suspends current fiber and passes the value to the `resume` call that resumed it.
+			// Returns the value passed to the next `resume`.
+			//
+			// ```ruby
+			// f = Fiber.new do
+			//   Fiber.yield(1)
+			// end
+			// ```
+			//
+			// @param value [Object]
+			// @return [Object]
+			Name: "yield",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return t.fiberYield(args)
+				}
+			},
+		},
+	}
+}
+
+func builtinFiberInstanceMethods() []*BuiltInMethodObject {
+	return []*BuiltInMethodObject{
+		{
+			// Returns true if the fiber can still be resumed.
+			//
+			// @return [Boolean]
+			Name: "is_alive",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					f := receiver.(*FiberObject)
+					f.Lock()
+					defer f.Unlock()
+
+					if f.isFinished {
+						return FALSE
+					}
+
+					return TRUE
+				}
+			},
+		},
+		{
+			// Starts or continues the fiber until it yields or finishes, returns the yielded value
+			// or the block's result.
+			// Arguments of the first call are passed to the block,
+			// and arguments of later calls are returned by `Fiber.yield`.
+			//
+			// @param *args [Object]
+			// @return [Object]
+			Name: "resume",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					v, _ := receiver.(*FiberObject).resume(t, args)
+					return v
+				}
+			},
+		},
+	}
+}
+
+// resume runs the fiber until it yields or finishes. It returns the passed value and true if the fiber has finished.
+func (f *fiber) resume(t *thread, args []Object) (Object, bool) {
+	f.Lock()
+
+	if f.isFinished {
+		f.Unlock()
+		return t.vm.initErrorObject(InternalError, "Can't resume a dead fiber"), true
+	}
+
+	if f.isRunning {
+		f.Unlock()
+		return t.vm.initErrorObject(InternalError, "Can't resume a running fiber"), false
+	}
+
+	f.isRunning = true
+	resumed := f.isStarted
+
+	if !f.isStarted {
+		f.isStarted = true
+		ft := t.vm.newThread()
+		ft.ctx = f.ctx
+		ft.fiber = f
+
+		go func() {
+			v := f.body(ft, args)
+
+			select {
+			case f.out <- fiberResult{value: v, done: true}:
+			case <-f.ctx.Done():
+			}
+		}()
+	}
+
+	f.Unlock()
+	r, ok := f.pass(t, resumed, args)
+	f.Lock()
+	defer f.Unlock()
+
+	f.isRunning = false
+
+	// The fiber's context is done, so it won't yield anymore
+	if !ok {
+		f.isFinished = true
+		return t.vm.initCancelledError(f.ctx), true
+	}
+
+	if r.done {
+		f.isFinished = true
+		f.cancel()
+	}
+
+	return r.value, r.done
+}
+
+// pass gives the arguments to a fiber that's resumed, and waits for its next value.
+// It's false if the fiber's context is done before that.
+func (f *fiber) pass(t *thread, resumed bool, args []Object) (fiberResult, bool) {
+	if resumed {
+		select {
+		case f.in <- packValues(t.vm, args):
+		case <-f.ctx.Done():
+			return fiberResult{}, false
+		}
+	}
+
+	select {
+	case r := <-f.out:
+		return r, true
+	case <-f.ctx.Done():
+		return fiberResult{}, false
+	}
+}
+
+// kill stops the fiber, the goroutine running it returns as soon as possible.
+func (f *fiber) kill() {
+	f.Lock()
+	defer f.Unlock()
+
+	f.isFinished = true
+	f.cancel()
+}
+
+// fiberYield passes values to the caller of current fiber and waits for next resume.
+func (t *thread) fiberYield(args []Object) Object {
+	f := t.fiber
+
+	if f == nil {
+		return t.vm.initErrorObject(InternalError, "Can't yield from outside of a fiber")
+	}
+
+	select {
+	case f.out <- fiberResult{value: packValues(t.vm, args)}:
+	case <-f.ctx.Done():
+		return t.vm.initCancelledError(f.ctx)
+	}
+
+	select {
+	case v := <-f.in:
+		return v
+	case <-f.ctx.Done():
+		return t.vm.initCancelledError(f.ctx)
+	}
+}
+
+// packValues returns `nil` for no value, the value itself for one value and an array for multiple values.
+func packValues(vm *VM, values []Object) Object {
+	switch len(values) {
+	case 0:
+		return NULL
+	case 1:
+		return values[0]
+	default:
+		return vm.initArrayObject(values)
+	}
+}
+
+// Polymorphic helper functions -----------------------------------------
+
+// toString returns the fiber's address.
+func (f *FiberObject) toString() string {
+	return fmt.Sprintf("<Fiber: %p>", f)
+}
+
+// toJSON converts the receiver into JSON string.
+func (f *FiberObject) toJSON() string {
+	return f.toString()
+}
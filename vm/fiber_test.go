@@ -0,0 +1,157 @@
+package vm
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestFiberMethods(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`
+		f = Fiber.new do |x|
+		  y = Fiber.yield(x + 1)
+		  y * 10
+		end
+
+		a = f.resume(1)
+		b = f.resume(5)
+		a + b
+		`, 52},
+		{`
+		f = Fiber.new do
+		  Fiber.yield(1)
+		  Fiber.yield(2)
+		  3
+		end
+
+		f.resume + f.resume + f.resume
+		`, 6},
+		{`
+		f = Fiber.new do
+		  Fiber.yield(1, 2)
+		end
+
+		f.resume.to_s
+		`, "[1, 2]"},
+		{`
+		f = Fiber.new do
+		  Fiber.yield
+		end
+
+		f.resume
+		`, nil},
+		{`
+		f = Fiber.new do
+		  Fiber.yield(1)
+		end
+
+		f.resume
+		f.is_alive
+		`, true},
+		{`
+		f = Fiber.new do
+		  1
+		end
+
+		f.resume
+		f.is_alive
+		`, false},
+	}
+
+	for i, tt := range tests {
+		vm := initTestVM()
+		evaluated := vm.testEval(t, tt.input)
+		checkExpected(t, i, evaluated, tt.expected)
+		vm.checkCFP(t, i, 0)
+	}
+}
+
+func TestFiberMethodsFail(t *testing.T) {
+	testsFail := []struct {
+		input    string
+		errType  string
+		expected string
+	}{
+		{`
+		f = Fiber.new do
+		  1
+		end
+
+		f.resume
+		f.resume
+		`, InternalError, "InternalError: Can't resume a dead fiber"},
+		{`Fiber.yield(1)`, InternalError, "InternalError: Can't yield from outside of a fiber"},
+	}
+
+	for i, tt := range testsFail {
+		v := initTestVM()
+		evaluated := v.testEval(t, tt.input)
+		checkError(t, i, evaluated, tt.errType, tt.expected)
+	}
+}
+
+func TestFiberGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+	v := New("./", []string{}, WithSandbox(Sandbox{Timeout: 100 * time.Millisecond}))
+	v.testEval(t, `
+	10.times do |i|
+	  f = Fiber.new do
+	    Fiber.yield(1)
+	  end
+	  f.resume
+	  [1, 2, 3].each.next
+	end
+	`)
+
+	// Fibers and enumerators that are dropped stop once the sandbox times out
+	for i := 0; runtime.NumGoroutine() > before; i++ {
+		if i == 100 {
+			t.Fatalf("Expect %d goroutines. got: %d", before, runtime.NumGoroutine())
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestDroppedFiberGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+	v := initTestVM()
+	evaluated := v.testEval(t, `
+	def fiber
+	  Fiber.new do
+	    Fiber.yield(1)
+	  end
+	end
+
+	def numbers
+	  Enumerator.new do |y|
+	    y.yield(1)
+	    y.yield(2)
+	  end
+	end
+
+	10.times do |i|
+	  fiber.resume
+	  numbers.next
+	  a = [1, 2, 3]
+	  a.each.next
+	  r = (1..3)
+	  r.each.next
+	end
+	`)
+	checkExpected(t, 0, evaluated, 10)
+
+	// Fibers and enumerators that are dropped stop once they're garbage collected
+	for i := 0; runtime.NumGoroutine() > before; i++ {
+		if i == 100 {
+			t.Fatalf("Expect %d goroutines. got: %d", before, runtime.NumGoroutine())
+		}
+
+		runtime.GC()
+		time.Sleep(20 * time.Millisecond)
+	}
+}
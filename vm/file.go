@@ -52,7 +52,7 @@ func builtinFileClassMethods() []*BuiltInMethodObject {
 			// Changes the mode of the file.
 			// Return number of files.
 			//
-			// ```ruby
+			// ```ruby nodoctest
 			// File.chmod(0755, "test.sh") # => 1
 			// File.chmod(0755, "goby", "../test.sh") # => 2
 			// ```
@@ -208,7 +208,7 @@ func builtinFileClassMethods() []*BuiltInMethodObject {
 		{
 			// Returns size of file in bytes.
 			//
-			// ```ruby
+			// ```ruby nodoctest
 			// File.size("loop.gb") # => 321123
 			// ```
 			// @param filename [String]
@@ -278,11 +278,34 @@ func builtinFileInstanceMethods() []*BuiltInMethodObject {
 			},
 		},
 		{
+			// Returns the file's content.
+			// An optional context can be given to stop waiting for a slow read.
+			//
+			// ```ruby
+			// File.new("loop.gb").read(Context.with_timeout(1))
+			// ```
+			// @param ctx [Context] Optional
+			// @return [String]
 			Name: "read",
 			Fn: func(receiver Object) builtinMethodBody {
 				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					var data []byte
+					var err error
+
+					ctx, _ := t.extractContext(args, 0)
 					file := receiver.(*FileObject).File
-					data, err := ioutil.ReadFile(file.Name())
+					done := make(chan struct{})
+
+					go func() {
+						data, err = ioutil.ReadFile(file.Name())
+						close(done)
+					}()
+
+					select {
+					case <-done:
+					case <-ctx.Done():
+						return t.vm.initCancelledError(ctx)
+					}
 
 					if err != nil {
 						return t.vm.initErrorObject(InternalError, err.Error())
@@ -295,7 +318,7 @@ func builtinFileInstanceMethods() []*BuiltInMethodObject {
 		{
 			// Returns size of file in bytes.
 			//
-			// ```ruby
+			// ```ruby nodoctest
 			// File.new("loop.gb").size # => 321123
 			// ```
 			// @return [Integer]
@@ -37,6 +37,12 @@ func TestFileObject(t *testing.T) {
 		{`
 		require "file"
 
+		f = File.new("../test_fixtures/file_test/size.gb")
+		f.read(Context.with_timeout(10))
+		`, "this file's size is\n22"},
+		{`
+		require "file"
+
 		file = ""
 		File.open("../test_fixtures/file_test/size.gb", "r", 0755) do |f|
 	 	  file = f.read
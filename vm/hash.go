@@ -20,7 +20,7 @@ import (
 // The internal key is actually a String and **not a Symbol** for now (TBD).
 // Thus only a String object or a string literal should be used when referencing with `[ ]`.
 //
-// ```ruby
+// ```ruby nodoctest
 // a = { balthazar1: 100 } # valid
 // b = { 2melchior: 200 }  # invalid
 // x = 'balthazar1'
@@ -42,6 +42,8 @@ type HashObject struct {
 }
 
 func (vm *VM) initHashObject(pairs map[string]Object) *HashObject {
+	vm.countObject()
+
 	return &HashObject{
 		baseObj: &baseObj{class: vm.topLevelClass(hashClass)},
 		Pairs:   pairs,
@@ -171,6 +173,45 @@ func builtinHashInstanceMethods() []*BuiltInMethodObject {
 				}
 			},
 		},
+		{
+			// Loop through key-value pairs of the hash in the alphabetical order of its keys,
+			// and returns the hash. Returns an enumerator if no block is given.
+			//
+			// ```Ruby
+			// h = { b: 2, a: 1 }
+			// h.each do |k, v|
+			//   puts(k + ": " + v.to_s)
+			// end
+			// # => a: 1
+			// # => b: 2
+			// ```
+			//
+			// @return [Hash]
+			Name: "each",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					if len(args) != 0 {
+						return t.vm.initErrorObject(ArgumentError, "Expect 0 argument. got: %d", len(args))
+					}
+
+					h := receiver.(*HashObject)
+
+					if blockFrame == nil {
+						return t.vm.hashEnumerator(h)
+					}
+
+					for _, k := range h.sortedKeys() {
+						t.builtInMethodYield(blockFrame, t.vm.initStringObject(k), h.Pairs[k])
+
+						if t.yieldError != nil {
+							break
+						}
+					}
+
+					return h
+				}
+			},
+		},
 		{
 			// Loop through keys of the hash with given block frame. It also returns array of
 			// keys in alphabetical order.
@@ -206,6 +247,10 @@ func builtinHashInstanceMethods() []*BuiltInMethodObject {
 						obj := t.vm.initStringObject(k)
 						arrOfKeys = append(arrOfKeys, obj)
 						t.builtInMethodYield(blockFrame, obj)
+
+						if t.yieldError != nil {
+							break
+						}
 					}
 
 					return t.vm.initArrayObject(arrOfKeys)
@@ -246,6 +291,10 @@ func builtinHashInstanceMethods() []*BuiltInMethodObject {
 						value := h.Pairs[k]
 						arrOfValues = append(arrOfValues, value)
 						t.builtInMethodYield(blockFrame, value)
+
+						if t.yieldError != nil {
+							break
+						}
 					}
 
 					return t.vm.initArrayObject(arrOfValues)
@@ -338,7 +387,7 @@ func builtinHashInstanceMethods() []*BuiltInMethodObject {
 			// Returns true if the key exist in the hash. Currently, it can only input string
 			// type object.
 			//
-			// ```Ruby
+			// ```ruby nodoctest
 			// h = { a: 1, b: "2", c: [1, 2, 3], d: { k: "v" } }
 			// h.has_key("a") # => true
 			// h.has_key("e") # => false
@@ -404,7 +453,7 @@ func builtinHashInstanceMethods() []*BuiltInMethodObject {
 		{
 			// Returns an array of keys (in arbitrary order)
 			//
-			// ```Ruby
+			// ```ruby nodoctest
 			// { a: 1, b: "2", c: [3, true, "Hello"] }.keys
 			// # =>  ["c", "b", "a"] or ["b", "a", "c"] ... etc
 			// ```
@@ -447,6 +496,48 @@ func builtinHashInstanceMethods() []*BuiltInMethodObject {
 				}
 			},
 		},
+		{
+			// Returns an array of the block's results for each key-value pair,
+			// in the alphabetical order of the keys. Returns an enumerator if no block is given.
+			//
+			// ```Ruby
+			// h = { a: 1, b: 2 }
+			// h.map do |k, v|
+			//   k + v.to_s
+			// end
+			// # => ["a1", "b2"]
+			// ```
+			//
+			// @return [Array]
+			Name: "map",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					if len(args) != 0 {
+						return t.vm.initErrorObject(ArgumentError, "Expect 0 argument. got: %d", len(args))
+					}
+
+					h := receiver.(*HashObject)
+
+					if blockFrame == nil {
+						return t.vm.hashEnumerator(h)
+					}
+
+					var elements []Object
+
+					for _, k := range h.sortedKeys() {
+						result := t.builtInMethodYield(blockFrame, t.vm.initStringObject(k), h.Pairs[k])
+
+						if t.yieldError != nil {
+							break
+						}
+
+						elements = append(elements, result.Target)
+					}
+
+					return t.vm.initArrayObject(elements)
+				}
+			},
+		},
 		{
 			// Returns a new hash with the results of running the block once for every value.
 			// This method does not change the keys and the receiver hash values.
@@ -456,7 +547,7 @@ func builtinHashInstanceMethods() []*BuiltInMethodObject {
 			// result = h.transform_values do |v|
 			//   v * 3
 			// end
-			// h      # => { a: 3, b: 6, c: 9 }
+			// h      # => { a: 1, b: 2, c: 3 }
 			// result # => { a: 3, b: 6, c: 9 }
 			// ```
 			//
@@ -475,6 +566,11 @@ func builtinHashInstanceMethods() []*BuiltInMethodObject {
 					h := receiver.(*HashObject)
 					for k, v := range h.Pairs {
 						result := t.builtInMethodYield(blockFrame, v)
+
+						if t.yieldError != nil {
+							break
+						}
+
 						h.Pairs[k] = result.Target
 					}
 					return h
@@ -554,7 +650,7 @@ func builtinHashInstanceMethods() []*BuiltInMethodObject {
 			// Returns two-dimensional array with the key-value pairs of hash. If specified true
 			// then it will return sorted key value pairs array
 			//
-			// ```Ruby
+			// ```ruby nodoctest
 			// { a: 1, b: 2, c: 3 }.to_a
 			// # => [["a", 1], ["c", 3], ["b", 2]] or [["b", 2], ["c", 3], ["a", 1]] ... etc
 			// { a: 1, b: 2, c: 3 }.to_a(true)
@@ -680,6 +776,11 @@ func builtinHashInstanceMethods() []*BuiltInMethodObject {
 					resultHash := make(map[string]Object)
 					for k, v := range h.Pairs {
 						result := t.builtInMethodYield(blockFrame, v)
+
+						if t.yieldError != nil {
+							break
+						}
+
 						resultHash[k] = result.Target
 					}
 					return t.vm.initHashObject(resultHash)
@@ -689,8 +790,8 @@ func builtinHashInstanceMethods() []*BuiltInMethodObject {
 		{
 			// Returns an array of values (in arbitrary order)
 			//
-			// ```Ruby
-			// { a: 1, b: "2", c: [3, true, "Hello"] }.keys
+			// ```ruby nodoctest
+			// { a: 1, b: "2", c: [3, true, "Hello"] }.values
 			// # =>  [1, "2", [3, true, "Hello"]] or ["2", [3, true, "Hello"], 1] ... etc
 			// ```
 			//
@@ -1260,3 +1260,30 @@ func TestHashValuesMethodFail(t *testing.T) {
 		vm.checkCFP(t, i, 1)
 	}
 }
+
+func TestHashEachAndMapMethod(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`
+		result = ""
+		{ b: 2, a: 1 }.each do |k, v|
+		  result = result + k + v.to_s
+		end
+		result
+		`, "a1b2"},
+		{`
+		{ b: 2, a: 1 }.map do |k, v|
+		  k + v.to_s
+		end.to_s
+		`, `["a1", "b2"]`},
+	}
+
+	for i, tt := range tests {
+		vm := initTestVM()
+		evaluated := vm.testEval(t, tt.input)
+		checkExpected(t, i, evaluated, tt.expected)
+		vm.checkCFP(t, i, 0)
+	}
+}
@@ -51,11 +51,22 @@ func builtinHTTPClassMethods() []*BuiltInMethodObject {
 	return []*BuiltInMethodObject{
 		{
 			// Sends a GET request to the target and returns the HTTP response as a string.
+			// An optional context can be given as the last argument to cancel the request.
+			//
+			// ```ruby
+			// Net::HTTP.get("https://example.com", "/", Context.with_timeout(5))
+			// ```
+			//
+			// @param domain [String]
+			// @param path [String] Optional
+			// @param ctx [Context] Optional
+			// @return [String]
 			Name: "get",
 			Fn: func(receiver Object) builtinMethodBody {
 				return func(t *thread, args []Object, blockFrame *callFrame) Object {
 					var path string
 
+					ctx, args := t.extractContext(args, 1)
 					domain := args[0].(*StringObject).Value
 
 					if len(args) > 1 {
@@ -66,16 +77,30 @@ func builtinHTTPClassMethods() []*BuiltInMethodObject {
 						path = "/" + path
 					}
 
-					resp, err := http.Get(domain + path)
+					req, err := http.NewRequest("GET", domain+path, nil)
 
 					if err != nil {
 						return t.vm.initErrorObject(InternalError, err.Error())
 					}
 
+					resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+
+					if err != nil {
+						if ctx.Err() != nil {
+							return t.vm.initCancelledError(ctx)
+						}
+
+						return t.vm.initErrorObject(InternalError, err.Error())
+					}
+
 					content, err := ioutil.ReadAll(resp.Body)
 					resp.Body.Close()
 
 					if err != nil {
+						if ctx.Err() != nil {
+							return t.vm.initCancelledError(ctx)
+						}
+
 						return t.vm.initErrorObject(InternalError, err.Error())
 					}
 
@@ -11,10 +11,42 @@ import (
 func (i *instruction) inspect() string {
 	var params []string
 
-	for _, param := range i.Params {
-		params = append(params, fmt.Sprint(param))
+	switch i.opcode {
+	case opPutObject:
+		if i.object != nil {
+			params = append(params, i.object.toString())
+		} else {
+			params = append(params, fmt.Sprint(i.integer))
+		}
+	case opPutString, opGetInstanceVariable, opSetConstant, opSetInstanceVariable:
+		params = append(params, i.name)
+	case opGetConstant:
+		params = append(params, i.name, fmt.Sprint(i.flag))
+	case opGetLocal, opSetLocal:
+		params = append(params, fmt.Sprint(i.depth), fmt.Sprint(i.index))
+	case opNewArray, opExpandArray, opNewHash, opDefMethod, opDefSingletonMethod, opInvokeBlock:
+		params = append(params, fmt.Sprint(i.count))
+	case opBranchUnless, opBranchIf, opJump:
+		params = append(params, fmt.Sprint(i.target))
+	case opDefClass:
+		params = append(params, i.name)
+
+		if i.superClass != "" {
+			params = append(params, i.superClass)
+		}
+	case opSend:
+		params = append(params, i.name, fmt.Sprint(i.count))
+
+		if i.body != nil {
+			params = append(params, "block:"+i.body.name)
+		}
+	}
+
+	if len(params) == 0 {
+		return i.opcode.String()
 	}
-	return fmt.Sprintf("%s: %s", i.action.name, strings.Join(params, ", "))
+
+	return fmt.Sprintf("%s: %s", i.opcode, strings.Join(params, ", "))
 }
 
 func (is *instructionSet) inspect() string {
@@ -1,467 +1,726 @@
 package vm
 
 import (
+	"fmt"
 	"github.com/goby-lang/goby/compiler/bytecode"
-	"strings"
+	"sync/atomic"
 )
 
-type operation func(t *thread, cf *callFrame, args ...interface{})
+// opcode identifies an instruction's operation. Opcodes are decoded from bytecode actions once by
+// the instructionTranslator, so the interpreter loop can dispatch them with a switch.
+type opcode uint8
+
+const (
+	opPop opcode = iota
+	opPutObject
+	opPutString
+	opPutSelf
+	opPutNull
+	opGetConstant
+	opGetLocal
+	opGetInstanceVariable
+	opSetLocal
+	opSetConstant
+	opSetInstanceVariable
+	opNewRange
+	opNewArray
+	opExpandArray
+	opNewHash
+	opBranchUnless
+	opBranchIf
+	opJump
+	opDefMethod
+	opDefSingletonMethod
+	opDefClass
+	opSend
+	opInvokeBlock
+	opLeave
+	opOptPlus
+	opOptMinus
+	opOptMult
+	opOptLt
+	opOptLe
+	opOptGt
+	opOptGe
+	opOptEq
+	opOptNeq
+)
+
+// opcodeNames maps opcodes to their bytecode action names.
+var opcodeNames = [...]string{
+	opPop:                 bytecode.Pop,
+	opPutObject:           bytecode.PutObject,
+	opPutString:           bytecode.PutString,
+	opPutSelf:             bytecode.PutSelf,
+	opPutNull:             bytecode.PutNull,
+	opGetConstant:         bytecode.GetConstant,
+	opGetLocal:            bytecode.GetLocal,
+	opGetInstanceVariable: bytecode.GetInstanceVariable,
+	opSetLocal:            bytecode.SetLocal,
+	opSetConstant:         bytecode.SetConstant,
+	opSetInstanceVariable: bytecode.SetInstanceVariable,
+	opNewRange:            bytecode.NewRange,
+	opNewArray:            bytecode.NewArray,
+	opExpandArray:         bytecode.ExpandArray,
+	opNewHash:             bytecode.NewHash,
+	opBranchUnless:        bytecode.BranchUnless,
+	opBranchIf:            bytecode.BranchIf,
+	opJump:                bytecode.Jump,
+	opDefMethod:           bytecode.DefMethod,
+	opDefSingletonMethod:  bytecode.DefSingletonMethod,
+	opDefClass:            bytecode.DefClass,
+	opSend:                bytecode.Send,
+	opInvokeBlock:         bytecode.InvokeBlock,
+	opLeave:               bytecode.Leave,
+	opOptPlus:             bytecode.OptPlus,
+	opOptMinus:            bytecode.OptMinus,
+	opOptMult:             bytecode.OptMult,
+	opOptLt:               bytecode.OptLt,
+	opOptLe:               bytecode.OptLe,
+	opOptGt:               bytecode.OptGt,
+	opOptGe:               bytecode.OptGe,
+	opOptEq:               bytecode.OptEq,
+	opOptNeq:              bytecode.OptNeq,
+}
+
+// optOperators maps specialized opcodes to the operators they send when they can't be evaluated directly.
+var optOperators = map[opcode]string{
+	opOptPlus:  "+",
+	opOptMinus: "-",
+	opOptMult:  "*",
+	opOptLt:    "<",
+	opOptLe:    "<=",
+	opOptGt:    ">",
+	opOptGe:    ">=",
+	opOptEq:    "==",
+	opOptNeq:   "!=",
+}
+
+// opcodes maps bytecode action names to opcodes.
+var opcodes = func() map[string]opcode {
+	m := make(map[string]opcode, len(opcodeNames))
 
-type operationType string
+	for op, name := range opcodeNames {
+		m[name] = opcode(op)
+	}
+
+	return m
+}()
 
-type setType string
+func (op opcode) String() string {
+	return opcodeNames[op]
+}
 
-type action struct {
-	name      string
-	operation operation
+// mayFail reports whether the operation can leave an error on the stack.
+// Other operations only move existing values, so we don't need to check the stack after them.
+func (op opcode) mayFail() bool {
+	switch op {
+	case opGetConstant, opExpandArray, opDefClass, opSend, opInvokeBlock:
+		return true
+	case opOptPlus, opOptMinus, opOptMult, opOptLt, opOptLe, opOptGt, opOptGe, opOptEq, opOptNeq:
+		return true
+	default:
+		return false
+	}
 }
 
+// instruction is a decoded bytecode instruction. Its operands are converted into typed fields by
+// the instructionTranslator, and which fields are used depends on the opcode.
 type instruction struct {
-	action *action
-	Params []interface{}
+	opcode opcode
 	Line   int
+	// sourceLine is the line of the source code the instruction is compiled from, 0 if it's unknown
+	sourceLine int
+	// lineStart is set if the instruction starts a new source line in its set, where the debugger can stop
+	lineStart bool
+	// name is the constant, variable, method or class name, or the text of putstring
+	name string
+	// body is the method or class body of def_method, def_singleton_method and def_class,
+	// or the block of send, which is nil if no block is given
+	body *instructionSet
+	// superClass is the superclass name of def_class, empty if it's not given
+	superClass string
+	// object is the immutable object of putobject, nil if it's an integer
+	object Object
+	// integer is the integer value of putobject
+	integer int
+	// depth and index locate the local variable of getlocal and setlocal
+	depth int
+	index int
+	// count is the number of arguments, elements or variables
+	count int
+	// target is the jump destination of branchunless, branchif and jump
+	target int
+	// flag is getconstant's namespace flag, setlocal's optioned flag or def_class's module flag
+	flag bool
+	// tailCall is set for method bodies' sends whose results are returned right away
+	tailCall bool
+	// cache is send's inline method cache, which holds a *methodCache
+	cache atomic.Value
 }
 
 type instructionSet struct {
 	name         string
+	isType       string
 	instructions []*instruction
 	filename     filename
 	argTypes     []int
+	// localCount is the number of local variables, which decides the size of the set's call frames
+	localCount int
+	// localNames are the names of local variables by their indexes
+	localNames []string
 }
 
-func (is *instructionSet) define(line int, a *action, params ...interface{}) {
-	i := &instruction{action: a, Params: params, Line: line}
+func (is *instructionSet) define(i *instruction) {
 	is.instructions = append(is.instructions, i)
 }
 
-var builtInActions = map[operationType]*action{
-	bytecode.Pop: {
-		name: bytecode.Pop,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			t.stack.pop()
-		},
-	},
-	bytecode.PutObject: {
-		name: bytecode.PutObject,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			object := t.vm.initObjectFromGoType(args[0])
-			t.stack.push(&Pointer{Target: object})
-		},
-	},
-	bytecode.GetConstant: {
-		name: bytecode.GetConstant,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			constName := args[0].(string)
-			c := t.vm.lookupConstant(cf, constName)
-
-			if c == nil {
-				err := t.vm.initErrorObject(NameError, "uninitialized constant %s", constName)
-				t.stack.push(&Pointer{Target: err})
-				return
-			}
+// evalCallFrame evaluates the frame's instructions until it leaves or an error occurs.
+// Methods, blocks and class bodies it calls don't start new loops: their frames are pushed and evaluated
+// by this loop, which continues with the caller once they leave. Only built-in methods that yield to blocks
+// evaluate them in new loops.
+func (t *thread) evalCallFrame(cf *callFrame) {
+	defer t.reportInternalError()
 
-			c.isNamespace = args[1].(string) == "true"
+	instructions := cf.instructionSet.instructions
 
-			if t.stack.top() != nil && t.stack.top().isNamespace {
-				t.stack.pop()
+	for {
+		if cf.pc >= len(instructions) {
+			if cf.caller == nil {
+				return
 			}
 
-			t.stack.push(c)
-		},
-	},
-	bytecode.GetLocal: {
-		name: bytecode.GetLocal,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			depth := args[0].(int)
-			index := args[1].(int)
+			cf = t.returnToCaller(cf)
+			instructions = cf.instructionSet.instructions
+			continue
+		}
 
-			p := cf.getLCL(index, depth)
+		if t.isCancelled() {
+			err := t.vm.initCancelledError(t.ctx)
+			t.stack.push(&Pointer{Target: err})
+			t.reportError(err)
+			return
+		}
 
-			if p == nil {
-				t.stack.push(&Pointer{Target: NULL})
+		if t.vm.sandbox != nil {
+			if err := t.vm.sandbox.check(t.vm); err != nil {
+				t.stack.push(&Pointer{Target: err})
+				t.reportError(err)
 				return
 			}
+		}
 
-			t.stack.push(p)
-		},
-	},
-	bytecode.GetInstanceVariable: {
-		name: bytecode.GetInstanceVariable,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			variableName := args[0].(string)
-			v, ok := cf.self.instanceVariableGet(variableName)
-			if !ok {
-				t.stack.push(&Pointer{Target: NULL})
-				return
-			}
+		if t.vm.debugger != nil {
+			t.vm.debugger.check(t, cf)
+		}
 
-			p := &Pointer{Target: v}
-			t.stack.push(p)
-		},
-	},
-	bytecode.SetInstanceVariable: {
-		name: bytecode.SetInstanceVariable,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			variableName := args[0].(string)
-			p := t.stack.pop()
-			cf.self.instanceVariableSet(variableName, p.Target)
-		},
-	},
-	bytecode.SetLocal: {
-		name: bytecode.SetLocal,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			var optioned bool
-			v := t.stack.pop()
-			depth := args[0].(int)
-			index := args[1].(int)
-
-			if len(args) > 2 && args[2].(int) == 1 {
-				optioned = true
-			}
+		if t.vm.profiler != nil {
+			t.vm.profiler.sample(t, cf)
+		}
 
-			if optioned {
-				if cf.getLCL(index, depth) == nil {
-					cf.insertLCL(index, depth, v.Target)
-				}
+		i := instructions[cf.pc]
+		cf.pc++
 
-				return
-			}
+		if i.lineStart && t.vm.coverage != nil {
+			t.vm.coverage.hit(i)
+		}
+		next := cf
 
-			cf.insertLCL(index, depth, v.Target)
-		},
-	},
-	bytecode.SetConstant: {
-		name: bytecode.SetConstant,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			constName := args[0].(string)
-			v := t.stack.pop()
-
-			cf.storeConstant(constName, v)
-		},
-	},
-	bytecode.NewRange: {
-		name: bytecode.NewRange,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			rangeEnd := t.stack.pop().Target.(*IntegerObject).Value
-			rangeStart := t.stack.pop().Target.(*IntegerObject).Value
-
-			t.stack.push(&Pointer{Target: t.vm.initRangeObject(rangeStart, rangeEnd)})
-		},
-	},
-	bytecode.NewArray: {
-		name: bytecode.NewArray,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			argCount := args[0].(int)
-			elems := []Object{}
-
-			for i := 0; i < argCount; i++ {
-				v := t.stack.pop()
-				elems = append([]Object{v.Target}, elems...)
-			}
+		switch i.opcode {
+		case opPop:
+			t.stack.pop()
+		case opPutObject:
+			t.opPutObject(i)
+		case opPutString:
+			t.stack.push(&Pointer{Target: t.vm.initStringObject(i.name)})
+		case opPutSelf:
+			t.stack.push(&Pointer{Target: cf.self})
+		case opPutNull:
+			t.stack.push(&Pointer{Target: NULL})
+		case opGetConstant:
+			t.opGetConstant(cf, i)
+		case opGetLocal:
+			t.opGetLocal(cf, i)
+		case opGetInstanceVariable:
+			t.opGetInstanceVariable(cf, i)
+		case opSetLocal:
+			t.opSetLocal(cf, i)
+		case opSetConstant:
+			cf.storeConstant(i.name, t.stack.pop())
+		case opSetInstanceVariable:
+			cf.self.instanceVariableSet(i.name, t.stack.pop().Target)
+		case opNewRange:
+			t.opNewRange()
+		case opNewArray:
+			t.opNewArray(i)
+		case opExpandArray:
+			t.opExpandArray(i)
+		case opNewHash:
+			t.opNewHash(i)
+		case opBranchUnless:
+			t.opBranchUnless(cf, i)
+		case opBranchIf:
+			t.opBranchIf(cf, i)
+		case opJump:
+			cf.pc = i.target
+		case opDefMethod:
+			t.opDefMethod(i)
+		case opDefSingletonMethod:
+			t.opDefSingletonMethod(i)
+		case opDefClass:
+			next = t.opDefClass(cf, i)
+		case opSend:
+			next = t.opSend(cf, i)
+			// A tail call evaluates the method in this frame
+			instructions = cf.instructionSet.instructions
+		case opInvokeBlock:
+			next = t.opInvokeBlock(cf, i)
+		case opLeave:
+			t.opLeave(cf)
+		case opOptPlus, opOptMinus, opOptMult, opOptLt, opOptLe, opOptGt, opOptGe, opOptEq, opOptNeq:
+			next = t.opOptOperator(cf, i)
+		}
 
-			arr := t.vm.initArrayObject(elems)
-			t.stack.push(&Pointer{Target: arr})
-		},
-	},
-	bytecode.ExpandArray: {
-		name: bytecode.ExpandArray,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			arrLength := args[0].(int)
-			arr, ok := t.stack.pop().Target.(*ArrayObject)
+		if next != cf {
+			cf = next
+			instructions = cf.instructionSet.instructions
+			continue
+		}
 
-			if !ok {
-				t.returnError(TypeError, "Expect stack top's value to be an Array when executing 'expandarray' instruction.")
+		if !i.opcode.mayFail() {
+			continue
+		}
+
+		if err, yes := t.hasError(); yes {
+			if t.vm.debugger != nil {
+				t.vm.debugger.raised(t, cf, err)
 			}
 
-			elems := []Object{}
+			t.reportError(err)
+			return
+		}
+	}
+}
 
-			for i := 0; i < arrLength; i++ {
-				var elem Object
-				if i < len(arr.Elements) {
-					elem = arr.Elements[i]
-				} else {
-					elem = NULL
-				}
+// reportError prints the error once at the frame where it occurred.
+func (t *thread) reportError(err *Error) {
+	// A cancelled thread is stopped on purpose, so we don't report its CancelledError
+	if err.reported || (t.isCancelled() && err.class.Name == CancelledError) {
+		return
+	}
 
-				elems = append([]Object{elem}, elems...)
-			}
+	// Errors of expressions the debugger evaluates are returned by Debugger.Eval instead
+	if t.vm.debugger != nil && t.vm.debugger.evaluating {
+		return
+	}
 
-			for _, elem := range elems {
-				t.stack.push(&Pointer{Target: elem})
-			}
-		},
-	},
-	bytecode.NewHash: {
-		name: bytecode.NewHash,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			argCount := args[0].(int)
-			pairs := map[string]Object{}
-
-			for i := 0; i < argCount/2; i++ {
-				v := t.stack.pop()
-				k := t.stack.pop()
-				pairs[k.Target.(*StringObject).Value] = v.Target
-			}
+	if t.silent {
+		return
+	}
 
-			hash := t.vm.initHashObject(pairs)
-			t.stack.push(&Pointer{Target: hash})
-		},
-	},
-	bytecode.BranchUnless: {
-		name: bytecode.BranchUnless,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			v := t.stack.pop()
-			bool, isBool := v.Target.(*BooleanObject)
-
-			if isBool {
-				if bool.Value {
-					return
-				}
-
-				line := args[0].(int)
-				cf.pc = line
-				return
-			}
+	fmt.Println(err.report())
+	err.reported = true
+}
 
-			_, isNull := v.Target.(*NullObject)
+// reportInternalError prints the panic once at the frame where it happened, and passes it on.
+func (t *thread) reportInternalError() {
+	if p := recover(); p != nil {
+		if t.vm.stackTraceCount == 0 {
+			fmt.Printf("Internal Error: %s\n", p)
+		}
+		t.vm.stackTraceCount++
+		panic(p)
+	}
+}
 
-			if isNull {
-				line := args[0].(int)
-				cf.pc = line
-				return
-			}
-		},
-	},
-	bytecode.BranchIf: {
-		name: bytecode.BranchIf,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			v := t.stack.pop()
-			bool, isBool := v.Target.(*BooleanObject)
-
-			if isBool {
-				if !bool.Value {
-					return
-				}
-
-				line := args[0].(int)
-				cf.pc = line
-				return
-			}
-		},
-	},
-	bytecode.Jump: {
-		name: bytecode.Jump,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			cf.pc = args[0].(int)
-		},
-	},
-	bytecode.PutSelf: {
-		name: bytecode.PutSelf,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			t.stack.push(&Pointer{Target: cf.self})
-		},
-	},
-	bytecode.PutString: {
-		name: bytecode.PutString,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			object := t.vm.initObjectFromGoType(args[0])
-			t.stack.push(&Pointer{Target: object})
-		},
-	},
-	bytecode.PutNull: {
-		name: bytecode.PutNull,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			t.stack.push(&Pointer{Target: NULL})
-		},
-	},
-	bytecode.DefMethod: {
-		name: bytecode.DefMethod,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			argCount := args[0].(int)
-			methodName := t.stack.pop().Target.(*StringObject).Value
-			is, ok := t.getMethodIS(methodName, cf.instructionSet.filename)
+func (t *thread) opPutObject(i *instruction) {
+	if i.object != nil {
+		t.stack.push(&Pointer{Target: i.object})
+		return
+	}
+
+	// Integers are mutable (see Integer#++), so every evaluation needs a new object.
+	t.stack.push(&Pointer{Target: t.vm.initIntegerObject(i.integer)})
+}
+
+func (t *thread) opGetConstant(cf *callFrame, i *instruction) {
+	c := t.vm.lookupConstant(t, cf, i.name)
+
+	if c == nil {
+		err := t.vm.initErrorObject(NameError, "uninitialized constant %s", i.name)
+		t.stack.push(&Pointer{Target: err})
+		return
+	}
+
+	if t.stack.top() != nil && t.stack.top().isNamespace {
+		t.stack.pop()
+	}
+
+	// The constant's pointer is shared by threads, so the flag goes on a pointer of this evaluation
+	t.stack.push(&Pointer{Target: c.Target, isNamespace: i.flag})
+}
+
+func (t *thread) opGetLocal(cf *callFrame, i *instruction) {
+	p := cf.getLCL(i.index, i.depth)
+
+	if p == nil {
+		t.stack.push(&Pointer{Target: NULL})
+		return
+	}
+
+	t.stack.push(p)
+}
+
+func (t *thread) opGetInstanceVariable(cf *callFrame, i *instruction) {
+	v, ok := cf.self.instanceVariableGet(i.name)
+
+	if !ok {
+		t.stack.push(&Pointer{Target: NULL})
+		return
+	}
+
+	t.stack.push(&Pointer{Target: v})
+}
+
+func (t *thread) opSetLocal(cf *callFrame, i *instruction) {
+	v := t.stack.pop()
+
+	// Optioned locals are parameters' default values, they're only set if no argument is given
+	if i.flag {
+		if cf.getLCL(i.index, i.depth) == nil {
+			cf.insertLCL(i.index, i.depth, v.Target)
+		}
+
+		return
+	}
+
+	cf.insertLCL(i.index, i.depth, v.Target)
+}
+
+func (t *thread) opNewRange() {
+	rangeEnd := t.stack.pop().Target.(*IntegerObject).Value
+	rangeStart := t.stack.pop().Target.(*IntegerObject).Value
+
+	t.stack.push(&Pointer{Target: t.vm.initRangeObject(rangeStart, rangeEnd)})
+}
+
+func (t *thread) opNewArray(i *instruction) {
+	elems := make([]Object, i.count)
+
+	for n := i.count - 1; n >= 0; n-- {
+		elems[n] = t.stack.pop().Target
+	}
+
+	t.stack.push(&Pointer{Target: t.vm.initArrayObject(elems)})
+}
+
+func (t *thread) opExpandArray(i *instruction) {
+	arr, ok := t.stack.pop().Target.(*ArrayObject)
+
+	if !ok {
+		t.returnError(TypeError, "Expect stack top's value to be an Array when executing 'expandarray' instruction.")
+		return
+	}
+
+	// Elements are pushed in reverse order, so the first variable is assigned last
+	for n := i.count - 1; n >= 0; n-- {
+		var elem Object = NULL
+
+		if n < len(arr.Elements) {
+			elem = arr.Elements[n]
+		}
+
+		t.stack.push(&Pointer{Target: elem})
+	}
+}
+
+func (t *thread) opNewHash(i *instruction) {
+	pairs := map[string]Object{}
+
+	for n := 0; n < i.count/2; n++ {
+		v := t.stack.pop()
+		k := t.stack.pop()
+		pairs[k.Target.(*StringObject).Value] = v.Target
+	}
+
+	t.stack.push(&Pointer{Target: t.vm.initHashObject(pairs)})
+}
+
+func (t *thread) opBranchUnless(cf *callFrame, i *instruction) {
+	jumped := false
+
+	switch v := t.stack.pop().Target.(type) {
+	case *BooleanObject:
+		jumped = !v.Value
+	case *NullObject:
+		jumped = true
+	}
+
+	if jumped {
+		cf.pc = i.target
+	}
+
+	if t.vm.coverage != nil {
+		t.vm.coverage.branch(i, jumped)
+	}
+}
+
+func (t *thread) opBranchIf(cf *callFrame, i *instruction) {
+	v, ok := t.stack.pop().Target.(*BooleanObject)
+	jumped := ok && v.Value
+
+	if jumped {
+		cf.pc = i.target
+	}
+
+	if t.vm.coverage != nil {
+		t.vm.coverage.branch(i, jumped)
+	}
+}
+
+func (t *thread) opDefMethod(i *instruction) {
+	methodName := t.stack.pop().Target.(*StringObject).Value
+	method := &MethodObject{Name: methodName, argc: i.count, instructionSet: i.body, baseObj: &baseObj{class: t.vm.topLevelClass(methodClass)}}
+
+	v := t.stack.pop().Target
+	switch self := v.(type) {
+	case *RClass:
+		self.Methods.set(methodName, method)
+	default:
+		self.Class().Methods.set(methodName, method)
+	}
+
+	t.vm.invalidateMethodCaches()
+}
+
+func (t *thread) opDefSingletonMethod(i *instruction) {
+	methodName := t.stack.pop().Target.(*StringObject).Value
+	method := &MethodObject{Name: methodName, argc: i.count, instructionSet: i.body, baseObj: &baseObj{class: t.vm.topLevelClass(methodClass)}}
+
+	v := t.stack.pop().Target
+	v.SingletonClass().Methods.set(methodName, method)
+	t.vm.invalidateMethodCaches()
+	// TODO: Support something like:
+	// ```
+	// f = Foo.new
+	// def f.bar
+	//   10
+	// end
+	// ```
+}
+
+func (t *thread) opDefClass(cf *callFrame, i *instruction) *callFrame {
+	classPtr := cf.lookupConstant(i.name)
+
+	if classPtr == nil {
+		class := t.vm.initializeClass(i.name, i.flag)
+		classPtr = cf.storeConstant(class.Name, class)
+
+		if i.superClass != "" {
+			superClass := t.vm.lookupConstant(t, cf, i.superClass)
+			inheritedClass, ok := superClass.Target.(*RClass)
 
 			if !ok {
-				t.returnError(InternalError, "Can't get method %s's instruction set.", methodName)
+				t.returnError(InternalError, "Constant %s is not a class. got=%s", i.superClass, string(superClass.Target.Class().ReturnName()))
 			}
 
-			method := &MethodObject{Name: methodName, argc: argCount, instructionSet: is, baseObj: &baseObj{class: t.vm.topLevelClass(methodClass)}}
+			class.inherits(inheritedClass)
+		}
+	}
 
-			v := t.stack.pop().Target
-			switch self := v.(type) {
-			case *RClass:
-				self.Methods.set(methodName, method)
-			default:
-				self.Class().Methods.set(methodName, method)
-			}
-		},
-	},
-	bytecode.DefSingletonMethod: {
-		name: bytecode.DefSingletonMethod,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			argCount := args[0].(int)
-			methodName := t.stack.pop().Target.(*StringObject).Value
-			is, _ := t.getMethodIS(methodName, cf.instructionSet.filename)
-			method := &MethodObject{Name: methodName, argc: argCount, instructionSet: is, baseObj: &baseObj{class: t.vm.topLevelClass(methodClass)}}
-
-			v := t.stack.pop().Target
-			v.SingletonClass().Methods.set(methodName, method)
-			// TODO: Support something like:
-			// ```
-			// f = Foo.new
-			// def f.bar
-			//   10
-			// end
-			// ```
-		},
-	},
-	bytecode.DefClass: {
-		name: bytecode.DefClass,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			subject := strings.Split(args[0].(string), ":")
-			subjectType, subjectName := subject[0], subject[1]
-
-			classPtr := cf.lookupConstant(subjectName)
-
-			if classPtr == nil {
-				class := t.vm.initializeClass(subjectName, subjectType == "module")
-				classPtr = cf.storeConstant(class.Name, class)
-
-				if len(args) >= 2 {
-					superClassName := args[1].(string)
-					superClass := t.vm.lookupConstant(cf, superClassName)
-					inheritedClass, ok := superClass.Target.(*RClass)
-
-					if !ok {
-						t.returnError(InternalError, "Constant %s is not a class. got=%s", superClassName, string(superClass.Target.Class().ReturnName()))
-					}
-
-					class.inherits(inheritedClass)
-				}
-			}
+	// Reopened classes may get new methods or includes, so caches can't be trusted anymore
+	t.vm.invalidateMethodCaches()
 
-			is := t.getClassIS(subjectName, cf.instructionSet.filename)
+	t.stack.pop()
+	c := newCallFrame(i.body)
+	c.self = classPtr.Target
+	c.result = classPtr.Target
 
-			t.stack.pop()
-			c := newCallFrame(is)
-			c.self = classPtr.Target
-			t.callFrameStack.push(c)
-			t.startFromTopFrame()
-
-			t.stack.push(classPtr)
-		},
-	},
-	bytecode.Send: {
-		name: bytecode.Send,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			var method Object
-
-			methodName := args[0].(string)
-			argCount := args[1].(int)
-			argPr := t.sp - argCount
-			receiverPr := argPr - 1
-			receiver := t.stack.Data[receiverPr].Target
-
-			method = receiver.findMethod(methodName)
-
-			if method == nil {
-				t.UndefinedMethodError(methodName, receiver)
-				return
-			}
+	return t.enterFrame(cf, c, t.sp)
+}
 
-			blockFrame := t.retrieveBlock(cf, args)
+// opSend calls the method and returns the frame to evaluate next,
+// which is the method's frame if it's a Goby method, or the current frame otherwise.
+func (t *thread) opSend(cf *callFrame, i *instruction) *callFrame {
+	argPr := t.sp - i.count
+	receiverPr := argPr - 1
+	receiver := t.stack.Data[receiverPr].Target
 
-			switch m := method.(type) {
-			case *MethodObject:
-				t.evalMethodObject(receiver, m, receiverPr, argCount, argPr, blockFrame)
-			case *BuiltInMethodObject:
-				t.evalBuiltInMethod(receiver, m, receiverPr, argCount, argPr, blockFrame)
-			case *Error:
-				t.returnError(InternalError, m.toString())
-			}
-		},
-	},
-	bytecode.InvokeBlock: {
-		name: bytecode.InvokeBlock,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			argCount := args[0].(int)
-			argPr := t.sp - argCount
-			receiverPr := argPr - 1
-			receiver := t.stack.Data[receiverPr].Target
-
-			if cf.blockFrame == nil {
-				t.returnError(InternalError, "Can't yield without a block")
-			}
+	method := t.findMethod(i, receiver)
 
-			blockFrame := cf.blockFrame
+	if method == nil {
+		t.UndefinedMethodError(i.name, receiver)
+		return cf
+	}
 
-			/*
-				This is for such condition:
+	if t.vm.profiler != nil {
+		t.vm.profiler.countCall(receiver, i.name)
+	}
 
-				```ruby
-				def foo(x)
-				  yield(x + 10)
-				end
+	if m, ok := method.(*MethodObject); ok && i.tailCall && t.vm.tailCalls && t.tailCall(cf, receiver, m, i.count, argPr) {
+		return cf
+	}
 
-				def bar(y)
-				  foo(y) do |f|
-				    yield(f) # <------- here
-				  end
-				end
+	blockFrame := t.retrieveBlock(cf, i.body)
 
-				bar(100) do |b|
-				  puts(b) #=> 110
-				end
-				```
+	switch m := method.(type) {
+	case *MethodObject:
+		c, err := t.newMethodFrame(receiver, m, i.count, argPr, blockFrame)
 
-				In this case the target frame is not first block frame we meet. It should be `bar`'s block.
-				And bar's frame is foo block frame's ep, so our target frame is ep's block frame.
-			*/
-			if cf.blockFrame.ep == cf.ep {
-				blockFrame = cf.blockFrame.ep.blockFrame
-			}
+		if err != nil {
+			t.setResult(receiverPr, err)
+			return cf
+		}
 
-			c := newCallFrame(blockFrame.instructionSet)
-			c.blockFrame = blockFrame
-			c.ep = blockFrame.ep
-			c.self = receiver
+		return t.enterFrame(cf, c, receiverPr)
+	case *BuiltInMethodObject:
+		evaluated := t.callBuiltInMethod(receiver, m, i.count, argPr, blockFrame)
+		instance := initializedInstance(receiver, m, evaluated)
 
-			for i := 0; i < argCount; i++ {
-				c.locals[i] = t.stack.Data[argPr+i]
-			}
+		if instance == nil {
+			t.setResult(receiverPr, evaluated)
+			return cf
+		}
 
-			t.callFrameStack.push(c)
-			t.startFromTopFrame()
-
-			t.stack.Data[receiverPr] = t.stack.top()
-			t.sp = receiverPr + 1
-		},
-	},
-	bytecode.Leave: {
-		name: bytecode.Leave,
-		operation: func(t *thread, cf *callFrame, args ...interface{}) {
-			//fmt.Println(t.callFrameStack.inspect())
-			//fmt.Println("Before leave--------------------------------")
-			cf = t.callFrameStack.pop()
-			cf.pc = len(cf.instructionSet.instructions)
-			//fmt.Println(t.callFrameStack.inspect())
-
-			/*
-				Remove top frame if it's a block frame
-
-				Block execution frame <- This was popped when executing leave
-				---------------------
-				Block frame           <- So this frame is useless
-				---------------------
-				Main frame
-			*/
-			topFrame := t.callFrameStack.top()
-			if topFrame != nil && topFrame.isBlock {
-				cf = t.callFrameStack.pop()
-				cf.pc = len(cf.instructionSet.instructions)
-			}
-		},
-	},
+		c, err := t.newMethodFrame(instance, instance.InitializeMethod, i.count, argPr, blockFrame)
+
+		if err != nil {
+			t.setResult(receiverPr, err)
+			return cf
+		}
+
+		c.result = instance
+
+		return t.enterFrame(cf, c, receiverPr)
+	case *Error:
+		t.returnError(InternalError, m.toString())
+	}
+
+	return cf
+}
+
+// opOptOperator evaluates a specialized operator send. When both operands are integers and Integer's operator
+// isn't redefined, the result is calculated here without a method call. Otherwise it's a normal send.
+func (t *thread) opOptOperator(cf *callFrame, i *instruction) *callFrame {
+	left, ok := t.stack.Data[t.sp-2].Target.(*IntegerObject)
+
+	if !ok {
+		return t.opSend(cf, i)
+	}
+
+	right, ok := t.stack.Data[t.sp-1].Target.(*IntegerObject)
+
+	if !ok {
+		return t.opSend(cf, i)
+	}
+
+	// Sandboxed vms need to check if the method is allowed, and profiled vms count calls, which opSend does
+	if _, ok := t.findMethod(i, left).(*BuiltInMethodObject); !ok || t.vm.sandbox != nil || t.vm.profiler != nil {
+		return t.opSend(cf, i)
+	}
+
+	var result Object
+
+	switch i.opcode {
+	case opOptPlus:
+		result = t.vm.initIntegerObject(left.Value + right.Value)
+	case opOptMinus:
+		result = t.vm.initIntegerObject(left.Value - right.Value)
+	case opOptMult:
+		result = t.vm.initIntegerObject(left.Value * right.Value)
+	case opOptLt:
+		result = toBooleanObject(left.Value < right.Value)
+	case opOptLe:
+		result = toBooleanObject(left.Value <= right.Value)
+	case opOptGt:
+		result = toBooleanObject(left.Value > right.Value)
+	case opOptGe:
+		result = toBooleanObject(left.Value >= right.Value)
+	case opOptEq:
+		result = toBooleanObject(left.Value == right.Value)
+	case opOptNeq:
+		result = toBooleanObject(left.Value != right.Value)
+	}
+
+	t.stack.pop()
+	t.stack.Data[t.sp-1] = &Pointer{Target: result}
+
+	return cf
+}
+
+// opInvokeBlock yields to the current frame's block and returns the block's frame to evaluate next.
+func (t *thread) opInvokeBlock(cf *callFrame, i *instruction) *callFrame {
+	argPr := t.sp - i.count
+	receiverPr := argPr - 1
+	receiver := t.stack.Data[receiverPr].Target
+
+	if cf.blockFrame == nil {
+		t.returnError(InternalError, "Can't yield without a block")
+		return cf
+	}
+
+	blockFrame := cf.blockFrame
+
+	/*
+		This is for such condition:
+
+		```ruby
+		def foo(x)
+		  yield(x + 10)
+		end
+
+		def bar(y)
+		  foo(y) do |f|
+		    yield(f) # <------- here
+		  end
+		end
+
+		bar(100) do |b|
+		  puts(b) #=> 110
+		end
+		```
+
+		In this case the target frame is not first block frame we meet. It should be `bar`'s block.
+		And bar's frame is foo block frame's ep, so our target frame is ep's block frame.
+	*/
+	if cf.blockFrame.ep == cf.ep {
+		blockFrame = cf.blockFrame.ep.blockFrame
+	}
+
+	c := newCallFrame(blockFrame.instructionSet)
+	c.blockFrame = blockFrame
+	c.ep = blockFrame.ep
+	c.self = receiver
+
+	// Blocks can be given more arguments than their parameters
+	c.growLocals(i.count)
+
+	for n := 0; n < i.count; n++ {
+		c.locals[n] = t.stack.Data[argPr+n]
+	}
+
+	return t.enterFrame(cf, c, receiverPr)
+}
+
+func (t *thread) opLeave(cf *callFrame) {
+	cf.pc = len(cf.instructionSet.instructions)
+	cf = t.callFrameStack.pop()
+	cf.pc = len(cf.instructionSet.instructions)
+
+	/*
+		Remove top frame if it's a block frame
+
+		Block execution frame <- This was popped when executing leave
+		---------------------
+		Block frame           <- So this frame is useless
+		---------------------
+		Main frame
+	*/
+	topFrame := t.callFrameStack.top()
+	if topFrame != nil && topFrame.isBlock {
+		cf = t.callFrameStack.pop()
+		cf.pc = len(cf.instructionSet.instructions)
+	}
 }
 
 func (vm *VM) initObjectFromGoType(value interface{}) Object {
@@ -0,0 +1,118 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/goby-lang/goby/compiler"
+)
+
+func TestInstructionDecoding(t *testing.T) {
+	input := `
+	a = [1, true]
+	if a
+	  a.push("foo")
+	end
+	`
+	expected := `putobject: 1
+putobject: true
+newarray: 2
+setlocal: 0, 0
+getlocal: 0, 0
+branchunless: 10
+getlocal: 0, 0
+putstring: foo
+send: push, 1
+jump: 11
+putnil
+leave
+`
+
+	sets, err := compiler.CompileToInstructions(input)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	it := newInstructionTranslator("./")
+	it.vm = initTestVM()
+	it.transferInstructionSets(sets)
+
+	if it.program.inspect() != expected {
+		t.Fatalf("Expect instructions to be:\n%s\ngot:\n%s", expected, it.program.inspect())
+	}
+}
+
+func TestSpecializedInstructions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`
+		a = 10
+		b = 3
+		[a + b, a - b, a * b, a < b, a <= b, a > b, a >= b, a == b, a != b].to_s
+		`, "[13, 7, 30, false, false, true, true, false, true]"},
+		// Operands that aren't both integers are sent to their methods
+		{`
+		a = "foo"
+		a + "bar"
+		`, "foobar"},
+		{`
+		a = 1
+		a == "1"
+		`, false},
+		// Redefined operators are called instead of being calculated directly
+		{`
+		class Integer
+		  def +(other)
+		    100
+		  end
+		end
+
+		a = 1
+		a + 2
+		`, 100},
+		{`
+		class Integer
+		  def +(other)
+		    100
+		  end
+		end
+
+		1 + 2
+		`, 100},
+		// Integers pushed by specialized instructions are new objects
+		{`
+		a = 1
+		b = a + 0
+		b++
+		a
+		`, 1},
+	}
+
+	for i, tt := range tests {
+		vm := initTestVM()
+		evaluated := vm.testEval(t, tt.input)
+		checkExpected(t, i, evaluated, tt.expected)
+		vm.checkCFP(t, i, 0)
+	}
+}
+
+func TestSpecializedInstructionsFail(t *testing.T) {
+	testsFail := []struct {
+		input    string
+		errType  string
+		expected string
+	}{
+		{`a = 1
+		a + "1"`, TypeError, "TypeError: Expect argument to be Integer. got: String"},
+		{`a = nil
+		a < 1`, UndefinedMethodError, "UndefinedMethodError: Undefined Method '<' for nil"},
+	}
+
+	for i, tt := range testsFail {
+		v := initTestVM()
+		evaluated := v.testEval(t, tt.input)
+		checkError(t, i, evaluated, tt.errType, tt.expected)
+	}
+}
@@ -9,40 +9,37 @@ import (
 
 // instructionTranslator is responsible for parsing bytecodes
 type instructionTranslator struct {
-	vm         *VM
-	line       int
-	setTable   map[setType]map[string][]*instructionSet
-	blockTable map[string]*instructionSet
-	filename   filename
-	program    *instructionSet
+	vm       *VM
+	line     int
+	filename filename
+	program  *instructionSet
+	// bodies are translated method, class and block bodies by their ids
+	bodies map[int]*instructionSet
+	// references are instructions that refer to bodies, they're resolved once all sets are translated
+	references []bodyReference
+}
+
+// bodyReference is an instruction that refers to a method, class or block body by its id
+type bodyReference struct {
+	ins *instruction
+	id  int
 }
 
 // newInstructionTranslator initializes instructionTranslator and its instruction set table then returns it
 func newInstructionTranslator(file filename) *instructionTranslator {
-	it := &instructionTranslator{filename: file}
-	it.blockTable = make(map[string]*instructionSet)
-	it.setTable = map[setType]map[string][]*instructionSet{
-		bytecode.MethodDef: make(map[string][]*instructionSet),
-		bytecode.ClassDef:  make(map[string][]*instructionSet),
-	}
-
-	return it
+	return &instructionTranslator{filename: file, bodies: make(map[int]*instructionSet)}
 }
 
 func (it *instructionTranslator) setMetadata(is *instructionSet, set *bytecode.InstructionSet) {
-	t := setType(set.SetType())
-	n := set.Name()
-
-	is.name = n
+	is.name = set.Name()
+	is.isType = set.SetType()
 
-	switch t {
-	case bytecode.Program:
+	if set.SetType() == bytecode.Program {
 		it.program = is
-	case bytecode.Block:
-		it.blockTable[n] = is
-	default:
-		it.setTable[t][n] = append(it.setTable[t][n], is)
+		return
 	}
+
+	it.bodies[set.ID()] = is
 }
 
 func (it *instructionTranslator) parseParam(param string) interface{} {
@@ -60,13 +57,32 @@ func (it *instructionTranslator) transferInstructionSets(sets []*bytecode.Instru
 	iss := []*instructionSet{}
 
 	for _, set := range sets {
-		it.transferInstructionSet(iss, set)
+		iss = append(iss, it.transferInstructionSet(set))
+	}
+
+	// Bodies can be compiled after the instructions that refer to them, so they're resolved at last
+	for _, ref := range it.references {
+		body, ok := it.bodies[ref.id]
+
+		if !ok {
+			panic(fmt.Sprintf("Can't find instruction set %d for %s", ref.id, ref.ins.inspect()))
+		}
+
+		ref.ins.body = body
+	}
+
+	it.references = nil
+
+	for _, is := range iss {
+		if is.isType == bytecode.MethodDef {
+			markTailCalls(is)
+		}
 	}
 
 	return iss
 }
 
-func (it *instructionTranslator) transferInstructionSet(iss []*instructionSet, set *bytecode.InstructionSet) {
+func (it *instructionTranslator) transferInstructionSet(set *bytecode.InstructionSet) *instructionSet {
 	is := &instructionSet{filename: it.filename}
 	it.setMetadata(is, set)
 
@@ -75,38 +91,149 @@ func (it *instructionTranslator) transferInstructionSet(iss []*instructionSet, s
 	}
 
 	is.argTypes = set.ArgTypes()
+	is.localCount = set.LocalCount()
+	is.localNames = set.LocalNames()
+	markLineStarts(is)
 
-	iss = append(iss, is)
+	return is
 }
 
-// transferInstruction transfer a bytecode.Instruction into an vm instruction and append it into given instruction set.
-func (it *instructionTranslator) transferInstruction(is *instructionSet, i *bytecode.Instruction) {
-	var params []interface{}
-	act := operationType(i.Action)
+// markLineStarts marks instructions whose source lines differ from their previous instructions'
+func markLineStarts(is *instructionSet) {
+	line := 0
+
+	for _, ins := range is.instructions {
+		if ins.sourceLine != 0 && ins.sourceLine != line {
+			ins.lineStart = true
+			line = ins.sourceLine
+		}
+	}
+}
+
+// markTailCalls marks sends that are followed by `leave`, directly or through jumps.
+// Sends with blocks aren't marked, since the blocks need their caller's frame.
+func markTailCalls(is *instructionSet) {
+	for n, ins := range is.instructions {
+		if ins.opcode != opSend || ins.body != nil {
+			continue
+		}
+
+		next := n + 1
 
-	action := builtInActions[act]
+		// Jumps can loop forever, so we only follow as many as there are instructions
+		for hops := 0; hops < len(is.instructions) && next < len(is.instructions) && is.instructions[next].opcode == opJump; hops++ {
+			next = is.instructions[next].target
+		}
+
+		ins.tailCall = next < len(is.instructions) && is.instructions[next].opcode == opLeave
+	}
+}
+
+// transferInstruction decodes a bytecode.Instruction into an vm instruction and append it into given instruction set.
+func (it *instructionTranslator) transferInstruction(is *instructionSet, i *bytecode.Instruction) {
+	op, ok := opcodes[i.Action]
 
-	if action == nil {
-		panic(fmt.Sprintf("Unknown command: %s. line: %d", act, i.Line()))
+	if !ok {
+		panic(fmt.Sprintf("Unknown command: %s. line: %d", i.Action, i.Line()))
 	}
 
-	switch act {
-	case bytecode.PutString:
-		text := strings.Split(i.Params[0], "\"")[1]
-		params = append(params, text)
-	case bytecode.BranchUnless, bytecode.BranchIf, bytecode.Jump:
+	ins := &instruction{opcode: op, Line: i.Line(), sourceLine: i.SourceLine()}
+
+	switch op {
+	case opPutObject:
+		it.decodeObject(ins, i.Params[0])
+	case opPutString:
+		ins.name = strings.Split(i.Params[0], "\"")[1]
+	case opGetConstant:
+		ins.name = i.Params[0]
+		ins.flag = i.Params[1] == "true"
+	case opGetInstanceVariable, opSetConstant, opSetInstanceVariable:
+		ins.name = i.Params[0]
+	case opGetLocal, opSetLocal:
+		ins.depth = it.parseInt(i, 0)
+		ins.index = it.parseInt(i, 1)
+
+		if len(i.Params) > 2 {
+			ins.flag = it.parseInt(i, 2) == 1
+		}
+	case opNewArray, opExpandArray, opNewHash, opInvokeBlock:
+		ins.count = it.parseInt(i, 0)
+	case opDefMethod, opDefSingletonMethod:
+		ins.count = it.parseInt(i, 0)
+		it.referBody(ins, it.parseInt(i, 1))
+	case opBranchUnless, opBranchIf, opJump:
 		line, err := i.AnchorLine()
 
 		if err != nil {
 			panic(err.Error())
 		}
 
-		params = append(params, line)
+		ins.target = line
+	case opDefClass:
+		subject := strings.Split(i.Params[0], ":")
+		ins.flag = subject[0] == "module"
+		ins.name = subject[1]
+		it.referBody(ins, it.parseInt(i, 1))
+
+		if len(i.Params) > 2 {
+			ins.superClass = i.Params[2]
+		}
+	case opSend:
+		ins.name = i.Params[0]
+		ins.count = it.parseInt(i, 1)
+
+		if len(i.Params) > 2 {
+			id, err := strconv.Atoi(strings.Split(i.Params[2], ":")[1])
+
+			if err != nil {
+				panic(fmt.Sprintf("Expect block id to be an integer. got: %s. line: %d", i.Params[2], i.Line()))
+			}
+
+			it.referBody(ins, id)
+		}
 	default:
-		for _, param := range i.Params {
-			params = append(params, it.parseParam(param))
+		if operator, ok := optOperators[op]; ok {
+			ins.name = operator
+			ins.count = 1
 		}
 	}
 
-	is.define(i.Line(), action, params...)
+	is.define(ins)
+}
+
+// referBody records that the instruction refers to the body with the id.
+func (it *instructionTranslator) referBody(ins *instruction, id int) {
+	it.references = append(it.references, bodyReference{ins: ins, id: id})
+}
+
+// parseInt decodes the nth param of the instruction as an integer.
+func (it *instructionTranslator) parseInt(i *bytecode.Instruction, n int) int {
+	integer, err := strconv.Atoi(i.Params[n])
+
+	if err != nil {
+		panic(fmt.Sprintf("Expect param %d of %s to be an integer. got: %s. line: %d", n, i.Action, i.Params[n], i.Line()))
+	}
+
+	return integer
+}
+
+// decodeObject sets putobject's operand. Integers are kept as values since they are mutable,
+// and other literals are converted into objects here.
+func (it *instructionTranslator) decodeObject(ins *instruction, param string) {
+	switch v := it.parseParam(param).(type) {
+	case int:
+		ins.integer = v
+	case string:
+		switch v {
+		case "true":
+			ins.object = TRUE
+		case "false":
+			ins.object = FALSE
+		case "nil":
+			ins.object = NULL
+		default:
+			ins.opcode = opPutString
+			ins.name = v
+		}
+	}
 }
@@ -31,6 +31,8 @@ type IntegerObject struct {
 }
 
 func (vm *VM) initIntegerObject(value int) *IntegerObject {
+	vm.countObject()
+
 	return &IntegerObject{
 		baseObj: &baseObj{class: vm.topLevelClass(integerClass)},
 		Value:   value,
@@ -421,7 +423,9 @@ func builtinIntegerInstanceMethods() []*BuiltInMethodObject {
 			// Adds 1 to self and returns.
 			//
 			// ```Ruby
-			// 1++ # => 2
+			// a = 1
+			// a++
+			// a # => 2
 			// ```
 			// @return [Integer]
 			Name: "++",
@@ -441,7 +445,9 @@ func builtinIntegerInstanceMethods() []*BuiltInMethodObject {
 			// Substracts 1 from self and returns.
 			//
 			// ```Ruby
-			// 0-- # => -1
+			// a = 0
+			// a--
+			// a # => -1
 			// ```
 			// @return [Integer]
 			Name: "--",
@@ -589,6 +595,10 @@ func builtinIntegerInstanceMethods() []*BuiltInMethodObject {
 
 					for i := 0; i < n.Value; i++ {
 						t.builtInMethodYield(blockFrame, t.vm.initIntegerObject(i))
+
+						if t.yieldError != nil {
+							break
+						}
 					}
 
 					return n
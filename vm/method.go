@@ -3,6 +3,7 @@ package vm
 import (
 	"bytes"
 	"fmt"
+	"github.com/goby-lang/goby/compiler/bytecode"
 )
 
 // MethodObject represents methods defined using goby.
@@ -26,6 +27,19 @@ func (vm *VM) initMethodClass() *RClass {
 	return vm.initializeClass(methodClass, false)
 }
 
+// normalArgCount returns the number of parameters without default values, which are required by calls
+func (m *MethodObject) normalArgCount() int {
+	n := 0
+
+	for _, at := range m.instructionSet.argTypes {
+		if at == bytecode.NormalArg {
+			n++
+		}
+	}
+
+	return n
+}
+
 // Polymorphic helper functions -----------------------------------------
 
 // toString returns method's name, params count and instruction set.
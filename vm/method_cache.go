@@ -0,0 +1,104 @@
+package vm
+
+import (
+	"sync/atomic"
+)
+
+// methodCacheSize is the number of receiver classes a call site remembers.
+// Call sites that see more classes than this keep the most recent ones.
+const methodCacheSize = 4
+
+// methodCache is the inline cache of a `send` instruction, which maps receivers' lookup classes
+// to the methods found for them.
+// A cache is immutable once it's stored, so threads evaluating the same instruction can share it.
+// It's outdated once the VM's method serial changes, which happens whenever methods are defined
+// or class hierarchies are changed.
+type methodCache struct {
+	serial  uint64
+	entries []methodCacheEntry
+}
+
+type methodCacheEntry struct {
+	class  *RClass
+	method Object
+}
+
+// MethodCacheStats counts `send` instructions that found their methods in inline caches (hits)
+// and ones that had to look methods up through class hierarchies (misses).
+type MethodCacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// MethodCacheStats returns the VM's inline method cache counters.
+func (vm *VM) MethodCacheStats() MethodCacheStats {
+	return MethodCacheStats{
+		Hits:   atomic.LoadUint64(&vm.methodCacheHits),
+		Misses: atomic.LoadUint64(&vm.methodCacheMisses),
+	}
+}
+
+// invalidateMethodCaches outdates every inline method cache. It should be called whenever a method
+// is defined or a class hierarchy is changed.
+func (vm *VM) invalidateMethodCaches() {
+	atomic.AddUint64(&vm.methodSerial, 1)
+}
+
+// findMethod looks up the method for the send instruction's receiver through its inline cache.
+func (t *thread) findMethod(i *instruction, receiver Object) Object {
+	vm := t.vm
+	class := methodLookupClass(receiver)
+	serial := atomic.LoadUint64(&vm.methodSerial)
+	cache, _ := i.cache.Load().(*methodCache)
+
+	if class != nil && cache != nil && cache.serial == serial {
+		for _, e := range cache.entries {
+			if e.class == class {
+				atomic.AddUint64(&vm.methodCacheHits, 1)
+				return e.method
+			}
+		}
+	}
+
+	atomic.AddUint64(&vm.methodCacheMisses, 1)
+	method := receiver.findMethod(i.name)
+
+	if class == nil || method == nil {
+		return method
+	}
+
+	newCache := &methodCache{serial: serial}
+
+	if cache != nil && cache.serial == serial {
+		entries := cache.entries
+
+		if len(entries) >= methodCacheSize {
+			entries = entries[1:]
+		}
+
+		newCache.entries = append(newCache.entries, entries...)
+	}
+
+	newCache.entries = append(newCache.entries, methodCacheEntry{class: class, method: method})
+	i.cache.Store(newCache)
+
+	return method
+}
+
+// methodLookupClass returns the class where the receiver's method lookup starts.
+// Together with the method name it decides the lookup's result, so it's used as inline caches' key.
+func methodLookupClass(receiver Object) *RClass {
+	if c, ok := receiver.(*RClass); ok {
+		if c.isSingleton {
+			return c
+		}
+
+		return c.SingletonClass()
+	}
+
+	if s := receiver.SingletonClass(); s != nil {
+		return s
+	}
+
+	return receiver.Class()
+}
@@ -0,0 +1,180 @@
+package vm
+
+import "testing"
+
+func TestMethodCacheInvalidation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// Redefining a method by reopening the class
+		{`
+		class Foo
+		  def bar
+		    1
+		  end
+		end
+
+		def call_bar(f)
+		  f.bar
+		end
+
+		f = Foo.new
+		a = call_bar(f)
+
+		class Foo
+		  def bar
+		    10
+		  end
+		end
+
+		a + call_bar(f)
+		`, 11},
+		// Including a module that overrides the superclass's method
+		{`
+		class Base
+		  def name
+		    "base"
+		  end
+		end
+
+		module Named
+		  def name
+		    "named"
+		  end
+		end
+
+		class Foo < Base
+		end
+
+		def call_name(f)
+		  f.name
+		end
+
+		f = Foo.new
+		a = call_name(f)
+
+		class Foo
+		  include(Named)
+		end
+
+		a + call_name(f)
+		`, "basenamed"},
+		// Defining the method in subclass after it's found in superclass
+		{`
+		class Base
+		  def name
+		    "base"
+		  end
+		end
+
+		class Foo < Base
+		end
+
+		def call_name(f)
+		  f.name
+		end
+
+		f = Foo.new
+		a = call_name(f)
+
+		class Foo
+		  def name
+		    "foo"
+		  end
+		end
+
+		a + call_name(f)
+		`, "basefoo"},
+		// Attribute readers
+		{`
+		class Foo
+		  def initialize
+		    @bar = 5
+		  end
+
+		  def bar
+		    1
+		  end
+		end
+
+		def call_bar(f)
+		  f.bar
+		end
+
+		f = Foo.new
+		a = call_bar(f)
+
+		class Foo
+		  attr_reader("bar")
+		end
+
+		a + call_bar(f)
+		`, 6},
+		// Polymorphic call site sees more classes than it can remember
+		{`
+		class A; def v; 1; end; end
+		class B; def v; 2; end; end
+		class C; def v; 3; end; end
+		class D; def v; 4; end; end
+		class E; def v; 5; end; end
+
+		sum = 0
+		objects = [A.new, B.new, C.new, D.new, E.new]
+		3.times do
+		  objects.each do |o|
+		    sum = sum + o.v
+		  end
+		end
+		sum
+		`, 45},
+		// Class methods are cached separately from instance methods
+		{`
+		class Foo
+		  def self.v
+		    1
+		  end
+
+		  def v
+		    2
+		  end
+		end
+
+		sum = 0
+		[Foo, Foo.new, Foo, Foo.new].each do |o|
+		  sum = sum + o.v
+		end
+		sum
+		`, 6},
+	}
+
+	for i, tt := range tests {
+		vm := initTestVM()
+		evaluated := vm.testEval(t, tt.input)
+		checkExpected(t, i, evaluated, tt.expected)
+		vm.checkCFP(t, i, 0)
+	}
+}
+
+func TestMethodCacheStats(t *testing.T) {
+	input := `
+	i = 0
+	while i < 100 do
+	  i = i + 1
+	end
+	i
+	`
+
+	vm := initTestVM()
+	vm.testEval(t, input)
+	stats := vm.MethodCacheStats()
+
+	// Both `<` and `+` miss once, and hit in every other iteration
+	if stats.Hits < 198 {
+		t.Fatalf("Expect at least 198 cache hits. got: %d (%d misses)", stats.Hits, stats.Misses)
+	}
+
+	if stats.Misses > 5 {
+		t.Fatalf("Expect at most 5 cache misses. got: %d", stats.Misses)
+	}
+}
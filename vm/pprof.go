@@ -0,0 +1,182 @@
+package vm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// This file encodes profiles in pprof's format, which is a gzipped protocol buffer of profile.proto.
+// See https://github.com/google/pprof/blob/master/proto/profile.proto
+
+// Field numbers of profile.proto's messages
+const (
+	profileSampleType    = 1
+	profileSample        = 2
+	profileLocation      = 4
+	profileFunction      = 5
+	profileStringTable   = 6
+	profileTimeNanos     = 9
+	profileDurationNanos = 10
+	profilePeriodType    = 11
+	profilePeriod        = 12
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID       = 1
+	functionName     = 2
+	functionFilename = 4
+)
+
+// protoBuffer encodes protocol buffer fields
+type protoBuffer struct {
+	bytes.Buffer
+}
+
+func (b *protoBuffer) varint(v uint64) {
+	for v >= 0x80 {
+		b.WriteByte(byte(v) | 0x80)
+		v >>= 7
+	}
+
+	b.WriteByte(byte(v))
+}
+
+// integer encodes a varint field, which is omitted if it's 0 like proto3 does
+func (b *protoBuffer) integer(field int, v int64) {
+	if v == 0 {
+		return
+	}
+
+	b.varint(uint64(field) << 3)
+	b.varint(uint64(v))
+}
+
+// message encodes a length-delimited field, like a string or an embedded message
+func (b *protoBuffer) message(field int, data []byte) {
+	b.varint(uint64(field)<<3 | 2)
+	b.varint(uint64(len(data)))
+	b.Write(data)
+}
+
+// packed encodes a repeated integer field
+func (b *protoBuffer) packed(field int, values []int64) {
+	var data protoBuffer
+
+	for _, v := range values {
+		data.varint(uint64(v))
+	}
+
+	b.message(field, data.Bytes())
+}
+
+// pprofEncoder builds a profile's string, function and location tables while samples are added
+type pprofEncoder struct {
+	protoBuffer
+	strings   map[string]int64
+	functions map[profileFunctionKey]int64
+	locations map[profileFrame]int64
+	tables    protoBuffer
+}
+
+type profileFunctionKey struct {
+	name string
+	file string
+}
+
+func newPprofEncoder() *pprofEncoder {
+	e := &pprofEncoder{strings: map[string]int64{}, functions: map[profileFunctionKey]int64{}, locations: map[profileFrame]int64{}}
+	// The first string must be empty
+	e.str("")
+	return e
+}
+
+// str returns the index of the string in the string table
+func (e *pprofEncoder) str(s string) int64 {
+	if index, ok := e.strings[s]; ok {
+		return index
+	}
+
+	index := int64(len(e.strings))
+	e.strings[s] = index
+	e.tables.message(profileStringTable, []byte(s))
+	return index
+}
+
+func (e *pprofEncoder) valueType(field int, typ, unit string) {
+	var vt protoBuffer
+	vt.integer(valueTypeType, e.str(typ))
+	vt.integer(valueTypeUnit, e.str(unit))
+	e.message(field, vt.Bytes())
+}
+
+// location returns the id of the frame's location, ids start from 1
+func (e *pprofEncoder) location(frame profileFrame) int64 {
+	if id, ok := e.locations[frame]; ok {
+		return id
+	}
+
+	key := profileFunctionKey{frame.function, frame.file}
+	fid, ok := e.functions[key]
+
+	if !ok {
+		fid = int64(len(e.functions) + 1)
+		e.functions[key] = fid
+
+		var f protoBuffer
+		f.integer(functionID, fid)
+		f.integer(functionName, e.str(frame.function))
+		f.integer(functionFilename, e.str(frame.file))
+		e.tables.message(profileFunction, f.Bytes())
+	}
+
+	id := int64(len(e.locations) + 1)
+	e.locations[frame] = id
+
+	var line, l protoBuffer
+	line.integer(lineFunctionID, fid)
+	line.integer(lineLine, int64(frame.line))
+	l.integer(locationID, id)
+	l.message(locationLine, line.Bytes())
+	e.tables.message(profileLocation, l.Bytes())
+
+	return id
+}
+
+func (e *pprofEncoder) sample(stack []profileFrame, values []int64) {
+	ids := make([]int64, len(stack))
+
+	for n, frame := range stack {
+		ids[n] = e.location(frame)
+	}
+
+	var s protoBuffer
+	s.packed(sampleLocationID, ids)
+	s.packed(sampleValue, values)
+	e.message(profileSample, s.Bytes())
+}
+
+// writeTo writes the gzipped profile, which has the samples and then the tables they refer to
+func (e *pprofEncoder) writeTo(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+
+	if _, err := gz.Write(e.Bytes()); err != nil {
+		return err
+	}
+
+	if _, err := gz.Write(e.tables.Bytes()); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
@@ -0,0 +1,354 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/goby-lang/goby/compiler/bytecode"
+)
+
+// DefaultProfileInterval is how often a Profiler samples if its interval isn't given
+const DefaultProfileInterval = time.Millisecond
+
+// Profiler samples which Goby methods, blocks and lines a vm is evaluating, and counts method calls.
+// It's given to New with WithProfiler, and stopped once the program ends:
+//
+// ```go
+// p := vm.NewProfiler(0)
+// v := vm.New(dir, args, vm.WithProfiler(p))
+// v.ExecInstructions(sets, "script.gb")
+// p.Stop()
+// p.WritePprof(file)
+// p.WriteSummary(os.Stderr)
+// ```
+//
+// A thread checks the clock every profileCheckInstructions instructions, and takes a sample from its call frame stack
+// if the interval passed. The sample has the time and the number of objects created since the last one,
+// so time is wall-clock time, and time spent in built-in methods is attributed to the lines that call them.
+type Profiler struct {
+	vm       *VM
+	interval time.Duration
+	// next is when the next sample is due, in nanoseconds since start
+	next int64
+	// objects counts objects created by the vm's scripts
+	objects uint64
+
+	mu          sync.Mutex
+	running     bool
+	start       time.Time
+	duration    time.Duration
+	last        time.Time
+	lastObjects uint64
+	// stacks are sampled stacks by their keys
+	stacks map[string]*profileStack
+	// calls counts calls of methods, which are named like `String#upcase` or `File.delete`
+	calls map[string]int64
+}
+
+// profileCheckInstructions is how many instructions a thread evaluates between checking the clock
+const profileCheckInstructions = 256
+
+// profileFrame is a function and the line it's evaluating
+type profileFrame struct {
+	function string
+	file     string
+	line     int
+}
+
+// profileStack is a stack of frames starting from the innermost one, and the values of its samples
+type profileStack struct {
+	frames  []profileFrame
+	samples int64
+	nanos   int64
+	objects int64
+}
+
+// NewProfiler returns a profiler that samples every interval, or every DefaultProfileInterval if it's 0
+func NewProfiler(interval time.Duration) *Profiler {
+	if interval <= 0 {
+		interval = DefaultProfileInterval
+	}
+
+	return &Profiler{interval: interval, stacks: map[string]*profileStack{}, calls: map[string]int64{}}
+}
+
+// WithProfiler profiles the vm's programs with the profiler, which starts once the vm is initialized
+func WithProfiler(p *Profiler) Option {
+	return func(vm *VM) {
+		vm.profiler = p
+		p.vm = vm
+	}
+}
+
+// begin starts sampling once the vm is initialized, so objects it creates for itself don't count
+func (p *Profiler) begin() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.start = time.Now()
+	p.last = p.start
+	p.running = true
+	atomic.StoreInt64(&p.next, int64(p.interval))
+	atomic.StoreUint64(&p.objects, 0)
+}
+
+// Stop stops sampling, it's called once the program ends
+func (p *Profiler) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.running {
+		return
+	}
+
+	p.running = false
+	p.duration = time.Since(p.start)
+}
+
+// sample records the thread's stack if the interval passed, it's called before the thread evaluates an instruction
+func (p *Profiler) sample(t *thread, cf *callFrame) {
+	if t.profileCountdown > 0 {
+		t.profileCountdown--
+		return
+	}
+
+	t.profileCountdown = profileCheckInstructions
+	now := time.Now()
+	elapsed := int64(now.Sub(p.start))
+	next := atomic.LoadInt64(&p.next)
+
+	// Only one thread takes the sample that's due
+	if elapsed < next || !atomic.CompareAndSwapInt64(&p.next, next, elapsed+int64(p.interval)) {
+		return
+	}
+
+	frames := []profileFrame{}
+
+	for _, f := range t.evaluatingFrames() {
+		// The top frame is about to evaluate its pc, and callers are evaluating their last instructions
+		pc := f.pc - 1
+
+		if f == cf {
+			pc = f.pc
+		}
+
+		frames = append(frames, profileFrame{function: profileFunctionName(f), file: string(f.instructionSet.filename), line: f.sourceLine(pc)})
+	}
+
+	var key strings.Builder
+
+	for _, f := range frames {
+		fmt.Fprintf(&key, "%s\x00%s\x00%d\x00", f.function, f.file, f.line)
+	}
+
+	objects := atomic.LoadUint64(&p.objects)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.running {
+		return
+	}
+
+	s, ok := p.stacks[key.String()]
+
+	if !ok {
+		s = &profileStack{frames: frames}
+		p.stacks[key.String()] = s
+	}
+
+	s.samples++
+	s.nanos += int64(now.Sub(p.last))
+	s.objects += int64(objects - p.lastObjects)
+	p.last = now
+	p.lastObjects = objects
+}
+
+// countCall counts a call of the receiver's method
+func (p *Profiler) countCall(receiver Object, name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls[methodLabel(receiver, name)]++
+}
+
+// profileFunctionName names what the frame evaluates, like `Foo#bar`, `Foo.bar`, `block in Foo#bar` or `class Foo`
+func profileFunctionName(cf *callFrame) string {
+	switch cf.instructionSet.isType {
+	case bytecode.Block:
+		if cf.ep != nil {
+			return "block in " + profileFunctionName(cf.ep)
+		}
+
+		return "block"
+	case bytecode.MethodDef:
+		return methodLabel(cf.self, cf.instructionSet.name)
+	default:
+		return cf.backtraceName()
+	}
+}
+
+// methodLabel names the receiver's method like `String#upcase`, or `File.delete` if the receiver is a class
+func methodLabel(receiver Object, name string) string {
+	if class, ok := receiver.(*RClass); ok {
+		return class.Name + "." + name
+	}
+
+	return receiver.Class().Name + "#" + name
+}
+
+// sortedStacks returns the sampled stacks in the order of their keys, so profiles are written in the same order
+func (p *Profiler) sortedStacks() []*profileStack {
+	keys := []string{}
+
+	for key := range p.stacks {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	stacks := []*profileStack{}
+
+	for _, key := range keys {
+		stacks = append(stacks, p.stacks[key])
+	}
+
+	return stacks
+}
+
+// WritePprof writes the samples in pprof's format, which `go tool pprof` reads.
+// Samples have the number of samples, wall-clock time and the number of created objects.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e := newPprofEncoder()
+	e.valueType(profileSampleType, "samples", "count")
+	e.valueType(profileSampleType, "wall", "nanoseconds")
+	e.valueType(profileSampleType, "objects", "count")
+	e.valueType(profilePeriodType, "wall", "nanoseconds")
+	e.integer(profilePeriod, int64(p.interval))
+	e.integer(profileTimeNanos, p.start.UnixNano())
+	e.integer(profileDurationNanos, int64(p.duration))
+
+	for _, s := range p.sortedStacks() {
+		e.sample(s.frames, []int64{s.samples, s.nanos, s.objects})
+	}
+
+	return e.writeTo(w)
+}
+
+// profileEntry is a row of the summary
+type profileEntry struct {
+	name    string
+	flat    int64
+	cum     int64
+	objects int64
+	calls   int64
+}
+
+// WriteSummary writes the time and objects of functions and lines, sorted by the time spent in them.
+// Flat time is spent in the function or line itself, and cumulative time includes what it calls.
+// Functions also have the number of calls, including built-in methods', which aren't sampled.
+func (p *Profiler) WriteSummary(w io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	functions := map[string]*profileEntry{}
+	lines := map[string]*profileEntry{}
+	var total, samples, objects int64
+
+	entry := func(entries map[string]*profileEntry, name string) *profileEntry {
+		e, ok := entries[name]
+
+		if !ok {
+			e = &profileEntry{name: name}
+			entries[name] = e
+		}
+
+		return e
+	}
+
+	for _, s := range p.sortedStacks() {
+		total += s.nanos
+		samples += s.samples
+		objects += s.objects
+		seen := map[string]bool{}
+
+		for n, f := range s.frames {
+			function := entry(functions, f.function)
+			line := entry(lines, fmt.Sprintf("%s:%d", f.file, f.line))
+
+			if n == 0 {
+				function.flat += s.nanos
+				function.objects += s.objects
+				line.flat += s.nanos
+				line.objects += s.objects
+			}
+
+			// Recursive calls are in a stack many times, but they only count once for cumulative time
+			if !seen[f.function] {
+				seen[f.function] = true
+				function.cum += s.nanos
+			}
+		}
+	}
+
+	for name, calls := range p.calls {
+		entry(functions, name).calls = calls
+	}
+
+	fmt.Fprintf(w, "Duration: %s, Samples: %d (%s), Objects: %d\n", p.duration.Round(time.Microsecond), samples, time.Duration(total).Round(time.Microsecond), objects)
+	fmt.Fprintf(w, "\n%10s %6s %10s %6s %8s %8s  %s\n", "flat", "flat%", "cum", "cum%", "objects", "calls", "function")
+
+	for _, e := range sortedEntries(functions) {
+		fmt.Fprintf(w, "%10s %6s %10s %6s %8d %8d  %s\n", formatNanos(e.flat), percentage(e.flat, total), formatNanos(e.cum), percentage(e.cum, total), e.objects, e.calls, e.name)
+	}
+
+	fmt.Fprintf(w, "\n%10s %6s %8s  %s\n", "flat", "flat%", "objects", "line")
+
+	for _, e := range sortedEntries(lines) {
+		fmt.Fprintf(w, "%10s %6s %8d  %s\n", formatNanos(e.flat), percentage(e.flat, total), e.objects, e.name)
+	}
+}
+
+// sortedEntries sorts entries by flat time, cumulative time, calls and then names
+func sortedEntries(entries map[string]*profileEntry) []*profileEntry {
+	sorted := []*profileEntry{}
+
+	for _, e := range entries {
+		sorted = append(sorted, e)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+
+		switch {
+		case a.flat != b.flat:
+			return a.flat > b.flat
+		case a.cum != b.cum:
+			return a.cum > b.cum
+		case a.calls != b.calls:
+			return a.calls > b.calls
+		default:
+			return a.name < b.name
+		}
+	})
+
+	return sorted
+}
+
+func formatNanos(nanos int64) string {
+	return fmt.Sprintf("%.2fms", float64(nanos)/float64(time.Millisecond))
+}
+
+func percentage(part, total int64) string {
+	if total == 0 {
+		return "0.0%"
+	}
+
+	return fmt.Sprintf("%.1f%%", float64(part)/float64(total)*100)
+}
@@ -0,0 +1,232 @@
+package vm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goby-lang/goby/compiler"
+)
+
+const profilerTestProgram = `class Counter
+  def self.count(n)
+    c = new
+    n.times do |i|
+      c.add(i)
+    end
+    c
+  end
+
+  def initialize
+    @sum = 0
+  end
+
+  def add(i)
+    @sum = @sum + i
+  end
+end
+
+Counter.count(3000)`
+
+// profile evaluates the program with a profiler, which samples whenever threads check the clock
+func profile(t *testing.T, input string) *Profiler {
+	t.Helper()
+	sets, err := compiler.CompileFileToInstructions("profiler_test.gb", input)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	p := NewProfiler(time.Nanosecond)
+	v := New("./", []string{}, WithProfiler(p))
+	v.ExecInstructions(sets, "profiler_test.gb")
+	p.Stop()
+	return p
+}
+
+func TestProfilerCalls(t *testing.T) {
+	p := profile(t, profilerTestProgram)
+
+	tests := []struct {
+		method   string
+		expected int64
+	}{
+		{"Counter.count", 1},
+		{"Counter.new", 1},
+		{"Integer#times", 1},
+		{"Counter#add", 3000},
+		{"Integer#+", 3000},
+	}
+
+	for i, tt := range tests {
+		if calls := p.calls[tt.method]; calls != tt.expected {
+			t.Fatalf("At case %d expect %s to be called %d times. got: %d", i, tt.method, tt.expected, calls)
+		}
+	}
+}
+
+func TestProfilerSamples(t *testing.T) {
+	p := profile(t, profilerTestProgram)
+	functions := map[string]bool{}
+
+	for _, s := range p.stacks {
+		names := []string{}
+
+		for _, f := range s.frames {
+			if f.file != "profiler_test.gb" || f.line < 1 {
+				t.Fatalf("Expect frames to have the file and lines. got: %v", f)
+			}
+
+			names = append(names, f.function)
+		}
+
+		functions[strings.Join(names, " < ")] = true
+	}
+
+	expected := []string{
+		"Counter#add < block in Counter.count < Counter.count < <main>",
+		"block in Counter.count < Counter.count < <main>",
+	}
+
+	for i, stack := range expected {
+		if !functions[stack] {
+			t.Fatalf("At case %d expect stack %s to be sampled. got: %v", i, stack, functions)
+		}
+	}
+}
+
+func TestProfilerSummary(t *testing.T) {
+	p := profile(t, profilerTestProgram)
+	var out bytes.Buffer
+	p.WriteSummary(&out)
+	summary := out.String()
+
+	expected := []string{
+		"Duration: ",
+		"  function\n",
+		"  line\n",
+		" 3000  Counter#add\n",
+		"  profiler_test.gb:15\n",
+	}
+
+	for i, s := range expected {
+		if !strings.Contains(summary, s) {
+			t.Fatalf("At case %d expect the summary to contain %q. got:\n%s", i, s, summary)
+		}
+	}
+}
+
+// readProtoFields decodes a protocol buffer message's varint and length-delimited fields
+func readProtoFields(t *testing.T, data []byte) (integers map[int][]uint64, messages map[int][][]byte) {
+	t.Helper()
+	integers = map[int][]uint64{}
+	messages = map[int][][]byte{}
+
+	varint := func() uint64 {
+		var v uint64
+
+		for shift := uint(0); ; shift += 7 {
+			if len(data) == 0 {
+				t.Fatal("Expect a complete varint")
+			}
+
+			b := data[0]
+			data = data[1:]
+			v |= uint64(b&0x7f) << shift
+
+			if b < 0x80 {
+				return v
+			}
+		}
+	}
+
+	for len(data) > 0 {
+		key := varint()
+		field := int(key >> 3)
+
+		switch key & 7 {
+		case 0:
+			integers[field] = append(integers[field], varint())
+		case 2:
+			n := varint()
+			messages[field] = append(messages[field], data[:n])
+			data = data[n:]
+		default:
+			t.Fatalf("Unexpected wire type %d", key&7)
+		}
+	}
+
+	return integers, messages
+}
+
+func TestProfilerPprof(t *testing.T) {
+	p := profile(t, profilerTestProgram)
+	var out bytes.Buffer
+
+	if err := p.WritePprof(&out); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	gz, err := gzip.NewReader(&out)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	data, err := ioutil.ReadAll(gz)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	integers, messages := readProtoFields(t, data)
+	stringTable := []string{}
+
+	for _, s := range messages[profileStringTable] {
+		stringTable = append(stringTable, string(s))
+	}
+
+	if stringTable[0] != "" {
+		t.Fatalf("Expect the first string to be empty. got: %q", stringTable[0])
+	}
+
+	sampleTypes := []string{}
+
+	for _, vt := range messages[profileSampleType] {
+		fields, _ := readProtoFields(t, vt)
+		sampleTypes = append(sampleTypes, stringTable[fields[valueTypeType][0]]+"/"+stringTable[fields[valueTypeUnit][0]])
+	}
+
+	if expected := []string{"samples/count", "wall/nanoseconds", "objects/count"}; !reflect.DeepEqual(sampleTypes, expected) {
+		t.Fatalf("Expect sample types %v. got: %v", expected, sampleTypes)
+	}
+
+	if period := integers[profilePeriod]; len(period) != 1 || period[0] != 1 {
+		t.Fatalf("Expect period 1. got: %v", period)
+	}
+
+	functions := map[string]bool{}
+
+	for _, f := range messages[profileFunction] {
+		fields, _ := readProtoFields(t, f)
+		functions[stringTable[fields[functionName][0]]] = true
+
+		if file := stringTable[fields[functionFilename][0]]; file != "profiler_test.gb" {
+			t.Fatalf("Expect functions to be in profiler_test.gb. got: %s", file)
+		}
+	}
+
+	for i, name := range []string{"<main>", "Counter.count", "block in Counter.count", "Counter#add"} {
+		if !functions[name] {
+			t.Fatalf("At case %d expect function %s. got: %v", i, name, functions)
+		}
+	}
+
+	if samples := len(messages[profileSample]); samples != len(p.stacks) {
+		t.Fatalf("Expect %d samples. got: %d", len(p.stacks), samples)
+	}
+}
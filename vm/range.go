@@ -29,6 +29,8 @@ type RangeObject struct {
 }
 
 func (vm *VM) initRangeObject(start, end int) *RangeObject {
+	vm.countObject()
+
 	return &RangeObject{
 		baseObj: &baseObj{class: vm.topLevelClass(rangeClass)},
 		Start:   start,
@@ -224,7 +226,7 @@ func builtInRangeInstanceMethods() []*BuiltInMethodObject {
 		},
 		{
 			// Iterates over the elements of range, passing each in turn to the block.
-			// Returns `nil`.
+			// Returns an enumerator if no block is given.
 			//
 			// ```ruby
 			// sum = 0
@@ -251,18 +253,26 @@ func builtInRangeInstanceMethods() []*BuiltInMethodObject {
 					ran := receiver.(*RangeObject)
 
 					if blockFrame == nil {
-						return t.vm.initErrorObject(InternalError, CantYieldWithoutBlockFormat)
+						return t.vm.rangeEnumerator(ran)
 					}
 
 					if ran.Start <= ran.End {
 						for i := ran.Start; i <= ran.End; i++ {
 							obj := t.vm.initIntegerObject(i)
 							t.builtInMethodYield(blockFrame, obj)
+
+							if t.yieldError != nil {
+								break
+							}
 						}
 					} else {
 						for i := ran.End; i <= ran.Start; i++ {
 							obj := t.vm.initIntegerObject(i)
 							t.builtInMethodYield(blockFrame, obj)
+
+							if t.yieldError != nil {
+								break
+							}
 						}
 					}
 					return ran
@@ -340,6 +350,49 @@ func builtInRangeInstanceMethods() []*BuiltInMethodObject {
 				}
 			},
 		},
+		{
+			// Returns an array of the block's results for each element of the range.
+			// Returns an enumerator if no block is given.
+			//
+			// ```ruby
+			// (1..3).map do |i|
+			//   i * 2
+			// end
+			// # => [2, 4, 6]
+			// ```
+			//
+			// @return [Array]
+			Name: "map",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					ran := receiver.(*RangeObject)
+
+					if blockFrame == nil {
+						return t.vm.rangeEnumerator(ran)
+					}
+
+					start, end := ran.Start, ran.End
+
+					if start > end {
+						start, end = end, start
+					}
+
+					var elements []Object
+
+					for i := start; i <= end; i++ {
+						result := t.builtInMethodYield(blockFrame, t.vm.initIntegerObject(i))
+
+						if t.yieldError != nil {
+							break
+						}
+
+						elements = append(elements, result.Target)
+					}
+
+					return t.vm.initArrayObject(elements)
+				}
+			},
+		},
 		{
 			// Returns the size of the range
 			//
@@ -381,7 +434,7 @@ func builtInRangeInstanceMethods() []*BuiltInMethodObject {
 			//
 			// sum = 0
 			// (-1..5).step(2) do |i|
-			//   sum = sum + 1
+			//   sum = sum + i
 			// end
 			// sum # => 8
 			//
@@ -414,6 +467,10 @@ func builtInRangeInstanceMethods() []*BuiltInMethodObject {
 						for i := ran.Start; i <= ran.End; i += stepValue {
 							obj := t.vm.initIntegerObject(i)
 							t.builtInMethodYield(blockFrame, obj)
+
+							if t.yieldError != nil {
+								break
+							}
 						}
 
 						return ran
@@ -432,7 +489,7 @@ func builtInRangeInstanceMethods() []*BuiltInMethodObject {
 			// ```ruby
 			// (1..5).to_a     # => [1, 2, 3, 4, 5]
 			// (1..5).to_a[2]  # => 3
-			// (-1..-5).to_a   # => [-1, -2, -3, -4, -5]
+			// (-1..-5).to_a   # => [-5, -4, -3, -2, -1]
 			// (-1..3).to_a    # => [-1, 0, 1, 2, 3]
 			// ```
 			//
@@ -441,6 +498,15 @@ func builtInRangeInstanceMethods() []*BuiltInMethodObject {
 			Fn: func(receiver Object) builtinMethodBody {
 				return func(t *thread, args []Object, blockFrame *callFrame) Object {
 					ro := receiver.(*RangeObject)
+					start, end := ro.Start, ro.End
+
+					if start > end {
+						start, end = end, start
+					}
+
+					if err := t.checkObjectSize(end-start+1, 1); err != nil {
+						return err
+					}
 
 					elems := []Object{}
 
@@ -502,3 +502,28 @@ func TestRangeToArrayMethod(t *testing.T) {
 		vm.checkCFP(t, i, 0)
 	}
 }
+
+func TestRangeMapMethod(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`
+		(1..3).map do |i|
+		  i * 2
+		end.to_s
+		`, "[2, 4, 6]"},
+		{`
+		(3..1).map do |i|
+		  i
+		end.to_s
+		`, "[1, 2, 3]"},
+	}
+
+	for i, tt := range tests {
+		vm := initTestVM()
+		evaluated := vm.testEval(t, tt.input)
+		checkExpected(t, i, evaluated, tt.expected)
+		vm.checkCFP(t, i, 0)
+	}
+}
@@ -1,15 +1,15 @@
 package vm
 
-import "github.com/goby-lang/goby/compiler/bytecode"
+import (
+	"errors"
+
+	"github.com/goby-lang/goby/compiler/bytecode"
+)
 
 // InitForREPL does following things:
-// - Initialize instruction sets' index tables
 // - Set vm to REPL mode
 // - Create and push main object frame
 func (vm *VM) InitForREPL() {
-	vm.SetClassISIndexTable("")
-	vm.SetMethodISIndexTable("")
-
 	// REPL should maintain a base call frame so that the whole program won't exit
 	cf := newCallFrame(&instructionSet{name: "REPL base"})
 	cf.self = vm.mainObj
@@ -18,18 +18,14 @@ func (vm *VM) InitForREPL() {
 
 // REPLExec executes instructions differently from normal program execution.
 func (vm *VM) REPLExec(sets []*bytecode.InstructionSet) {
+	if !vm.verifyInstructions(sets) {
+		return
+	}
+
 	p := newInstructionTranslator("")
 	p.vm = vm
 	p.transferInstructionSets(sets)
 
-	for setType, table := range p.setTable {
-		for name, is := range table {
-			vm.isTables[setType][name] = is
-		}
-	}
-
-	vm.blockTables[p.filename] = p.blockTable
-
 	oldFrame := vm.mainThread.callFrameStack.pop()
 	cf := newCallFrame(p.program)
 	cf.self = vm.mainObj
@@ -42,6 +38,27 @@ func (vm *VM) REPLExec(sets []*bytecode.InstructionSet) {
 	vm.startFromTopFrame()
 }
 
+// REPLEval executes instructions like REPLExec without printing errors, and returns the result's inspection,
+// where strings are quoted, or the error it raised. It's for tools like doctests that check results themselves.
+func (vm *VM) REPLEval(sets []*bytecode.InstructionSet) (string, error) {
+	t := vm.mainThread
+	t.silent = true
+	defer func() { t.silent = false }()
+
+	vm.REPLExec(sets)
+	top := t.stack.pop()
+
+	if top == nil {
+		return NULL.toString(), nil
+	}
+
+	if err, ok := top.Target.(*Error); ok {
+		return "", errors.New(err.Message)
+	}
+
+	return inspectObject(top.Target), nil
+}
+
 // GetExecResult returns stack's top most value. Normally it's used in tests.
 func (vm *VM) GetExecResult() Object {
 	top := vm.mainThread.stack.top()
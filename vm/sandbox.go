@@ -0,0 +1,218 @@
+package vm
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Sandbox restricts what scripts evaluated by a vm can do, so hosts can run untrusted scripts.
+// It's given to New with WithSandbox, and zero values mean no restriction.
+//
+// Violations raise errors that stop the script, which hosts can inspect with GetExecResult:
+//
+// * `InstructionLimitError`: evaluating more instructions than MaxInstructions
+// * `TimeLimitError`: running longer than Timeout
+// * `ObjectLimitError`: creating more objects than MaxObjects, or a bigger one than MaxObjectSize
+// * `ForbiddenLibraryError`: requiring a library that's not in AllowedLibraries
+// * `ForbiddenMethodError`: calling a built-in method that's not in AllowedMethods
+//
+// ```go
+// v := vm.New(dir, args, vm.WithSandbox(vm.Sandbox{
+// 	MaxInstructions:  1000000,
+// 	Timeout:          time.Second,
+// 	AllowedLibraries: []string{"uri"},
+// 	AllowedMethods:   []string{"Object#puts", "Integer#*", "String#*", "Array#*"},
+// }))
+// ```
+type Sandbox struct {
+	// MaxInstructions is the number of instructions all threads of the vm can evaluate
+	MaxInstructions int
+	// Timeout is how long the vm can run since it's initialized.
+	// Blocking methods like `sleep` are interrupted once it's passed.
+	Timeout time.Duration
+	// MaxObjects is the number of integers, strings, arrays, hashes, ranges and instances scripts can create.
+	// It's checked between instructions, so a single built-in method call can exceed it.
+	MaxObjects int
+	// MaxObjectSize is how long strings and arrays built from a single size argument can be,
+	// like the results of `String#*`, `String#ljust`, `String#rjust` and `Range#to_a`.
+	// Other objects are only bound by MaxObjects.
+	MaxObjectSize int
+	// AllowedLibraries lists standard libraries `require` can load, nil allows all of them
+	AllowedLibraries []string
+	// AllowedMethods lists built-in methods scripts can call, nil allows all of them.
+	// Methods are named after the class that defines them, like `String#upcase` for instance methods
+	// and `File.delete` for class methods. `String#*` and `File.*` allow all of them in a class.
+	// Top level methods like `puts`, `require` and `import` are Object's instance methods.
+	AllowedMethods []string
+}
+
+// WithSandbox restricts the vm's scripts with the given sandbox.
+func WithSandbox(s Sandbox) Option {
+	return func(vm *VM) {
+		vm.sandbox = &sandbox{Sandbox: s}
+	}
+}
+
+// sandbox is a Sandbox with its usage
+type sandbox struct {
+	Sandbox
+	// ctx is done once the timeout passed, it's nil if there's no timeout
+	ctx    context.Context
+	cancel context.CancelFunc
+	// instructions and objects count evaluated instructions and created objects
+	instructions uint64
+	objects      uint64
+}
+
+// start begins counting usage once the vm is initialized, so objects it creates for itself don't count
+func (s *sandbox) start() {
+	atomic.StoreUint64(&s.objects, 0)
+
+	if s.Timeout > 0 {
+		s.ctx, s.cancel = context.WithTimeout(context.Background(), s.Timeout)
+	}
+}
+
+func (s *sandbox) timedOut() bool {
+	return s.ctx != nil && s.ctx.Err() != nil
+}
+
+// sandboxContext returns a context that's done once ctx is done or the sandbox's time is up,
+// so contexts scripts pass, like `Context.background`, can't outlive the sandbox
+func (vm *VM) sandboxContext(ctx context.Context) context.Context {
+	if vm.sandbox == nil || vm.sandbox.ctx == nil {
+		return ctx
+	}
+
+	merged, cancel := context.WithCancel(ctx)
+
+	// The sandbox's context is done once its timeout passes, so this doesn't leak
+	go func() {
+		select {
+		case <-vm.sandbox.ctx.Done():
+			cancel()
+		case <-merged.Done():
+		}
+	}()
+
+	return merged
+}
+
+// check counts an evaluated instruction and returns the error of the limit it exceeds
+func (s *sandbox) check(vm *VM) *Error {
+	n := atomic.AddUint64(&s.instructions, 1)
+
+	if s.MaxInstructions > 0 && n > uint64(s.MaxInstructions) {
+		return vm.initErrorObject(InstructionLimitError, "Exceeded the limit of %d instructions", s.MaxInstructions)
+	}
+
+	if s.timedOut() {
+		return vm.initTimeLimitError()
+	}
+
+	if s.MaxObjects > 0 && atomic.LoadUint64(&s.objects) > uint64(s.MaxObjects) {
+		return vm.initErrorObject(ObjectLimitError, "Exceeded the limit of %d objects", s.MaxObjects)
+	}
+
+	return nil
+}
+
+func (s *sandbox) allowsLibrary(name string) bool {
+	if s.AllowedLibraries == nil {
+		return true
+	}
+
+	for _, l := range s.AllowedLibraries {
+		if l == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowsMethod reports whether the method, written like `String#upcase` or `File.delete`, is allowed
+func (s *sandbox) allowsMethod(method string) bool {
+	if s.AllowedMethods == nil {
+		return true
+	}
+
+	for _, m := range s.AllowedMethods {
+		if m == method || (strings.HasSuffix(m, "*") && strings.HasPrefix(method, m[:len(m)-1])) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (vm *VM) initTimeLimitError() *Error {
+	return vm.initErrorObject(TimeLimitError, "Exceeded the time limit of %s", vm.sandbox.Timeout)
+}
+
+// countObject counts an object created by the vm's scripts
+func (vm *VM) countObject() {
+	if vm.sandbox != nil {
+		atomic.AddUint64(&vm.sandbox.objects, 1)
+	}
+
+	if vm.profiler != nil {
+		atomic.AddUint64(&vm.profiler.objects, 1)
+	}
+}
+
+// checkObjectSize returns ObjectLimitError if the sandbox doesn't allow creating an object
+// of count elements that are size long each
+func (t *thread) checkObjectSize(count, size int) *Error {
+	s := t.vm.sandbox
+
+	if s == nil || s.MaxObjectSize <= 0 || size <= 0 || count <= s.MaxObjectSize/size {
+		return nil
+	}
+
+	return t.vm.initErrorObject(ObjectLimitError, "Exceeded the limit of %d for an object's size", s.MaxObjectSize)
+}
+
+// checkBuiltInMethod returns ForbiddenMethodError if the sandbox doesn't allow the built-in method
+func (t *thread) checkBuiltInMethod(receiver Object, method *BuiltInMethodObject) *Error {
+	s := t.vm.sandbox
+
+	if s == nil || s.AllowedMethods == nil {
+		return nil
+	}
+
+	name := builtInMethodName(receiver, method)
+
+	if s.allowsMethod(name) {
+		return nil
+	}
+
+	return t.vm.initErrorObject(ForbiddenMethodError, "Method %s is not allowed", name)
+}
+
+// builtInMethodName names the method after the class where the receiver's lookup finds it
+func builtInMethodName(receiver Object, method *BuiltInMethodObject) string {
+	for c := methodLookupClass(receiver); c != nil; c = c.superClass {
+		if m, ok := c.Methods.get(method.Name); ok && m == method {
+			if c.isSingleton {
+				return singletonClassOwner(c.Name) + "." + method.Name
+			}
+
+			return c.Name + "#" + method.Name
+		}
+
+		// Object's super class is itself
+		if c.superClass == c {
+			break
+		}
+	}
+
+	return receiver.Class().Name + "#" + method.Name
+}
+
+// singletonClassOwner returns the name of the class a singleton class belongs to, like `File` for `#<Class:File>`
+func singletonClassOwner(name string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(name, "#<Class:"), ">")
+}
@@ -0,0 +1,216 @@
+package vm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSandboxLimits(t *testing.T) {
+	tests := []struct {
+		input   string
+		sandbox Sandbox
+		errType string
+		errMsg  string
+	}{
+		{`
+		i = 0
+		while true do
+		  i += 1
+		end
+		`, Sandbox{MaxInstructions: 1000},
+			InstructionLimitError, "InstructionLimitError: Exceeded the limit of 1000 instructions"},
+		{`
+		[1, 2, 3].each do |i|
+		  while true do
+		  end
+		end
+		`, Sandbox{MaxInstructions: 1000},
+			InstructionLimitError, "InstructionLimitError: Exceeded the limit of 1000 instructions"},
+		{`
+		while true do
+		end
+		`, Sandbox{Timeout: 50 * time.Millisecond},
+			TimeLimitError, "TimeLimitError: Exceeded the time limit of 50ms"},
+		{`
+		sleep(10)
+		`, Sandbox{Timeout: 50 * time.Millisecond},
+			TimeLimitError, "TimeLimitError: Exceeded the time limit of 50ms"},
+		{`
+		a = []
+		while true do
+		  a.push("foo")
+		end
+		`, Sandbox{MaxObjects: 100},
+			ObjectLimitError, "ObjectLimitError: Exceeded the limit of 100 objects"},
+		{`
+		class Foo
+		end
+
+		while true do
+		  Foo.new
+		end
+		`, Sandbox{MaxObjects: 100},
+			ObjectLimitError, "ObjectLimitError: Exceeded the limit of 100 objects"},
+		{`
+		"foo" * 1000000000000
+		`, Sandbox{MaxObjectSize: 1000},
+			ObjectLimitError, "ObjectLimitError: Exceeded the limit of 1000 for an object's size"},
+		{`
+		"foo".rjust(1000000000000)
+		`, Sandbox{MaxObjectSize: 1000},
+			ObjectLimitError, "ObjectLimitError: Exceeded the limit of 1000 for an object's size"},
+		{`
+		(1000000000000..1).to_a
+		`, Sandbox{MaxObjectSize: 1000},
+			ObjectLimitError, "ObjectLimitError: Exceeded the limit of 1000 for an object's size"},
+	}
+
+	for i, tt := range tests {
+		v := New("./", []string{}, WithSandbox(tt.sandbox))
+		evaluated := v.testEval(t, tt.input)
+		checkError(t, i, evaluated, tt.errType, tt.errMsg)
+	}
+}
+
+func TestSandboxBuiltInLoops(t *testing.T) {
+	tests := []string{`
+		100000000.times do |i|
+		end
+		`, `
+		(1..100000000).each do |i|
+		end
+		`, `
+		(1..100000000).step(2) do |i|
+		end
+		`, `
+		(1..100000000).map do |i|
+		  i
+		end
+		`, `
+		(1..100000).to_a.each do |i|
+		  (1..100000).to_a.map do |j|
+		    j
+		  end
+		end
+		`}
+
+	for i, input := range tests {
+		v := New("./", []string{}, WithSandbox(Sandbox{Timeout: 50 * time.Millisecond}))
+		start := time.Now()
+		evaluated := v.testEval(t, input)
+		checkError(t, i, evaluated, TimeLimitError, "TimeLimitError: Exceeded the time limit of 50ms")
+
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("At case %d expect built-in loops to stop at the time limit. got: %s", i, elapsed)
+		}
+	}
+
+	v := New("./", []string{}, WithSandbox(Sandbox{MaxInstructions: 1000}))
+	start := time.Now()
+	evaluated := v.testEval(t, `
+	100000000.times do |i|
+	end
+	`)
+	checkError(t, 0, evaluated, InstructionLimitError, "InstructionLimitError: Exceeded the limit of 1000 instructions")
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expect built-in loops to stop at the instruction limit. got: %s", elapsed)
+	}
+}
+
+func TestSandboxContexts(t *testing.T) {
+	tests := []string{`
+		sleep(3, Context.background)
+		`, `
+		Channel.new.receive(Context.with_timeout(3))
+		`, `
+		pool = ThreadPool.new(1)
+		pool.submit do
+		  sleep(3)
+		end.value(Context.background)
+		`, `
+		pool = ThreadPool.new(1)
+		pool.submit do
+		  sleep(3)
+		end
+		pool.await(Context.background)
+		`}
+
+	for i, input := range tests {
+		v := New("./", []string{}, WithSandbox(Sandbox{Timeout: 50 * time.Millisecond}))
+		start := time.Now()
+		evaluated := v.testEval(t, input)
+		checkError(t, i, evaluated, TimeLimitError, "TimeLimitError: Exceeded the time limit of 50ms")
+
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("At case %d expect the script to stop at the time limit. got: %s", i, elapsed)
+		}
+	}
+}
+
+func TestSandboxAllowlists(t *testing.T) {
+	tests := []struct {
+		input   string
+		sandbox Sandbox
+		errType string
+		errMsg  string
+	}{
+		{`
+		require("net/http")
+		`, Sandbox{AllowedLibraries: []string{"uri"}},
+			ForbiddenLibraryError, `ForbiddenLibraryError: Library "net/http" is not allowed`},
+		{`
+		require("file")
+		File.delete("foo.txt")
+		`, Sandbox{AllowedMethods: []string{"Object#require", "File#*"}},
+			ForbiddenMethodError, "ForbiddenMethodError: Method File.delete is not allowed"},
+		{`
+		import("github.com/goby-lang/goby/test_fixtures/import_test/plugin")
+		`, Sandbox{AllowedMethods: []string{"Object#puts"}},
+			ForbiddenMethodError, "ForbiddenMethodError: Method Object#import is not allowed"},
+		{`
+		a = 1
+		a + 2
+		`, Sandbox{AllowedMethods: []string{"String#*"}},
+			ForbiddenMethodError, "ForbiddenMethodError: Method Integer#+ is not allowed"},
+		{`
+		[1, 2].each do |i|
+		  "foo".upcase
+		end
+		`, Sandbox{AllowedMethods: []string{"Array#each"}},
+			ForbiddenMethodError, "ForbiddenMethodError: Method String#upcase is not allowed"},
+	}
+
+	for i, tt := range tests {
+		v := New("./", []string{}, WithSandbox(tt.sandbox))
+		evaluated := v.testEval(t, tt.input)
+		checkError(t, i, evaluated, tt.errType, tt.errMsg)
+	}
+}
+
+func TestSandboxAllowedEvaluation(t *testing.T) {
+	input := `
+	require("uri")
+
+	def sum(n)
+	  s = 0
+	  n.times do |i|
+	    s += i
+	  end
+	  s
+	end
+
+	sum(10).to_s.size
+	`
+
+	v := New("./", []string{}, WithSandbox(Sandbox{
+		MaxInstructions:  10000,
+		Timeout:          time.Second,
+		MaxObjects:       1000,
+		AllowedLibraries: []string{"uri"},
+		AllowedMethods:   []string{"Object#require", "Integer#*", "String#size"},
+	}))
+	evaluated := v.testEval(t, input)
+	checkExpected(t, 0, evaluated, 2)
+	v.checkCFP(t, 0, 0)
+}
@@ -0,0 +1,688 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	expectationClass = "Expectation"
+	matcherClass     = "Matcher"
+)
+
+// testSuite has examples defined by `it`, and `describe` blocks being evaluated
+type testSuite struct {
+	root *testGroup
+	// current is the innermost `describe` block being evaluated
+	current  *testGroup
+	examples []*testExample
+	sync.Mutex
+}
+
+// testGroup is a `describe` block and its hooks
+type testGroup struct {
+	name   string
+	parent *testGroup
+	before []*callFrame
+	after  []*callFrame
+}
+
+// testExample is an `it` block
+type testExample struct {
+	group *testGroup
+	name  string
+	block *callFrame
+	file  string
+	line  int
+}
+
+// ExpectationObject is the value or the block given to `expect`, which `to` and `not_to` check with matchers
+type ExpectationObject struct {
+	*baseObj
+	actual Object
+	block  *callFrame
+}
+
+// MatcherObject checks expectations, it's returned by methods like `eq` and `raise_error`
+type MatcherObject struct {
+	*baseObj
+	// description follows `to` in failure messages, like `eq 2`
+	description string
+	// block is set if the matcher checks blocks instead of values
+	block bool
+	// match reports whether the value matches, or what the block raised for block matchers
+	match func(t *thread, e *ExpectationObject) (matched bool, got string, err *Error)
+}
+
+// TestResult is the result of an example
+type TestResult struct {
+	// Groups are names of the `describe` blocks of the example, starting from the outermost one
+	Groups   []string
+	Name     string
+	File     string
+	Line     int
+	Duration time.Duration
+	// Failure is the message of the error that failed the example, which is empty if the example passed
+	Failure     string
+	FailureFile string
+	FailureLine int
+	// Error is set if the failure isn't an ExpectationError
+	Error bool
+}
+
+// FullName returns names of the example's groups and the example
+func (r TestResult) FullName() string {
+	return strings.Join(append(append([]string{}, r.Groups...), r.Name), " ")
+}
+
+// Passed reports whether the example passed
+func (r TestResult) Passed() bool {
+	return r.Failure == ""
+}
+
+// initTestLibrary defines the `test` library, which defines examples with `describe`, `it`, `before` and `after`,
+// and checks them with `expect`. Requiring it only defines examples, `goby test` runs them with RunTests:
+//
+// ```ruby
+// require "test"
+//
+// describe Counter do
+//   before do
+//     @counter = Counter.new
+//   end
+//
+//   it "adds values" do
+//     expect(@counter.add([1, 2])).to eq(3)
+//   end
+//
+//   it "raises errors of invalid values" do
+//     expect do
+//       @counter.add(nil)
+//     end.to raise_error(UndefinedMethodError)
+//   end
+// end
+// ```
+//
+// Each hook and example runs on its own thread, so an error stops only the example that raised it.
+// Failed expectations raise ExpectationError.
+func initTestLibrary(vm *VM) {
+	if vm.tests != nil {
+		return
+	}
+
+	root := &testGroup{}
+	vm.tests = &testSuite{root: root, current: root}
+
+	expectation := vm.initializeClass(expectationClass, false)
+	expectation.setBuiltInMethods(builtinExpectationInstanceMethods(), false)
+	matcher := vm.initializeClass(matcherClass, false)
+	vm.objectClass.setClassConstant(expectation)
+	vm.objectClass.setClassConstant(matcher)
+	vm.objectClass.setBuiltInMethods(builtinTestMethods(), false)
+}
+
+func builtinTestMethods() []*BuiltInMethodObject {
+	return []*BuiltInMethodObject{
+		{
+			// Groups examples and hooks defined in the block, which is evaluated right away.
+			// The name is usually a class or a description.
+			//
+			// ```ruby
+			// describe String do
+			//   describe "#upcase" do
+			//     it "upcases letters" do
+			//       expect("a".upcase).to eq("A")
+			//     end
+			//   end
+			// end
+			// ```
+			//
+			// @param name [Object] A class or a String
+			// @return [Null]
+			Name: "describe",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					name, err := t.testName(args, blockFrame)
+
+					if err != nil {
+						return err
+					}
+
+					s := t.vm.tests
+					s.Lock()
+					group := &testGroup{name: name, parent: s.current}
+					s.current = group
+					s.Unlock()
+
+					t.builtInMethodYield(blockFrame)
+
+					s.Lock()
+					s.current = group.parent
+					s.Unlock()
+
+					return NULL
+				}
+			},
+		},
+		{
+			// Defines an example, whose block runs once the file's examples are run by `goby test`.
+			//
+			// ```ruby
+			// it "adds numbers" do
+			//   expect(1 + 1).to eq(2)
+			// end
+			// ```
+			//
+			// @param name [String] What the example checks
+			// @return [Null]
+			Name: "it",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					name, err := t.testName(args, blockFrame)
+
+					if err != nil {
+						return err
+					}
+
+					example := &testExample{name: name, block: blockFrame}
+
+					if frames := t.evaluatingFrames(); len(frames) > 0 {
+						example.file = string(frames[0].instructionSet.filename)
+						example.line = frames[0].sourceLine(frames[0].pc - 1)
+					}
+
+					s := t.vm.tests
+					s.Lock()
+					defer s.Unlock()
+
+					example.group = s.current
+					s.examples = append(s.examples, example)
+
+					return NULL
+				}
+			},
+		},
+		{
+			// Runs the block before each example of the current `describe` block, including nested ones.
+			// Outer blocks' hooks run first.
+			//
+			// @return [Null]
+			Name: "before",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return t.addTestHook(blockFrame, false)
+				}
+			},
+		},
+		{
+			// Runs the block after each example of the current `describe` block, including nested ones,
+			// even if the example failed. Inner blocks' hooks run first.
+			//
+			// @return [Null]
+			Name: "after",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return t.addTestHook(blockFrame, true)
+				}
+			},
+		},
+		{
+			// Returns an expectation of the value, or of the block for matchers like `raise_error`.
+			// The block is evaluated by the matcher.
+			//
+			// ```ruby
+			// expect(1 + 1).to eq(2)
+			// expect do
+			//   1 + nil
+			// end.to raise_error(UndefinedMethodError)
+			// ```
+			//
+			// @param value [Object]
+			// @return [Expectation]
+			Name: "expect",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					e := &ExpectationObject{baseObj: &baseObj{class: t.vm.topLevelClass(expectationClass)}}
+
+					switch {
+					case len(args) == 1 && blockFrame == nil:
+						e.actual = args[0]
+					case len(args) == 0 && blockFrame != nil:
+						e.block = blockFrame
+					default:
+						return t.vm.initErrorObject(ArgumentError, "Expect a value or a block")
+					}
+
+					return e
+				}
+			},
+		},
+		{
+			// Matches values that are `==` to the expected value.
+			//
+			// @param expected [Object]
+			// @return [Matcher]
+			Name: "eq",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					if len(args) != 1 {
+						return t.vm.initErrorObject(ArgumentError, "Expect 1 argument. got: %d", len(args))
+					}
+
+					expected := args[0]
+
+					return t.vm.initValueMatcher("eq "+inspectObject(expected), func(t *thread, actual Object) (bool, *Error) {
+						result := t.sendMethod(actual, "==", expected)
+
+						if err := errorOf(result); err != nil {
+							return false, err
+						}
+
+						return result == TRUE, nil
+					})
+				}
+			},
+		},
+		{
+			// Matches nil.
+			//
+			// @return [Matcher]
+			Name: "be_nil",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return t.vm.initIdentityMatcher("be nil", NULL)
+				}
+			},
+		},
+		{
+			// Matches true.
+			//
+			// @return [Matcher]
+			Name: "be_true",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return t.vm.initIdentityMatcher("be true", TRUE)
+				}
+			},
+		},
+		{
+			// Matches false.
+			//
+			// @return [Matcher]
+			Name: "be_false",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return t.vm.initIdentityMatcher("be false", FALSE)
+				}
+			},
+		},
+		{
+			// Matches instances of the class or its subclasses.
+			//
+			// ```ruby
+			// expect(1).to be_a(Integer)
+			// ```
+			//
+			// @param class [Class]
+			// @return [Matcher]
+			Name: "be_a",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					if len(args) != 1 {
+						return t.vm.initErrorObject(ArgumentError, "Expect 1 argument. got: %d", len(args))
+					}
+
+					class, ok := args[0].(*RClass)
+
+					if !ok {
+						return t.vm.initErrorObject(TypeError, WrongArgumentTypeFormat, classClass, args[0].Class().Name)
+					}
+
+					return t.vm.initValueMatcher("be a "+class.Name, func(t *thread, actual Object) (bool, *Error) {
+						result := t.sendMethod(actual, "is_a", class)
+
+						if err := errorOf(result); err != nil {
+							return false, err
+						}
+
+						return result == TRUE, nil
+					})
+				}
+			},
+		},
+		{
+			// Matches blocks that raise an error, which can be limited to the error class and the message.
+			//
+			// ```ruby
+			// expect do
+			//   1.foo
+			// end.to raise_error(UndefinedMethodError, "Undefined Method 'foo' for 1")
+			// ```
+			//
+			// @param class [Class] optional
+			// @param message [String] optional, without the class name
+			// @return [Matcher]
+			Name: "raise_error",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return t.raiseErrorMatcher(args)
+				}
+			},
+		},
+	}
+}
+
+func builtinExpectationInstanceMethods() []*BuiltInMethodObject {
+	return []*BuiltInMethodObject{
+		{
+			// Raises ExpectationError unless the matcher matches.
+			//
+			// @param matcher [Matcher]
+			// @return [Boolean] true
+			Name: "to",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return t.checkExpectation(receiver.(*ExpectationObject), args, true)
+				}
+			},
+		},
+		{
+			// Raises ExpectationError if the matcher matches.
+			//
+			// @param matcher [Matcher]
+			// @return [Boolean] true
+			Name: "not_to",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return t.checkExpectation(receiver.(*ExpectationObject), args, false)
+				}
+			},
+		},
+	}
+}
+
+// testName returns the name given to `describe` or `it`, which needs a block
+func (t *thread) testName(args []Object, blockFrame *callFrame) (string, *Error) {
+	if blockFrame == nil {
+		return "", t.vm.initErrorObject(InternalError, CantYieldWithoutBlockFormat)
+	}
+
+	if len(args) != 1 {
+		return "", t.vm.initErrorObject(ArgumentError, "Expect 1 argument. got: %d", len(args))
+	}
+
+	switch name := args[0].(type) {
+	case *StringObject:
+		return name.Value, nil
+	case *RClass:
+		return name.Name, nil
+	default:
+		return "", t.vm.initErrorObject(TypeError, WrongArgumentTypeFormat, "String or Class", name.Class().Name)
+	}
+}
+
+func (t *thread) addTestHook(blockFrame *callFrame, after bool) Object {
+	if blockFrame == nil {
+		return t.vm.initErrorObject(InternalError, CantYieldWithoutBlockFormat)
+	}
+
+	s := t.vm.tests
+	s.Lock()
+	defer s.Unlock()
+
+	if after {
+		s.current.after = append(s.current.after, blockFrame)
+	} else {
+		s.current.before = append(s.current.before, blockFrame)
+	}
+
+	return NULL
+}
+
+func (vm *VM) initValueMatcher(description string, match func(t *thread, actual Object) (bool, *Error)) *MatcherObject {
+	return &MatcherObject{
+		baseObj:     &baseObj{class: vm.topLevelClass(matcherClass)},
+		description: description,
+		match: func(t *thread, e *ExpectationObject) (bool, string, *Error) {
+			matched, err := match(t, e.actual)
+			return matched, "", err
+		},
+	}
+}
+
+// initIdentityMatcher returns a matcher of the object, which is nil, true or false
+func (vm *VM) initIdentityMatcher(description string, obj Object) *MatcherObject {
+	return vm.initValueMatcher(description, func(t *thread, actual Object) (bool, *Error) {
+		return actual == obj, nil
+	})
+}
+
+func (t *thread) raiseErrorMatcher(args []Object) Object {
+	if len(args) > 2 {
+		return t.vm.initErrorObject(ArgumentError, "Expect at most 2 arguments. got: %d", len(args))
+	}
+
+	var class *RClass
+	var message *StringObject
+	description := "raise an error"
+
+	if len(args) > 0 {
+		c, ok := args[0].(*RClass)
+
+		if !ok {
+			return t.vm.initErrorObject(TypeError, WrongArgumentTypeFormat, classClass, args[0].Class().Name)
+		}
+
+		class = c
+		description = "raise " + class.Name
+	}
+
+	if len(args) > 1 {
+		m, ok := args[1].(*StringObject)
+
+		if !ok {
+			return t.vm.initErrorObject(TypeError, WrongArgumentTypeFormat, stringClass, args[1].Class().Name)
+		}
+
+		message = m
+		description += " " + inspectObject(message)
+	}
+
+	return &MatcherObject{
+		baseObj:     &baseObj{class: t.vm.topLevelClass(matcherClass)},
+		description: description,
+		block:       true,
+		match: func(t *thread, e *ExpectationObject) (bool, string, *Error) {
+			err, _, _ := t.vm.runTestBlock(e.block)
+
+			if err == nil {
+				return false, "no error", nil
+			}
+
+			matched := (class == nil || err.class.Name == class.Name) &&
+				(message == nil || strings.TrimPrefix(err.Message, err.class.Name+": ") == message.Value)
+
+			return matched, err.Message, nil
+		},
+	}
+}
+
+// checkExpectation returns true if the matcher's result is positive, otherwise ExpectationError
+func (t *thread) checkExpectation(e *ExpectationObject, args []Object, positive bool) Object {
+	if len(args) != 1 {
+		return t.vm.initErrorObject(ArgumentError, "Expect 1 argument. got: %d", len(args))
+	}
+
+	m, ok := args[0].(*MatcherObject)
+
+	if !ok {
+		return t.vm.initErrorObject(TypeError, WrongArgumentTypeFormat, matcherClass, args[0].Class().Name)
+	}
+
+	switch {
+	case m.block && e.block == nil:
+		return t.vm.initErrorObject(ArgumentError, "Expect a block to %s", m.description)
+	case !m.block && e.block != nil:
+		return t.vm.initErrorObject(ArgumentError, "Expect a value to %s, not a block", m.description)
+	}
+
+	matched, got, err := m.match(t, e)
+
+	if err != nil {
+		return err
+	}
+
+	if matched == positive {
+		return TRUE
+	}
+
+	subject, verb := "the block", "to"
+
+	if e.block == nil {
+		subject = inspectObject(e.actual)
+	}
+
+	if !positive {
+		verb = "not to"
+	}
+
+	if got != "" {
+		return t.vm.initErrorObject(ExpectationError, "Expect %s %s %s. got: %s", subject, verb, m.description, got)
+	}
+
+	return t.vm.initErrorObject(ExpectationError, "Expect %s %s %s", subject, verb, m.description)
+}
+
+// runTestBlock evaluates the block on a new thread, and returns the error it raised and where it raised the error
+func (vm *VM) runTestBlock(block *callFrame) (err *Error, file string, line int) {
+	t := vm.newThread()
+	t.silent = true
+	if err = errorOf(t.yieldBlock(block)); err == nil {
+		return nil, "", 0
+	}
+
+	// Frames are kept on the call frame stack once they stop because of an error
+	for _, cf := range t.evaluatingFrames() {
+		if line = cf.sourceLine(cf.pc - 1); line > 0 {
+			return err, string(cf.instructionSet.filename), line
+		}
+	}
+
+	return err, "", 0
+}
+
+// RunTests runs examples defined with the `test` library in the order they're defined, on at most workers threads at once.
+// Results are in the same order.
+func (vm *VM) RunTests(workers int) []TestResult {
+	if vm.tests == nil {
+		return []TestResult{}
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	s := vm.tests
+	s.Lock()
+	examples := s.examples
+	s.Unlock()
+
+	results := make([]TestResult, len(examples))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+
+	for n := 0; n < workers; n++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				results[i] = vm.runTestExample(examples[i])
+			}
+		}()
+	}
+
+	for i := range examples {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+func (vm *VM) runTestExample(example *testExample) TestResult {
+	result := TestResult{Name: example.name, File: example.file, Line: example.line}
+	groups := []*testGroup{}
+
+	for g := example.group; g.parent != nil; g = g.parent {
+		groups = append([]*testGroup{g}, groups...)
+		result.Groups = append([]string{g.name}, result.Groups...)
+	}
+
+	// The root group has hooks defined outside `describe` blocks
+	groups = append([]*testGroup{vm.tests.root}, groups...)
+	start := time.Now()
+
+	fail := func(err *Error, file string, line int) {
+		if err == nil || result.Failure != "" {
+			return
+		}
+
+		result.Failure = err.Message
+		result.FailureFile = file
+		result.FailureLine = line
+		result.Error = err.class.Name != ExpectationError
+	}
+
+	for _, g := range groups {
+		for _, hook := range g.before {
+			if result.Failure == "" {
+				fail(vm.runTestBlock(hook))
+			}
+		}
+	}
+
+	if result.Failure == "" {
+		fail(vm.runTestBlock(example.block))
+	}
+
+	for n := len(groups) - 1; n >= 0; n-- {
+		for _, hook := range groups[n].after {
+			fail(vm.runTestBlock(hook))
+		}
+	}
+
+	result.Duration = time.Since(start)
+	return result
+}
+
+// Polymorphic helper functions -----------------------------------------
+
+// toString returns the expectation's value.
+func (e *ExpectationObject) toString() string {
+	if e.block != nil {
+		return "#<Expectation of a block>"
+	}
+
+	return fmt.Sprintf("#<Expectation of %s>", inspectObject(e.actual))
+}
+
+// toJSON converts the receiver into JSON string.
+func (e *ExpectationObject) toJSON() string {
+	return e.toString()
+}
+
+// toString returns the matcher's description.
+func (m *MatcherObject) toString() string {
+	return fmt.Sprintf("#<Matcher %s>", m.description)
+}
+
+// toJSON converts the receiver into JSON string.
+func (m *MatcherObject) toJSON() string {
+	return m.toString()
+}
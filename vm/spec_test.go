@@ -0,0 +1,244 @@
+package vm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goby-lang/goby/compiler"
+)
+
+// runTests evaluates the program in spec_test.gb and runs its examples
+func runTests(t *testing.T, input string, workers int) []TestResult {
+	t.Helper()
+	sets, err := compiler.CompileFileToInstructions("spec_test.gb", input)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	v := initTestVM()
+	v.ExecInstructions(sets, "spec_test.gb")
+
+	if err, ok := v.GetExecResult().(*Error); ok {
+		t.Fatal(err.Message)
+	}
+
+	return v.RunTests(workers)
+}
+
+func TestTestLibraryResults(t *testing.T) {
+	input := `require "test"
+
+describe String do
+  describe "#upcase" do
+    it "upcases letters" do
+      expect("a".upcase).to eq("A")
+    end
+
+    it "fails" do
+      expect("a".upcase).to eq("B")
+    end
+  end
+
+  it "raises errors" do
+    "a".foo
+  end
+end
+
+it "has no group" do
+  expect(nil).to be_nil
+end`
+
+	tests := []struct {
+		groups      []string
+		name        string
+		line        int
+		failure     string
+		failureLine int
+		isError     bool
+	}{
+		{[]string{"String", "#upcase"}, "upcases letters", 5, "", 0, false},
+		{[]string{"String", "#upcase"}, "fails", 9, `ExpectationError: Expect "A" to eq "B"`, 10, false},
+		{[]string{"String"}, "raises errors", 14, "UndefinedMethodError: Undefined Method 'foo' for a", 15, true},
+		{nil, "has no group", 19, "", 0, false},
+	}
+
+	for _, workers := range []int{1, 3} {
+		results := runTests(t, input, workers)
+
+		if len(results) != len(tests) {
+			t.Fatalf("Expect %d results. got: %d", len(tests), len(results))
+		}
+
+		for i, tt := range tests {
+			r := results[i]
+
+			if !reflect.DeepEqual(r.Groups, tt.groups) || r.Name != tt.name || r.File != "spec_test.gb" || r.Line != tt.line {
+				t.Fatalf("At case %d expect example %v %q at line %d. got: %v %q at %s:%d", i, tt.groups, tt.name, tt.line, r.Groups, r.Name, r.File, r.Line)
+			}
+
+			if r.Failure != tt.failure || r.FailureLine != tt.failureLine || r.Error != tt.isError {
+				t.Fatalf("At case %d expect failure %q at line %d (error: %t). got: %q at line %d (error: %t)", i, tt.failure, tt.failureLine, tt.isError, r.Failure, r.FailureLine, r.Error)
+			}
+		}
+	}
+}
+
+func TestTestLibraryParallel(t *testing.T) {
+	input := `require "test"
+
+module Shapes
+  class Square
+    def initialize(n)
+      @n = n
+    end
+
+    def area
+      @n * @n
+    end
+  end
+end
+
+describe Shapes::Square do
+  before do
+    100.times do |i|
+      @square = Shapes::Square.new(2)
+    end
+  end
+` + strings.Repeat(`
+  it "has an area" do
+    def side
+      2
+    end
+
+    expect(@square.area).to eq(side * 2)
+    expect(Shapes::Square.new(3).area).to eq(9)
+  end
+`, 20) + `
+end`
+
+	// Examples share objects, classes and constants, so this is run with -race too
+	for i, r := range runTests(t, input, 4) {
+		if r.Failure != "" {
+			t.Fatalf("At case %d expect the example to pass. got: %s", i, r.Failure)
+		}
+	}
+}
+
+func TestTestLibraryHooks(t *testing.T) {
+	input := `require "test"
+
+Log = []
+
+before do
+  Log.push("outer before")
+end
+
+describe "group" do
+  before do
+    Log.push("inner before")
+  end
+
+  after do
+    Log.push("inner after")
+  end
+
+  it "fails" do
+    Log.push("example")
+    expect(1).to eq(2)
+  end
+end
+
+after do
+  Log.push("outer after")
+end`
+
+	sets, err := compiler.CompileFileToInstructions("spec_test.gb", input)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	v := initTestVM()
+	v.ExecInstructions(sets, "spec_test.gb")
+	results := v.RunTests(1)
+
+	if results[0].Failure != "ExpectationError: Expect 1 to eq 2" {
+		t.Fatalf("Expect the example to fail. got: %q", results[0].Failure)
+	}
+
+	// After hooks run even if the example failed
+	expected := `["outer before", "inner before", "example", "inner after", "outer after"]`
+
+	if log := v.testEval(t, "Log").toString(); log != expected {
+		t.Fatalf("Expect hooks to run in order %s. got: %s", expected, log)
+	}
+}
+
+func TestTestLibraryMatchers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`expect(1 + 1).to eq(2)`, ""},
+		{`expect(1).not_to eq(1)`, "ExpectationError: Expect 1 not to eq 1"},
+		{`expect(nil).to be_nil`, ""},
+		{`expect(1).to be_nil`, "ExpectationError: Expect 1 to be nil"},
+		{`expect(1 > 0).to be_true`, ""},
+		{`expect(true).to be_false`, "ExpectationError: Expect true to be false"},
+		{`expect(1).to be_a(Integer)`, ""},
+		{`expect(1).to be_a(Object)`, ""},
+		{`expect("a").to be_a(Integer)`, `ExpectationError: Expect "a" to be a Integer`},
+		{`expect do
+  1 + nil
+end.to raise_error(TypeError)`, ""},
+		{`expect do
+  1.foo
+end.to raise_error(UndefinedMethodError, "Undefined Method 'foo' for 1")`, ""},
+		{`expect do
+  1 + nil
+end.to raise_error(ArgumentError)`, "ExpectationError: Expect the block to raise ArgumentError. got: TypeError: Expect argument to be Integer. got: Null"},
+		{`expect do
+  1
+end.to raise_error`, "ExpectationError: Expect the block to raise an error. got: no error"},
+		{`expect do
+  1
+end.not_to raise_error`, ""},
+		{`expect(1).to raise_error`, "ArgumentError: Expect a block to raise an error"},
+		{`expect do
+  1
+end.to eq(1)`, "ArgumentError: Expect a value to eq 1, not a block"},
+		{`expect(1).to 1`, "TypeError: Expect argument to be Matcher. got: Integer"},
+	}
+
+	for i, tt := range tests {
+		results := runTests(t, "require \"test\"\nit \"x\" do\n"+tt.input+"\nend", 1)
+
+		if results[0].Failure != tt.expected {
+			t.Fatalf("At case %d expect failure %q. got: %q", i, tt.expected, results[0].Failure)
+		}
+	}
+}
+
+func TestTestLibraryArgumentErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`describe 1 do
+end`, "TypeError: Expect argument to be String or Class. got: Integer"},
+		{`it "x"`, "InternalError: Can't yield without a block"},
+		{`describe("a", "b") do
+end`, "ArgumentError: Expect 1 argument. got: 2"},
+	}
+
+	for i, tt := range tests {
+		v := initTestVM()
+		evaluated := v.testEval(t, "require \"test\"\n"+tt.input)
+
+		if err, ok := evaluated.(*Error); !ok || err.Message != tt.expected {
+			t.Fatalf("At case %d expect error %q. got: %s", i, tt.expected, evaluated.toString())
+		}
+	}
+}
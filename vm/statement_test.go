@@ -83,6 +83,75 @@ func TestDefStatement(t *testing.T) {
 	vm.checkCFP(t, 0, 0)
 }
 
+func TestRepeatedDefinitions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// Defining a method in a loop
+		{`
+		i = 0
+		while i < 3 do
+		  def foo
+		    10
+		  end
+		  i += 1
+		end
+
+		foo
+		`, 10},
+		// Executing the same def twice
+		{`
+		def define_bar
+		  def bar
+		    5
+		  end
+		end
+
+		define_bar
+		define_bar
+		bar
+		`, 5},
+		// Reopening a class in a loop
+		{`
+		i = 0
+		while i < 2 do
+		  class Foo
+		    def value
+		      20
+		    end
+		  end
+		  i += 1
+		end
+
+		Foo.new.value
+		`, 20},
+		// Methods with same name in different classes keep their own bodies
+		{`
+		class Foo
+		  def value
+		    1
+		  end
+		end
+
+		class Bar
+		  def value
+		    2
+		  end
+		end
+
+		Foo.new.value * 10 + Bar.new.value
+		`, 12},
+	}
+
+	for i, tt := range tests {
+		vm := initTestVM()
+		evaluated := vm.testEval(t, tt.input)
+		checkExpected(t, i, evaluated, tt.expected)
+		vm.checkCFP(t, i, 0)
+	}
+}
+
 func TestModuleStatement(t *testing.T) {
 	tests := []struct {
 		input    string
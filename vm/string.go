@@ -31,6 +31,8 @@ type StringObject struct {
 }
 
 func (vm *VM) initStringObject(value string) *StringObject {
+	vm.countObject()
+
 	replacer := strings.NewReplacer("\\n", "\n", "\\r", "\r", "\\t", "\t", "\\v", "\v", "\\f", "\f", "\\\\", "\\")
 	return &StringObject{
 		baseObj: &baseObj{class: vm.topLevelClass(stringClass)},
@@ -90,7 +92,7 @@ func builtinStringInstanceMethods() []*BuiltInMethodObject {
 			// Returns self multiplying another Integer
 			//
 			// ```ruby
-			// "string " * 2 # => "string string string "
+			// "string " * 2 # => "string string "
 			// ```
 			//
 			// @return [String]
@@ -110,6 +112,10 @@ func builtinStringInstanceMethods() []*BuiltInMethodObject {
 						return t.vm.initErrorObject(ArgumentError, "Second argument must be greater than or equal to 0. got=%v", right.Value)
 					}
 
+					if err := t.checkObjectSize(right.Value, len(leftValue)); err != nil {
+						return err
+					}
+
 					var result string
 
 					for i := 0; i < right.Value; i++ {
@@ -491,6 +497,9 @@ func builtinStringInstanceMethods() []*BuiltInMethodObject {
 			// ```ruby
 			// "Hello hello HeLlo".delete("el")        # => "Hlo hlo HeLlo"
 			// "Hello 😊 Hello 😊 Hello".delete("😊") # => "Hello  Hello  Hello"
+			// ```
+			//
+			// ```ruby nodoctest
 			// # TODO: Handle delete intersection of multiple strings' input case
 			// "Hello hello HeLlo".delete("el", "e") # => "Hllo hllo HLlo"
 			// ```
@@ -712,8 +721,8 @@ func builtinStringInstanceMethods() []*BuiltInMethodObject {
 			// "Hello".insert(0, "X") # => "XHello"
 			// "Hello".insert(2, "X") # => "HeXllo"
 			// "Hello".insert(5, "X") # => "HelloX"
-			// "Hello".insert(-1, "X") # => "HelloX"
-			// "Hello".insert(-3, "X") # => "HelXlo"
+			// "Hello".insert(-1, "X") # => "HellXo"
+			// "Hello".insert(-3, "X") # => "HeXllo"
 			// ```
 			//
 			// @return [String]
@@ -815,6 +824,10 @@ func builtinStringInstanceMethods() []*BuiltInMethodObject {
 
 					strLengthValue := strLength.Value
 
+					if err := t.checkObjectSize(strLengthValue, 1); err != nil {
+						return err
+					}
+
 					var padStrValue string
 					if len(args) == 1 {
 						padStrValue = " "
@@ -849,8 +862,8 @@ func builtinStringInstanceMethods() []*BuiltInMethodObject {
 			//
 			// ```ruby
 			// "Hello".replace("World")          # => "World"
-			// "你好"replace("再見")              # => "再見"
-			// "Ruby\nLang".replace("Goby\nLang") # => "Goby Lang"
+			// "你好".replace("再見")              # => "再見"
+			// "Ruby\nLang".replace("Goby\nLang") # => "Goby\nLang"
 			// "Hello😊".replace("World🐟")      # => "World🐟"
 			// ```
 			//
@@ -932,6 +945,10 @@ func builtinStringInstanceMethods() []*BuiltInMethodObject {
 
 					strLengthValue := strLength.Value
 
+					if err := t.checkObjectSize(strLengthValue, 1); err != nil {
+						return err
+					}
+
 					var padStrValue string
 					if len(args) == 1 {
 						padStrValue = " "
@@ -1012,7 +1029,7 @@ func builtinStringInstanceMethods() []*BuiltInMethodObject {
 			// "Hello 😊🐟 World".slice(1..-1)   # => "ello 😊🐟 World"
 			// "Hello 😊🐟 World".slice(-12..-5) # => "llo 😊🐟 W"
 			// "Hello World".slice(4)       # => "o"
-			// "Hello\nWorld".slice(6)      # => "\n"
+			// "Hello\nWorld".slice(5)      # => "\n"
 			// "Hello World".slice(-3)      # => "r"
 			// "Hello World".slice(-11)     # => "H"
 			// "Hello World".slice(-12)     # => nil
@@ -1100,7 +1117,7 @@ func builtinStringInstanceMethods() []*BuiltInMethodObject {
 			// ```ruby
 			// "Hello World".split("o") # => ["Hell", " W", "rld"]
 			// "Goby".split("")         # => ["G", "o", "b", "y"]
-			// "Hello\nWorld\nGoby".split("o") # => ["Hello", "World", "Goby"]
+			// "Hello\nWorld\nGoby".split("\n") # => ["Hello", "World", "Goby"]
 			// "Hello🐟World🐟Goby".split("🐟") # => ["Hello", "World", "Goby"]
 			// ```
 			//
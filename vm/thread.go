@@ -1,9 +1,8 @@
 package vm
 
 import (
+	"context"
 	"fmt"
-	"github.com/goby-lang/goby/compiler/bytecode"
-	"strings"
 )
 
 type thread struct {
@@ -15,67 +14,47 @@ type thread struct {
 	stack *stack
 	// stack pointer
 	sp int
+	// ctx stops the thread once it's done, it's nil for threads that can't be cancelled
+	ctx context.Context
+	// fiber is the fiber running on this thread, it's nil for normal threads
+	fiber *fiber
+	// yieldError is the error raised by a block the current built-in method yielded to
+	yieldError *Error
+	// maxCallDepth limits how many frames the call frame stack can hold, 0 means no limit
+	maxCallDepth int
+	// profileCountdown counts instructions until the thread checks if the profiler is due for a sample
+	profileCountdown int
+	// silent threads don't print their errors, since their callers report them, like examples of the test library
+	silent bool
 
 	vm *VM
 }
 
-func (t *thread) getBlock(name string, filename filename) *instructionSet {
-	return t.vm.getBlock(name, filename)
-}
-
-func (t *thread) getMethodIS(name string, filename filename) (*instructionSet, bool) {
-	return t.vm.getMethodIS(name, filename)
-}
-
-func (t *thread) getClassIS(name string, filename filename) *instructionSet {
-	return t.vm.getClassIS(name, filename)
-}
-
 func (t *thread) startFromTopFrame() {
 	cf := t.callFrameStack.top()
 	t.evalCallFrame(cf)
 }
 
-func (t *thread) evalCallFrame(cf *callFrame) {
-	for cf.pc < len(cf.instructionSet.instructions) {
-		i := cf.instructionSet.instructions[cf.pc]
-		t.execInstruction(cf, i)
-		if msg, yes := t.hasError(); yes {
-			fmt.Println(msg)
-			return
-		}
-	}
+func (t *thread) isCancelled() bool {
+	return t.ctx != nil && t.ctx.Err() != nil
 }
 
-func (t *thread) hasError() (string, bool) {
-	var hasError bool
-	var msg string
+func (t *thread) hasError() (*Error, bool) {
 	if t.stack.top() != nil {
-		if err, ok := t.stack.top().Target.(*Error); ok {
-			hasError = true
-			msg = err.Message
+		if err, ok := t.stack.top().Target.(*Error); ok && !err.rescued {
+			return err, true
 		}
 	}
-	return msg, hasError
-}
-
-func (t *thread) execInstruction(cf *callFrame, i *instruction) {
-	cf.pc++
-
-	defer func() {
-		if p := recover(); p != nil {
-			if t.vm.stackTraceCount == 0 {
-				fmt.Printf("Internal Error: %s\n", p)
-			}
-			t.vm.stackTraceCount++
-			panic(p)
-		}
-	}()
 
-	i.action.operation(t, cf, i.Params...)
+	return nil, false
 }
 
 func (t *thread) builtInMethodYield(blockFrame *callFrame, args ...Object) *Pointer {
+	// Once a block raised an error, the rest of the built-in method's yields are skipped
+	if t.yieldError != nil {
+		return &Pointer{Target: t.yieldError}
+	}
+
 	c := newCallFrame(blockFrame.instructionSet)
 	c.blockFrame = blockFrame
 	c.ep = blockFrame.ep
@@ -85,91 +64,305 @@ func (t *thread) builtInMethodYield(blockFrame *callFrame, args ...Object) *Poin
 		c.insertLCL(i, 0, args[i])
 	}
 
+	t.evalFrame(c)
+
+	// An empty block on a new thread leaves nothing on the stack
+	if err, ok := t.hasError(); ok {
+		t.yieldError = err
+	}
+
+	return t.stack.top()
+}
+
+// detachBlockFrame pops the block's frame for built-in methods that evaluate their blocks on other threads or later.
+// We need to pop it from this thread manually, because the block's 'leave' instruction isn't running on this thread.
+func (t *thread) detachBlockFrame() {
+	t.callFrameStack.pop()
+}
+
+// evalFrame pushes the frame and evaluates it. The frame is released after it leaves,
+// but if it stops because of an error, it's kept on the call frame stack.
+func (t *thread) evalFrame(c *callFrame) {
+	cfp := t.cfp
+
+	if t.maxCallDepth > 0 && cfp >= t.maxCallDepth {
+		t.stack.push(&Pointer{Target: t.stackOverflowError()})
+		releaseCallFrame(c)
+		return
+	}
+
 	t.callFrameStack.push(c)
 	t.startFromTopFrame()
 
-	return t.stack.top()
+	if t.cfp <= cfp {
+		releaseCallFrame(c)
+	}
 }
 
-func (t *thread) retrieveBlock(cf *callFrame, args []interface{}) (blockFrame *callFrame) {
-	var blockName string
-	var hasBlock bool
+// backtraceLength is the number of innermost and outermost frames StackOverflowError's backtrace keeps
+const backtraceLength = 10
 
-	if len(args) > 2 {
-		hasBlock = true
-		blockFlag := args[2].(string)
-		blockName = strings.Split(blockFlag, ":")[1]
-	} else {
-		hasBlock = false
+func (t *thread) stackOverflowError() *Error {
+	err := t.vm.initErrorObject(StackOverflowError, "Stack level too deep. Max call depth is %d", t.maxCallDepth)
+	frames := []string{}
+
+	for n := t.cfp - 1; n >= 0; n-- {
+		cf := t.callFrameStack.callFrames[n]
+
+		// Frames of blocks given to calls don't run until they're yielded
+		if !cf.isBlock {
+			frames = append(frames, cf.backtraceName())
+		}
 	}
 
-	if hasBlock {
-		block := t.getBlock(blockName, cf.instructionSet.filename)
+	if len(frames) > backtraceLength*2 {
+		omitted := fmt.Sprintf("... %d levels ...", len(frames)-backtraceLength*2)
+		frames = append(append(frames[:backtraceLength:backtraceLength], omitted), frames[len(frames)-backtraceLength:]...)
+	}
+
+	err.Backtrace = frames
+
+	return err
+}
 
-		c := newCallFrame(block)
-		c.isBlock = true
-		c.ep = cf
-		c.self = cf.self
+// evaluatingFrames returns the frames being evaluated, starting from the innermost one.
+// Frames of blocks given to calls are skipped, since they don't run until they're yielded.
+func (t *thread) evaluatingFrames() []*callFrame {
+	frames := []*callFrame{}
 
-		t.callFrameStack.push(c)
+	for n := t.cfp - 1; n >= 0; n-- {
+		cf := t.callFrameStack.callFrames[n]
 
-		blockFrame = c
+		if !cf.isBlock {
+			frames = append(frames, cf)
+		}
 	}
 
-	return
+	return frames
+}
+
+// retrieveBlock pushes a frame of the block given to a method call.
+// It returns nil if no block is given.
+func (t *thread) retrieveBlock(cf *callFrame, block *instructionSet) (blockFrame *callFrame) {
+	if block == nil {
+		return
+	}
+
+	c := newCallFrame(block)
+	c.isBlock = true
+	c.ep = cf
+	c.self = cf.self
+	cf.captured = true
+
+	t.callFrameStack.push(c)
+
+	return c
 }
 
 func (t *thread) evalBuiltInMethod(receiver Object, method *BuiltInMethodObject, receiverPr, argCount, argPr int, blockFrame *callFrame) {
+	evaluated := t.callBuiltInMethod(receiver, method, argCount, argPr, blockFrame)
+
+	if instance := initializedInstance(receiver, method, evaluated); instance != nil {
+		t.evalMethodObject(instance, instance.InitializeMethod, receiverPr, argCount, argPr, blockFrame)
+
+		if err, ok := t.hasError(); ok {
+			evaluated = err
+		}
+	}
+
+	t.setResult(receiverPr, evaluated)
+}
+
+// callBuiltInMethod calls the built-in method with the arguments from argPr and returns its result.
+func (t *thread) callBuiltInMethod(receiver Object, method *BuiltInMethodObject, argCount, argPr int, blockFrame *callFrame) Object {
+	if err := t.checkBuiltInMethod(receiver, method); err != nil {
+		return err
+	}
+
 	methodBody := method.Fn(receiver)
-	args := []Object{}
+	args := make([]Object, argCount)
 
 	for i := 0; i < argCount; i++ {
-		args = append(args, t.stack.Data[argPr+i].Target)
+		args[i] = t.stack.Data[argPr+i].Target
 	}
 
+	outerYieldError := t.yieldError
+	t.yieldError = nil
 	evaluated := methodBody(t, args, blockFrame)
 
-	_, ok := receiver.(*RClass)
-	if method.Name == "new" && ok {
-		instance, ok := evaluated.(*RObject)
-		if ok && instance.InitializeMethod != nil {
-			t.evalMethodObject(instance, instance.InitializeMethod, receiverPr, argCount, argPr, blockFrame)
-		}
+	// Errors raised by the given block stop the method, like errors raised by the method itself
+	if t.yieldError != nil {
+		evaluated = t.yieldError
 	}
-	t.stack.Data[receiverPr] = &Pointer{Target: evaluated}
-	t.sp = receiverPr + 1
+
+	t.yieldError = outerYieldError
+
+	// Methods that don't yield to their blocks would leave the block frames on the call frame stack.
+	// Like other frames, they're kept if the method raised an error.
+	if blockFrame != nil && errorOf(evaluated) == nil {
+		t.popBlockFrame(blockFrame)
+	}
+
+	return evaluated
+}
+
+// initializedInstance returns the instance created by `new` if it needs to be initialized by its `initialize` method.
+func initializedInstance(receiver Object, method *BuiltInMethodObject, evaluated Object) *RObject {
+	if _, ok := receiver.(*RClass); !ok || method.Name != "new" {
+		return nil
+	}
+
+	if instance, ok := evaluated.(*RObject); ok && instance.InitializeMethod != nil {
+		return instance
+	}
+
+	return nil
+}
+
+// setResult replaces a call's receiver and arguments from receiverPr with its result.
+func (t *thread) setResult(receiverPr int, result Object) {
+	t.sp = receiverPr
+	t.stack.push(&Pointer{Target: result})
 }
 
 func (t *thread) evalMethodObject(receiver Object, method *MethodObject, receiverPr, argC, argPr int, blockFrame *callFrame) {
-	var normalArgCount int
+	c, err := t.newMethodFrame(receiver, method, argC, argPr, blockFrame)
+
+	if err != nil {
+		t.setResult(receiverPr, err)
+		return
+	}
+
+	t.evalFrame(c)
+
+	t.stack.Data[receiverPr] = t.stack.top()
+	t.sp = receiverPr + 1
+}
+
+// newMethodFrame returns a frame that evaluates the method with the arguments from argPr.
+// It returns ArgumentError if the number of arguments doesn't match the method's parameters.
+func (t *thread) newMethodFrame(receiver Object, method *MethodObject, argC, argPr int, blockFrame *callFrame) (*callFrame, *Error) {
+	normalArgCount := method.normalArgCount()
+
+	if argC < normalArgCount {
+		return nil, t.vm.initErrorObject(ArgumentError, "Expect at least %d args for method '%s'. got: %d", normalArgCount, method.Name, argC)
+	}
+
+	if argC > method.argc {
+		return nil, t.vm.initErrorObject(ArgumentError, "Expect at most %d args for method '%s'. got: %d", method.argc, method.Name, argC)
+	}
 
 	c := newCallFrame(method.instructionSet)
 	c.self = receiver
 
-	for _, at := range method.instructionSet.argTypes {
-		if at == bytecode.NormalArg {
-			normalArgCount++
-		}
+	for i := 0; i < argC; i++ {
+		c.insertLCL(i, 0, t.stack.Data[argPr+i].Target)
 	}
 
-	if argC < normalArgCount {
-		e := t.vm.initErrorObject(ArgumentError, "Expect at least %d args for method '%s'. got: %d", normalArgCount, method.Name, argC)
-		t.stack.push(&Pointer{Target: e})
-	} else if argC > method.argc {
-		e := t.vm.initErrorObject(ArgumentError, "Expect at most %d args for method '%s'. got: %d", method.argc, method.Name, argC)
-		t.stack.push(&Pointer{Target: e})
-	} else {
-		for i := 0; i < argC; i++ {
-			c.insertLCL(i, 0, t.stack.Data[argPr+i].Target)
-		}
+	c.blockFrame = blockFrame
+	c.stackBase = t.sp
 
-		c.blockFrame = blockFrame
-		t.callFrameStack.push(c)
-		t.startFromTopFrame()
+	return c, nil
+}
+
+// enterFrame pushes the frame called by the caller's instruction, so the loop evaluates it next.
+// Once it leaves, its result replaces the caller's values from resultPr.
+// If the call frame stack is full, the frame isn't pushed and StackOverflowError becomes the result.
+func (t *thread) enterFrame(caller, c *callFrame, resultPr int) *callFrame {
+	if t.maxCallDepth > 0 && t.cfp >= t.maxCallDepth {
+		releaseCallFrame(c)
+		t.setResult(resultPr, t.stackOverflowError())
+		return caller
 	}
 
-	t.stack.Data[receiverPr] = t.stack.top()
-	t.sp = receiverPr + 1
+	c.caller = caller
+	c.resultPr = resultPr
+	t.callFrameStack.push(c)
+
+	return c
+}
+
+// returnToCaller moves the result of the frame that left to its caller's stack, and returns the caller.
+func (t *thread) returnToCaller(c *callFrame) *callFrame {
+	caller := c.caller
+	result := c.result
+
+	if result == nil {
+		result = t.stack.top().Target
+	}
+
+	t.setResult(c.resultPr, result)
+
+	// Captured frames aren't released, so they shouldn't keep their callers either
+	c.caller = nil
+	c.result = nil
+	releaseCallFrame(c)
+
+	return caller
+}
+
+// tailCall evaluates the method in the caller's frame instead of pushing a new one, so tail recursion
+// uses constant frames. It reports false if the frame can't be reused, and the call should be a normal one.
+func (t *thread) tailCall(cf *callFrame, receiver Object, method *MethodObject, argC, argPr int) bool {
+	// Blocks can still use a captured frame's locals, and errors need to be raised by a new frame
+	if cf.captured || t.callFrameStack.top() != cf || argC < method.normalArgCount() || argC > method.argc {
+		return false
+	}
+
+	for i := range cf.locals {
+		cf.locals[i] = nil
+	}
+
+	cf.locals = cf.locals[:0]
+	cf.lPr = 0
+	cf.growLocals(method.instructionSet.localCount)
+
+	for i := 0; i < argC; i++ {
+		cf.insertLCL(i, 0, t.stack.Data[argPr+i].Target)
+	}
+
+	cf.instructionSet = method.instructionSet
+	cf.self = receiver
+	cf.blockFrame = nil
+	cf.pc = 0
+	// Values left by the caller's instructions aren't used anymore
+	t.sp = cf.stackBase
+
+	return true
+}
+
+// sendMethod calls the receiver's method with given arguments and returns the result.
+func (t *thread) sendMethod(receiver Object, methodName string, args ...Object) Object {
+	method := receiver.findMethod(methodName)
+
+	if method == nil {
+		return t.vm.initErrorObject(UndefinedMethodError, "Undefined Method '%+v' for %+v", methodName, receiver.toString())
+	}
+
+	receiverPr := t.sp
+	t.stack.push(&Pointer{Target: receiver})
+	argPr := t.sp
+
+	for _, arg := range args {
+		t.stack.push(&Pointer{Target: arg})
+	}
+
+	switch m := method.(type) {
+	case *MethodObject:
+		t.evalMethodObject(receiver, m, receiverPr, len(args), argPr, nil)
+	case *BuiltInMethodObject:
+		t.evalBuiltInMethod(receiver, m, receiverPr, len(args), argPr, nil)
+	default:
+		return t.vm.initErrorObject(InternalError, "Can't call %s on %s", methodName, receiver.toString())
+	}
+
+	result := t.stack.top()
+
+	if result == nil {
+		return NULL
+	}
+
+	return result.Target
 }
 
 func (t *thread) returnError(errorType, format string, args ...interface{}) {
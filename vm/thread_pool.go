@@ -0,0 +1,432 @@
+package vm
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+const (
+	threadPoolClass = "ThreadPool"
+	futureClass     = "Future"
+)
+
+// ThreadPoolObject represents a pool with a fixed number of workers.
+// Every submitted block is executed on a new goby thread, but no more than `size` blocks run at the same time.
+//
+// ```ruby
+// pool = ThreadPool.new(4)
+//
+// futures = [1, 2, 3].map do |i|
+//   pool.submit(i) do |n|
+//     n * 10
+//   end
+// end
+//
+// pool.shutdown
+// pool.await
+//
+// futures.map do |f|
+//   f.value
+// end # => [10, 20, 30]
+// ```
+type ThreadPoolObject struct {
+	*baseObj
+	size int
+	// slots holds a value for each running block, so submitting blocks when it's full
+	slots      chan struct{}
+	quit       chan struct{}
+	pending    sync.WaitGroup
+	isShutdown bool
+	sync.RWMutex
+}
+
+// FutureObject represents the result of a block submitted to a thread pool.
+// Calling `value` blocks the current thread until the result is ready.
+type FutureObject struct {
+	*baseObj
+	done   chan struct{}
+	result Object
+}
+
+func (vm *VM) initThreadPoolClass() *RClass {
+	class := vm.initializeClass(threadPoolClass, false)
+	class.setBuiltInMethods(builtinThreadPoolClassMethods(), true)
+	class.setBuiltInMethods(builtinThreadPoolInstanceMethods(), false)
+	return class
+}
+
+func (vm *VM) initFutureClass() *RClass {
+	class := vm.initializeClass(futureClass, false)
+	class.setBuiltInMethods(builtinFutureClassMethods(), true)
+	class.setBuiltInMethods(builtinFutureInstanceMethods(), false)
+	return class
+}
+
+func (vm *VM) initThreadPoolObject(size int) *ThreadPoolObject {
+	return &ThreadPoolObject{
+		baseObj: &baseObj{class: vm.topLevelClass(threadPoolClass)},
+		size:    size,
+		slots:   make(chan struct{}, size),
+		quit:    make(chan struct{}),
+	}
+}
+
+func (vm *VM) initFutureObject() *FutureObject {
+	return &FutureObject{
+		baseObj: &baseObj{class: vm.topLevelClass(futureClass)},
+		done:    make(chan struct{}),
+	}
+}
+
+func builtinThreadPoolClassMethods() []*BuiltInMethodObject {
+	return []*BuiltInMethodObject{
+		{
+			// Creates a thread pool with given number of workers.
+			// The number of workers defaults to the number of CPUs.
+			//
+			// ```ruby
+			// ThreadPool.new(4)
+			// ```
+			//
+			// @param workers [Integer]
+			// @return [ThreadPool]
+			Name: "new",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					if len(args) > 1 {
+						return t.vm.initErrorObject(ArgumentError, "Expect 0..1 argument. got: %d", len(args))
+					}
+
+					workers, err := t.vm.workerCount(args)
+
+					if err != nil {
+						return err
+					}
+
+					return t.vm.initThreadPoolObject(workers)
+				}
+			},
+		},
+	}
+}
+
+func builtinThreadPoolInstanceMethods() []*BuiltInMethodObject {
+	return []*BuiltInMethodObject{
+		{
+			// Waits until every submitted block finishes.
+			//
+			// @param ctx [Context] Optional
+			// @return [ThreadPool]
+			Name: "await",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					p := receiver.(*ThreadPoolObject)
+					ctx, _ := t.extractContext(args, 0)
+					done := make(chan struct{})
+
+					// The goroutine ends with the blocks even if the wait is cancelled
+					go func() {
+						p.pending.Wait()
+						close(done)
+					}()
+
+					select {
+					case <-done:
+						return p
+					case <-ctx.Done():
+						return t.vm.initCancelledError(ctx)
+					}
+				}
+			},
+		},
+		{
+			// Returns true if the pool has been shut down.
+			//
+			// @return [Boolean]
+			Name: "is_shutdown",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					p := receiver.(*ThreadPoolObject)
+					p.RLock()
+					defer p.RUnlock()
+
+					if p.isShutdown {
+						return TRUE
+					}
+
+					return FALSE
+				}
+			},
+		},
+		{
+			// Stops the pool from accepting new blocks. Blocks already submitted will still be executed.
+			//
+			// @return [ThreadPool]
+			Name: "shutdown",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					p := receiver.(*ThreadPoolObject)
+					p.Lock()
+					defer p.Unlock()
+
+					if !p.isShutdown {
+						p.isShutdown = true
+						close(p.quit)
+					}
+
+					return p
+				}
+			},
+		},
+		{
+			// Returns the number of workers.
+			//
+			// @return [Integer]
+			Name: "size",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return t.vm.initIntegerObject(receiver.(*ThreadPoolObject).size)
+				}
+			},
+		},
+		{
+			// Schedules the given block with given arguments and returns a Future of its result.
+			// It blocks when all workers are busy.
+			//
+			// ```ruby
+			// pool = ThreadPool.new(2)
+			// f = pool.submit(10) do |n|
+			//   n * 2
+			// end
+			// f.value # => 20
+			// ```
+			//
+			// @param *args [Object] Arguments passed to the block
+			// @return [Future]
+			Name: "submit",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					p := receiver.(*ThreadPoolObject)
+
+					if blockFrame == nil {
+						return t.vm.initErrorObject(InternalError, CantYieldWithoutBlockFormat)
+					}
+
+					t.detachBlockFrame()
+
+					p.RLock()
+					isShutdown := p.isShutdown
+					p.RUnlock()
+
+					if isShutdown {
+						return t.vm.initErrorObject(InternalError, "Can't submit to a thread pool that is shut down")
+					}
+
+					ctx := t.currentContext()
+
+					// Waiting for a free slot doesn't hold the lock, so the pool can be shut down meanwhile
+					select {
+					case p.slots <- struct{}{}:
+					case <-p.quit:
+						return t.vm.initErrorObject(InternalError, "Can't submit to a thread pool that is shut down")
+					case <-ctx.Done():
+						return t.vm.initCancelledError(ctx)
+					}
+
+					f := t.vm.initFutureObject()
+					p.pending.Add(1)
+
+					go func() {
+						defer func() {
+							<-p.slots
+							p.pending.Done()
+						}()
+
+						f.result = t.vm.newThread().yieldBlock(blockFrame, args...)
+						close(f.done)
+					}()
+
+					return f
+				}
+			},
+		},
+	}
+}
+
+func builtinFutureClassMethods() []*BuiltInMethodObject {
+	return []*BuiltInMethodObject{
+		{
+			Name: "new",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return t.UnsupportedMethodError("#new", receiver)
+				}
+			},
+		},
+	}
+}
+
+func builtinFutureInstanceMethods() []*BuiltInMethodObject {
+	return []*BuiltInMethodObject{
+		{
+			// Returns true if the result is ready.
+			//
+			// @return [Boolean]
+			Name: "is_done",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					select {
+					case <-receiver.(*FutureObject).done:
+						return TRUE
+					default:
+						return FALSE
+					}
+				}
+			},
+		},
+		{
+			// Waits for the block to finish and returns its result.
+			// If the block failed, the error is returned.
+			//
+			// @param ctx [Context] Optional
+			// @return [Object]
+			Name: "value",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					f := receiver.(*FutureObject)
+					ctx, _ := t.extractContext(args, 0)
+
+					select {
+					case <-f.done:
+						return f.result
+					case <-ctx.Done():
+						return t.vm.initCancelledError(ctx)
+					}
+				}
+			},
+		},
+	}
+}
+
+// workerCount extracts the number of workers from arguments like `(4)` or `({ workers: 4 })`.
+func (vm *VM) workerCount(args []Object) (int, *Error) {
+	if len(args) == 0 {
+		return runtime.NumCPU(), nil
+	}
+
+	arg := args[0]
+
+	if h, ok := arg.(*HashObject); ok {
+		arg, ok = h.Pairs["workers"]
+
+		if !ok {
+			return 0, vm.initErrorObject(ArgumentError, "Expect hash to have key 'workers'")
+		}
+	}
+
+	n, ok := arg.(*IntegerObject)
+
+	if !ok {
+		return 0, vm.initErrorObject(TypeError, WrongArgumentTypeFormat, integerClass, arg.Class().Name)
+	}
+
+	if n.Value < 1 {
+		return 0, vm.initErrorObject(ArgumentError, "Expect workers to be positive. got: %d", n.Value)
+	}
+
+	return n.Value, nil
+}
+
+// yieldBlock is like builtInMethodYield but returns the block's result directly.
+func (t *thread) yieldBlock(blockFrame *callFrame, args ...Object) Object {
+	p := t.builtInMethodYield(blockFrame, args...)
+
+	if p == nil {
+		return NULL
+	}
+
+	return p.Target
+}
+
+// parallelYield yields every element to the block on at most `workers` threads at once.
+// Results keep the elements' order. If any block fails, no more elements will be scheduled
+// and the error of the first failed element is returned.
+func (t *thread) parallelYield(blockFrame *callFrame, elems []Object, workers int) ([]Object, *Error) {
+	results := make([]Object, len(elems))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	var failed bool
+	var mutex sync.Mutex
+
+	t.detachBlockFrame()
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				mutex.Lock()
+				skip := failed
+				mutex.Unlock()
+
+				if skip {
+					continue
+				}
+
+				result := t.vm.newThread().yieldBlock(blockFrame, elems[i])
+				results[i] = result
+
+				if _, ok := result.(*Error); ok {
+					mutex.Lock()
+					failed = true
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := range elems {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	for _, result := range results {
+		if err, ok := result.(*Error); ok {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// Polymorphic helper functions -----------------------------------------
+
+// toString returns the pool's worker count.
+func (p *ThreadPoolObject) toString() string {
+	return fmt.Sprintf("<ThreadPool: %d workers>", p.size)
+}
+
+// toJSON converts the receiver into JSON string.
+func (p *ThreadPoolObject) toJSON() string {
+	return p.toString()
+}
+
+// toString returns the future's state.
+func (f *FutureObject) toString() string {
+	select {
+	case <-f.done:
+		return fmt.Sprintf("<Future: %s>", f.result.toString())
+	default:
+		return "<Future: pending>"
+	}
+}
+
+// toJSON converts the receiver into JSON string.
+func (f *FutureObject) toJSON() string {
+	return f.toString()
+}
@@ -0,0 +1,125 @@
+package vm
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestThreadPoolSubmit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`
+		pool = ThreadPool.new(2)
+		f = pool.submit(10) do |n|
+		  n * 2
+		end
+		f.value
+		`, 20},
+		{`
+		pool = ThreadPool.new(3)
+		futures = []
+
+		10.times do |i|
+		  futures.push(pool.submit(i) do |n|
+		    n + 1
+		  end)
+		end
+
+		pool.shutdown
+		pool.await
+
+		sum = 0
+		futures.each do |f|
+		  sum = sum + f.value
+		end
+		sum
+		`, 55},
+		{`
+		pool = ThreadPool.new(1)
+		f = pool.submit do
+		  "foo"
+		end
+		pool.await
+		f.is_done
+		`, true},
+		{`
+		pool = ThreadPool.new
+		pool.shutdown
+		pool.is_shutdown
+		`, true},
+		{`
+		ThreadPool.new({ workers: 5 }).size
+		`, 5},
+	}
+
+	for i, tt := range tests {
+		vm := initTestVM()
+		evaluated := vm.testEval(t, tt.input)
+		checkExpected(t, i, evaluated, tt.expected)
+		vm.checkCFP(t, i, 0)
+	}
+}
+
+func TestThreadPoolFail(t *testing.T) {
+	testsFail := []struct {
+		input    string
+		errType  string
+		expected string
+	}{
+		{`ThreadPool.new(0)`, ArgumentError, "ArgumentError: Expect workers to be positive. got: 0"},
+		{`ThreadPool.new("1")`, TypeError, "TypeError: Expect argument to be Integer. got: String"},
+		{`
+		pool = ThreadPool.new(1)
+		pool.shutdown
+		pool.submit do
+		  1
+		end
+		`, InternalError, "InternalError: Can't submit to a thread pool that is shut down"},
+		{`
+		pool = ThreadPool.new(1)
+		f = pool.submit do
+		  1.foo
+		end
+		f.value
+		`, UndefinedMethodError, "UndefinedMethodError: Undefined Method 'foo' for 1"},
+		{`
+		pool = ThreadPool.new(1)
+		f = pool.submit do
+		  pool.submit do
+		    1
+		  end
+		end
+		pool.shutdown
+		f.value
+		`, InternalError, "InternalError: Can't submit to a thread pool that is shut down"},
+	}
+
+	for i, tt := range testsFail {
+		v := initTestVM()
+		evaluated := v.testEval(t, tt.input)
+		checkError(t, i, evaluated, tt.errType, tt.expected)
+	}
+}
+
+func TestThreadPoolWithoutShutdown(t *testing.T) {
+	v := initTestVM()
+	before := runtime.NumGoroutine()
+	v.testEval(t, `
+	pool = ThreadPool.new(8)
+	pool.submit do
+	  1
+	end.value
+	`)
+
+	// Blocks' goroutines end with them, so pools that are never shut down don't leak
+	for i := 0; runtime.NumGoroutine() > before; i++ {
+		if i == 100 {
+			t.Fatalf("Expect %d goroutines. got: %d", before, runtime.NumGoroutine())
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,285 @@
+package vm
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const timerClass = "Timer"
+
+// TimerObject is a handle of a scheduled job, which can be stopped by calling `stop`.
+// Durations can be given as Integer (in sec) or String like "1.5s" and "300ms".
+//
+// ```ruby
+// Timer.after(1) do
+//   puts("1 sec later")
+// end
+//
+// t = Timer.every("500ms") do |i|
+//   puts(i)
+// end
+//
+// sleep(2)
+// t.stop
+// ```
+//
+// Timers created without a block deliver their ticks to a channel instead:
+//
+// ```ruby
+// t = Timer.every("100ms")
+// t.channel.receive # => 1
+// t.channel.receive # => 2
+// t.stop
+// ```
+type TimerObject struct {
+	*baseObj
+	duration  time.Duration
+	repeat    bool
+	channel   *ChannelObject
+	stop      chan struct{}
+	isStopped bool
+	sync.Mutex
+}
+
+func (vm *VM) initTimerClass() *RClass {
+	class := vm.initializeClass(timerClass, false)
+	class.setBuiltInMethods(builtinTimerClassMethods(), true)
+	class.setBuiltInMethods(builtinTimerInstanceMethods(), false)
+	return class
+}
+
+func (vm *VM) initTimerObject(d time.Duration, repeat bool) *TimerObject {
+	return &TimerObject{
+		baseObj:  &baseObj{class: vm.topLevelClass(timerClass)},
+		duration: d,
+		repeat:   repeat,
+		channel:  vm.initChannelObject(),
+		stop:     make(chan struct{}),
+	}
+}
+
+func builtinTimerClassMethods() []*BuiltInMethodObject {
+	return []*BuiltInMethodObject{
+		{
+			// Runs the block on a new thread once after given duration.
+			// Without a block, the timer's channel receives `1` instead.
+			//
+			// ```ruby
+			// Timer.after("1.5s") do
+			//   puts("Hello")
+			// end
+			// ```
+			//
+			// @param duration [Integer] in sec, or [String] like "300ms"
+			// @return [Timer]
+			Name: "after",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return t.startTimer(args, blockFrame, false)
+				}
+			},
+		},
+		{
+			// Runs the block on a new thread every given duration, passing the tick count starting from 1.
+			// Without a block, the tick count is delivered to the timer's channel instead.
+			//
+			// ```ruby
+			// t = Timer.every(1) do |i|
+			//   puts(i)
+			// end
+			// ```
+			//
+			// @param duration [Integer] in sec, or [String] like "300ms"
+			// @return [Timer]
+			Name: "every",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return t.startTimer(args, blockFrame, true)
+				}
+			},
+		},
+		{
+			Name: "new",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return t.UnsupportedMethodError("#new", receiver)
+				}
+			},
+		},
+	}
+}
+
+func builtinTimerInstanceMethods() []*BuiltInMethodObject {
+	return []*BuiltInMethodObject{
+		{
+			// Returns the channel that receives ticks of a timer created without a block.
+			// The channel is closed once the timer is stopped.
+			//
+			// @return [Channel]
+			Name: "channel",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					return receiver.(*TimerObject).channel
+				}
+			},
+		},
+		{
+			// Returns true if the timer is stopped or has finished.
+			//
+			// @return [Boolean]
+			Name: "is_stopped",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					tm := receiver.(*TimerObject)
+					tm.Lock()
+					defer tm.Unlock()
+
+					if tm.isStopped {
+						return TRUE
+					}
+
+					return FALSE
+				}
+			},
+		},
+		{
+			// Stops the timer. Returns false if the timer was already stopped or has finished.
+			//
+			// @return [Boolean]
+			Name: "stop",
+			Fn: func(receiver Object) builtinMethodBody {
+				return func(t *thread, args []Object, blockFrame *callFrame) Object {
+					if receiver.(*TimerObject).halt() {
+						return TRUE
+					}
+
+					return FALSE
+				}
+			},
+		},
+	}
+}
+
+// startTimer creates a timer with given duration and starts it on a new goroutine.
+func (t *thread) startTimer(args []Object, blockFrame *callFrame, repeat bool) Object {
+	if len(args) != 1 {
+		return t.vm.initErrorObject(ArgumentError, "Expect 1 argument. got: %d", len(args))
+	}
+
+	d, err := t.vm.toDuration(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	if repeat && d <= 0 {
+		return t.vm.initErrorObject(ArgumentError, "Expect duration to be positive. got: %s", d)
+	}
+
+	tm := t.vm.initTimerObject(d, repeat)
+
+	if blockFrame != nil {
+		t.detachBlockFrame()
+	}
+
+	go tm.run(func(count int) {
+		c := t.vm.initIntegerObject(count)
+
+		if blockFrame == nil {
+			id := t.vm.channelObjectMap.storeObj(c)
+
+			select {
+			case tm.channel.Chan <- id:
+			case <-tm.stop:
+				t.vm.channelObjectMap.retrieveObj(id)
+			}
+
+			return
+		}
+
+		t.vm.newThread().yieldBlock(blockFrame, c)
+	})
+
+	return tm
+}
+
+func (tm *TimerObject) run(tick func(int)) {
+	// Only this goroutine delivers ticks, so it's the one that closes the channel.
+	defer close(tm.channel.Chan)
+
+	if !tm.repeat {
+		timer := time.NewTimer(tm.duration)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			tick(1)
+			tm.halt()
+		case <-tm.stop:
+		}
+
+		return
+	}
+
+	ticker := time.NewTicker(tm.duration)
+	defer ticker.Stop()
+
+	for count := 1; ; count++ {
+		select {
+		case <-ticker.C:
+			tick(count)
+		case <-tm.stop:
+			return
+		}
+	}
+}
+
+// halt stops the timer, returns false if it's already stopped.
+func (tm *TimerObject) halt() bool {
+	tm.Lock()
+	defer tm.Unlock()
+
+	if tm.isStopped {
+		return false
+	}
+
+	tm.isStopped = true
+	close(tm.stop)
+
+	return true
+}
+
+// toDuration converts an Integer (in sec) or a String like "1.5s" into time.Duration
+func (vm *VM) toDuration(obj Object) (time.Duration, *Error) {
+	switch d := obj.(type) {
+	case *IntegerObject:
+		return time.Duration(d.Value) * time.Second, nil
+	case *StringObject:
+		duration, err := time.ParseDuration(d.Value)
+
+		if err != nil {
+			return 0, vm.initErrorObject(ArgumentError, "Invalid duration: %s", d.Value)
+		}
+
+		return duration, nil
+	default:
+		return 0, vm.initErrorObject(TypeError, WrongArgumentTypeFormat, "Integer or String", obj.Class().Name)
+	}
+}
+
+// Polymorphic helper functions -----------------------------------------
+
+// toString returns the timer's duration.
+func (tm *TimerObject) toString() string {
+	if tm.repeat {
+		return fmt.Sprintf("<Timer: every %s>", tm.duration)
+	}
+
+	return fmt.Sprintf("<Timer: after %s>", tm.duration)
+}
+
+// toJSON converts the receiver into JSON string.
+func (tm *TimerObject) toJSON() string {
+	return tm.toString()
+}
@@ -0,0 +1,113 @@
+package vm
+
+import "testing"
+
+func TestTimerMethods(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`
+		c = Channel.new
+
+		Timer.after("10ms") do
+		  c.deliver("done")
+		end
+
+		c.receive
+		`, "done"},
+		{`
+		c = Channel.new
+
+		t = Timer.every("5ms") do |i|
+		  c.deliver(i)
+		end
+
+		sum = 0
+		3.times do
+		  sum = sum + c.receive
+		end
+		t.stop
+		sum
+		`, 6},
+		{`
+		t = Timer.every("5ms")
+		a = t.channel.receive
+		b = t.channel.receive
+		t.stop
+		a + b
+		`, 3},
+		{`
+		t = Timer.after("10ms")
+		t.channel.receive
+		`, 1},
+		{`
+		t = Timer.every("5ms")
+		t.stop
+		t.channel.receive
+		`, nil},
+		{`
+		t = Timer.after(100)
+		t.stop
+		`, true},
+		{`
+		t = Timer.after(100)
+		t.stop
+		t.stop
+		`, false},
+		{`
+		t = Timer.after(100)
+		t.stop
+		t.is_stopped
+		`, true},
+		{`
+		t = Timer.after("5ms")
+		t.channel.receive
+		t.channel.receive
+		t.is_stopped
+		`, true},
+	}
+
+	for i, tt := range tests {
+		vm := initTestVM()
+		evaluated := vm.testEval(t, tt.input)
+		checkExpected(t, i, evaluated, tt.expected)
+		vm.checkCFP(t, i, 0)
+	}
+}
+
+func TestTimerMethodsFail(t *testing.T) {
+	testsFail := []struct {
+		input    string
+		errType  string
+		expected string
+	}{
+		{`Timer.every(0)`, ArgumentError, "ArgumentError: Expect duration to be positive. got: 0s"},
+		{`Timer.after("foo")`, ArgumentError, "ArgumentError: Invalid duration: foo"},
+		{`Timer.after(true)`, TypeError, "TypeError: Expect argument to be Integer or String. got: Boolean"},
+		{`Timer.new`, UnsupportedMethodError, "UnsupportedMethodError: Unsupported Method #new for Timer"},
+	}
+
+	for i, tt := range testsFail {
+		v := initTestVM()
+		evaluated := v.testEval(t, tt.input)
+		checkError(t, i, evaluated, tt.errType, tt.expected)
+	}
+}
+
+func TestSleepMethod(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`sleep(0)`, 0},
+		{`sleep("1.5ms")`, "1.5ms"},
+	}
+
+	for i, tt := range tests {
+		vm := initTestVM()
+		evaluated := vm.testEval(t, tt.input)
+		checkExpected(t, i, evaluated, tt.expected)
+		vm.checkCFP(t, i, 0)
+	}
+}
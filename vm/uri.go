@@ -40,7 +40,7 @@ func builtinURIClassMethods() []*BuiltInMethodObject {
 			// u = URI.parse("https://example.com")
 			// u.scheme # => "https"
 			// u.host # => "example.com"
-			// u.port # => 80
+			// u.port # => 443
 			// u.path # => "/"
 			// ```
 			Name: "parse",
@@ -7,46 +7,46 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 )
 
 // Version stores current Goby version
 const Version = "0.0.9"
 
-type isIndexTable struct {
-	Data map[string]int
-}
-
-func newISIndexTable() *isIndexTable {
-	return &isIndexTable{Data: make(map[string]int)}
-}
-
-type isTable map[string][]*instructionSet
+// DefaultMaxCallDepth is the default number of call frames a thread can hold before it raises StackOverflowError
+const DefaultMaxCallDepth = 10000
 
 type filename string
 
 type errorMessage string
 
 var standardLibraries = map[string]func(*VM){
+	"actor":             initActorClass,
 	"file":              initFileClass,
 	"net/http":          initHTTPClass,
 	"net/simple_server": initSimpleServerClass,
+	"test":              initTestLibrary,
 	"uri":               initURIClass,
 }
 
+// StandardLibraries returns names of libraries that `require` loads without files, like "uri", sorted
+func StandardLibraries() []string {
+	names := []string{}
+
+	for name := range standardLibraries {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
 // VM represents a stack based virtual machine.
 type VM struct {
 	mainObj     *RObject
 	mainThread  *thread
 	objectClass *RClass
-	// a map holds different types of instruction set tables
-	isTables map[setType]isTable
-	// method instruction set table
-	methodISIndexTables map[filename]*isIndexTable
-	// class instruction set table
-	classISIndexTables map[filename]*isIndexTable
-	// block instruction set table
-	blockTables map[filename]map[string]*instructionSet
 	// fileDir indicates executed file's directory
 	fileDir string
 	// args are command line arguments
@@ -56,28 +56,63 @@ type VM struct {
 
 	stackTraceCount int
 
+	// maxCallDepth is copied to every thread the vm creates, see WithMaxCallDepth
+	maxCallDepth int
+	// tailCalls enables tail call optimization, see WithTailCallOptimization
+	tailCalls bool
+	// sandbox restricts scripts, it's nil unless WithSandbox is given
+	sandbox *sandbox
+	// debugger stops the main thread, it's nil unless WithDebugger is given
+	debugger *Debugger
+	// profiler samples the vm's threads, it's nil unless WithProfiler is given
+	profiler *Profiler
+	// coverage records evaluated lines and branches, it's nil unless WithCoverage is given
+	coverage *Coverage
+	// tests are examples of the test library, it's nil unless the library is required
+	tests *testSuite
+
+	// methodSerial changes whenever methods are defined, which outdates all inline method caches
+	methodSerial uint64
+	// methodCacheHits and methodCacheMisses count inline method cache lookups, see MethodCacheStats
+	methodCacheHits   uint64
+	methodCacheMisses uint64
+
 	channelObjectMap *objectMap
 
 	sync.Mutex
 }
 
-// New initializes a vm to initialize state and returns it.
-func New(fileDir string, args []string) *VM {
-	vm := &VM{args: args}
-	vm.mainThread = vm.newThread()
+// Option configures a vm when it's initialized by New.
+type Option func(*VM)
 
-	vm.initConstants()
-	vm.methodISIndexTables = map[filename]*isIndexTable{
-		filename(fileDir): newISIndexTable(),
+// WithMaxCallDepth sets how many call frames a thread can hold before it raises StackOverflowError.
+// 0 means there's no limit.
+func WithMaxCallDepth(n int) Option {
+	return func(vm *VM) {
+		vm.maxCallDepth = n
 	}
-	vm.classISIndexTables = map[filename]*isIndexTable{
-		filename(fileDir): newISIndexTable(),
+}
+
+// WithTailCallOptimization turns tail call optimization on or off, it's on by default.
+// Methods called in tail positions are evaluated in their callers' frames, so they don't show up
+// in backtraces. Turning it off keeps every call's frame.
+func WithTailCallOptimization(enabled bool) Option {
+	return func(vm *VM) {
+		vm.tailCalls = enabled
 	}
-	vm.blockTables = make(map[filename]map[string]*instructionSet)
-	vm.isTables = map[setType]isTable{
-		bytecode.MethodDef: make(isTable),
-		bytecode.ClassDef:  make(isTable),
+}
+
+// New initializes a vm to initialize state and returns it.
+func New(fileDir string, args []string, options ...Option) *VM {
+	vm := &VM{args: args, maxCallDepth: DefaultMaxCallDepth, tailCalls: true}
+
+	for _, option := range options {
+		option(vm)
 	}
+
+	vm.mainThread = vm.newThread()
+
+	vm.initConstants()
 	vm.fileDir = fileDir
 
 	gobyRoot := os.Getenv("GOBY_ROOT")
@@ -91,52 +126,65 @@ func New(fileDir string, args []string) *VM {
 	vm.mainObj = vm.initMainObj()
 	vm.channelObjectMap = &objectMap{store: map[int]Object{}}
 
+	if vm.sandbox != nil {
+		vm.sandbox.start()
+		vm.mainThread.ctx = vm.sandbox.ctx
+	}
+
+	if vm.profiler != nil {
+		vm.profiler.begin()
+	}
+
 	return vm
 }
 
 func (vm *VM) newThread() *thread {
 	s := &stack{}
 	cfs := &callFrameStack{callFrames: []*callFrame{}}
-	t := &thread{stack: s, callFrameStack: cfs, sp: 0, cfp: 0}
+	t := &thread{stack: s, callFrameStack: cfs, sp: 0, cfp: 0, maxCallDepth: vm.maxCallDepth}
 	s.thread = t
 	cfs.thread = t
 	t.vm = vm
+
+	if vm.sandbox != nil {
+		t.ctx = vm.sandbox.ctx
+	}
+
 	return t
 }
 
 // ExecInstructions accepts a sequence of bytecodes and use vm to evaluate them.
+// Sets rejected by bytecode.Verify aren't evaluated, and leave a BytecodeError instead.
 func (vm *VM) ExecInstructions(sets []*bytecode.InstructionSet, fn string) {
+	if !vm.verifyInstructions(sets) {
+		return
+	}
+
 	filename := filename(fn)
 	p := newInstructionTranslator(filename)
 	p.vm = vm
-	p.transferInstructionSets(sets)
+	iss := p.transferInstructionSets(sets)
 
-	// Keep instruction set table updated after parsed new files.
-	// TODO: Find more efficient way to do this.
-	for setType, table := range p.setTable {
-		for name, is := range table {
-			vm.isTables[setType][name] = is
-		}
+	if vm.coverage != nil {
+		vm.coverage.register(filename, iss)
 	}
 
-	vm.blockTables[p.filename] = p.blockTable
-	vm.SetClassISIndexTable(p.filename)
-	vm.SetMethodISIndexTable(p.filename)
-
 	cf := newCallFrame(p.program)
 	cf.self = vm.mainObj
 	vm.mainThread.callFrameStack.push(cf)
 	vm.startFromTopFrame()
 }
 
-// SetClassISIndexTable adds new instruction set's index table to vm.classISIndexTables
-func (vm *VM) SetClassISIndexTable(fn filename) {
-	vm.classISIndexTables[fn] = newISIndexTable()
-}
+// verifyInstructions reports whether the sets pass bytecode.Verify, otherwise it reports the error
+func (vm *VM) verifyInstructions(sets []*bytecode.InstructionSet) bool {
+	if err := bytecode.Verify(sets); err != nil {
+		e := vm.initErrorObject(BytecodeError, "%s", err.Error())
+		vm.mainThread.stack.push(&Pointer{Target: e})
+		vm.mainThread.reportError(e)
+		return false
+	}
 
-// SetMethodISIndexTable adds new instruction set's index table to vm.methodISIndexTables
-func (vm *VM) SetMethodISIndexTable(fn filename) {
-	vm.methodISIndexTables[fn] = newISIndexTable()
+	return true
 }
 
 func (vm *VM) initMainObj() *RObject {
@@ -165,6 +213,12 @@ func (vm *VM) initConstants() {
 		vm.initChannelClass(),
 		vm.initPluginClass(),
 		vm.initStructClass(),
+		vm.initThreadPoolClass(),
+		vm.initFutureClass(),
+		vm.initContextClass(),
+		vm.initTimerClass(),
+		vm.initFiberClass(),
+		vm.initEnumeratorClass(),
 	}
 
 	vm.initErrorClasses()
@@ -192,53 +246,78 @@ func (vm *VM) topLevelClass(cn string) *RClass {
 	return objClass.constants[cn].Target.(*RClass)
 }
 
-// Start evaluation from top most call frame
-func (vm *VM) startFromTopFrame() {
-	vm.mainThread.startFromTopFrame()
-}
-
-func (vm *VM) currentFilePath() string {
-	return string(vm.mainThread.callFrameStack.top().instructionSet.filename)
-}
-
-func (vm *VM) getBlock(name string, filename filename) *instructionSet {
-	// The "name" here is actually an index of block
-	// for example <Block:1>'s name is "1"
-	is, ok := vm.blockTables[filename][name]
+// HasBuiltInMethod checks if the built-in class's instances have the method, or the class has it if classMethod is true.
+// ok is false if there's no such built-in class. Tools like `goby lint` use it to check method calls without running them.
+func (vm *VM) HasBuiltInMethod(className, methodName string, classMethod bool) (has, ok bool) {
+	class, ok := vm.builtInClass(className)
 
 	if !ok {
-		panic(fmt.Sprintf("Can't find block %s", name))
+		return false, false
 	}
 
-	return is
+	if classMethod {
+		return class.findMethod(methodName) != nil, true
+	}
+
+	return class.lookupMethod(methodName) != nil, true
 }
 
-func (vm *VM) getMethodIS(name string, filename filename) (*instructionSet, bool) {
-	iss, ok := vm.isTables[bytecode.MethodDef][name]
+// BuiltInMethods returns sorted names of the built-in class's instance methods, or its class methods if classMethod is true,
+// including inherited ones. ok is false if there's no such built-in class. `goby lsp` completes method names with it.
+func (vm *VM) BuiltInMethods(className string, classMethod bool) (names []string, ok bool) {
+	class, ok := vm.builtInClass(className)
 
 	if !ok {
 		return nil, false
 	}
 
-	is := iss[vm.methodISIndexTables[filename].Data[name]]
+	if classMethod {
+		class = class.SingletonClass()
+	}
 
-	vm.methodISIndexTables[filename].Data[name]++
+	found := map[string]bool{}
 
-	return is, ok
+	// This walks the same classes as lookupMethod does
+	for c := class; c != nil; c = c.superClass {
+		for _, name := range c.Methods.names() {
+			if !found[name] {
+				found[name] = true
+				names = append(names, name)
+			}
+		}
+
+		if c.superClass == c || c.Name == classClass {
+			break
+		}
+	}
+
+	sort.Strings(names)
+	return names, true
 }
 
-func (vm *VM) getClassIS(name string, filename filename) *instructionSet {
-	iss, ok := vm.isTables[bytecode.ClassDef][name]
+func (vm *VM) builtInClass(className string) (*RClass, bool) {
+	// Object isn't its own constant
+	if className == objectClass {
+		return vm.objectClass, true
+	}
+
+	p, ok := vm.objectClass.constants[className]
 
 	if !ok {
-		panic(fmt.Sprintf("Can't find class %s's instructions", name))
+		return nil, false
 	}
 
-	is := iss[vm.classISIndexTables[filename].Data[name]]
+	class, ok := p.Target.(*RClass)
+	return class, ok
+}
 
-	vm.classISIndexTables[filename].Data[name]++
+// Start evaluation from top most call frame
+func (vm *VM) startFromTopFrame() {
+	vm.mainThread.startFromTopFrame()
+}
 
-	return is
+func (vm *VM) currentFilePath() string {
+	return string(vm.mainThread.callFrameStack.top().instructionSet.filename)
 }
 
 // loadConstant makes sure we don't create a class twice.
@@ -258,11 +337,12 @@ func (vm *VM) loadConstant(name string, isModule bool) *RClass {
 	return c
 }
 
-func (vm *VM) lookupConstant(cf *callFrame, constName string) (constant *Pointer) {
+func (vm *VM) lookupConstant(t *thread, cf *callFrame, constName string) (constant *Pointer) {
 	var namespace *RClass
 	var hasNamespace bool
 
-	top := vm.mainThread.stack.top()
+	// Namespaces like `Foo` in `Foo::Bar` are on the stack of the thread that looks the constant up
+	top := t.stack.top()
 
 	if top == nil {
 		hasNamespace = false
@@ -303,32 +383,14 @@ func (vm *VM) execGobyLib(libName string) {
 }
 
 func (vm *VM) execRequiredFile(filepath string, file []byte) {
-	instructionSets, err := compiler.CompileToInstructions(string(file))
+	instructionSets, err := compiler.CompileFileToInstructions(filepath, string(file))
 
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
 
-	oldMethodTable := isTable{}
-	oldClassTable := isTable{}
-
-	// Copy current file's instruction sets.
-	for name, is := range vm.isTables[bytecode.MethodDef] {
-		oldMethodTable[name] = is
-	}
-
-	for name, is := range vm.isTables[bytecode.ClassDef] {
-		oldClassTable[name] = is
-	}
-
-	// This creates new execution environments for required file, including new instruction set table.
-	// So we need to copy old instruction sets and restore them later, otherwise current program's instruction set would be overwrite.
 	vm.ExecInstructions(instructionSets, filepath)
-
-	// Restore instruction sets.
-	vm.isTables[bytecode.MethodDef] = oldMethodTable
-	vm.isTables[bytecode.ClassDef] = oldClassTable
 }
 
 func newError(format string, args ...interface{}) *Error {
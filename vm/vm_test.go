@@ -5,6 +5,7 @@ import (
 	"github.com/goby-lang/goby/compiler/bytecode"
 	"github.com/goby-lang/goby/compiler/lexer"
 	"github.com/goby-lang/goby/compiler/parser"
+	"sort"
 	"testing"
 )
 
@@ -97,6 +98,25 @@ end
 foo
 `,
 			}, 345},
+		{
+			[]string{
+				`
+def define_foo
+  def foo
+    1
+  end
+end
+`,
+				`
+define_foo
+`,
+				`
+define_foo
+`,
+				`
+foo
+`,
+			}, 1},
 	}
 
 	for i, test := range tests {
@@ -120,6 +140,7 @@ foo
 			sets := g.GenerateInstructions(program.Statements)
 
 			v.REPLExec(sets)
+			g.ResetInstructionSets()
 		}
 
 		evaluated := v.GetExecResult()
@@ -129,6 +150,86 @@ foo
 	}
 }
 
+func TestVM_HasBuiltInMethod(t *testing.T) {
+	tests := []struct {
+		className   string
+		methodName  string
+		classMethod bool
+		has         bool
+		ok          bool
+	}{
+		{"Integer", "to_s", false, true, true},
+		{"Integer", "times", false, true, true},
+		{"String", "lenght", false, false, true},
+		{"String", "puts", false, true, true},
+		{"Array", "new", true, true, true},
+		{"Channel", "new", true, true, true},
+		{"Channel", "recieve", false, false, true},
+		{"Array", "push", true, false, true},
+		{"Object", "puts", false, true, true},
+		{"Foo", "bar", false, false, false},
+	}
+
+	v := initTestVM()
+
+	for i, tt := range tests {
+		has, ok := v.HasBuiltInMethod(tt.className, tt.methodName, tt.classMethod)
+
+		if has != tt.has || ok != tt.ok {
+			t.Fatalf("At case %d expect %t, %t. got: %t, %t", i, tt.has, tt.ok, has, ok)
+		}
+	}
+}
+
+func TestVM_BuiltInMethods(t *testing.T) {
+	tests := []struct {
+		className   string
+		classMethod bool
+		included    []string
+		excluded    []string
+		ok          bool
+	}{
+		{"Integer", false, []string{"+", "times", "to_s", "puts"}, []string{"push"}, true},
+		{"Integer", true, []string{"new", "puts"}, []string{"times"}, true},
+		{"Channel", true, []string{"new"}, []string{"receive"}, true},
+		{"Object", false, []string{"puts", "to_s"}, []string{"times"}, true},
+		{"Object", true, []string{"new", "attr_reader"}, []string{"times"}, true},
+		{"Foo", false, nil, nil, false},
+	}
+
+	v := initTestVM()
+
+	for i, tt := range tests {
+		names, ok := v.BuiltInMethods(tt.className, tt.classMethod)
+
+		if ok != tt.ok {
+			t.Fatalf("At case %d expect ok to be %t. got: %t", i, tt.ok, ok)
+		}
+
+		if !sort.StringsAreSorted(names) {
+			t.Fatalf("At case %d expect sorted names. got: %v", i, names)
+		}
+
+		has := map[string]bool{}
+
+		for _, name := range names {
+			has[name] = true
+		}
+
+		for _, name := range tt.included {
+			if !has[name] {
+				t.Fatalf("At case %d expect %s to be included. got: %v", i, name, names)
+			}
+		}
+
+		for _, name := range tt.excluded {
+			if has[name] {
+				t.Fatalf("At case %d expect %s to be excluded. got: %v", i, name, names)
+			}
+		}
+	}
+}
+
 func initTestVM() *VM {
 	return New("./", []string{})
 }